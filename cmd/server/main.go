@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"go-postgres-example/pkg/handlers"
+	"go-postgres-example/pkg/jukebox"
+	"go-postgres-example/pkg/middleware"
+	"go-postgres-example/pkg/playqueue"
 	"go-postgres-example/pkg/router"
+	"go-postgres-example/pkg/scrobbler"
 	"go-postgres-example/pkg/subsonic"
 	"log"
 	"net/http"
+	"time"
 
 	"go-postgres-example/pkg/auth"
 	"go-postgres-example/pkg/config"
@@ -20,6 +26,9 @@ func main() {
 	if cfg.DatabaseURL == "" {
 		log.Fatal("DATABASE_URL is not set")
 	}
+	if cfg.Notifier == "smtp" && cfg.PublicURL == "" {
+		log.Fatal("NOTIFIER is smtp but PUBLIC_URL, needed for password reset links, is not set")
+	}
 
 	// Connect to the database
 	conn, err := db.NewConnection(cfg.DatabaseURL)
@@ -46,6 +55,66 @@ func main() {
 	if err = db.Migrate(conn, "db/migrations/0003_add_is_admin.sql"); err != nil {
 		log.Fatalf("Failed to run migration 0003: %v", err)
 	}
+	if err = db.Migrate(conn, "db/migrations/0005_smart_playlists.sql"); err != nil {
+		log.Fatalf("Failed to run migration 0005: %v", err)
+	}
+	if err = db.Migrate(conn, "db/migrations/0006_playlist_import.sql"); err != nil {
+		log.Fatalf("Failed to run migration 0006: %v", err)
+	}
+	if err = db.Migrate(conn, "db/migrations/0007_playlist_entries.sql"); err != nil {
+		log.Fatalf("Failed to run migration 0007: %v", err)
+	}
+	if err = db.Migrate(conn, "db/migrations/0008_play_history.sql"); err != nil {
+		log.Fatalf("Failed to run migration 0008: %v", err)
+	}
+	if err = db.Migrate(conn, "db/migrations/0009_scrobble_forwarding.sql"); err != nil {
+		log.Fatalf("Failed to run migration 0009: %v", err)
+	}
+	if err = db.Migrate(conn, "db/migrations/0010_favorites.sql"); err != nil {
+		log.Fatalf("Failed to run migration 0010: %v", err)
+	}
+	if err = db.Migrate(conn, "db/migrations/0011_play_queue.sql"); err != nil {
+		log.Fatalf("Failed to run migration 0011: %v", err)
+	}
+	if err = db.Migrate(conn, "db/migrations/0012_jukebox_role.sql"); err != nil {
+		log.Fatalf("Failed to run migration 0012: %v", err)
+	}
+	if err = db.Migrate(conn, "db/migrations/0013_shares.sql"); err != nil {
+		log.Fatalf("Failed to run migration 0013: %v", err)
+	}
+	if err = db.Migrate(conn, "db/migrations/0014_playlist_collaboration.sql"); err != nil {
+		log.Fatalf("Failed to run migration 0014: %v", err)
+	}
+	if err = db.Migrate(conn, "db/migrations/0015_download_role.sql"); err != nil {
+		log.Fatalf("Failed to run migration 0015: %v", err)
+	}
+	if err = db.Migrate(conn, "db/migrations/0016_sessions.sql"); err != nil {
+		log.Fatalf("Failed to run migration 0016: %v", err)
+	}
+	if err = db.Migrate(conn, "db/migrations/0017_oidc.sql"); err != nil {
+		log.Fatalf("Failed to run migration 0017: %v", err)
+	}
+	if err = db.Migrate(conn, "db/migrations/0018_login_failures.sql"); err != nil {
+		log.Fatalf("Failed to run migration 0018: %v", err)
+	}
+	if err = db.Migrate(conn, "db/migrations/0019_two_factor.sql"); err != nil {
+		log.Fatalf("Failed to run migration 0019: %v", err)
+	}
+	if err = db.Migrate(conn, "db/migrations/0020_user_disabled.sql"); err != nil {
+		log.Fatalf("Failed to run migration 0020: %v", err)
+	}
+	if err = db.Migrate(conn, "db/migrations/0021_roles.sql"); err != nil {
+		log.Fatalf("Failed to run migration 0021: %v", err)
+	}
+	if err = db.Migrate(conn, "db/migrations/0022_account.sql"); err != nil {
+		log.Fatalf("Failed to run migration 0022: %v", err)
+	}
+	if err = db.Migrate(conn, "db/migrations/0023_quotas.sql"); err != nil {
+		log.Fatalf("Failed to run migration 0023: %v", err)
+	}
+	if err = db.Migrate(conn, "db/migrations/0024_album_covers.sql"); err != nil {
+		log.Fatalf("Failed to run migration 0024: %v", err)
+	}
 
 	// Ensure an admin user exists on first deployment
 	if err := ensureAdminUser(conn, cfg); err != nil {
@@ -58,10 +127,57 @@ func main() {
 	libraryHandler := handlers.NewLibraryHandler(conn, cfg)
 	playlistHandler := handlers.NewPlaylistHandler(conn, cfg)
 	songHandler := handlers.NewSongHandler(conn, cfg)
+	shareHandler := handlers.NewShareHandler(conn, cfg)
 	subsonicHandler := subsonic.NewHandler(conn, cfg)
 
+	// Forward plays to linked ListenBrainz-compatible accounts in the background
+	forwarder := scrobbler.NewForwarder(conn, cfg)
+	libraryHandler.Scrobbler = forwarder
+	subsonicHandler.Scrobbler = forwarder
+	go forwarder.Run(context.Background())
+
+	// Delete abandoned resumable uploads
+	go uploadHandler.Tus.Store.Run(context.Background(), time.Hour)
+
+	// Share play queue changes between REST and Subsonic clients
+	queueEvents := playqueue.NewBroker()
+	libraryHandler.QueueEvents = queueEvents
+	subsonicHandler.QueueEvents = queueEvents
+
+	// Play through the server's speakers when a jukebox player is configured
+	if cfg.JukeboxMPVPath != "" {
+		player, err := jukebox.StartMPV(cfg.JukeboxMPVPath, cfg.JukeboxSocket)
+		if err != nil {
+			log.Printf("Jukebox disabled: %v", err)
+		} else {
+			defer player.Close()
+			subsonicHandler.Jukebox = jukebox.New(player)
+		}
+	}
+
+	// Take client addresses from X-Forwarded-For behind trusted proxies
+	trusted, err := middleware.ParseCIDRs(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	authHandler.TrustedProxies = trusted
+	shareHandler.TrustedProxies = trusted
+
+	// Trust the username header of a reverse proxy that signs users in
+	if cfg.ProxyAuthHeader != "" {
+		if len(trusted) == 0 {
+			log.Fatal("PROXY_AUTH_HEADER is set but TRUSTED_PROXIES is empty")
+		}
+		authHandler.HeaderAuth = middleware.HeaderAuth{
+			Header:         cfg.ProxyAuthHeader,
+			EmailHeader:    cfg.ProxyAuthEmailHeader,
+			TrustedProxies: trusted,
+			AutoCreate:     cfg.ProxyAuthAutoCreate,
+		}
+	}
+
 	// Initialize router
-	r := router.New(authHandler, uploadHandler, libraryHandler, playlistHandler, songHandler, subsonicHandler)
+	r := router.New(authHandler, uploadHandler, libraryHandler, playlistHandler, songHandler, shareHandler, subsonicHandler)
 
 	// Start server
 	log.Printf("Server starting on port %s", cfg.Port)
@@ -91,7 +207,7 @@ func ensureAdminUser(conn *sql.DB, cfg *config.Config) error {
 	}
 
 	// Insert admin user
-	_, err = conn.Exec("INSERT INTO users (username, email, password_hash, is_admin) VALUES ($1, $2, $3, TRUE)", cfg.AdminUsername, cfg.AdminEmail, hashed)
+	_, err = conn.Exec("INSERT INTO users (username, email, password_hash, is_admin, role) VALUES ($1, $2, $3, TRUE, 'admin')", cfg.AdminUsername, cfg.AdminEmail, hashed)
 	if err != nil {
 		return fmt.Errorf("failed inserting admin user: %w", err)
 	}
@@ -0,0 +1,14 @@
+-- Smart playlists store a JSON rule tree that is compiled to SQL and evaluated on read.
+-- Static playlists keep rules NULL and use playlist_songs as before.
+ALTER TABLE playlists
+ADD COLUMN IF NOT EXISTS rules JSONB;
+
+-- Play history, used by smart playlist play count rules
+CREATE TABLE IF NOT EXISTS plays (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    song_id INTEGER NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+    played_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_plays_user_song ON plays (user_id, song_id);
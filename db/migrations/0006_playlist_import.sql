@@ -0,0 +1,8 @@
+-- Imported playlists remember where they came from so re-importing the same
+-- file updates the existing playlist instead of creating a duplicate.
+ALTER TABLE playlists
+ADD COLUMN IF NOT EXISTS import_key TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_playlists_user_import_key
+ON playlists (user_id, import_key)
+WHERE import_key IS NOT NULL;
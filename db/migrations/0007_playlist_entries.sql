@@ -0,0 +1,18 @@
+-- Give playlist entries their own IDs so a song can appear more than once,
+-- and add a version to playlists for optimistic concurrency control.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'playlist_songs' AND column_name = 'id'
+    ) THEN
+        ALTER TABLE playlist_songs DROP CONSTRAINT IF EXISTS playlist_songs_pkey;
+        ALTER TABLE playlist_songs ADD COLUMN id SERIAL PRIMARY KEY;
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_playlist_songs_playlist_position
+ON playlist_songs (playlist_id, position);
+
+ALTER TABLE playlists
+ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,23 @@
+-- Scrobbles: each row in plays is one completed listen, with how long the
+-- song was listened to. Smart playlist play count rules read it too.
+CREATE TABLE IF NOT EXISTS plays (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    song_id INTEGER NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+    played_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    duration_listened INTEGER
+);
+
+-- 0005_smart_playlists.sql creates plays first, without this column
+ALTER TABLE plays
+ADD COLUMN IF NOT EXISTS duration_listened INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_plays_user_song ON plays (user_id, song_id);
+CREATE INDEX IF NOT EXISTS idx_plays_user_played_at ON plays (user_id, played_at DESC);
+
+-- What each user is listening to right now, replaced on every "now playing" scrobble
+CREATE TABLE IF NOT EXISTS now_playing (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    song_id INTEGER NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,29 @@
+-- Accounts on ListenBrainz-compatible services (ListenBrainz, Maloja, Koito, ...)
+-- that a user's plays are forwarded to. Tokens are encrypted by the server.
+CREATE TABLE IF NOT EXISTS scrobble_accounts (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    service VARCHAR(50) NOT NULL DEFAULT 'listenbrainz',
+    api_url TEXT NOT NULL,
+    username VARCHAR(255) NOT NULL DEFAULT '',
+    token_encrypted TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, api_url)
+);
+
+-- Outbound listens and now playing notifications waiting to be submitted.
+-- Rows stay here while a service is unreachable and are retried with backoff.
+CREATE TABLE IF NOT EXISTS scrobble_queue (
+    id SERIAL PRIMARY KEY,
+    account_id INTEGER NOT NULL REFERENCES scrobble_accounts(id) ON DELETE CASCADE,
+    song_id INTEGER NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+    listen_type VARCHAR(20) NOT NULL,
+    listened_at TIMESTAMPTZ NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_scrobble_queue_next_attempt ON scrobble_queue (next_attempt_at);
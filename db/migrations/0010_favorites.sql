@@ -0,0 +1,64 @@
+-- Artist and album entities. getIndexes and search already read these tables;
+-- they are backfilled from the free-text tags on songs and kept in sync on upload.
+CREATE TABLE IF NOT EXISTS artists (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_artists_name ON artists (name);
+
+CREATE TABLE IF NOT EXISTS albums (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    artist VARCHAR(255) NOT NULL DEFAULT ''
+);
+ALTER TABLE albums ADD COLUMN IF NOT EXISTS artist_id INTEGER REFERENCES artists(id) ON DELETE SET NULL;
+ALTER TABLE albums ADD COLUMN IF NOT EXISTS year INTEGER;
+ALTER TABLE albums ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+CREATE UNIQUE INDEX IF NOT EXISTS idx_albums_name_artist ON albums (name, artist);
+
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS artist_id INTEGER REFERENCES artists(id) ON DELETE SET NULL;
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS album_id INTEGER REFERENCES albums(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_songs_artist_id ON songs (artist_id);
+CREATE INDEX IF NOT EXISTS idx_songs_album_id ON songs (album_id);
+
+INSERT INTO artists (name)
+SELECT DISTINCT artist FROM songs WHERE COALESCE(artist, '') <> ''
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO albums (name, artist, artist_id, year)
+SELECT s.album, COALESCE(s.artist, ''), a.id, MIN(NULLIF(s.year, 0))
+FROM songs s
+LEFT JOIN artists a ON a.name = s.artist
+WHERE COALESCE(s.album, '') <> ''
+GROUP BY s.album, COALESCE(s.artist, ''), a.id
+ON CONFLICT (name, artist) DO NOTHING;
+
+UPDATE songs SET artist_id = a.id
+FROM artists a
+WHERE songs.artist_id IS NULL AND a.name = songs.artist;
+
+UPDATE songs SET album_id = al.id
+FROM albums al
+WHERE songs.album_id IS NULL AND al.name = songs.album AND al.artist = COALESCE(songs.artist, '');
+
+-- Starred (favorite) songs, albums and artists
+CREATE TABLE IF NOT EXISTS starred_songs (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    song_id INTEGER NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+    starred_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, song_id)
+);
+
+CREATE TABLE IF NOT EXISTS starred_albums (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    album_id INTEGER NOT NULL REFERENCES albums(id) ON DELETE CASCADE,
+    starred_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, album_id)
+);
+
+CREATE TABLE IF NOT EXISTS starred_artists (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    artist_id INTEGER NOT NULL REFERENCES artists(id) ON DELETE CASCADE,
+    starred_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, artist_id)
+);
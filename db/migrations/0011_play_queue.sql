@@ -0,0 +1,15 @@
+-- Server-side play queue, one per user, so playback can resume on another device
+CREATE TABLE IF NOT EXISTS play_queues (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    current_index INTEGER NOT NULL DEFAULT 0,
+    position_ms BIGINT NOT NULL DEFAULT 0,
+    client VARCHAR(255) NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS play_queue_songs (
+    user_id INTEGER NOT NULL REFERENCES play_queues(user_id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    song_id INTEGER NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, position)
+);
@@ -0,0 +1,3 @@
+-- Users allowed to control the server's jukebox (admins always are)
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS jukebox_role BOOLEAN NOT NULL DEFAULT FALSE;
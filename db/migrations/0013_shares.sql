@@ -0,0 +1,23 @@
+-- Public share links for songs, albums and playlists
+CREATE TABLE IF NOT EXISTS shares (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token VARCHAR(64) NOT NULL UNIQUE,
+    item_type VARCHAR(16) NOT NULL CHECK (item_type IN ('song', 'album', 'playlist')),
+    description TEXT NOT NULL DEFAULT '',
+    password_hash VARCHAR(255),
+    allow_download BOOLEAN NOT NULL DEFAULT FALSE,
+    expires_at TIMESTAMPTZ,
+    visit_count INTEGER NOT NULL DEFAULT 0,
+    last_visited_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_shares_user_id ON shares (user_id);
+
+-- The shared songs, or the shared album or playlist (whose current songs are served)
+CREATE TABLE IF NOT EXISTS share_items (
+    share_id INTEGER NOT NULL REFERENCES shares(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    item_id INTEGER NOT NULL,
+    PRIMARY KEY (share_id, position)
+);
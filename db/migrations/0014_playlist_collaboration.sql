@@ -0,0 +1,37 @@
+-- Playlist visibility: private playlists are only visible to their owner and
+-- collaborators, public ones to every user of the server.
+ALTER TABLE playlists
+ADD COLUMN IF NOT EXISTS visibility VARCHAR(16) NOT NULL DEFAULT 'private';
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.table_constraints
+        WHERE table_name = 'playlists' AND constraint_name = 'playlists_visibility_check'
+    ) THEN
+        ALTER TABLE playlists
+        ADD CONSTRAINT playlists_visibility_check CHECK (visibility IN ('private', 'public'));
+    END IF;
+END $$;
+
+-- Users invited to a playlist: viewers may read it, editors may also change it
+CREATE TABLE IF NOT EXISTS playlist_collaborators (
+    playlist_id INTEGER NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(16) NOT NULL CHECK (role IN ('viewer', 'editor')),
+    added_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (playlist_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_playlist_collaborators_user_id ON playlist_collaborators (user_id);
+
+-- Who changed what in a playlist
+CREATE TABLE IF NOT EXISTS playlist_activity (
+    id SERIAL PRIMARY KEY,
+    playlist_id INTEGER NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(32) NOT NULL,
+    song_id INTEGER REFERENCES songs(id) ON DELETE SET NULL,
+    details TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_playlist_activity_playlist ON playlist_activity (playlist_id, created_at DESC);
@@ -0,0 +1,3 @@
+-- Users allowed to download songs and album/playlist archives (admins always are)
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS download_role BOOLEAN NOT NULL DEFAULT TRUE;
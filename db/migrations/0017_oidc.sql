@@ -0,0 +1,3 @@
+-- Users signed in through OpenID Connect are linked to the provider's subject
+ALTER TABLE users ADD COLUMN IF NOT EXISTS oidc_subject VARCHAR(255);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_oidc_subject ON users (oidc_subject);
@@ -0,0 +1,8 @@
+-- Failed logins, counted per username ("user:<name>") and per client address
+-- ("ip:<addr>"). Keys are locked until locked_until after too many failures.
+CREATE TABLE IF NOT EXISTS login_failures (
+    key VARCHAR(320) PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMPTZ
+);
@@ -0,0 +1,2 @@
+-- Disabled accounts cannot log in or use existing sessions
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,96 @@
+-- Roles grant users a set of permissions. The built-in roles cannot be changed;
+-- admins can add their own, except that only the admin role grants "admin".
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(32) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    built_in BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(32) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    permission VARCHAR(32) NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+INSERT INTO roles (name, description, built_in) VALUES
+    ('admin', 'Full access, including user management', TRUE),
+    ('user', 'Uploads, edits tags, downloads and shares music', TRUE),
+    ('listener', 'Listens to and downloads music, but cannot change the library', TRUE),
+    ('guest', 'Only listens to music, e.g. an account for share recipients', TRUE)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'upload'),
+    ('admin', 'edit_metadata'),
+    ('admin', 'manage_playlists'),
+    ('admin', 'download'),
+    ('admin', 'jukebox'),
+    ('admin', 'share'),
+    ('admin', 'admin'),
+    ('user', 'upload'),
+    ('user', 'edit_metadata'),
+    ('user', 'download'),
+    ('user', 'share'),
+    ('listener', 'download')
+ON CONFLICT (role, permission) DO NOTHING;
+
+-- Every user has one role; existing admins get the admin role. is_admin is kept
+-- in step with it for the admin checks that predate roles.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(32) NOT NULL DEFAULT 'user' REFERENCES roles(name);
+UPDATE users SET role = 'admin' WHERE is_admin AND role <> 'admin';
+
+-- The per-user jukebox_role and download_role flags of 0012 and 0015 become
+-- roles: users whose flag changes what their role allows get a copy of the role
+-- with the jukebox added or downloads removed. The flags are then reset to
+-- their defaults, as nothing reads them any more, so this only does anything
+-- once.
+DO $$
+BEGIN
+    CREATE TEMP TABLE user_role_overrides ON COMMIT DROP AS
+    SELECT id, role, add_jukebox, remove_download,
+        LEFT(role, 16) || CASE
+            WHEN add_jukebox AND remove_download THEN '-jukebox-nodl'
+            WHEN add_jukebox THEN '-jukebox'
+            ELSE '-nodl'
+        END AS new_role
+    FROM (
+        SELECT u.id, u.role,
+            u.jukebox_role AND NOT EXISTS (
+                SELECT 1 FROM role_permissions rp WHERE rp.role = u.role AND rp.permission = 'jukebox'
+            ) AS add_jukebox,
+            NOT u.download_role AND EXISTS (
+                SELECT 1 FROM role_permissions rp WHERE rp.role = u.role AND rp.permission = 'download'
+            ) AS remove_download
+        FROM users u
+        WHERE NOT COALESCE(u.is_admin, FALSE)
+    ) overrides
+    WHERE add_jukebox OR remove_download;
+
+    -- Never add permissions to an existing role that happens to have the name
+    UPDATE user_role_overrides
+    SET new_role = LEFT(new_role, 26) || '-' || LEFT(md5(role || add_jukebox || remove_download), 5)
+    WHERE new_role IN (SELECT name FROM roles);
+
+    INSERT INTO roles (name, description)
+    SELECT DISTINCT new_role, 'The ' || role || ' role'
+        || CASE WHEN add_jukebox THEN ' with the jukebox' ELSE '' END
+        || CASE WHEN remove_download THEN ' without downloads' ELSE '' END
+    FROM user_role_overrides
+    ON CONFLICT (name) DO NOTHING;
+
+    INSERT INTO role_permissions (role, permission)
+    SELECT DISTINCT o.new_role, rp.permission
+    FROM user_role_overrides o
+    JOIN role_permissions rp ON rp.role = o.role
+    WHERE NOT (o.remove_download AND rp.permission = 'download')
+    UNION
+    SELECT new_role, 'jukebox' FROM user_role_overrides WHERE add_jukebox
+    ON CONFLICT (role, permission) DO NOTHING;
+
+    UPDATE users u SET role = o.new_role
+    FROM user_role_overrides o
+    WHERE u.id = o.id;
+
+    UPDATE users SET jukebox_role = FALSE, download_role = TRUE
+    WHERE jukebox_role OR NOT download_role;
+END $$;
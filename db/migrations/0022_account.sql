@@ -0,0 +1,14 @@
+-- The name shown for a user instead of their username, when set
+ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name VARCHAR(255);
+
+-- Password reset tokens, stored as SHA-256 hashes. A token is used once and
+-- expires; requesting a new one replaces the user's unused tokens.
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens (user_id);
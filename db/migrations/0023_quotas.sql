@@ -0,0 +1,17 @@
+-- Storage quotas per user, overriding the server defaults when set. 0 means
+-- unlimited.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS quota_bytes BIGINT CHECK (quota_bytes >= 0);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS quota_tracks INTEGER CHECK (quota_tracks >= 0);
+
+-- The users who uploaded each song. Songs are deduplicated by their file hash,
+-- so one song may have several owners; each is charged for it once. Nothing
+-- recorded who uploaded the songs added before this table (user_songs only
+-- holds ratings), so they are not backfilled and count against no quota until
+-- someone uploads them again.
+CREATE TABLE IF NOT EXISTS song_uploads (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    song_id INTEGER NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, song_id)
+);
+CREATE INDEX IF NOT EXISTS idx_song_uploads_song_id ON song_uploads (song_id);
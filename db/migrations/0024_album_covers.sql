@@ -0,0 +1,3 @@
+-- Cover images of albums, taken from cover.jpg and similar files uploaded with
+-- their songs
+ALTER TABLE albums ADD COLUMN IF NOT EXISTS cover_path TEXT;
@@ -18,6 +18,47 @@ api.interceptors.request.use((config) => {
   return config;
 });
 
+// Access tokens are short-lived: on a 401, exchange the refresh token for a new
+// pair once and retry. Concurrent requests share the same refresh.
+let refreshing = null;
+
+const refreshTokens = () => {
+  if (!refreshing) {
+    const refreshToken = localStorage.getItem('refresh_token');
+    refreshing = (refreshToken
+      ? axios.post(`${API_BASE_URL}/api/auth/refresh`, { refresh_token: refreshToken })
+      : Promise.reject(new Error('No refresh token')))
+      .then((response) => {
+        localStorage.setItem('token', response.data.token);
+        localStorage.setItem('refresh_token', response.data.refresh_token);
+        return response.data.token;
+      })
+      .catch((err) => {
+        localStorage.removeItem('token');
+        localStorage.removeItem('refresh_token');
+        throw err;
+      })
+      .finally(() => { refreshing = null; });
+  }
+  return refreshing;
+};
+
+api.interceptors.response.use(undefined, async (error) => {
+  const original = error.config;
+  if (error.response?.status !== 401 || !original || original._retried || original.url === '/login' || original.url.startsWith('/api/auth/')) {
+    throw error;
+  }
+  original._retried = true;
+  try {
+    const token = await refreshTokens();
+    original.headers.Authorization = `Bearer ${token}`;
+    return api(original);
+  } catch {
+    window.location.href = '/login';
+    throw error;
+  }
+});
+
 // Auth APIs
 export const register = (username, email, password) => 
   api.post('/register', { username, email, password });
@@ -25,13 +66,98 @@ export const register = (username, email, password) =>
 export const login = (username, password) => 
   api.post('/login', { username, password });
 
+// Ends this browser's session and forgets its tokens
+export const logout = () => {
+  const refreshToken = localStorage.getItem('refresh_token');
+  localStorage.removeItem('token');
+  localStorage.removeItem('refresh_token');
+  return refreshToken
+    ? api.post('/api/auth/logout', { refresh_token: refreshToken }).catch(() => {})
+    : Promise.resolve();
+};
+
+// Sessions (logged in devices)
+export const getSessions = () => api.get('/api/me/sessions');
+
+export const revokeSession = (sessionId) => api.delete(`/api/me/sessions/${sessionId}`);
+
+export const revokeOtherSessions = () => api.delete('/api/me/sessions');
+
+// Second login step for users with two-factor authentication
+export const loginTwoFactor = (challengeToken, code) =>
+  api.post('/api/auth/2fa', { challenge_token: challengeToken, code });
+
+export const getTwoFactor = () => api.get('/api/me/2fa');
+
+export const setupTwoFactor = () => api.post('/api/me/2fa/setup');
+
+export const verifyTwoFactor = (code) => api.post('/api/me/2fa/verify', { code });
+
+export const disableTwoFactor = (code) => api.post('/api/me/2fa/disable', { code });
+
+// App passwords let Subsonic clients sign in to accounts with two-factor authentication
+export const getAppPasswords = () => api.get('/api/me/app-passwords');
+
+export const createAppPassword = (name) => api.post('/api/me/app-passwords', { name });
+
+export const deleteAppPassword = (id) => api.delete(`/api/me/app-passwords/${id}`);
+
+// Single sign-on starts with a full page redirect to the identity provider
+export const oidcLoginURL = `${API_BASE_URL}/auth/oidc/login`;
+
+// Behind a reverse proxy that signs users in, trade its sign-in for tokens
+export const proxyLogin = () => api.post('/api/auth/proxy');
+
 // Current user info
 export const getMe = () => api.get('/api/me');
 
+export const updateMe = (changes) => api.put('/api/me', changes);
+
+export const changePassword = (currentPassword, newPassword) =>
+  api.put('/api/me/password', { current_password: currentPassword, new_password: newPassword });
+
+export const deleteAccount = (password) => api.delete('/api/me', { data: { password } });
+
+// Password reset: request a link by username or email, then set a new password with its token
+export const requestPasswordReset = (login) => api.post('/api/auth/password-reset', { login });
+
+export const confirmPasswordReset = (token, password) =>
+  api.post('/api/auth/password-reset/confirm', { token, password });
+
 // Admin APIs
 export const adminCreateUser = (username, password, email = '') =>
   api.post('/api/admin/users', { username, password, email });
 
+export const adminListUsers = (params = {}) => api.get('/api/admin/users', { params });
+
+export const adminUpdateUser = (id, changes) => api.patch(`/api/admin/users/${id}`, changes);
+
+export const adminResetPassword = (id, password) =>
+  api.post(`/api/admin/users/${id}/password`, { password });
+
+export const adminSetUserDisabled = (id, disabled) =>
+  api.post(`/api/admin/users/${id}/${disabled ? 'disable' : 'enable'}`);
+
+export const adminGetQuota = (id) => api.get(`/api/admin/users/${id}/quota`);
+
+// null quotas follow the server default, 0 is unlimited
+export const adminSetQuota = (id, quotaBytes, quotaTracks) =>
+  api.put(`/api/admin/users/${id}/quota`, { quota_bytes: quotaBytes, quota_tracks: quotaTracks });
+
+export const adminListRoles = () => api.get('/api/admin/roles');
+
+export const adminCreateRole = (name, description, permissions) =>
+  api.post('/api/admin/roles', { name, description, permissions });
+
+export const adminUpdateRole = (name, description, permissions) =>
+  api.put(`/api/admin/roles/${name}`, { description, permissions });
+
+export const adminDeleteRole = (name) => api.delete(`/api/admin/roles/${name}`);
+
+// playlists is 'delete' or 'reassign' (to the admin deleting the user)
+export const adminDeleteUser = (id, playlists = 'delete') =>
+  api.delete(`/api/admin/users/${id}`, { params: { playlists } });
+
 // Public config
 export const getPublicConfig = () => api.get('/api/config');
 
@@ -53,6 +179,8 @@ export const getSimilarSongs = (songId) =>
   api.get(`/api/songs/${songId}/similar`);
 
 // Upload API
+export const getUsage = () => api.get('/api/me/usage');
+
 export const uploadFiles = (formData, onUploadProgress) => 
   api.post('/api/upload', formData, {
     headers: {
@@ -83,4 +211,58 @@ export const addSongToPlaylist = (playlistId, songId, position = 0) =>
 export const removeSongFromPlaylist = (playlistId, songId) => 
   api.delete(`/api/playlists/${playlistId}/songs/${songId}`);
 
+// Play queue APIs
+// Each tab saves the queue under its own client name so it can tell its own
+// changes apart from those made on other devices.
+export const queueClientName = `Web player (${Math.random().toString(36).slice(2, 6)})`;
+
+export const getQueue = () => api.get('/api/queue');
+
+export const saveQueue = (songIds, currentIndex, positionMs) =>
+  api.put('/api/queue', {
+    song_ids: songIds,
+    current_index: currentIndex,
+    position_ms: positionMs,
+    client: queueClientName,
+  });
+
+// Calls onQueue with the queue each time it is saved by any client. EventSource
+// cannot send the Authorization header, so the event stream is read with fetch.
+// Returns a function that closes the stream.
+export const subscribeToQueue = (onQueue) => {
+  const controller = new AbortController();
+  const token = localStorage.getItem('token');
+
+  fetch(`${API_BASE_URL}/api/queue/events`, {
+    headers: token ? { Authorization: `Bearer ${token}` } : {},
+    signal: controller.signal,
+  })
+    .then(async (response) => {
+      const reader = response.body.getReader();
+      const decoder = new TextDecoder();
+      let buffer = '';
+      for (;;) {
+        const { value, done } = await reader.read();
+        if (done) break;
+        buffer += decoder.decode(value, { stream: true });
+        let end;
+        while ((end = buffer.indexOf('\n\n')) >= 0) {
+          const data = buffer
+            .slice(0, end)
+            .split('\n')
+            .filter(line => line.startsWith('data: '))
+            .map(line => line.slice(6))
+            .join('\n');
+          buffer = buffer.slice(end + 2);
+          if (data) onQueue(JSON.parse(data));
+        }
+      }
+    })
+    .catch(err => {
+      if (err.name !== 'AbortError') console.error('Queue events error:', err);
+    });
+
+  return () => controller.abort();
+};
+
 export default api;
@@ -1,6 +1,6 @@
 import React, { useEffect, useState } from 'react';
 import { useNavigate } from 'react-router-dom';
-import { getMe, adminCreateUser } from '../api';
+import { getMe, adminCreateUser, adminListUsers, adminUpdateUser, adminSetUserDisabled, adminDeleteUser, adminListRoles } from '../api';
 import './Auth.css';
 
 function AdminUsers() {
@@ -11,6 +11,8 @@ function AdminUsers() {
   const [password, setPassword] = useState('');
   const [message, setMessage] = useState('');
   const [error, setError] = useState('');
+  const [users, setUsers] = useState([]);
+  const [roles, setRoles] = useState([]);
 
   useEffect(() => {
     let mounted = true;
@@ -33,6 +35,37 @@ function AdminUsers() {
     }
   }, [loading, isAdmin, navigate]);
 
+  const loadUsers = () => {
+    adminListUsers({ limit: 500 })
+      .then(({ data }) => setUsers(data.users))
+      .catch(() => setUsers([]));
+  };
+
+  useEffect(() => {
+    if (!isAdmin) return;
+    loadUsers();
+    adminListRoles()
+      .then(({ data }) => setRoles(data.roles))
+      .catch(() => setRoles([]));
+  }, [isAdmin]);
+
+  const runAction = async (action, fallback) => {
+    setError('');
+    setMessage('');
+    try {
+      await action();
+      loadUsers();
+    } catch (err) {
+      const msg = err?.response?.data || fallback;
+      setError(typeof msg === 'string' ? msg : fallback);
+    }
+  };
+
+  const handleDelete = (user) => {
+    if (!window.confirm(`Delete '${user.username}'? Their playlists will be moved to you.`)) return;
+    runAction(() => adminDeleteUser(user.id, 'reassign'), 'Failed to delete user');
+  };
+
   const handleSubmit = async (e) => {
     e.preventDefault();
     setError('');
@@ -42,6 +75,7 @@ function AdminUsers() {
       setMessage(`User '${username}' created`);
       setUsername('');
       setPassword('');
+      loadUsers();
     } catch (err) {
       const msg = err?.response?.data || 'Failed to create user';
       setError(typeof msg === 'string' ? msg : 'Failed to create user');
@@ -78,6 +112,32 @@ function AdminUsers() {
         </div>
         <button type="submit" className="auth-button">Create</button>
       </form>
+      <h2>Users</h2>
+      <ul className="admin-user-list">
+        {users.map((user) => (
+          <li key={user.id}>
+            <span>
+              {user.username}
+              {user.disabled && ' (disabled)'}
+            </span>
+            <select
+              value={user.role}
+              onChange={(e) => runAction(() => adminUpdateUser(user.id, { role: e.target.value }), 'Failed to change role')}
+            >
+              {roles.map((role) => (
+                <option key={role.name} value={role.name} title={role.permissions.join(', ')}>{role.name}</option>
+              ))}
+            </select>
+            <button
+              type="button"
+              onClick={() => runAction(() => adminSetUserDisabled(user.id, !user.disabled), 'Failed to update user')}
+            >
+              {user.disabled ? 'Enable' : 'Disable'}
+            </button>
+            <button type="button" onClick={() => handleDelete(user)}>Delete</button>
+          </li>
+        ))}
+      </ul>
     </div>
   );
 }
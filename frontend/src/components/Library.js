@@ -1,5 +1,5 @@
 import React, { useState, useEffect } from 'react';
-import { getLibrary, getMe } from '../api';
+import { getLibrary, getMe, logout, getQueue, saveQueue, subscribeToQueue, queueClientName } from '../api';
 import PlayerBar from './PlayerBar';
 import './Library.css';
 
@@ -8,6 +8,8 @@ function Library() {
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState('');
   const [currentSong, setCurrentSong] = useState(null);
+  const [queue, setQueue] = useState([]);
+  const [startPosition, setStartPosition] = useState(0);
   const [isAdmin, setIsAdmin] = useState(false);
   
   // Filters and sort
@@ -29,6 +31,31 @@ function Library() {
       .catch(() => setIsAdmin(false));
   }, []);
 
+  useEffect(() => {
+    // Resume the queue saved on this or another device, and follow changes made elsewhere
+    getQueue()
+      .then(({ data }) => applyQueue(data))
+      .catch(err => console.error('Failed to load play queue:', err));
+    return subscribeToQueue((data) => {
+      if (data.client !== queueClientName) applyQueue(data);
+    });
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, []);
+
+  const applyQueue = (data) => {
+    const queueSongs = data?.songs || [];
+    if (queueSongs.length === 0) return;
+    setQueue(queueSongs);
+    setStartPosition((data.position_ms || 0) / 1000);
+    setCurrentSong(queueSongs[data.current_index] || queueSongs[0]);
+  };
+
+  const persistQueue = (queueSongs, song, positionSeconds = 0) => {
+    const index = Math.max(queueSongs.findIndex(s => s.id === song.id), 0);
+    saveQueue(queueSongs.map(s => s.id), index, Math.round(positionSeconds * 1000))
+      .catch(err => console.error('Failed to save play queue:', err));
+  };
+
   const loadLibrary = async () => {
     setLoading(true);
     setError('');
@@ -49,7 +76,19 @@ function Library() {
   };
 
   const handlePlaySong = (song) => {
+    setQueue(filteredSongs);
+    setStartPosition(0);
+    setCurrentSong(song);
+    persistQueue(filteredSongs, song);
+  };
+
+  const handleSongChange = (song) => {
+    // Songs picked from the similar list are added to the end of the queue
+    const nextQueue = queue.some(s => s.id === song.id) ? queue : [...queue, song];
+    setQueue(nextQueue);
+    setStartPosition(0);
     setCurrentSong(song);
+    persistQueue(nextQueue, song);
   };
 
   const filteredSongs = songs.filter(song => {
@@ -83,8 +122,7 @@ function Library() {
             </button>
           )}
           <button className="logout-btn" onClick={() => {
-            localStorage.removeItem('token');
-            window.location.href = '/login';
+            logout().then(() => { window.location.href = '/login'; });
           }}>Logout</button>
         </div>
       </div>
@@ -162,7 +200,15 @@ function Library() {
         </div>
       )}
 
-      {currentSong && <PlayerBar song={currentSong} songs={filteredSongs} onSongChange={setCurrentSong} />}
+      {currentSong && (
+        <PlayerBar
+          song={currentSong}
+          songs={queue}
+          startPosition={startPosition}
+          onSongChange={handleSongChange}
+          onPause={(seconds) => persistQueue(queue, currentSong, seconds)}
+        />
+      )}
     </div>
   );
 }
@@ -1,25 +1,51 @@
 import React, { useEffect, useState } from 'react';
 import { useNavigate, Link } from 'react-router-dom';
-import { login, getPublicConfig } from '../api';
+import { login, loginTwoFactor, getPublicConfig, oidcLoginURL, proxyLogin } from '../api';
 import './Auth.css';
 
 function Login() {
   const [username, setUsername] = useState('');
   const [password, setPassword] = useState('');
+  const [challenge, setChallenge] = useState('');
+  const [code, setCode] = useState('');
   const [error, setError] = useState('');
   const [loading, setLoading] = useState(false);
   const [allowRegistration, setAllowRegistration] = useState(true);
+  const [oidcEnabled, setOidcEnabled] = useState(false);
   const navigate = useNavigate();
 
+  // Single sign-on comes back here with the tokens in the URL fragment
+  useEffect(() => {
+    const params = new URLSearchParams(window.location.hash.slice(1));
+    if (params.get('token') && params.get('refresh_token')) {
+      localStorage.setItem('token', params.get('token'));
+      localStorage.setItem('refresh_token', params.get('refresh_token'));
+      window.history.replaceState(null, '', window.location.pathname);
+      navigate('/library');
+    }
+  }, [navigate]);
+
   useEffect(() => {
     let mounted = true;
     getPublicConfig()
       .then(({ data }) => {
-        if (mounted) setAllowRegistration(!!data?.allow_registration);
+        if (mounted) {
+          setAllowRegistration(!!data?.allow_registration);
+          setOidcEnabled(!!data?.oidc_enabled);
+        }
+        if (mounted && data?.proxy_auth) {
+          proxyLogin()
+            .then((response) => {
+              localStorage.setItem('token', response.data.token);
+              localStorage.setItem('refresh_token', response.data.refresh_token);
+              navigate('/library');
+            })
+            .catch(() => {});
+        }
       })
       .catch(() => setAllowRegistration(false));
     return () => { mounted = false; };
-  }, []);
+  }, [navigate]);
 
   const handleSubmit = async (e) => {
     e.preventDefault();
@@ -27,8 +53,15 @@ function Login() {
     setLoading(true);
 
     try {
-      const response = await login(username, password);
+      const response = challenge
+        ? await loginTwoFactor(challenge, code)
+        : await login(username, password);
+      if (response.data.two_factor_required) {
+        setChallenge(response.data.challenge_token);
+        return;
+      }
       localStorage.setItem('token', response.data.token);
+      localStorage.setItem('refresh_token', response.data.refresh_token);
       navigate('/library');
     } catch (err) {
       setError(err.response?.data || 'Login failed. Please check your credentials.');
@@ -43,6 +76,23 @@ function Login() {
         <h1>Biomuzak</h1>
         <h2>Login</h2>
         <form onSubmit={handleSubmit}>
+          {challenge ? (
+            <div className="form-group">
+              <label htmlFor="code">Authentication or recovery code</label>
+              <input
+                type="text"
+                id="code"
+                inputMode="numeric"
+                autoComplete="one-time-code"
+                value={code}
+                onChange={(e) => setCode(e.target.value)}
+                required
+                autoFocus
+                disabled={loading}
+              />
+            </div>
+          ) : (
+          <>
           <div className="form-group">
             <label htmlFor="username">Username</label>
             <input
@@ -65,16 +115,26 @@ function Login() {
               disabled={loading}
             />
           </div>
+          </>
+          )}
           {error && <div className="error-message">{error}</div>}
           <button type="submit" disabled={loading}>
             {loading ? 'Logging in...' : 'Login'}
           </button>
         </form>
+        {oidcEnabled && (
+          <button type="button" disabled={loading} onClick={() => { window.location.href = oidcLoginURL; }}>
+            Sign in with single sign-on
+          </button>
+        )}
         {allowRegistration && (
           <p className="auth-link">
             Don't have an account? <Link to="/register">Register here</Link>
           </p>
         )}
+        <p className="auth-link">
+          <Link to="/reset-password">Forgot your password?</Link>
+        </p>
       </div>
     </div>
   );
@@ -2,7 +2,7 @@ import React, { useState, useEffect, useRef } from 'react';
 import { getSimilarSongs, rateSong } from '../api';
 import './PlayerBar.css';
 
-function PlayerBar({ song, songs, onSongChange }) {
+function PlayerBar({ song, songs, startPosition = 0, onSongChange, onPause }) {
   const [isPlaying, setIsPlaying] = useState(false);
   const [volume, setVolume] = useState(70);
   const [currentTime, setCurrentTime] = useState(0);
@@ -52,6 +52,10 @@ function PlayerBar({ song, songs, onSongChange }) {
   };
 
   const handlePlayPause = () => {
+    // Remember where playback stopped so it can resume on another device
+    if (isPlaying && onPause && audioRef.current) {
+      onPause(audioRef.current.currentTime);
+    }
     setIsPlaying(!isPlaying);
   };
 
@@ -74,6 +78,13 @@ function PlayerBar({ song, songs, onSongChange }) {
     }
   };
 
+  const handleLoadedMetadata = () => {
+    if (startPosition > 0 && audioRef.current) {
+      audioRef.current.currentTime = startPosition;
+    }
+    handleTimeUpdate();
+  };
+
   const handleSeek = (e) => {
     const seekTime = (e.target.value / 100) * duration;
     if (audioRef.current) {
@@ -111,7 +122,7 @@ function PlayerBar({ song, songs, onSongChange }) {
         ref={audioRef}
         src={song.file_path}
         onTimeUpdate={handleTimeUpdate}
-        onLoadedMetadata={handleTimeUpdate}
+        onLoadedMetadata={handleLoadedMetadata}
         onEnded={handleSongEnd}
       />
 
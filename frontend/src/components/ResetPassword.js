@@ -0,0 +1,112 @@
+import React, { useState } from 'react';
+import { useNavigate, useSearchParams, Link } from 'react-router-dom';
+import { requestPasswordReset, confirmPasswordReset } from '../api';
+import './Auth.css';
+
+// Without a token, asks for a reset link; with the token from the link, sets a new password.
+function ResetPassword() {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token');
+  const [login, setLogin] = useState('');
+  const [password, setPassword] = useState('');
+  const [confirmPassword, setConfirmPassword] = useState('');
+  const [message, setMessage] = useState('');
+  const [error, setError] = useState('');
+  const [loading, setLoading] = useState(false);
+  const navigate = useNavigate();
+
+  const handleRequest = async (e) => {
+    e.preventDefault();
+    setError('');
+    setLoading(true);
+    try {
+      await requestPasswordReset(login);
+      setMessage('If the account exists, a reset link has been sent to its email.');
+    } catch (err) {
+      setError(err.response?.data || 'Failed to request a reset link.');
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const handleConfirm = async (e) => {
+    e.preventDefault();
+    setError('');
+    if (password !== confirmPassword) {
+      setError('Passwords do not match');
+      return;
+    }
+    setLoading(true);
+    try {
+      await confirmPasswordReset(token, password);
+      navigate('/login', { state: { message: 'Password reset! Please login.' } });
+    } catch (err) {
+      setError(err.response?.data || 'Failed to reset password.');
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div className="auth-container">
+      <div className="auth-box">
+        <h1>Biomuzak</h1>
+        <h2>Reset Password</h2>
+        {message && <div className="success-message">{message}</div>}
+        {token ? (
+          <form onSubmit={handleConfirm}>
+            <div className="form-group">
+              <label htmlFor="password">New Password</label>
+              <input
+                type="password"
+                id="password"
+                value={password}
+                onChange={(e) => setPassword(e.target.value)}
+                required
+                disabled={loading}
+              />
+            </div>
+            <div className="form-group">
+              <label htmlFor="confirmPassword">Confirm Password</label>
+              <input
+                type="password"
+                id="confirmPassword"
+                value={confirmPassword}
+                onChange={(e) => setConfirmPassword(e.target.value)}
+                required
+                disabled={loading}
+              />
+            </div>
+            {error && <div className="error-message">{error}</div>}
+            <button type="submit" disabled={loading}>
+              {loading ? 'Saving...' : 'Set Password'}
+            </button>
+          </form>
+        ) : (
+          <form onSubmit={handleRequest}>
+            <div className="form-group">
+              <label htmlFor="login">Username or Email</label>
+              <input
+                type="text"
+                id="login"
+                value={login}
+                onChange={(e) => setLogin(e.target.value)}
+                required
+                disabled={loading}
+              />
+            </div>
+            {error && <div className="error-message">{error}</div>}
+            <button type="submit" disabled={loading}>
+              {loading ? 'Sending...' : 'Send Reset Link'}
+            </button>
+          </form>
+        )}
+        <p className="auth-link">
+          <Link to="/login">Back to login</Link>
+        </p>
+      </div>
+    </div>
+  );
+}
+
+export default ResetPassword;
@@ -101,7 +101,7 @@ function Upload() {
           ref={fileInputRef}
           type="file"
           multiple
-          accept="audio/*,.mp3,.flac,.wav,.ogg,.m4a"
+          accept="audio/*,.mp3,.flac,.wav,.ogg,.m4a,.zip,.tar,.tgz,.gz"
           onChange={handleFileInput}
           style={{ display: 'none' }}
         />
@@ -0,0 +1,243 @@
+// Package archive extracts zip and tar archives uploaded by users. Entries
+// may not escape the destination, and limits on the number and total size of
+// the files extracted guard against archive bombs.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Formats of archives, as named in upload format lists.
+const (
+	FormatZip = "zip"
+	FormatTar = "tar" // Also gzip compressed
+)
+
+var (
+	// ErrUnsafePath is returned for archives with entries outside the destination.
+	ErrUnsafePath = errors.New("archive entry escapes the destination")
+	// ErrTooManyEntries is returned when archives hold more entries than allowed.
+	ErrTooManyEntries = errors.New("archive has too many entries")
+	// ErrTooLarge is returned when archives extract to more bytes than allowed.
+	ErrTooLarge = errors.New("archive is too large when extracted")
+	// ErrUnknownFormat is returned for files that are not zip or tar archives.
+	ErrUnknownFormat = errors.New("not a zip or tar archive")
+)
+
+// Format returns the archive format of a file by its name, FormatZip or
+// FormatTar, or "" if it is not an archive.
+func Format(filename string) string {
+	name := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return FormatZip
+	case strings.HasSuffix(name, ".tar"), strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return FormatTar
+	}
+	return ""
+}
+
+// BaseName returns the name of an archive without its extension.
+func BaseName(filename string) string {
+	name := filepath.Base(filename)
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip"} {
+		if len(name) > len(ext) && strings.EqualFold(name[len(name)-len(ext):], ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+	return name
+}
+
+// Extractor extracts archives within limits shared by all of them, so that a
+// batch of archives is limited as a whole.
+type Extractor struct {
+	// MaxEntries is the most entries read, 0 for no limit
+	MaxEntries int
+	// MaxSize is the most bytes extracted, 0 for no limit. The entries of tar
+	// archives that are skipped count too, as they are decompressed all the
+	// same to reach the next entry.
+	MaxSize int64
+	// Keep selects the regular files extracted by their path in the archive;
+	// nil keeps all of them. Links and other special files are never extracted.
+	Keep func(name string) bool
+
+	entries int
+	size    int64
+}
+
+// Extract extracts the archive at src into dest, keeping its folders, and
+// returns the number of files extracted. The format is detected from the
+// content. On error, dest may hold some of the files.
+func (e *Extractor) Extract(src, dest string) (int, error) {
+	file, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return 0, ErrUnknownFormat
+	}
+	header = header[:n]
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		stat, err := file.Stat()
+		if err != nil {
+			return 0, err
+		}
+		reader, err := zip.NewReader(file, stat.Size())
+		if err != nil {
+			return 0, fmt.Errorf("invalid zip archive: %w", err)
+		}
+		return e.extractZip(reader, dest)
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(bufio.NewReader(file))
+		if err != nil {
+			return 0, fmt.Errorf("invalid gzip archive: %w", err)
+		}
+		defer gz.Close()
+		return e.extractTar(tar.NewReader(gz), dest)
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		return e.extractTar(tar.NewReader(file), dest)
+	}
+	return 0, ErrUnknownFormat
+}
+
+func (e *Extractor) extractZip(reader *zip.Reader, dest string) (int, error) {
+	extracted := 0
+	for _, f := range reader.File {
+		if err := e.countEntry(); err != nil {
+			return extracted, err
+		}
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		ok, err := e.extractEntry(f.Name, dest, func() (io.ReadCloser, error) { return f.Open() })
+		if err != nil {
+			return extracted, err
+		}
+		if ok {
+			extracted++
+		}
+	}
+	return extracted, nil
+}
+
+func (e *Extractor) extractTar(reader *tar.Reader, dest string) (int, error) {
+	extracted := 0
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return extracted, nil
+		} else if err != nil {
+			return extracted, fmt.Errorf("invalid tar archive: %w", err)
+		}
+		if err := e.countEntry(); err != nil {
+			return extracted, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			if err := e.skip(header.Size); err != nil {
+				return extracted, err
+			}
+			continue
+		}
+		ok, err := e.extractEntry(header.Name, dest, func() (io.ReadCloser, error) { return io.NopCloser(reader), nil })
+		if err != nil {
+			return extracted, err
+		}
+		if ok {
+			extracted++
+		} else if err := e.skip(header.Size); err != nil {
+			return extracted, err
+		}
+	}
+}
+
+// skip counts the size of a tar entry that is not extracted.
+func (e *Extractor) skip(size int64) error {
+	e.size += size
+	if e.MaxSize > 0 && e.size > e.MaxSize {
+		return ErrTooLarge
+	}
+	return nil
+}
+
+func (e *Extractor) countEntry() error {
+	e.entries++
+	if e.MaxEntries > 0 && e.entries > e.MaxEntries {
+		return ErrTooManyEntries
+	}
+	return nil
+}
+
+// extractEntry writes the regular file named name to dest, if it is kept,
+// reporting whether it was.
+func (e *Extractor) extractEntry(name string, dest string, open func() (io.ReadCloser, error)) (bool, error) {
+	target, err := safeJoin(dest, name)
+	if err != nil {
+		return false, err
+	}
+	if e.Keep != nil && !e.Keep(name) {
+		return false, nil
+	}
+
+	src, err := open()
+	if err != nil {
+		return false, err
+	}
+	defer src.Close()
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return false, err
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return false, err
+	}
+	defer out.Close()
+
+	// Sizes in headers can lie, so the data itself is counted
+	var reader io.Reader = src
+	if e.MaxSize > 0 {
+		reader = io.LimitReader(src, e.MaxSize-e.size+1)
+	}
+	n, err := io.Copy(out, reader)
+	e.size += n
+	if err != nil {
+		return false, fmt.Errorf("failed to extract %s: %w", name, err)
+	}
+	if e.MaxSize > 0 && e.size > e.MaxSize {
+		return false, ErrTooLarge
+	}
+	return true, nil
+}
+
+// safeJoin returns the path of an archive entry in dest, or ErrUnsafePath for
+// absolute paths and paths leaving dest ("zip slip").
+func safeJoin(dest, name string) (string, error) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if path.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", ErrUnsafePath
+	}
+	cleaned := path.Clean(name)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", ErrUnsafePath
+	}
+	return filepath.Join(dest, filepath.FromSlash(cleaned)), nil
+}
@@ -0,0 +1,164 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeZip writes a zip archive of files, by name, with the given content.
+func writeZip(t *testing.T, path string, files [][2]string) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range files {
+		w, err := zw.Create(file[0])
+		require.NoError(t, err)
+		w.Write([]byte(file[1]))
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+}
+
+// writeTar writes a tar archive of files, gzip compressed if compress is set.
+func writeTar(t *testing.T, path string, compress bool, headers []*tar.Header, contents []string) {
+	t.Helper()
+	var buf bytes.Buffer
+	var gz *gzip.Writer
+	tw := tar.NewWriter(&buf)
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(gz)
+	}
+	for i, header := range headers {
+		require.NoError(t, tw.WriteHeader(header))
+		tw.Write([]byte(contents[i]))
+	}
+	require.NoError(t, tw.Close())
+	if gz != nil {
+		require.NoError(t, gz.Close())
+	}
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+}
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, FormatZip, Format("Album.ZIP"))
+	assert.Equal(t, FormatTar, Format("album.tar"))
+	assert.Equal(t, FormatTar, Format("album.tar.gz"))
+	assert.Equal(t, FormatTar, Format("album.tgz"))
+	assert.Equal(t, "", Format("song.mp3"))
+	assert.Equal(t, "Kind of Blue", BaseName("/tmp/Kind of Blue.tar.gz"))
+	assert.Equal(t, "Album", BaseName("Album.zip"))
+}
+
+func TestExtractZipKeepsFolders(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "album.zip")
+	writeZip(t, src, [][2]string{
+		{"Jazz/Kind of Blue/01 So What.mp3", "so what"},
+		{"Jazz/Kind of Blue/cover.jpg", "jpeg"},
+		{"Jazz/Kind of Blue/notes.txt", "skipped"},
+	})
+	dest := filepath.Join(dir, "out")
+
+	e := &Extractor{Keep: func(name string) bool { return !strings.HasSuffix(name, ".txt") }}
+	n, err := e.Extract(src, dest)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	content, err := os.ReadFile(filepath.Join(dest, "Jazz", "Kind of Blue", "01 So What.mp3"))
+	require.NoError(t, err)
+	assert.Equal(t, "so what", string(content))
+	assert.FileExists(t, filepath.Join(dest, "Jazz", "Kind of Blue", "cover.jpg"))
+	assert.NoFileExists(t, filepath.Join(dest, "Jazz", "Kind of Blue", "notes.txt"))
+}
+
+func TestExtractTarGzSkipsLinks(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "album.tgz")
+	writeTar(t, src, true, []*tar.Header{
+		{Name: "Album/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "Album/01.flac", Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+		{Name: "Album/passwd", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+	}, []string{"", "flac", ""})
+	dest := filepath.Join(dir, "out")
+
+	n, err := (&Extractor{}).Extract(src, dest)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.FileExists(t, filepath.Join(dest, "Album", "01.flac"))
+	_, err = os.Lstat(filepath.Join(dest, "Album", "passwd"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestExtractRejectsPathsOutsideDestination(t *testing.T) {
+	for _, name := range []string{"../evil.mp3", "a/../../evil.mp3", "/etc/evil.mp3", `..\evil.mp3`} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := filepath.Join(dir, "evil.zip")
+			writeZip(t, src, [][2]string{{name, "evil"}})
+			dest := filepath.Join(dir, "out", "nested")
+
+			_, err := (&Extractor{}).Extract(src, dest)
+
+			assert.ErrorIs(t, err, ErrUnsafePath)
+			assert.NoFileExists(t, filepath.Join(dir, "out", "evil.mp3"))
+			assert.NoFileExists(t, filepath.Join(dir, "evil.mp3"))
+		})
+	}
+}
+
+func TestExtractLimitsAcrossArchives(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.zip")
+	second := filepath.Join(dir, "second.tar")
+	writeZip(t, first, [][2]string{{"a.mp3", strings.Repeat("a", 60)}})
+	writeTar(t, second, false, []*tar.Header{
+		{Name: "b.mp3", Typeflag: tar.TypeReg, Mode: 0644, Size: 60},
+	}, []string{strings.Repeat("b", 60)})
+
+	e := &Extractor{MaxSize: 100}
+	_, err := e.Extract(first, filepath.Join(dir, "first"))
+	require.NoError(t, err)
+	_, err = e.Extract(second, filepath.Join(dir, "second"))
+	assert.ErrorIs(t, err, ErrTooLarge)
+
+	e = &Extractor{MaxEntries: 2}
+	writeZip(t, first, [][2]string{{"a.mp3", "a"}, {"b.mp3", "b"}, {"c.mp3", "c"}})
+	_, err = e.Extract(first, filepath.Join(dir, "entries"))
+	assert.ErrorIs(t, err, ErrTooManyEntries)
+}
+
+func TestExtractTarCountsSkippedEntries(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "album.tgz")
+	writeTar(t, src, true, []*tar.Header{
+		{Name: "video.mkv", Typeflag: tar.TypeReg, Mode: 0644, Size: 200},
+		{Name: "01.mp3", Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+	}, []string{strings.Repeat("v", 200), "song"})
+
+	e := &Extractor{MaxSize: 100, Keep: func(name string) bool { return strings.HasSuffix(name, ".mp3") }}
+	_, err := e.Extract(src, filepath.Join(dir, "out"))
+
+	assert.ErrorIs(t, err, ErrTooLarge)
+	assert.NoFileExists(t, filepath.Join(dir, "out", "01.mp3"))
+}
+
+func TestExtractRejectsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "fake.zip")
+	require.NoError(t, os.WriteFile(src, []byte("not an archive"), 0644))
+
+	_, err := (&Extractor{}).Extract(src, filepath.Join(dir, "out"))
+
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
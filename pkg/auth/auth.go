@@ -8,9 +8,14 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-// Claims represents the JWT claims
+// DefaultAccessTokenTTL is how long access tokens are valid unless configured otherwise.
+const DefaultAccessTokenTTL = 15 * time.Minute
+
+// Claims represents the JWT claims. SessionID is the login session the token
+// was issued for.
 type Claims struct {
-	UserID int `json:"user_id"`
+	UserID    int `json:"user_id"`
+	SessionID int `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -26,13 +31,20 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
-// GenerateJWT creates a new JWT for a given user ID
-func GenerateJWT(userID int, jwtSecret string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+// GenerateAccessToken creates a new access token for a user's session, valid for ttl
+func GenerateAccessToken(userID int, sessionID int, jwtSecret string, ttl time.Duration) (string, error) {
+	jti, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
 	claims := &Claims{
-		UserID: userID,
+		UserID:    userID,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
 		},
 	}
 
@@ -43,7 +43,7 @@ func TestGenerateAndValidateJWT(t *testing.T) {
 	userID := 1
 	jwtSecret := "test-secret"
 
-	tokenString, err := GenerateJWT(userID, jwtSecret)
+	tokenString, err := GenerateAccessToken(userID, 7, jwtSecret, DefaultAccessTokenTTL)
 	if err != nil {
 		t.Fatalf("Failed to generate JWT: %v", err)
 	}
@@ -65,6 +65,9 @@ func TestGenerateAndValidateJWT(t *testing.T) {
 		if claims.UserID != userID {
 			t.Fatalf("Expected user ID to be %d, got %d", userID, claims.UserID)
 		}
+		if claims.SessionID != 7 {
+			t.Fatalf("Expected session ID to be 7, got %d", claims.SessionID)
+		}
 	} else {
 		t.Fatal("Failed to parse claims")
 	}
@@ -91,3 +94,98 @@ func TestExpiredJWT(t *testing.T) {
 		t.Fatal("Expected token validation to fail for expired token")
 	}
 }
+
+func TestEncryptSecret(t *testing.T) {
+	ciphertext, err := EncryptSecret("key", "listenbrainz-token")
+	if err != nil {
+		t.Fatalf("Failed to encrypt secret: %v", err)
+	}
+	if ciphertext == "listenbrainz-token" {
+		t.Fatal("Expected ciphertext to be different from plaintext")
+	}
+
+	plaintext, err := DecryptSecret("key", ciphertext)
+	if err != nil {
+		t.Fatalf("Failed to decrypt secret: %v", err)
+	}
+	if plaintext != "listenbrainz-token" {
+		t.Fatalf("Expected 'listenbrainz-token', got %q", plaintext)
+	}
+
+	if _, err := DecryptSecret("other-key", ciphertext); err == nil {
+		t.Fatal("Expected decryption with the wrong key to fail")
+	}
+}
+
+func TestGenerateAccessToken(t *testing.T) {
+	first, err := GenerateAccessToken(1, 7, "test-secret", time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to generate access token: %v", err)
+	}
+	second, err := GenerateAccessToken(1, 7, "test-secret", time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to generate access token: %v", err)
+	}
+
+	token, err := ValidateJWT(first, "test-secret")
+	if err != nil {
+		t.Fatalf("Failed to validate access token: %v", err)
+	}
+	claims := token.Claims.(*Claims)
+	if claims.UserID != 1 || claims.SessionID != 7 {
+		t.Fatalf("Expected user 1 and session 7, got %d and %d", claims.UserID, claims.SessionID)
+	}
+	if claims.ID == "" {
+		t.Fatal("Expected token to have an ID")
+	}
+	other, _ := ValidateJWT(second, "test-secret")
+	if other.Claims.(*Claims).ID == claims.ID {
+		t.Fatal("Expected tokens to have different IDs")
+	}
+	if ttl := claims.ExpiresAt.Sub(claims.IssuedAt.Time); ttl != time.Minute {
+		t.Fatalf("Expected token to be valid for a minute, got %s", ttl)
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 test secret "12345678901234567890"
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	for unix, want := range map[int64]string{59: "287082", 1111111109: "081804", 2000000000: "279037"} {
+		code, err := TOTPCode(secret, unix/30)
+		if err != nil {
+			t.Fatalf("Failed to generate code: %v", err)
+		}
+		if code != want {
+			t.Fatalf("Expected %s at %d, got %s", want, unix, code)
+		}
+	}
+
+	now := time.Unix(1111111109, 0)
+	if step, ok := ValidateTOTP(secret, "081804", now.Add(25*time.Second)); !ok || step != 1111111109/30 {
+		t.Fatal("Expected code from the previous step to be accepted")
+	}
+	if _, ok := ValidateTOTP(secret, "081804", now.Add(2*time.Minute)); ok {
+		t.Fatal("Expected stale code to be rejected")
+	}
+	if _, ok := ValidateTOTP(secret, "123", now); ok {
+		t.Fatal("Expected malformed code to be rejected")
+	}
+}
+
+func TestChallengeToken(t *testing.T) {
+	token, err := GenerateChallengeToken(4, "test-secret", time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to generate challenge token: %v", err)
+	}
+	userID, err := ValidateChallengeToken(token, "test-secret")
+	if err != nil || userID != 4 {
+		t.Fatalf("Expected user 4, got %d (%v)", userID, err)
+	}
+	if _, err := ValidateJWT(token, "test-secret"); err == nil {
+		t.Fatal("Expected challenge token to be rejected as an access token")
+	}
+	access, _ := GenerateAccessToken(4, 1, "test-secret", time.Minute)
+	if _, err := ValidateChallengeToken(access, "test-secret"); err == nil {
+		t.Fatal("Expected access token to be rejected as a challenge token")
+	}
+}
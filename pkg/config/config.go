@@ -5,6 +5,10 @@ import (
 	"log"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -26,10 +30,90 @@ type Config struct {
 	Port             string
 	MusicBrainzEmail string
 
+	// Login sessions: lifetime of access tokens, and of refresh tokens since
+	// the session was last used
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// EncryptionKey protects third-party credentials stored in the database,
+	// such as scrobbling tokens. Defaults to JWTSecret.
+	EncryptionKey string
+
+	// Scrobble forwarding: the default API, and the other APIs users may link
+	// accounts on, as base URLs
+	ListenBrainzURL     string
+	ScrobbleAllowedURLs []string
+
+	// PublicURL is the externally reachable base URL used in share links.
+	// When empty, links are built from the request's host.
+	PublicURL string
+
+	// Jukebox: path of the mpv binary (empty disables the jukebox) and its IPC socket
+	JukeboxMPVPath string
+	JukeboxSocket  string
+
+	// OpenID Connect single sign-on, enabled when OIDCIssuerURL is set.
+	// Users in OIDCAdminGroup (when set) are made admins, others lose admin.
+	// Existing accounts are linked by verified email, and by username only
+	// when OIDCLinkByUsername is set.
+	OIDCIssuerURL      string
+	OIDCClientID       string
+	OIDCClientSecret   string
+	OIDCRedirectURL    string
+	OIDCScopes         []string
+	OIDCGroupsClaim    string
+	OIDCAdminGroup     string
+	OIDCAutoProvision  bool
+	OIDCLinkByUsername bool
+
+	// Reverse proxy authentication: the header holding the username (empty
+	// disables it), accepted only from the trusted proxy CIDRs
+	ProxyAuthHeader      string
+	ProxyAuthEmailHeader string
+	TrustedProxies       []string
+	ProxyAuthAutoCreate  bool
+
+	// Login throttling: failures per username and per address before a
+	// lockout (0 disables), the first delay and the lockout period
+	LoginMaxFailures      int
+	LoginMaxFailuresPerIP int
+	LoginBaseDelay        time.Duration
+	LoginLockout          time.Duration
+
+	// Refuse admin endpoints to admins without two-factor authentication
+	RequireAdminTwoFactor bool
+
+	// Notifications such as password reset links: Notifier is "smtp" to send
+	// email through SMTPAddr, or "log" to only log them
+	Notifier         string
+	SMTPAddr         string
+	SMTPFrom         string
+	SMTPUsername     string
+	SMTPPassword     string
+	PasswordResetTTL time.Duration
+
 	// Upload & Audio Processing
 	UploadDir          string
 	AudioProcessorURL  string
 
+	// Uploads: the largest request in bytes and the accepted audio formats,
+	// as file extensions without the dot, and "zip" and "tar" for archives
+	MaxUploadSize int64
+	UploadFormats []string
+
+	// Limits on the files extracted from the archives of an upload
+	ArchiveMaxEntries int
+	ArchiveMaxSize    int64
+
+	// Resumable uploads: where partial uploads are kept, and for how long
+	// after they were last written to
+	PartialUploadDir    string
+	PartialUploadExpiry time.Duration
+
+	// Storage quotas of users who have none of their own; 0 is unlimited
+	DefaultQuotaBytes  int64
+	DefaultQuotaTracks int
+
 	// Registration & bootstrap
 	AllowRegistration bool
 	AdminUsername     string
@@ -51,6 +135,16 @@ func New() *Config {
 		Port:             getEnv("PORT", "8080"),
 		MusicBrainzEmail: getEnv("MUSICBRAINZ_EMAIL", "youremail@example.com"),
 
+		AccessTokenTTL:  getDurationEnv("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getDurationEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+		ListenBrainzURL: getEnv("LISTENBRAINZ_URL", "https://api.listenbrainz.org"),
+
+		PublicURL: strings.TrimSuffix(getEnv("PUBLIC_URL", ""), "/"),
+
+		JukeboxMPVPath: getEnv("JUKEBOX_MPV_PATH", ""),
+		JukeboxSocket:  getEnv("JUKEBOX_SOCKET", filepath.Join(os.TempDir(), "biomuzak-mpv.sock")),
+
 		UploadDir:         getEnv("UPLOAD_DIR", "./uploads"),
 		AudioProcessorURL: getEnv("AUDIO_PROCESSOR_URL", "http://localhost:8000"),
 
@@ -60,6 +154,54 @@ func New() *Config {
 		AdminEmail:        getEnv("ADMIN_EMAIL", "admin@local"),
 	}
 
+	cfg.EncryptionKey = getEnv("ENCRYPTION_KEY", cfg.JWTSecret)
+
+	cfg.MaxUploadSize = getSizeEnv("MAX_UPLOAD_SIZE", 500<<20)
+	for _, format := range strings.Split(getEnv("UPLOAD_FORMATS", "mp3,flac,wav,m4a,ogg,zip,tar"), ",") {
+		if format = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(format)), "."); format != "" {
+			cfg.UploadFormats = append(cfg.UploadFormats, format)
+		}
+	}
+	cfg.ArchiveMaxEntries = getIntEnv("ARCHIVE_MAX_ENTRIES", 1000)
+	cfg.ArchiveMaxSize = getSizeEnv("ARCHIVE_MAX_SIZE", 2<<30)
+	cfg.PartialUploadDir = getEnv("PARTIAL_UPLOAD_DIR", filepath.Join(cfg.UploadDir, ".partial"))
+	cfg.PartialUploadExpiry = getDurationEnv("PARTIAL_UPLOAD_EXPIRY", 24*time.Hour)
+	cfg.DefaultQuotaBytes = getSizeEnv("DEFAULT_QUOTA", 0)
+	cfg.DefaultQuotaTracks = getIntEnv("DEFAULT_QUOTA_TRACKS", 0)
+
+	cfg.ProxyAuthHeader = getEnv("PROXY_AUTH_HEADER", "")
+	cfg.ProxyAuthEmailHeader = getEnv("PROXY_AUTH_EMAIL_HEADER", "")
+	cfg.TrustedProxies = strings.Split(getEnv("TRUSTED_PROXIES", ""), ",")
+	for _, allowed := range strings.Split(getEnv("SCROBBLE_ALLOWED_URLS", ""), ",") {
+		if allowed = strings.TrimSpace(allowed); allowed != "" {
+			cfg.ScrobbleAllowedURLs = append(cfg.ScrobbleAllowedURLs, allowed)
+		}
+	}
+	cfg.ProxyAuthAutoCreate = getEnv("PROXY_AUTH_AUTO_CREATE", "false") == "true"
+
+	cfg.LoginMaxFailures = getIntEnv("LOGIN_MAX_FAILURES", 5)
+	cfg.LoginMaxFailuresPerIP = getIntEnv("LOGIN_MAX_FAILURES_PER_IP", 20)
+	cfg.LoginBaseDelay = getDurationEnv("LOGIN_BASE_DELAY", time.Second)
+	cfg.LoginLockout = getDurationEnv("LOGIN_LOCKOUT", 15*time.Minute)
+	cfg.RequireAdminTwoFactor = getEnv("REQUIRE_ADMIN_2FA", "false") == "true"
+
+	cfg.Notifier = getEnv("NOTIFIER", "log")
+	cfg.SMTPAddr = getEnv("SMTP_ADDR", "localhost:25")
+	cfg.SMTPFrom = getEnv("SMTP_FROM", "biomuzak@localhost")
+	cfg.SMTPUsername = getEnv("SMTP_USERNAME", "")
+	cfg.SMTPPassword = getEnv("SMTP_PASSWORD", "")
+	cfg.PasswordResetTTL = getDurationEnv("PASSWORD_RESET_TTL", time.Hour)
+
+	cfg.OIDCIssuerURL = getEnv("OIDC_ISSUER_URL", "")
+	cfg.OIDCClientID = getEnv("OIDC_CLIENT_ID", "")
+	cfg.OIDCClientSecret = getEnv("OIDC_CLIENT_SECRET", "")
+	cfg.OIDCRedirectURL = getEnv("OIDC_REDIRECT_URL", cfg.PublicURL+"/auth/oidc/callback")
+	cfg.OIDCScopes = strings.Fields(getEnv("OIDC_SCOPES", "openid profile email"))
+	cfg.OIDCGroupsClaim = getEnv("OIDC_GROUPS_CLAIM", "groups")
+	cfg.OIDCAdminGroup = getEnv("OIDC_ADMIN_GROUP", "")
+	cfg.OIDCAutoProvision = getEnv("OIDC_AUTO_PROVISION", "false") == "true"
+	cfg.OIDCLinkByUsername = getEnv("OIDC_LINK_BY_USERNAME", "false") == "true"
+
 	// Prefer explicit DATABASE_URL if provided
 	if dsn := getEnv("DATABASE_URL", ""); dsn != "" {
 		cfg.DatabaseURL = dsn
@@ -98,3 +240,63 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getDurationEnv reads a duration such as "15m" or "720h" from an environment
+// variable, falling back to the default when it is unset or invalid
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid %s %q, using %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+// getIntEnv reads a non-negative integer from an environment variable,
+// falling back to the default when it is unset or invalid
+func getIntEnv(key string, defaultValue int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("Invalid %s %q, using %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+// sizeUnits are the suffixes accepted by getSizeEnv, longest first.
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1},
+}
+
+// getSizeEnv reads a size in bytes such as "500MB" or "10GB" from an
+// environment variable, falling back to the default when it is unset or invalid
+func getSizeEnv(key string, defaultValue int64) int64 {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	number, multiplier := strings.ToUpper(strings.TrimSpace(value)), int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(number, unit.suffix) {
+			number, multiplier = strings.TrimSpace(strings.TrimSuffix(number, unit.suffix)), unit.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 || n > (1<<62)/multiplier {
+		log.Printf("Invalid %s %q, using %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n * multiplier
+}
@@ -0,0 +1,148 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrEmailTaken is returned when a user changes their email to another user's.
+var ErrEmailTaken = errors.New("email is already in use")
+
+// ErrInvalidResetToken is returned for password reset tokens that do not
+// exist, have expired or were already used.
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// GetPasswordHash returns a user's password hash, "" for users who sign in
+// through single sign-on or a reverse proxy only.
+func GetPasswordHash(db *sql.DB, userID int) (string, error) {
+	var hash string
+	err := db.QueryRow("SELECT password_hash FROM users WHERE id = $1", userID).Scan(&hash)
+	return hash, err
+}
+
+// UpdateProfile changes a user's email and display name; nil fields are kept
+// and an empty display name removes it. An email used by another user returns
+// ErrEmailTaken.
+func UpdateProfile(db *sql.DB, userID int, email, displayName *string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if email != nil {
+		var taken bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE email = $1 AND id <> $2)", *email, userID).Scan(&taken)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrEmailTaken
+		}
+	}
+	result, err := tx.Exec(
+		"UPDATE users SET email = COALESCE($2, email), display_name = NULLIF(COALESCE($3, display_name), ''), updated_at = NOW() WHERE id = $1",
+		userID, email, displayName,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// ChangePassword replaces a user's password hash and logs them out of every
+// session except keepSessionID (0 to log them out everywhere).
+func ChangePassword(db *sql.DB, userID int, passwordHash string, keepSessionID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE users SET password_hash = $2, updated_at = NOW() WHERE id = $1", userID, passwordHash); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		"UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL",
+		userID, keepSessionID,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindUserForPasswordReset finds the enabled user with the given username or
+// email, returning their ID, username and email. If there is none,
+// sql.ErrNoRows is returned.
+func FindUserForPasswordReset(db *sql.DB, login string) (int, string, string, error) {
+	var userID int
+	var username, email string
+	err := db.QueryRow(
+		"SELECT id, username, email FROM users WHERE (username = $1 OR email = $1) AND NOT disabled ORDER BY username = $1 DESC LIMIT 1",
+		login,
+	).Scan(&userID, &username, &email)
+	return userID, username, email, err
+}
+
+// CreatePasswordResetToken stores the hash of a new password reset token for a
+// user, replacing their unused ones.
+func CreatePasswordResetToken(db *sql.DB, userID int, tokenHash string, expiresAt time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM password_reset_tokens WHERE user_id = $1 AND used_at IS NULL", userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		"INSERT INTO password_reset_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3)",
+		userID, tokenHash, expiresAt,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ResetPassword uses a password reset token to set a new password hash, and
+// logs the user out everywhere. It returns the user's ID, or
+// ErrInvalidResetToken if the token cannot be used.
+func ResetPassword(db *sql.DB, tokenHash string, passwordHash string) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRow(`
+		UPDATE password_reset_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id
+	`, tokenHash).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrInvalidResetToken
+	} else if err != nil {
+		return 0, err
+	}
+	result, err := tx.Exec("UPDATE users SET password_hash = $2, updated_at = NOW() WHERE id = $1 AND NOT disabled", userID, passwordHash)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, ErrInvalidResetToken
+	}
+	if _, err := tx.Exec("UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID); err != nil {
+		return 0, err
+	}
+	return userID, tx.Commit()
+}
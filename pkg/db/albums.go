@@ -0,0 +1,252 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go-postgres-example/pkg/models"
+)
+
+// ErrInvalidAlbumList is returned for an unknown album list type or one missing its parameters.
+var ErrInvalidAlbumList = errors.New("invalid album list")
+
+// GetAlbumList retrieves a page of albums ordered according to the list type. Per-user
+// statistics (plays, ratings and stars) are those of the given user.
+func GetAlbumList(db *sql.DB, userID int, opts models.AlbumListOptions) ([]models.AlbumSummary, error) {
+	args := []interface{}{userID}
+	where := ""
+	var orderBy string
+
+	switch opts.Type {
+	case models.AlbumListRandom:
+		orderBy = "RANDOM()"
+	case models.AlbumListNewest:
+		orderBy = "al.created_at DESC, al.id DESC"
+	case models.AlbumListHighest:
+		where = "WHERE r.rating IS NOT NULL"
+		orderBy = "r.rating DESC, LOWER(al.name), al.id"
+	case models.AlbumListFrequent:
+		where = "WHERE p.play_count > 0"
+		orderBy = "p.play_count DESC, p.last_played DESC, al.id"
+	case models.AlbumListRecent:
+		where = "WHERE p.last_played IS NOT NULL"
+		orderBy = "p.last_played DESC, al.id"
+	case models.AlbumListAlphabeticalByName:
+		orderBy = "LOWER(al.name), LOWER(al.artist), al.id"
+	case models.AlbumListAlphabeticalByArtist:
+		orderBy = "LOWER(al.artist), LOWER(al.name), al.id"
+	case models.AlbumListStarred:
+		where = "WHERE sa.starred_at IS NOT NULL"
+		orderBy = "sa.starred_at DESC, al.id"
+	case models.AlbumListByYear:
+		if opts.FromYear == 0 && opts.ToYear == 0 {
+			return nil, fmt.Errorf("%w: byYear requires a year range", ErrInvalidAlbumList)
+		}
+		from, to, direction := opts.FromYear, opts.ToYear, "ASC"
+		if from > to {
+			from, to, direction = to, from, "DESC"
+		}
+		args = append(args, from, to)
+		where = "WHERE al.year BETWEEN $2 AND $3"
+		orderBy = "al.year " + direction + ", LOWER(al.name), al.id"
+	case models.AlbumListByGenre:
+		if strings.TrimSpace(opts.Genre) == "" {
+			return nil, fmt.Errorf("%w: byGenre requires a genre", ErrInvalidAlbumList)
+		}
+		args = append(args, opts.Genre)
+		where = `WHERE EXISTS (
+			SELECT 1 FROM songs s JOIN genres g ON g.id = s.genre_id
+			WHERE s.album_id = al.id AND LOWER(g.name) = LOWER($2)
+		)`
+		orderBy = "LOWER(al.name), al.id"
+	default:
+		return nil, fmt.Errorf("%w: unknown list type %q", ErrInvalidAlbumList, opts.Type)
+	}
+
+	args = append(args, opts.Size, opts.Offset)
+	query := fmt.Sprintf(`
+		SELECT
+			al.id, al.name, al.artist, COALESCE(al.artist_id, 0), COALESCE(al.year, 0), al.created_at,
+			st.genre, st.song_count, st.duration,
+			COALESCE(p.play_count, 0), p.last_played, r.rating, sa.starred_at
+		FROM albums al
+		JOIN LATERAL (
+			SELECT COUNT(*) AS song_count, COALESCE(SUM(s.duration), 0) AS duration, COALESCE(MAX(g.name), '') AS genre
+			FROM songs s
+			LEFT JOIN genres g ON s.genre_id = g.id
+			WHERE s.album_id = al.id
+		) st ON st.song_count > 0
+		LEFT JOIN LATERAL (
+			SELECT COUNT(*) AS play_count, MAX(pl.played_at) AS last_played
+			FROM plays pl
+			JOIN songs s ON s.id = pl.song_id
+			WHERE s.album_id = al.id AND pl.user_id = $1
+		) p ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT AVG(us.rating)::float8 AS rating
+			FROM user_songs us
+			JOIN songs s ON s.id = us.song_id
+			WHERE s.album_id = al.id AND us.user_id = $1
+		) r ON TRUE
+		LEFT JOIN starred_albums sa ON sa.album_id = al.id AND sa.user_id = $1
+		%s
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d
+	`, where, orderBy, len(args)-1, len(args))
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	albums := []models.AlbumSummary{}
+	for rows.Next() {
+		var album models.AlbumSummary
+		var rating sql.NullFloat64
+		if err := rows.Scan(
+			&album.ID, &album.Name, &album.Artist, &album.ArtistID, &album.Year, &album.CreatedAt,
+			&album.Genre, &album.SongCount, &album.Duration,
+			&album.PlayCount, &album.LastPlayed, &rating, &album.StarredAt,
+		); err != nil {
+			return nil, err
+		}
+		album.Rating = rating.Float64
+		albums = append(albums, album)
+	}
+	return albums, rows.Err()
+}
+
+// AlbumExists reports whether an album with the given ID exists
+func AlbumExists(db *sql.DB, albumID int) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM albums WHERE id = $1)", albumID).Scan(&exists)
+	return exists, err
+}
+
+// GetAlbumByID retrieves an album by its ID
+func GetAlbumByID(db *sql.DB, albumID int) (*models.Album, error) {
+	var album models.Album
+	err := db.QueryRow(
+		"SELECT id, name, artist, COALESCE(artist_id, 0), COALESCE(year, 0) FROM albums WHERE id = $1",
+		albumID,
+	).Scan(&album.ID, &album.Name, &album.Artist, &album.ArtistID, &album.Year)
+	if err != nil {
+		return nil, err
+	}
+	return &album, nil
+}
+
+// GetAlbumCover returns the path of an album's cover image, "" if it has none.
+// If there is no such album, sql.ErrNoRows is returned.
+func GetAlbumCover(db *sql.DB, albumID int) (string, error) {
+	var coverPath string
+	err := db.QueryRow("SELECT COALESCE(cover_path, '') FROM albums WHERE id = $1", albumID).Scan(&coverPath)
+	return coverPath, err
+}
+
+// SetAlbumCover sets the cover image of an album that has none, reporting
+// whether it did.
+func SetAlbumCover(db *sql.DB, albumID int, coverPath string) (bool, error) {
+	result, err := db.Exec("UPDATE albums SET cover_path = $2 WHERE id = $1 AND cover_path IS NULL", albumID, coverPath)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// AlbumCoverInUse reports whether an image is the cover of any album.
+func AlbumCoverInUse(db *sql.DB, coverPath string) (bool, error) {
+	var inUse bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM albums WHERE cover_path = $1)", coverPath).Scan(&inUse)
+	return inUse, err
+}
+
+// GetAlbumSongs retrieves the songs of an album in the order they were added,
+// as the library has no track numbers.
+func GetAlbumSongs(db *sql.DB, albumID int) ([]models.Song, error) {
+	query := `
+		SELECT
+			s.id, s.fingerprint_hash, s.file_path, s.title, s.artist, s.album, s.year,
+			s.genre_id, g.name as genre, s.duration, s.bitrate, s.file_size, s.last_modified
+		FROM songs s
+		LEFT JOIN genres g ON s.genre_id = g.id
+		WHERE s.album_id = $1
+		ORDER BY s.id ASC
+	`
+	rows, err := db.Query(query, albumID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	songs := []models.Song{}
+	for rows.Next() {
+		var song models.Song
+		if err := rows.Scan(
+			&song.ID, &song.FingerprintHash, &song.FilePath, &song.Title, &song.Artist, &song.Album, &song.Year,
+			&song.GenreID, &song.Genre, &song.Duration, &song.Bitrate, &song.FileSize, &song.LastModified,
+		); err != nil {
+			return nil, err
+		}
+		songs = append(songs, song)
+	}
+	return songs, rows.Err()
+}
+
+// GetRandomSongs retrieves up to size random songs, optionally limited to a genre
+// and to a range of years (either bound may be 0 to leave it open).
+func GetRandomSongs(db *sql.DB, size int, genre string, fromYear int, toYear int) ([]models.Song, error) {
+	var conditions []string
+	var args []interface{}
+	if genre != "" {
+		args = append(args, genre)
+		conditions = append(conditions, fmt.Sprintf("LOWER(g.name) = LOWER($%d)", len(args)))
+	}
+	if fromYear > 0 {
+		args = append(args, fromYear)
+		conditions = append(conditions, fmt.Sprintf("s.year >= $%d", len(args)))
+	}
+	if toYear > 0 {
+		args = append(args, toYear)
+		conditions = append(conditions, fmt.Sprintf("s.year <= $%d", len(args)))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, size)
+
+	query := fmt.Sprintf(`
+		SELECT
+			s.id, s.fingerprint_hash, s.file_path, s.title, s.artist, s.album, s.year,
+			s.genre_id, g.name as genre, s.duration, s.bitrate, s.file_size, s.last_modified
+		FROM songs s
+		LEFT JOIN genres g ON s.genre_id = g.id
+		%s
+		ORDER BY RANDOM()
+		LIMIT $%d
+	`, where, len(args))
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	songs := []models.Song{}
+	for rows.Next() {
+		var song models.Song
+		if err := rows.Scan(
+			&song.ID, &song.FingerprintHash, &song.FilePath, &song.Title, &song.Artist, &song.Album, &song.Year,
+			&song.GenreID, &song.Genre, &song.Duration, &song.Bitrate, &song.FileSize, &song.LastModified,
+		); err != nil {
+			return nil, err
+		}
+		songs = append(songs, song)
+	}
+	return songs, rows.Err()
+}
@@ -0,0 +1,134 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"go-postgres-example/pkg/models"
+)
+
+// Kinds of items that can be starred.
+const (
+	StarSong   = "song"
+	StarAlbum  = "album"
+	StarArtist = "artist"
+)
+
+// starTables maps each kind of starred item to its star table, the column
+// referencing the item and the table the item lives in.
+var starTables = map[string]struct{ table, column, items string }{
+	StarSong:   {"starred_songs", "song_id", "songs"},
+	StarAlbum:  {"starred_albums", "album_id", "albums"},
+	StarArtist: {"starred_artists", "artist_id", "artists"},
+}
+
+// Star stars songs, albums or artists for a user. Items that are already starred
+// keep their original timestamp. It returns how many of the IDs exist.
+func Star(db *sql.DB, userID int, kind string, ids []int) (int64, error) {
+	t, ok := starTables[kind]
+	if !ok {
+		return 0, fmt.Errorf("unknown star kind %q", kind)
+	}
+	query := fmt.Sprintf(`
+		INSERT INTO %[1]s (user_id, %[2]s)
+		SELECT $1, id FROM %[3]s WHERE id = ANY($2::int[])
+		ON CONFLICT (user_id, %[2]s) DO UPDATE SET starred_at = %[1]s.starred_at
+	`, t.table, t.column, t.items)
+	result, err := db.Exec(query, userID, intArrayToString(ids))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Unstar removes stars from songs, albums or artists for a user.
+func Unstar(db *sql.DB, userID int, kind string, ids []int) error {
+	t, ok := starTables[kind]
+	if !ok {
+		return fmt.Errorf("unknown star kind %q", kind)
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE user_id = $1 AND %s = ANY($2::int[])", t.table, t.column)
+	_, err := db.Exec(query, userID, intArrayToString(ids))
+	return err
+}
+
+// GetFavorites retrieves everything a user has starred, most recently starred first.
+func GetFavorites(db *sql.DB, userID int) (*models.Favorites, error) {
+	favorites := &models.Favorites{
+		Songs:   []models.FavoriteSong{},
+		Albums:  []models.FavoriteAlbum{},
+		Artists: []models.FavoriteArtist{},
+	}
+
+	songRows, err := db.Query(`
+		SELECT
+			s.id, s.fingerprint_hash, s.file_path, s.title, s.artist, s.album, s.year,
+			s.genre_id, g.name as genre, s.duration, s.bitrate, s.file_size, s.last_modified,
+			ss.starred_at
+		FROM starred_songs ss
+		JOIN songs s ON s.id = ss.song_id
+		LEFT JOIN genres g ON s.genre_id = g.id
+		WHERE ss.user_id = $1
+		ORDER BY ss.starred_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer songRows.Close()
+	for songRows.Next() {
+		var song models.FavoriteSong
+		if err := songRows.Scan(
+			&song.ID, &song.FingerprintHash, &song.FilePath, &song.Title, &song.Artist, &song.Album, &song.Year,
+			&song.GenreID, &song.Genre, &song.Duration, &song.Bitrate, &song.FileSize, &song.LastModified,
+			&song.StarredAt,
+		); err != nil {
+			return nil, err
+		}
+		favorites.Songs = append(favorites.Songs, song)
+	}
+	if err := songRows.Err(); err != nil {
+		return nil, err
+	}
+
+	albumRows, err := db.Query(`
+		SELECT al.id, al.name, al.artist, COALESCE(al.artist_id, 0), COALESCE(al.year, 0), sa.starred_at
+		FROM starred_albums sa
+		JOIN albums al ON al.id = sa.album_id
+		WHERE sa.user_id = $1
+		ORDER BY sa.starred_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer albumRows.Close()
+	for albumRows.Next() {
+		var album models.FavoriteAlbum
+		if err := albumRows.Scan(&album.ID, &album.Name, &album.Artist, &album.ArtistID, &album.Year, &album.StarredAt); err != nil {
+			return nil, err
+		}
+		favorites.Albums = append(favorites.Albums, album)
+	}
+	if err := albumRows.Err(); err != nil {
+		return nil, err
+	}
+
+	artistRows, err := db.Query(`
+		SELECT ar.id, ar.name, sa.starred_at
+		FROM starred_artists sa
+		JOIN artists ar ON ar.id = sa.artist_id
+		WHERE sa.user_id = $1
+		ORDER BY sa.starred_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer artistRows.Close()
+	for artistRows.Next() {
+		var artist models.FavoriteArtist
+		if err := artistRows.Scan(&artist.ID, &artist.Name, &artist.StarredAt); err != nil {
+			return nil, err
+		}
+		favorites.Artists = append(favorites.Artists, artist)
+	}
+	return favorites, artistRows.Err()
+}
@@ -13,10 +13,15 @@ func GetSongsByUserID(db *sql.DB, userID int, filters map[string]string, sortBy
 		SELECT
 			s.id, s.fingerprint_hash, s.file_path, s.title, s.artist, s.album, s.year,
 			s.genre_id, g.name as genre, s.duration, s.bitrate, s.file_size, s.last_modified,
-			us.rating
+			us.rating, pc.play_count, pc.last_played
 		FROM songs s
 		LEFT JOIN genres g ON s.genre_id = g.id
 		JOIN user_songs us ON s.id = us.song_id
+		LEFT JOIN LATERAL (
+			SELECT COUNT(*) AS play_count, MAX(p.played_at) AS last_played
+			FROM plays p
+			WHERE p.user_id = us.user_id AND p.song_id = s.id
+		) pc ON true
 		WHERE us.user_id = $1
 	`
 
@@ -47,6 +52,13 @@ func GetSongsByUserID(db *sql.DB, userID int, filters map[string]string, sortBy
 				break
 			}
 		}
+		// Play history sorts put the most played and most recently played songs first.
+		switch sortBy {
+		case "play_count":
+			query += " ORDER BY pc.play_count DESC, pc.last_played DESC NULLS LAST"
+		case "last_played":
+			query += " ORDER BY pc.last_played DESC NULLS LAST"
+		}
 	}
 
 	rows, err := db.Query(query, args...)
@@ -61,7 +73,7 @@ func GetSongsByUserID(db *sql.DB, userID int, filters map[string]string, sortBy
 		if err := rows.Scan(
 			&song.ID, &song.FingerprintHash, &song.FilePath, &song.Title, &song.Artist, &song.Album, &song.Year,
 			&song.GenreID, &song.Genre, &song.Duration, &song.Bitrate, &song.FileSize, &song.LastModified,
-			&song.Rating,
+			&song.Rating, &song.PlayCount, &song.LastPlayed,
 		); err != nil {
 			return nil, err
 		}
@@ -71,8 +83,13 @@ func GetSongsByUserID(db *sql.DB, userID int, filters map[string]string, sortBy
 	return songs, nil
 }
 
-// RateSong inserts or updates a user's rating for a song
+// RateSong inserts or updates a user's rating for a song. A rating of 0 clears it.
 func RateSong(db *sql.DB, userID int, songID int, rating int) error {
+	if rating == 0 {
+		_, err := db.Exec("UPDATE user_songs SET rating = NULL WHERE user_id = $1 AND song_id = $2", userID, songID)
+		return err
+	}
+
 	query := `
 		INSERT INTO user_songs (user_id, song_id, rating)
 		VALUES ($1, $2, $3)
@@ -171,6 +188,56 @@ func GetSongFilePath(db *sql.DB, songID int) (string, error) {
 	return filePath, nil
 }
 
+// GetSongsByIDs retrieves songs in the order of the given IDs, which may repeat.
+// If any of the songs does not exist, sql.ErrNoRows is returned.
+func GetSongsByIDs(db *sql.DB, songIDs []int) ([]models.Song, error) {
+	query := `
+		SELECT
+			s.id, s.fingerprint_hash, s.file_path, s.title, s.artist, s.album, s.year,
+			s.genre_id, g.name as genre, s.duration, s.bitrate, s.file_size, s.last_modified
+		FROM songs s
+		LEFT JOIN genres g ON s.genre_id = g.id
+		WHERE s.id = ANY($1::int[])
+	`
+	rows, err := db.Query(query, intArrayToString(songIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := map[int]models.Song{}
+	for rows.Next() {
+		var song models.Song
+		if err := rows.Scan(
+			&song.ID, &song.FingerprintHash, &song.FilePath, &song.Title, &song.Artist, &song.Album, &song.Year,
+			&song.GenreID, &song.Genre, &song.Duration, &song.Bitrate, &song.FileSize, &song.LastModified,
+		); err != nil {
+			return nil, err
+		}
+		byID[song.ID] = song
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	songs := make([]models.Song, 0, len(songIDs))
+	for _, id := range songIDs {
+		song, ok := byID[id]
+		if !ok {
+			return nil, sql.ErrNoRows
+		}
+		songs = append(songs, song)
+	}
+	return songs, nil
+}
+
+// SongExists reports whether a song with the given ID exists
+func SongExists(db *sql.DB, songID int) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM songs WHERE id = $1)", songID).Scan(&exists)
+	return exists, err
+}
+
 // FindGenreByTrigramSearch finds the closest matching genre using trigram similarity.
 func FindGenreByTrigramSearch(db *sql.DB, genreName string) (string, error) {
 	var bestMatch string
@@ -2,37 +2,154 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"go-postgres-example/pkg/models"
 )
 
+// playlistColumns lists the columns read by scanPlaylist, in order, except for the
+// requesting user's role which each query appends: playlistRole for selects and
+// playlistOwnerRole for statements that only the owner can run.
+const playlistColumns = `playlists.id, playlists.user_id, playlists.name, playlists.rules, playlists.version,
+	playlists.created_at, playlists.updated_at, playlists.visibility,
+	(SELECT username FROM users WHERE users.id = playlists.user_id)`
+
+// playlistOwnerRole is the role column for queries made by the playlist's owner.
+const playlistOwnerRole = "'" + models.PlaylistOwner + "'"
+
+// ErrVersionConflict is returned when a playlist was changed since the version the caller expected.
+var ErrVersionConflict = errors.New("playlist has been modified")
+
+// ErrInvalidPlaylistOperation is returned when a playlist edit cannot be applied.
+var ErrInvalidPlaylistOperation = errors.New("invalid playlist operation")
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// playlistRole returns an SQL expression for the role on a playlist of the user
+// passed as the given query parameter (e.g. "$2"). It is NULL when the user has no
+// access: the playlist is neither theirs, shared with them nor public. Users who
+// may manage other users' playlists are editors of every playlist.
+func playlistRole(param string) string {
+	return `CASE WHEN playlists.user_id = ` + param + ` THEN '` + models.PlaylistOwner + `'
+		WHEN ` + hasPermission(param, models.PermissionManagePlaylists) + ` THEN '` + models.PlaylistEditor + `' ELSE COALESCE(
+		(SELECT pc.role FROM playlist_collaborators pc WHERE pc.playlist_id = playlists.id AND pc.user_id = ` + param + `),
+		CASE WHEN playlists.visibility = '` + models.PlaylistPublic + `' THEN '` + models.PlaylistViewer + `' END) END`
+}
+
+// playlistEditable returns an SQL condition that holds when the user passed as the
+// given query parameter owns the playlist, is one of its editors or may manage
+// other users' playlists.
+func playlistEditable(param string) string {
+	return `(playlists.user_id = ` + param + ` OR EXISTS (
+		SELECT 1 FROM playlist_collaborators pc
+		WHERE pc.playlist_id = playlists.id AND pc.user_id = ` + param + ` AND pc.role = '` + models.PlaylistEditor + `')
+		OR ` + hasPermission(param, models.PermissionManagePlaylists) + `)`
+}
+
+// playlistListed returns an SQL condition that holds when the playlist belongs in
+// the lists of the user passed as the given query parameter: it is theirs, shared
+// with them or public. Permission to manage other users' playlists reaches their
+// private playlists by ID but does not list them.
+func playlistListed(param string) string {
+	return `(playlists.user_id = ` + param + ` OR playlists.visibility = '` + models.PlaylistPublic + `' OR EXISTS (
+		SELECT 1 FROM playlist_collaborators pc
+		WHERE pc.playlist_id = playlists.id AND pc.user_id = ` + param + `))`
+}
+
+// scanPlaylist scans a row selected with playlistColumns and a role into a playlist.
+func scanPlaylist(row rowScanner, playlist *models.Playlist) error {
+	var rules []byte
+	var owner sql.NullString
+	if err := row.Scan(
+		&playlist.ID,
+		&playlist.UserID,
+		&playlist.Name,
+		&rules,
+		&playlist.Version,
+		&playlist.CreatedAt,
+		&playlist.UpdatedAt,
+		&playlist.Visibility,
+		&owner,
+		&playlist.Role,
+	); err != nil {
+		return err
+	}
+	playlist.Owner = owner.String
+	if rules != nil {
+		playlist.Rules = &models.SmartRules{}
+		if err := json.Unmarshal(rules, playlist.Rules); err != nil {
+			return err
+		}
+		playlist.Smart = true
+		playlist.ReadOnly = true
+	}
+	return nil
+}
+
 // CreatePlaylist inserts a new playlist for a user into the database.
 func CreatePlaylist(db *sql.DB, userID int, name string) (*models.Playlist, error) {
 	query := `
 		INSERT INTO playlists (user_id, name)
 		VALUES ($1, $2)
-		RETURNING id, user_id, name, created_at, updated_at
-	`
+		RETURNING ` + playlistColumns + `, ` + playlistOwnerRole
 	playlist := &models.Playlist{}
-	err := db.QueryRow(query, userID, name).Scan(
-		&playlist.ID,
-		&playlist.UserID,
-		&playlist.Name,
-		&playlist.CreatedAt,
-		&playlist.UpdatedAt,
-	)
+	if err := scanPlaylist(db.QueryRow(query, userID, name), playlist); err != nil {
+		return nil, err
+	}
+	return playlist, nil
+}
+
+// CreatePlaylistWithSongs creates a playlist for a user holding the given songs, in
+// order, in a single transaction, so that no playlist is left behind when a song
+// does not exist.
+func CreatePlaylistWithSongs(db *sql.DB, userID int, name string, songIDs []int) (*models.Playlist, error) {
+	tx, err := db.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO playlists (user_id, name)
+		VALUES ($1, $2)
+		RETURNING ` + playlistColumns + `, ` + playlistOwnerRole
+	playlist := &models.Playlist{}
+	if err := scanPlaylist(tx.QueryRow(query, userID, name), playlist); err != nil {
+		return nil, err
+	}
+	if len(songIDs) > 0 {
+		ops := make([]models.PlaylistOperation, 0, len(songIDs))
+		for _, songID := range songIDs {
+			ops = append(ops, models.PlaylistOperation{Op: "insert", SongID: songID})
+		}
+		if err := editPlaylistSongs(tx, userID, playlist.ID, ops); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 	return playlist, nil
 }
 
-// GetUserPlaylists retrieves all playlists owned by a specific user.
+// GetUserPlaylists retrieves the playlists listed for a user: their own, those
+// shared with them and public ones.
 func GetUserPlaylists(db *sql.DB, userID int) ([]models.Playlist, error) {
 	query := `
-		SELECT id, user_id, name, created_at, updated_at
+		SELECT ` + playlistColumns + `, ` + playlistRole("$1") + `
 		FROM playlists
-		WHERE user_id = $1
-		ORDER BY created_at DESC
+		WHERE ` + playlistListed("$1") + `
+		ORDER BY playlists.created_at DESC
 	`
 	rows, err := db.Query(query, userID)
 	if err != nil {
@@ -43,7 +160,7 @@ func GetUserPlaylists(db *sql.DB, userID int) ([]models.Playlist, error) {
 	var playlists []models.Playlist
 	for rows.Next() {
 		var p models.Playlist
-		if err := rows.Scan(&p.ID, &p.UserID, &p.Name, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		if err := scanPlaylist(rows, &p); err != nil {
 			return nil, err
 		}
 		playlists = append(playlists, p)
@@ -51,21 +168,16 @@ func GetUserPlaylists(db *sql.DB, userID int) ([]models.Playlist, error) {
 	return playlists, nil
 }
 
-// GetPlaylistByID retrieves a single playlist by its ID, checking for user ownership.
+// GetPlaylistByID retrieves a single playlist by its ID, checking that the user can
+// see it. The playlist's Role tells what else the user may do with it.
 func GetPlaylistByID(db *sql.DB, userID int, playlistID int) (*models.Playlist, error) {
 	query := `
-		SELECT id, user_id, name, created_at, updated_at
+		SELECT ` + playlistColumns + `, ` + playlistRole("$2") + `
 		FROM playlists
-		WHERE id = $1 AND user_id = $2
+		WHERE playlists.id = $1 AND ` + playlistRole("$2") + ` IS NOT NULL
 	`
 	playlist := &models.Playlist{}
-	err := db.QueryRow(query, playlistID, userID).Scan(
-		&playlist.ID,
-		&playlist.UserID,
-		&playlist.Name,
-		&playlist.CreatedAt,
-		&playlist.UpdatedAt,
-	)
+	err := scanPlaylist(db.QueryRow(query, playlistID, userID), playlist)
 	if err != nil {
 		// Return the error, the handler can check for sql.ErrNoRows
 		return nil, err
@@ -79,12 +191,12 @@ func GetPlaylistSongs(db *sql.DB, playlistID int) ([]models.PlaylistSong, error)
 		SELECT
 			s.id, s.fingerprint_hash, s.file_path, s.title, s.artist, s.album, s.year,
 			s.genre_id, g.name as genre, s.duration, s.bitrate, s.file_size, s.last_modified,
-			ps.position
+			ps.id, ps.position
 		FROM songs s
 		LEFT JOIN genres g ON s.genre_id = g.id
 		JOIN playlist_songs ps ON s.id = ps.song_id
 		WHERE ps.playlist_id = $1
-		ORDER BY ps.position ASC
+		ORDER BY ps.position ASC, ps.id ASC
 	`
 	rows, err := db.Query(query, playlistID)
 	if err != nil {
@@ -99,7 +211,7 @@ func GetPlaylistSongs(db *sql.DB, playlistID int) ([]models.PlaylistSong, error)
 		if err := rows.Scan(
 			&song.Song.ID, &song.Song.FingerprintHash, &song.Song.FilePath, &song.Song.Title, &song.Song.Artist, &song.Song.Album, &song.Song.Year,
 			&song.Song.GenreID, &song.Song.Genre, &song.Song.Duration, &song.Song.Bitrate, &song.Song.FileSize, &song.Song.LastModified,
-			&song.Position,
+			&song.EntryID, &song.Position,
 		); err != nil {
 			return nil, err
 		}
@@ -108,14 +220,29 @@ func GetPlaylistSongs(db *sql.DB, playlistID int) ([]models.PlaylistSong, error)
 	return songs, nil
 }
 
-// UpdatePlaylistName updates the name of a playlist, checking for user ownership.
+// GetPlaylistContents retrieves the songs of any playlist: smart playlists are
+// evaluated against their rules, static ones are read from playlist_songs.
+func GetPlaylistContents(db *sql.DB, playlist *models.Playlist) ([]models.PlaylistSong, error) {
+	if playlist.Smart {
+		return GetSmartPlaylistSongs(db, playlist)
+	}
+	return GetPlaylistSongs(db, playlist.ID)
+}
+
+// UpdatePlaylistName updates the name of a playlist, checking that the user owns it
+// or is one of its editors.
 func UpdatePlaylistName(db *sql.DB, userID int, playlistID int, name string) error {
 	query := `
-		UPDATE playlists
-		SET name = $1, updated_at = NOW()
-		WHERE id = $2 AND user_id = $3
+		WITH updated AS (
+			UPDATE playlists
+			SET name = $1, version = version + 1, updated_at = NOW()
+			WHERE playlists.id = $2 AND ` + playlistEditable("$3") + `
+			RETURNING playlists.id
+		)
+		INSERT INTO playlist_activity (playlist_id, user_id, action, details)
+		SELECT id, $3, $4, $1 FROM updated
 	`
-	res, err := db.Exec(query, name, playlistID, userID)
+	res, err := db.Exec(query, name, playlistID, userID, models.PlaylistActivityRename)
 	if err != nil {
 		return err
 	}
@@ -129,11 +256,92 @@ func UpdatePlaylistName(db *sql.DB, userID int, playlistID int, name string) err
 	return nil
 }
 
-// DeletePlaylist deletes a playlist, checking for user ownership.
+// UpdatePlaylist changes the name, smart rules and visibility of a playlist in a
+// single transaction, recording the changes in its activity, and returns its new
+// version. If expectedVersion is not 0 and the playlist is at a different version,
+// nothing is changed and ErrVersionConflict is returned. The user must own the
+// playlist or be one of its editors, and own it to change its visibility;
+// otherwise, or when rules are set on a static playlist, sql.ErrNoRows is returned.
+func UpdatePlaylist(db *sql.DB, userID int, playlistID int, expectedVersion int, update models.PlaylistUpdate) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Bumping the version first locks the playlist row until the transaction ends.
+	newVersion, err := bumpPlaylistVersion(tx, userID, playlistID, expectedVersion)
+	if err != nil {
+		return 0, err
+	}
+
+	if update.Rules != nil {
+		rulesJSON, err := json.Marshal(update.Rules)
+		if err != nil {
+			return 0, err
+		}
+		if err := execPlaylistChange(tx, `
+			WITH updated AS (
+				UPDATE playlists SET rules = $1 WHERE id = $2 AND rules IS NOT NULL RETURNING id
+			)
+			INSERT INTO playlist_activity (playlist_id, user_id, action)
+			SELECT id, $3, $4 FROM updated
+		`, rulesJSON, playlistID, userID, models.PlaylistActivityRules); err != nil {
+			return 0, err
+		}
+	}
+	if update.Name != "" {
+		if err := execPlaylistChange(tx, `
+			WITH updated AS (
+				UPDATE playlists SET name = $1 WHERE id = $2 RETURNING id
+			)
+			INSERT INTO playlist_activity (playlist_id, user_id, action, details)
+			SELECT id, $3, $4, $1 FROM updated
+		`, update.Name, playlistID, userID, models.PlaylistActivityRename); err != nil {
+			return 0, err
+		}
+	}
+	if update.Visibility != "" {
+		if err := execPlaylistChange(tx, `
+			WITH updated AS (
+				UPDATE playlists SET visibility = $1 WHERE id = $2 AND user_id = $3 RETURNING id
+			)
+			INSERT INTO playlist_activity (playlist_id, user_id, action, details)
+			SELECT id, $3, $4, $1 FROM updated
+		`, update.Visibility, playlistID, userID, models.PlaylistActivityVisibility); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return newVersion, nil
+}
+
+// execPlaylistChange runs a statement that changes a playlist, returning
+// sql.ErrNoRows if it changed nothing.
+func execPlaylistChange(exec execer, query string, args ...interface{}) error {
+	res, err := exec.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeletePlaylist deletes a playlist, checking that the user owns it or may
+// manage other users' playlists.
 func DeletePlaylist(db *sql.DB, userID int, playlistID int) error {
 	query := `
 		DELETE FROM playlists
-		WHERE id = $1 AND user_id = $2
+		WHERE id = $1 AND (user_id = $2 OR ` + hasPermission("$2", models.PermissionManagePlaylists) + `)
 	`
 	res, err := db.Exec(query, playlistID, userID)
 	if err != nil {
@@ -149,71 +357,264 @@ func DeletePlaylist(db *sql.DB, userID int, playlistID int) error {
 	return nil
 }
 
-// AddSongToPlaylist adds a song to a playlist.
-func AddSongToPlaylist(db *sql.DB, playlistID int, songID int, position int) error {
+// AddSongToPlaylist adds a song to a playlist at the given position, or at the end if position <= 0.
+func AddSongToPlaylist(db *sql.DB, userID int, playlistID int, songID int, position int) error {
+	_, err := EditPlaylistSongs(db, userID, playlistID, 0, []models.PlaylistOperation{
+		{Op: "insert", SongID: songID, Position: position},
+	})
+	return err
+}
+
+// RemoveSongFromPlaylist removes every occurrence of a song from a playlist and re-orders the remaining songs.
+func RemoveSongFromPlaylist(db *sql.DB, userID int, playlistID int, songID int) error {
+	_, err := EditPlaylistSongs(db, userID, playlistID, 0, []models.PlaylistOperation{
+		{Op: "remove", SongID: songID},
+	})
+	return err
+}
+
+// playlistEntry is a row of playlist_songs while edits are being applied.
+type playlistEntry struct {
+	ID       int
+	SongID   int
+	Position int // Position stored in the database, 0 for new entries
+}
+
+// EditPlaylistSongs applies a batch of operations to a playlist in a single transaction,
+// recording them in the playlist's activity, and returns the playlist's new version.
+// If expectedVersion is not 0 and the playlist is at a different version, nothing is
+// changed and ErrVersionConflict is returned. The user must own the playlist or be one
+// of its editors, otherwise sql.ErrNoRows is returned.
+func EditPlaylistSongs(db *sql.DB, userID int, playlistID int, expectedVersion int, ops []models.PlaylistOperation) (int, error) {
 	tx, err := db.Begin()
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer tx.Rollback()
 
-	// Remove the song if it's already in the playlist to handle re-ordering.
-	_, err = tx.Exec("DELETE FROM playlist_songs WHERE playlist_id = $1 AND song_id = $2", playlistID, songID)
+	// Bumping the version first locks the playlist row until the transaction ends.
+	newVersion, err := bumpPlaylistVersion(tx, userID, playlistID, expectedVersion)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	if err := editPlaylistSongs(tx, userID, playlistID, ops); err != nil {
+		return 0, err
 	}
 
-	// If position is not specified (<= 0), append to the end.
-	if position <= 0 {
-		err = tx.QueryRow("SELECT COALESCE(MAX(position), 0) + 1 FROM playlist_songs WHERE playlist_id = $1", playlistID).Scan(&position)
-		if err != nil {
-			return err
-		}
-	} else {
-		// Shift existing songs to make space.
-		_, err = tx.Exec("UPDATE playlist_songs SET position = position + 1 WHERE playlist_id = $1 AND position >= $2", playlistID, position)
-		if err != nil {
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return newVersion, nil
+}
+
+// editPlaylistSongs applies operations to the songs of a playlist within a
+// transaction and records them in its activity.
+func editPlaylistSongs(tx *sql.Tx, userID int, playlistID int, ops []models.PlaylistOperation) error {
+	rows, err := tx.Query("SELECT id, song_id, position FROM playlist_songs WHERE playlist_id = $1 ORDER BY position ASC, id ASC", playlistID)
+	if err != nil {
+		return err
+	}
+	var entries []playlistEntry
+	for rows.Next() {
+		var e playlistEntry
+		if err := rows.Scan(&e.ID, &e.SongID, &e.Position); err != nil {
+			rows.Close()
 			return err
 		}
+		entries = append(entries, e)
 	}
+	rows.Close()
 
-	// Insert the song at the desired position.
-	_, err = tx.Exec("INSERT INTO playlist_songs (playlist_id, song_id, position) VALUES ($1, $2, $3)", playlistID, songID, position)
+	edited, removed, err := applyPlaylistOperations(entries, ops)
 	if err != nil {
 		return err
 	}
+	var newSongIDs []int
+	for _, e := range edited {
+		if e.ID == 0 {
+			newSongIDs = append(newSongIDs, e.SongID)
+		}
+	}
+	if err := checkSongsExist(tx, newSongIDs); err != nil {
+		return err
+	}
+
+	for _, id := range removed {
+		if _, err := tx.Exec("DELETE FROM playlist_songs WHERE id = $1", id); err != nil {
+			return err
+		}
+	}
+	for i, e := range edited {
+		position := i + 1
+		switch {
+		case e.ID == 0:
+			if _, err := tx.Exec("INSERT INTO playlist_songs (playlist_id, song_id, position) VALUES ($1, $2, $3)", playlistID, e.SongID, position); err != nil {
+				return err
+			}
+		case e.Position != position:
+			if _, err := tx.Exec("UPDATE playlist_songs SET position = $1 WHERE id = $2", position, e.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	added, removedSongs, moved := playlistEditActivity(entries, edited, removed, ops)
+	for _, activity := range []struct {
+		action  string
+		songIDs []int
+	}{
+		{models.PlaylistActivityAdd, added},
+		{models.PlaylistActivityRemove, removedSongs},
+		{models.PlaylistActivityMove, moved},
+	} {
+		if err := logPlaylistSongActivity(tx, playlistID, userID, activity.action, activity.songIDs); err != nil {
+			return err
+		}
+	}
 
-	return tx.Commit()
+	return nil
 }
 
-// RemoveSongFromPlaylist removes a song from a playlist and re-orders the remaining songs.
-func RemoveSongFromPlaylist(db *sql.DB, playlistID int, songID int) error {
-	tx, err := db.Begin()
+// bumpPlaylistVersion increments a playlist's version, checking it against expectedVersion
+// when set and checking that the user owns the playlist or is one of its editors.
+func bumpPlaylistVersion(tx *sql.Tx, userID int, playlistID int, expectedVersion int) (int, error) {
+	var newVersion int
+	err := tx.QueryRow(`
+		UPDATE playlists
+		SET version = version + 1, updated_at = NOW()
+		WHERE playlists.id = $1 AND ($2 = 0 OR version = $2) AND `+playlistEditable("$3")+`
+		RETURNING version
+	`, playlistID, expectedVersion, userID).Scan(&newVersion)
+	if err == sql.ErrNoRows && expectedVersion != 0 {
+		return 0, ErrVersionConflict
+	}
+	return newVersion, err
+}
+
+// checkSongsExist returns ErrInvalidPlaylistOperation naming the first of the
+// songs that does not exist, if any.
+func checkSongsExist(tx *sql.Tx, songIDs []int) error {
+	if len(songIDs) == 0 {
+		return nil
+	}
+	var missing int
+	err := tx.QueryRow(`
+		SELECT new.id FROM unnest($1::int[]) AS new(id)
+		WHERE NOT EXISTS (SELECT 1 FROM songs s WHERE s.id = new.id)
+		LIMIT 1
+	`, intArrayToString(songIDs)).Scan(&missing)
+	if err == sql.ErrNoRows {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
+	return fmt.Errorf("%w: song %d not found", ErrInvalidPlaylistOperation, missing)
+}
 
-	var removedPosition int
-	err = tx.QueryRow("SELECT position FROM playlist_songs WHERE playlist_id = $1 AND song_id = $2", playlistID, songID).Scan(&removedPosition)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			// Song isn't in the playlist, so there's nothing to do.
-			return nil
+// playlistEditActivity works out which songs an edit added, removed and moved, given
+// the entries before the edit and the result of applyPlaylistOperations.
+func playlistEditActivity(before, edited []playlistEntry, removed []int, ops []models.PlaylistOperation) (added, removedSongs, moved []int) {
+	songOf := make(map[int]int, len(before))
+	for _, e := range before {
+		songOf[e.ID] = e.SongID
+	}
+	for _, e := range edited {
+		if e.ID == 0 {
+			added = append(added, e.SongID)
 		}
-		return err
 	}
+	for _, id := range removed {
+		removedSongs = append(removedSongs, songOf[id])
+	}
+	for _, op := range ops {
+		if songID, ok := songOf[op.EntryID]; ok && op.Op == "move" {
+			moved = append(moved, songID)
+		}
+	}
+	return added, removedSongs, moved
+}
 
-	_, err = tx.Exec("DELETE FROM playlist_songs WHERE playlist_id = $1 AND song_id = $2", playlistID, songID)
-	if err != nil {
-		return err
+// logPlaylistSongActivity records that the user applied an action to each of the
+// given songs of a playlist. Nothing is recorded for an empty list.
+func logPlaylistSongActivity(exec execer, playlistID int, userID int, action string, songIDs []int) error {
+	if len(songIDs) == 0 {
+		return nil
 	}
+	_, err := exec.Exec(`
+		INSERT INTO playlist_activity (playlist_id, user_id, action, song_id)
+		SELECT $1, $2, $3, unnest($4::int[])
+	`, playlistID, userID, action, intArrayToString(songIDs))
+	return err
+}
 
-	// Shift subsequent songs to close the gap.
-	_, err = tx.Exec("UPDATE playlist_songs SET position = position - 1 WHERE playlist_id = $1 AND position > $2", playlistID, removedPosition)
-	if err != nil {
-		return err
+// applyPlaylistOperations applies operations to an ordered list of entries in memory.
+// It returns the new order and the IDs of the entries that were removed.
+func applyPlaylistOperations(entries []playlistEntry, ops []models.PlaylistOperation) ([]playlistEntry, []int, error) {
+	result := append([]playlistEntry(nil), entries...)
+	var removed []int
+
+	indexOf := func(entryID int) int {
+		for i, e := range result {
+			if e.ID == entryID && e.ID != 0 {
+				return i
+			}
+		}
+		return -1
+	}
+	// insertAt clamps a 1-based position to the list, treating <= 0 as the end.
+	insertAt := func(e playlistEntry, position int) {
+		i := position - 1
+		if position <= 0 || i > len(result) {
+			i = len(result)
+		}
+		result = append(result, playlistEntry{})
+		copy(result[i+1:], result[i:])
+		result[i] = e
 	}
 
-	return tx.Commit()
+	for n, op := range ops {
+		switch op.Op {
+		case "insert":
+			if op.SongID <= 0 {
+				return nil, nil, fmt.Errorf("%w: operation %d: insert requires a song_id", ErrInvalidPlaylistOperation, n)
+			}
+			insertAt(playlistEntry{SongID: op.SongID}, op.Position)
+		case "move":
+			i := indexOf(op.EntryID)
+			if i < 0 {
+				return nil, nil, fmt.Errorf("%w: operation %d: entry %d not found", ErrInvalidPlaylistOperation, n, op.EntryID)
+			}
+			e := result[i]
+			result = append(result[:i], result[i+1:]...)
+			insertAt(e, op.Position)
+		case "remove":
+			switch {
+			case op.EntryID != 0:
+				i := indexOf(op.EntryID)
+				if i < 0 {
+					return nil, nil, fmt.Errorf("%w: operation %d: entry %d not found", ErrInvalidPlaylistOperation, n, op.EntryID)
+				}
+				removed = append(removed, result[i].ID)
+				result = append(result[:i], result[i+1:]...)
+			case op.SongID != 0:
+				kept := result[:0]
+				for _, e := range result {
+					if e.SongID == op.SongID {
+						if e.ID != 0 {
+							removed = append(removed, e.ID)
+						}
+						continue
+					}
+					kept = append(kept, e)
+				}
+				result = kept
+			default:
+				return nil, nil, fmt.Errorf("%w: operation %d: remove requires an entry_id or song_id", ErrInvalidPlaylistOperation, n)
+			}
+		default:
+			return nil, nil, fmt.Errorf("%w: operation %d: unknown op %q", ErrInvalidPlaylistOperation, n, op.Op)
+		}
+	}
+	return result, removed, nil
 }
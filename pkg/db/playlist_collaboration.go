@@ -0,0 +1,142 @@
+package db
+
+import (
+	"database/sql"
+	"go-postgres-example/pkg/models"
+)
+
+// SetPlaylistVisibility makes a playlist private or public, checking that the user owns it.
+func SetPlaylistVisibility(db *sql.DB, userID int, playlistID int, visibility string) error {
+	query := `
+		WITH updated AS (
+			UPDATE playlists
+			SET visibility = $1, version = version + 1, updated_at = NOW()
+			WHERE id = $2 AND user_id = $3
+			RETURNING id
+		)
+		INSERT INTO playlist_activity (playlist_id, user_id, action, details)
+		SELECT id, $3, $4, $1 FROM updated
+	`
+	res, err := db.Exec(query, visibility, playlistID, userID, models.PlaylistActivityVisibility)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Not found or not authorized
+	}
+	return nil
+}
+
+// GetPlaylistCollaborators retrieves the users a playlist is shared with.
+func GetPlaylistCollaborators(db *sql.DB, playlistID int) ([]models.PlaylistCollaborator, error) {
+	rows, err := db.Query(`
+		SELECT pc.user_id, u.username, pc.role, pc.added_at
+		FROM playlist_collaborators pc
+		JOIN users u ON u.id = pc.user_id
+		WHERE pc.playlist_id = $1
+		ORDER BY u.username ASC
+	`, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collaborators := []models.PlaylistCollaborator{}
+	for rows.Next() {
+		var c models.PlaylistCollaborator
+		if err := rows.Scan(&c.UserID, &c.Username, &c.Role, &c.AddedAt); err != nil {
+			return nil, err
+		}
+		collaborators = append(collaborators, c)
+	}
+	return collaborators, rows.Err()
+}
+
+// SetPlaylistCollaborator shares a playlist with another user as a viewer or editor,
+// or changes their role, checking that the user making the change owns the playlist.
+func SetPlaylistCollaborator(db *sql.DB, ownerID int, playlistID int, collaboratorID int, role string) error {
+	query := `
+		WITH changed AS (
+			INSERT INTO playlist_collaborators (playlist_id, user_id, role)
+			SELECT id, $3, $4 FROM playlists WHERE id = $1 AND user_id = $2
+			ON CONFLICT (playlist_id, user_id) DO UPDATE SET role = EXCLUDED.role
+			RETURNING playlist_id
+		)
+		INSERT INTO playlist_activity (playlist_id, user_id, action, details)
+		SELECT playlist_id, $2, $5, (SELECT username FROM users WHERE id = $3) || ':' || $4 FROM changed
+	`
+	res, err := db.Exec(query, playlistID, ownerID, collaboratorID, role, models.PlaylistActivityCollaborator)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Not found or not authorized
+	}
+	return nil
+}
+
+// RemovePlaylistCollaborator stops sharing a playlist with a user. The owner may
+// remove anyone; collaborators may only remove themselves.
+func RemovePlaylistCollaborator(db *sql.DB, userID int, playlistID int, collaboratorID int) error {
+	query := `
+		WITH removed AS (
+			DELETE FROM playlist_collaborators pc
+			USING playlists p
+			WHERE pc.playlist_id = p.id AND pc.playlist_id = $1 AND pc.user_id = $3
+				AND (p.user_id = $2 OR pc.user_id = $2)
+			RETURNING pc.playlist_id
+		)
+		INSERT INTO playlist_activity (playlist_id, user_id, action, details)
+		SELECT playlist_id, $2, $4, (SELECT username FROM users WHERE id = $3) FROM removed
+	`
+	res, err := db.Exec(query, playlistID, userID, collaboratorID, models.PlaylistActivityCollaboratorRemoved)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Not found or not authorized
+	}
+	return nil
+}
+
+// GetPlaylistActivity retrieves the most recent changes made to a playlist, newest first.
+func GetPlaylistActivity(db *sql.DB, playlistID int, limit int) ([]models.PlaylistActivity, error) {
+	rows, err := db.Query(`
+		SELECT a.id, a.user_id, u.username, a.action, a.song_id, s.title, a.details, a.created_at
+		FROM playlist_activity a
+		LEFT JOIN users u ON u.id = a.user_id
+		LEFT JOIN songs s ON s.id = a.song_id
+		WHERE a.playlist_id = $1
+		ORDER BY a.created_at DESC, a.id DESC
+		LIMIT $2
+	`, playlistID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activity := []models.PlaylistActivity{}
+	for rows.Next() {
+		var a models.PlaylistActivity
+		var username, songTitle sql.NullString
+		if err := rows.Scan(&a.ID, &a.UserID, &username, &a.Action, &a.SongID, &songTitle, &a.Details, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.Username = username.String
+		a.SongTitle = songTitle.String
+		activity = append(activity, a)
+	}
+	return activity, rows.Err()
+}
@@ -0,0 +1,167 @@
+package db
+
+import (
+	"database/sql"
+	"go-postgres-example/pkg/models"
+	"sort"
+)
+
+// TagMatch is a song found by fuzzy artist/title matching, with its match score.
+type TagMatch struct {
+	models.Song
+	Score float64
+}
+
+// FindSongIDByPath finds a song by its stored file path or, failing that, by its file name.
+func FindSongIDByPath(db *sql.DB, filePath string, fileName string) (int, error) {
+	query := `
+		SELECT id FROM songs
+		WHERE file_path = $1 OR right(file_path, length($2) + 1) = '/' || $2
+		ORDER BY file_path = $1 DESC
+		LIMIT 1
+	`
+	var songID int
+	err := db.QueryRow(query, filePath, fileName).Scan(&songID)
+	return songID, err
+}
+
+// FindSongsByTags finds the songs whose title and artist best match the given
+// values using trigram similarity. The artist may be empty.
+func FindSongsByTags(db *sql.DB, artist string, title string, limit int) ([]TagMatch, error) {
+	query := `
+		SELECT
+			s.id, s.fingerprint_hash, s.file_path, s.title, s.artist, s.album, s.year,
+			s.genre_id, g.name as genre, s.duration, s.bitrate, s.file_size, s.last_modified,
+			similarity(COALESCE(s.title, ''), $2) +
+				CASE WHEN $1 = '' THEN 0 ELSE similarity(COALESCE(s.artist, ''), $1) END AS score
+		FROM songs s
+		LEFT JOIN genres g ON s.genre_id = g.id
+		WHERE s.title % $2
+		ORDER BY score DESC
+		LIMIT $3
+	`
+	rows, err := db.Query(query, artist, title, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []TagMatch
+	for rows.Next() {
+		var m TagMatch
+		if err := rows.Scan(
+			&m.ID, &m.FingerprintHash, &m.FilePath, &m.Title, &m.Artist, &m.Album, &m.Year,
+			&m.GenreID, &m.Genre, &m.Duration, &m.Bitrate, &m.FileSize, &m.LastModified,
+			&m.Score,
+		); err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
+
+// GetPlaylistByImportKey retrieves a user's playlist previously imported from the given source.
+func GetPlaylistByImportKey(db *sql.DB, userID int, importKey string) (*models.Playlist, error) {
+	query := `
+		SELECT ` + playlistColumns + `, ` + playlistOwnerRole + `
+		FROM playlists
+		WHERE user_id = $1 AND import_key = $2
+	`
+	playlist := &models.Playlist{}
+	if err := scanPlaylist(db.QueryRow(query, userID, importKey), playlist); err != nil {
+		return nil, err
+	}
+	return playlist, nil
+}
+
+// CreateImportedPlaylist inserts a new playlist remembering the source it was imported from.
+func CreateImportedPlaylist(db *sql.DB, userID int, name string, importKey string) (*models.Playlist, error) {
+	query := `
+		INSERT INTO playlists (user_id, name, import_key)
+		VALUES ($1, $2, $3)
+		RETURNING ` + playlistColumns + `, ` + playlistOwnerRole
+	playlist := &models.Playlist{}
+	if err := scanPlaylist(db.QueryRow(query, userID, name, importKey), playlist); err != nil {
+		return nil, err
+	}
+	return playlist, nil
+}
+
+// ReplacePlaylistSongs replaces all songs of a playlist with the given songs, in order.
+// The user must own the playlist or be one of its editors, otherwise sql.ErrNoRows is
+// returned. Songs that were added or dropped are recorded in the playlist's activity.
+func ReplacePlaylistSongs(db *sql.DB, userID int, playlistID int, songIDs []int) error {
+	return ReplacePlaylist(db, userID, playlistID, "", songIDs)
+}
+
+// ReplacePlaylist renames a playlist, unless name is empty, and replaces its
+// songs like ReplacePlaylistSongs, in a single transaction and version.
+func ReplacePlaylist(db *sql.DB, userID int, playlistID int, name string, songIDs []int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := bumpPlaylistVersion(tx, userID, playlistID, 0); err != nil {
+		return err
+	}
+	if name != "" {
+		if _, err := tx.Exec("UPDATE playlists SET name = $1 WHERE id = $2", name, playlistID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			"INSERT INTO playlist_activity (playlist_id, user_id, action, details) VALUES ($1, $2, $3, $4)",
+			playlistID, userID, models.PlaylistActivityRename, name,
+		); err != nil {
+			return err
+		}
+	}
+
+	rows, err := tx.Query("DELETE FROM playlist_songs WHERE playlist_id = $1 RETURNING song_id", playlistID)
+	if err != nil {
+		return err
+	}
+	previous := make(map[int]int)
+	for rows.Next() {
+		var songID int
+		if err := rows.Scan(&songID); err != nil {
+			rows.Close()
+			return err
+		}
+		previous[songID]++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var added []int
+	for i, songID := range songIDs {
+		if _, err := tx.Exec("INSERT INTO playlist_songs (playlist_id, song_id, position) VALUES ($1, $2, $3)", playlistID, songID, i+1); err != nil {
+			return err
+		}
+		if previous[songID] > 0 {
+			previous[songID]--
+		} else {
+			added = append(added, songID)
+		}
+	}
+	var removed []int
+	for songID, count := range previous {
+		for ; count > 0; count-- {
+			removed = append(removed, songID)
+		}
+	}
+	sort.Ints(removed)
+
+	if err := logPlaylistSongActivity(tx, playlistID, userID, models.PlaylistActivityAdd, added); err != nil {
+		return err
+	}
+	if err := logPlaylistSongActivity(tx, playlistID, userID, models.PlaylistActivityRemove, removed); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
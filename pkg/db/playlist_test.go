@@ -0,0 +1,156 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"go-postgres-example/pkg/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyPlaylistOperations(t *testing.T) {
+	entries := []playlistEntry{
+		{ID: 1, SongID: 10, Position: 1},
+		{ID: 2, SongID: 20, Position: 2},
+		{ID: 3, SongID: 10, Position: 3},
+	}
+
+	t.Run("insert duplicates and move", func(t *testing.T) {
+		result, removed, err := applyPlaylistOperations(entries, []models.PlaylistOperation{
+			{Op: "insert", SongID: 20, Position: 1},
+			{Op: "move", EntryID: 3, Position: 2},
+			{Op: "insert", SongID: 30},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, removed)
+
+		var songs, ids []int
+		for _, e := range result {
+			songs = append(songs, e.SongID)
+			ids = append(ids, e.ID)
+		}
+		assert.Equal(t, []int{20, 10, 10, 20, 30}, songs)
+		assert.Equal(t, []int{0, 3, 1, 2, 0}, ids)
+		// The input is left untouched.
+		assert.Equal(t, 3, entries[2].ID)
+	})
+
+	t.Run("remove by entry and by song", func(t *testing.T) {
+		result, removed, err := applyPlaylistOperations(entries, []models.PlaylistOperation{
+			{Op: "remove", EntryID: 2},
+			{Op: "remove", SongID: 10},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, result)
+		assert.ElementsMatch(t, []int{1, 2, 3}, removed)
+	})
+
+	t.Run("invalid operations", func(t *testing.T) {
+		for _, op := range []models.PlaylistOperation{
+			{Op: "insert"},
+			{Op: "move", EntryID: 99, Position: 1},
+			{Op: "remove"},
+			{Op: "shuffle"},
+		} {
+			_, _, err := applyPlaylistOperations(entries, []models.PlaylistOperation{op})
+			assert.True(t, errors.Is(err, ErrInvalidPlaylistOperation), "op %q", op.Op)
+		}
+	})
+}
+
+func TestEditPlaylistSongs(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE playlists").WithArgs(4, 3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(4))
+	mock.ExpectQuery("SELECT id, song_id, position FROM playlist_songs").WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "song_id", "position"}).
+			AddRow(1, 10, 1).
+			AddRow(2, 20, 2))
+	mock.ExpectExec("UPDATE playlist_songs SET position").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE playlist_songs SET position").WithArgs(2, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO playlist_activity").WithArgs(4, 1, "move", "{20}").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	version, err := EditPlaylistSongs(conn, 1, 4, 3, []models.PlaylistOperation{{Op: "move", EntryID: 2, Position: 1}})
+	require.NoError(t, err)
+	assert.Equal(t, 4, version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEditPlaylistSongsVersionConflict(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE playlists").WithArgs(4, 3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectRollback()
+
+	_, err = EditPlaylistSongs(conn, 1, 4, 3, []models.PlaylistOperation{{Op: "insert", SongID: 10}})
+	assert.ErrorIs(t, err, ErrVersionConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReplacePlaylistSongsLogsChanges(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE playlists").WithArgs(4, 0, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(5))
+	mock.ExpectQuery("DELETE FROM playlist_songs").WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"song_id"}).AddRow(10).AddRow(20).AddRow(20))
+	mock.ExpectExec("INSERT INTO playlist_songs").WithArgs(4, 20, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO playlist_songs").WithArgs(4, 30, 2).WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("INSERT INTO playlist_activity").WithArgs(4, 2, "add", "{30}").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO playlist_activity").WithArgs(4, 2, "remove", "{10,20}").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	require.NoError(t, ReplacePlaylistSongs(conn, 2, 4, []int{20, 30}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReplacePlaylistRenamesInTheSameVersion(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE playlists").WithArgs(4, 0, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(5))
+	mock.ExpectExec("UPDATE playlists SET name").WithArgs("Road Trip", 4).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO playlist_activity").WithArgs(4, 2, "rename", "Road Trip").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("DELETE FROM playlist_songs").WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"song_id"}).AddRow(10))
+	mock.ExpectExec("INSERT INTO playlist_songs").WithArgs(4, 20, 1).WillReturnError(errors.New("connection lost"))
+	mock.ExpectRollback()
+
+	// The rename is rolled back with the songs
+	assert.Error(t, ReplacePlaylist(conn, 2, 4, "Road Trip", []int{20}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserPlaylistsSkipsOthersPrivatePlaylists(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Managing other users' playlists must not list their private ones.
+	mock.ExpectQuery(`WHERE \(playlists.user_id = \$1 OR playlists.visibility = 'public' OR EXISTS`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	playlists, err := GetUserPlaylists(conn, 7)
+	require.NoError(t, err)
+	assert.Empty(t, playlists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,188 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go-postgres-example/pkg/models"
+)
+
+// RecordPlay stores a completed listen. durationListened is in seconds; 0 means unknown.
+// It returns sql.ErrNoRows if the song does not exist.
+func RecordPlay(db *sql.DB, userID int, songID int, playedAt time.Time, durationListened int) error {
+	var duration sql.NullInt64
+	if durationListened > 0 {
+		duration = sql.NullInt64{Int64: int64(durationListened), Valid: true}
+	}
+
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO plays (user_id, song_id, played_at, duration_listened)
+		SELECT $1, id, $3, $4 FROM songs WHERE id = $2
+		RETURNING id
+	`, userID, songID, playedAt, duration).Scan(&id)
+	return err
+}
+
+// SetNowPlaying records the song a user has just started listening to.
+// It returns sql.ErrNoRows if the song does not exist.
+func SetNowPlaying(db *sql.DB, userID int, songID int) error {
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO now_playing (user_id, song_id, started_at)
+		SELECT $1, id, NOW() FROM songs WHERE id = $2
+		ON CONFLICT (user_id) DO UPDATE SET song_id = EXCLUDED.song_id, started_at = EXCLUDED.started_at
+		RETURNING song_id
+	`, userID, songID).Scan(&id)
+	return err
+}
+
+// GetRecentPlays retrieves a user's most recent listens, newest first.
+func GetRecentPlays(db *sql.DB, userID int, limit int) ([]models.Play, error) {
+	query := `
+		SELECT
+			p.id, p.played_at, COALESCE(p.duration_listened, 0),
+			s.id, s.fingerprint_hash, s.file_path, s.title, s.artist, s.album, s.year,
+			s.genre_id, g.name as genre, s.duration, s.bitrate, s.file_size, s.last_modified
+		FROM plays p
+		JOIN songs s ON s.id = p.song_id
+		LEFT JOIN genres g ON s.genre_id = g.id
+		WHERE p.user_id = $1
+		ORDER BY p.played_at DESC, p.id DESC
+		LIMIT $2
+	`
+	rows, err := db.Query(query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plays := []models.Play{}
+	for rows.Next() {
+		var play models.Play
+		if err := rows.Scan(
+			&play.ID, &play.PlayedAt, &play.DurationListened,
+			&play.Song.ID, &play.Song.FingerprintHash, &play.Song.FilePath, &play.Song.Title, &play.Song.Artist, &play.Song.Album, &play.Song.Year,
+			&play.Song.GenreID, &play.Song.Genre, &play.Song.Duration, &play.Song.Bitrate, &play.Song.FileSize, &play.Song.LastModified,
+		); err != nil {
+			return nil, err
+		}
+		plays = append(plays, play)
+	}
+	return plays, rows.Err()
+}
+
+// GetRecentlyPlayedSongIDs retrieves the IDs of the songs a user played last,
+// most recent first.
+func GetRecentlyPlayedSongIDs(db *sql.DB, userID int, limit int) ([]int, error) {
+	rows, err := db.Query(`
+		SELECT song_id
+		FROM plays
+		WHERE user_id = $1
+		GROUP BY song_id
+		ORDER BY MAX(played_at) DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetTopPlayedSongs retrieves a user's most played songs since the given time
+// (or of all time if since is zero).
+func GetTopPlayedSongs(db *sql.DB, userID int, since time.Time, limit int) ([]models.SongPlayCount, error) {
+	where, args := playsWindow(userID, since)
+	query := fmt.Sprintf(`
+		SELECT
+			s.id, s.fingerprint_hash, s.file_path, s.title, s.artist, s.album, s.year,
+			s.genre_id, g.name as genre, s.duration, s.bitrate, s.file_size, s.last_modified,
+			COUNT(*) AS play_count
+		FROM plays p
+		JOIN songs s ON s.id = p.song_id
+		LEFT JOIN genres g ON s.genre_id = g.id
+		WHERE %s
+		GROUP BY s.id, g.name
+		ORDER BY play_count DESC, MAX(p.played_at) DESC
+		LIMIT $%d
+	`, where, len(args)+1)
+	rows, err := db.Query(query, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	songs := []models.SongPlayCount{}
+	for rows.Next() {
+		var song models.SongPlayCount
+		if err := rows.Scan(
+			&song.ID, &song.FingerprintHash, &song.FilePath, &song.Title, &song.Artist, &song.Album, &song.Year,
+			&song.GenreID, &song.Genre, &song.Duration, &song.Bitrate, &song.FileSize, &song.LastModified,
+			&song.PlayCount,
+		); err != nil {
+			return nil, err
+		}
+		songs = append(songs, song)
+	}
+	return songs, rows.Err()
+}
+
+// GetTopPlayedArtists retrieves the artists a user played most since the given time.
+func GetTopPlayedArtists(db *sql.DB, userID int, since time.Time, limit int) ([]models.PlayCount, error) {
+	return getTopPlayed(db, "s.artist", userID, since, limit)
+}
+
+// GetTopPlayedGenres retrieves the genres a user played most since the given time.
+func GetTopPlayedGenres(db *sql.DB, userID int, since time.Time, limit int) ([]models.PlayCount, error) {
+	return getTopPlayed(db, "g.name", userID, since, limit)
+}
+
+// getTopPlayed counts a user's plays grouped by a (trusted) column expression.
+func getTopPlayed(db *sql.DB, column string, userID int, since time.Time, limit int) ([]models.PlayCount, error) {
+	where, args := playsWindow(userID, since)
+	query := fmt.Sprintf(`
+		SELECT %[1]s AS name, COUNT(*) AS play_count
+		FROM plays p
+		JOIN songs s ON s.id = p.song_id
+		LEFT JOIN genres g ON s.genre_id = g.id
+		WHERE %[2]s AND COALESCE(%[1]s, '') <> ''
+		GROUP BY %[1]s
+		ORDER BY play_count DESC, name ASC
+		LIMIT $%[3]d
+	`, column, where, len(args)+1)
+	rows, err := db.Query(query, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []models.PlayCount{}
+	for rows.Next() {
+		var count models.PlayCount
+		if err := rows.Scan(&count.Name, &count.PlayCount); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+// playsWindow builds the WHERE clause restricting plays to a user and, unless
+// since is zero, to plays after the given time.
+func playsWindow(userID int, since time.Time) (string, []interface{}) {
+	if since.IsZero() {
+		return "p.user_id = $1", []interface{}{userID}
+	}
+	return "p.user_id = $1 AND p.played_at >= $2", []interface{}{userID, since}
+}
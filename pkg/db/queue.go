@@ -0,0 +1,103 @@
+package db
+
+import (
+	"database/sql"
+
+	"go-postgres-example/pkg/models"
+)
+
+// SavePlayQueue replaces a user's play queue. If any of the songs does not exist,
+// nothing is saved and sql.ErrNoRows is returned.
+func SavePlayQueue(db *sql.DB, userID int, songIDs []int, currentIndex int, positionMs int64, client string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO play_queues (user_id, current_index, position_ms, client, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			current_index = EXCLUDED.current_index,
+			position_ms = EXCLUDED.position_ms,
+			client = EXCLUDED.client,
+			updated_at = EXCLUDED.updated_at
+	`, userID, currentIndex, positionMs, client)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM play_queue_songs WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+
+	if len(songIDs) > 0 {
+		result, err := tx.Exec(`
+			INSERT INTO play_queue_songs (user_id, position, song_id)
+			SELECT $1, q.ord, s.id
+			FROM unnest($2::int[]) WITH ORDINALITY AS q(id, ord)
+			JOIN songs s ON s.id = q.id
+		`, userID, intArrayToString(songIDs))
+		if err != nil {
+			return err
+		}
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if inserted != int64(len(songIDs)) {
+			return sql.ErrNoRows
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetPlayQueue retrieves a user's play queue, returning sql.ErrNoRows if they never saved one.
+func GetPlayQueue(db *sql.DB, userID int) (*models.PlayQueue, error) {
+	queue := &models.PlayQueue{Songs: []models.Song{}}
+	err := db.QueryRow(`
+		SELECT current_index, position_ms, client, updated_at
+		FROM play_queues
+		WHERE user_id = $1
+	`, userID).Scan(&queue.CurrentIndex, &queue.PositionMs, &queue.Client, &queue.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT
+			s.id, s.fingerprint_hash, s.file_path, s.title, s.artist, s.album, s.year,
+			s.genre_id, g.name as genre, s.duration, s.bitrate, s.file_size, s.last_modified
+		FROM play_queue_songs q
+		JOIN songs s ON s.id = q.song_id
+		LEFT JOIN genres g ON s.genre_id = g.id
+		WHERE q.user_id = $1
+		ORDER BY q.position ASC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var song models.Song
+		if err := rows.Scan(
+			&song.ID, &song.FingerprintHash, &song.FilePath, &song.Title, &song.Artist, &song.Album, &song.Year,
+			&song.GenreID, &song.Genre, &song.Duration, &song.Bitrate, &song.FileSize, &song.LastModified,
+		); err != nil {
+			return nil, err
+		}
+		queue.Songs = append(queue.Songs, song)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Songs deleted since the queue was saved drop out of it; keep the index in range.
+	if queue.CurrentIndex >= len(queue.Songs) {
+		queue.CurrentIndex = 0
+	}
+	return queue, nil
+}
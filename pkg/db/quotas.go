@@ -0,0 +1,126 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	"go-postgres-example/pkg/models"
+)
+
+// ErrQuotaExceeded is returned when a song would take a user over a quota.
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// uploadLockSpace is the first key of the advisory locks on users' uploads.
+const uploadLockSpace = 23
+
+// GetUsage returns the storage taken by the songs a user uploaded, counting
+// each song once, with their quotas. Quotas the user has none of are the
+// given defaults. If there is no such user, sql.ErrNoRows is returned.
+func GetUsage(db *sql.DB, userID int, defaultBytes int64, defaultTracks int) (*models.Usage, error) {
+	var usage models.Usage
+	var quotaBytes, quotaTracks sql.NullInt64
+	err := db.QueryRow(`
+		SELECT u.quota_bytes, u.quota_tracks, COUNT(s.id), COALESCE(SUM(s.file_size), 0)
+		FROM users u
+		LEFT JOIN song_uploads su ON su.user_id = u.id
+		LEFT JOIN songs s ON s.id = su.song_id
+		WHERE u.id = $1
+		GROUP BY u.id
+	`, userID).Scan(&quotaBytes, &quotaTracks, &usage.Tracks, &usage.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	usage.QuotaBytes = defaultBytes
+	if quotaBytes.Valid {
+		usage.QuotaBytes = quotaBytes.Int64
+		usage.UserQuotaBytes = &quotaBytes.Int64
+	}
+	usage.QuotaTracks = defaultTracks
+	if quotaTracks.Valid {
+		tracks := int(quotaTracks.Int64)
+		usage.QuotaTracks = tracks
+		usage.UserQuotaTracks = &tracks
+	}
+	return &usage, nil
+}
+
+// SetUserQuota replaces a user's quotas; nil ones follow the server defaults.
+// If there is no such user, sql.ErrNoRows is returned.
+func SetUserQuota(db *sql.DB, userID int, update models.QuotaUpdate) error {
+	result, err := db.Exec(
+		"UPDATE users SET quota_bytes = $2, quota_tracks = $3, updated_at = NOW() WHERE id = $1",
+		userID, update.QuotaBytes, update.QuotaTracks,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// LockUploads locks a user's uploads until the returned function is called, so
+// that checking their quota and recording the songs they upload happen one file
+// at a time, however many uploads are processed at once and on however many
+// servers. The lock is held by a transaction, so it cannot outlive its connection.
+func LockUploads(db *sql.DB, userID int) (func(), error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1, $2)", uploadLockSpace, userID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return func() { tx.Rollback() }, nil
+}
+
+// CheckQuota returns ErrQuotaExceeded if the song with the given file hash and
+// size would take a user over a quota. Songs the user already uploaded take
+// nothing more. Quotas the user has none of are the given defaults.
+func CheckQuota(db *sql.DB, userID int, hash string, size int64, defaultBytes int64, defaultTracks int) error {
+	usage, err := GetUsage(db, userID, defaultBytes, defaultTracks)
+	if err != nil {
+		return err
+	}
+	if usage.QuotaBytes == 0 && usage.QuotaTracks == 0 {
+		return nil
+	}
+	if owned, err := OwnsSong(db, userID, hash); err != nil || owned {
+		return err
+	}
+	if usage.QuotaBytes > 0 && usage.Bytes+size > usage.QuotaBytes {
+		return ErrQuotaExceeded
+	}
+	if usage.QuotaTracks > 0 && usage.Tracks+1 > usage.QuotaTracks {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// OwnsSong reports whether a user uploaded the song with the given file hash.
+func OwnsSong(db *sql.DB, userID int, hash string) (bool, error) {
+	var owns bool
+	err := db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM song_uploads su JOIN songs s ON s.id = su.song_id
+			WHERE su.user_id = $1 AND s.fingerprint_hash = $2
+		)
+	`, userID, hash).Scan(&owns)
+	return owns, err
+}
+
+// AddSongUpload records a user as an owner of the song with the given file
+// hash. Uploading a song again changes nothing.
+func AddSongUpload(db *sql.DB, userID int, hash string) error {
+	_, err := db.Exec(`
+		INSERT INTO song_uploads (user_id, song_id)
+		SELECT $1, id FROM songs WHERE fingerprint_hash = $2
+		ON CONFLICT DO NOTHING
+	`, userID, hash)
+	return err
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var usageColumns = []string{"quota_bytes", "quota_tracks", "tracks", "bytes"}
+
+func TestCheckQuota(t *testing.T) {
+	tests := []struct {
+		name  string
+		owned bool
+		size  int64
+		want  error
+	}{
+		{"fits", false, 400, nil},
+		{"too big", false, 600, ErrQuotaExceeded},
+		{"already uploaded", true, 600, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer conn.Close()
+
+			mock.ExpectQuery("FROM users u").
+				WithArgs(3).
+				WillReturnRows(sqlmock.NewRows(usageColumns).AddRow(1000, nil, 2, 500))
+			mock.ExpectQuery("SELECT EXISTS").
+				WithArgs(3, "abc").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(tt.owned))
+
+			assert.Equal(t, tt.want, CheckQuota(conn, 3, "abc", tt.size, 0, 0))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCheckQuotaUnlimited(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	mock.ExpectQuery("FROM users u").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(usageColumns).AddRow(nil, nil, 2, 500))
+
+	assert.NoError(t, CheckQuota(conn, 3, "abc", 1<<40, 0, 0))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
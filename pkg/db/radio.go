@@ -0,0 +1,199 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"go-postgres-example/pkg/models"
+	"strconv"
+	"strings"
+)
+
+// RadioCandidate is a song considered by the radio generator, along with the
+// requesting user's rating and the song's own embedding for the next step of the walk.
+type RadioCandidate struct {
+	SimilarSong
+	Rating    sql.NullInt64 `json:"rating"`
+	Embedding []float64     `json:"-"`
+}
+
+// intArrayToString converts a slice of ints to a PostgreSQL array literal.
+func intArrayToString(ids []int) string {
+	vals := make([]string, len(ids))
+	for i, id := range ids {
+		vals[i] = strconv.Itoa(id)
+	}
+	return fmt.Sprintf("{%s}", strings.Join(vals, ","))
+}
+
+// parseIntArray parses a PostgreSQL integer array literal such as "{1,2,3}".
+func parseIntArray(s string) ([]int, error) {
+	s = strings.Trim(s, "{}")
+	if s == "" {
+		return []int{}, nil
+	}
+	parts := strings.Split(s, ",")
+	ids := make([]int, len(parts))
+	for i, part := range parts {
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// scanEmbeddings reads rows containing a song ID and a text-encoded embedding.
+func scanEmbeddings(rows *sql.Rows) ([]int, [][]float64, error) {
+	defer rows.Close()
+
+	var ids []int
+	var embeddings [][]float64
+	for rows.Next() {
+		var id int
+		var embeddingStr string
+		if err := rows.Scan(&id, &embeddingStr); err != nil {
+			return nil, nil, err
+		}
+		vec, err := stringToVector(embeddingStr)
+		if err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, id)
+		embeddings = append(embeddings, vec)
+	}
+	return ids, embeddings, rows.Err()
+}
+
+// GetArtistEmbeddings retrieves the embeddings of songs whose artist matches the given name.
+func GetArtistEmbeddings(db *sql.DB, artist string, limit int) ([]int, [][]float64, error) {
+	query := `
+		SELECT se.song_id, se.embedding::text
+		FROM song_embeddings se
+		JOIN songs s ON se.song_id = s.id
+		WHERE s.artist ILIKE $1 ESCAPE '\'
+		LIMIT $2
+	`
+	rows, err := db.Query(query, escapeLike(artist), limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	return scanEmbeddings(rows)
+}
+
+// GetGenreEmbeddings retrieves the embeddings of songs in the given genre.
+func GetGenreEmbeddings(db *sql.DB, genre string, limit int) ([]int, [][]float64, error) {
+	query := `
+		SELECT se.song_id, se.embedding::text
+		FROM song_embeddings se
+		JOIN songs s ON se.song_id = s.id
+		JOIN genres g ON s.genre_id = g.id
+		WHERE g.name ILIKE $1 ESCAPE '\'
+		LIMIT $2
+	`
+	rows, err := db.Query(query, escapeLike(genre), limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	return scanEmbeddings(rows)
+}
+
+// GetSongEmbeddings retrieves the embeddings of the given songs, skipping songs without one.
+func GetSongEmbeddings(db *sql.DB, songIDs []int) ([]int, [][]float64, error) {
+	query := `
+		SELECT song_id, embedding::text
+		FROM song_embeddings
+		WHERE song_id = ANY($1::int[])
+	`
+	rows, err := db.Query(query, intArrayToString(songIDs))
+	if err != nil {
+		return nil, nil, err
+	}
+	return scanEmbeddings(rows)
+}
+
+// FindRadioCandidates finds the songs nearest to an embedding, skipping the excluded IDs
+// and any song the user rated 1 (an explicit "never play this").
+func FindRadioCandidates(db *sql.DB, userID int, queryEmbedding []float64, excludeIDs []int, limit int) ([]RadioCandidate, error) {
+	embeddingStr := vectorToString(queryEmbedding)
+
+	query := `
+		SELECT
+			s.id, s.fingerprint_hash, s.file_path, s.title, s.artist, s.album, s.year,
+			s.genre_id, g.name as genre, s.duration, s.bitrate, s.file_size, s.last_modified,
+			1 - (se.embedding <=> $1) AS similarity, us.rating, se.embedding::text
+		FROM song_embeddings se
+		JOIN songs s ON se.song_id = s.id
+		LEFT JOIN genres g ON s.genre_id = g.id
+		LEFT JOIN user_songs us ON us.song_id = s.id AND us.user_id = $2
+		WHERE NOT (se.song_id = ANY($3::int[]))
+		  AND (us.rating IS NULL OR us.rating > 1)
+		ORDER BY se.embedding <=> $1
+		LIMIT $4
+	`
+
+	rows, err := db.Query(query, embeddingStr, userID, intArrayToString(excludeIDs), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var songs []RadioCandidate
+	for rows.Next() {
+		var song RadioCandidate
+		var embeddingStr string
+		if err := rows.Scan(
+			&song.ID, &song.FingerprintHash, &song.FilePath, &song.Title, &song.Artist, &song.Album, &song.Year,
+			&song.GenreID, &song.Genre, &song.Duration, &song.Bitrate, &song.FileSize, &song.LastModified,
+			&song.Similarity, &song.Rating, &embeddingStr,
+		); err != nil {
+			return nil, err
+		}
+		if song.Embedding, err = stringToVector(embeddingStr); err != nil {
+			return nil, err
+		}
+		songs = append(songs, song)
+	}
+	return songs, nil
+}
+
+// GetTopSongsByArtist retrieves an artist's songs ordered by their average user rating.
+func GetTopSongsByArtist(db *sql.DB, artist string, limit int) ([]models.Song, error) {
+	query := `
+		SELECT
+			s.id, s.fingerprint_hash, s.file_path, s.title, s.artist, s.album, s.year,
+			s.genre_id, g.name as genre, s.duration, s.bitrate, s.file_size, s.last_modified
+		FROM songs s
+		LEFT JOIN genres g ON s.genre_id = g.id
+		LEFT JOIN user_songs us ON us.song_id = s.id
+		WHERE s.artist ILIKE $1 ESCAPE '\'
+		GROUP BY s.id, g.name
+		ORDER BY COALESCE(AVG(us.rating), 0) DESC, s.title ASC
+		LIMIT $2
+	`
+	rows, err := db.Query(query, escapeLike(artist), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var songs []models.Song
+	for rows.Next() {
+		var song models.Song
+		if err := rows.Scan(
+			&song.ID, &song.FingerprintHash, &song.FilePath, &song.Title, &song.Artist, &song.Album, &song.Year,
+			&song.GenreID, &song.Genre, &song.Duration, &song.Bitrate, &song.FileSize, &song.LastModified,
+		); err != nil {
+			return nil, err
+		}
+		songs = append(songs, song)
+	}
+	return songs, nil
+}
+
+// GetArtistNameByID retrieves an artist's name by its ID.
+func GetArtistNameByID(db *sql.DB, artistID int) (string, error) {
+	var name string
+	err := db.QueryRow("SELECT name FROM artists WHERE id = $1", artistID).Scan(&name)
+	return name, err
+}
@@ -0,0 +1,244 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	"go-postgres-example/pkg/models"
+)
+
+// ErrUnknownRole is returned when a user is given a role that does not exist.
+var ErrUnknownRole = errors.New("unknown role")
+
+// ErrRoleExists is returned when creating a role whose name is taken.
+var ErrRoleExists = errors.New("role already exists")
+
+// ErrBuiltInRole is returned when changing or deleting a built-in role.
+var ErrBuiltInRole = errors.New("built-in roles cannot be changed")
+
+// ErrRoleInUse is returned when deleting a role that users still have.
+var ErrRoleInUse = errors.New("role is in use")
+
+// permissionQuery reports whether user $1 has permission $2. Admins have every
+// permission.
+const permissionQuery = `
+	SELECT COALESCE(u.is_admin, FALSE)
+		OR EXISTS (SELECT 1 FROM role_permissions rp WHERE rp.role = u.role AND rp.permission = $2)
+	FROM users u
+	WHERE u.id = $1`
+
+// HasPermission reports whether a user has a permission through their role.
+func HasPermission(db *sql.DB, userID int, permission string) (bool, error) {
+	var allowed bool
+	err := db.QueryRow(permissionQuery, userID, permission).Scan(&allowed)
+	return allowed, err
+}
+
+// hasPermission returns an SQL condition that holds when the user passed as the
+// given query parameter (e.g. "$2") has the permission through their role.
+func hasPermission(param string, permission string) string {
+	return `EXISTS (
+		SELECT 1 FROM users u JOIN role_permissions rp ON rp.role = u.role
+		WHERE u.id = ` + param + ` AND rp.permission = '` + permission + `')`
+}
+
+// GetUserPermissions returns a user's role and the permissions they have, in
+// the order of models.Permissions. It agrees with HasPermission.
+func GetUserPermissions(db *sql.DB, userID int) (string, []string, error) {
+	var role string
+	var isAdmin bool
+	err := db.QueryRow(
+		"SELECT role, COALESCE(is_admin, FALSE) FROM users WHERE id = $1", userID,
+	).Scan(&role, &isAdmin)
+	if err != nil {
+		return "", nil, err
+	}
+
+	granted, err := getRolePermissions(db, role)
+	if err != nil {
+		return "", nil, err
+	}
+	permissions := []string{}
+	for _, p := range models.Permissions {
+		if isAdmin || granted[p] {
+			permissions = append(permissions, p)
+		}
+	}
+	return role, permissions, nil
+}
+
+func getRolePermissions(db *sql.DB, role string) (map[string]bool, error) {
+	rows, err := db.Query("SELECT permission FROM role_permissions WHERE role = $1", role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	granted := make(map[string]bool)
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		granted[p] = true
+	}
+	return granted, rows.Err()
+}
+
+// GetRoles returns every role with its permissions, built-in roles first.
+func GetRoles(db *sql.DB) ([]models.Role, error) {
+	rows, err := db.Query(`
+		SELECT r.name, r.description, r.built_in, rp.permission
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role = r.name
+		ORDER BY r.built_in DESC, r.name, rp.permission
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []models.Role{}
+	for rows.Next() {
+		var role models.Role
+		var permission sql.NullString
+		if err := rows.Scan(&role.Name, &role.Description, &role.BuiltIn, &permission); err != nil {
+			return nil, err
+		}
+		if n := len(roles); n == 0 || roles[n-1].Name != role.Name {
+			role.Permissions = []string{}
+			roles = append(roles, role)
+		}
+		if permission.Valid {
+			last := &roles[len(roles)-1]
+			last.Permissions = append(last.Permissions, permission.String)
+		}
+	}
+	return roles, rows.Err()
+}
+
+// CreateRole adds a role. If its name is taken, ErrRoleExists is returned.
+func CreateRole(db *sql.DB, role models.Role) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"INSERT INTO roles (name, description) VALUES ($1, $2) ON CONFLICT (name) DO NOTHING",
+		role.Name, role.Description,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrRoleExists
+	}
+	if err := insertRolePermissions(tx, role.Name, role.Permissions); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateRole replaces the description and permissions of a role added by an
+// admin. Built-in roles return ErrBuiltInRole.
+func UpdateRole(db *sql.DB, role models.Role) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockCustomRole(tx, role.Name); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE roles SET description = $2 WHERE name = $1", role.Name, role.Description); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM role_permissions WHERE role = $1", role.Name); err != nil {
+		return err
+	}
+	if err := insertRolePermissions(tx, role.Name, role.Permissions); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteRole deletes a role added by an admin. Built-in roles return
+// ErrBuiltInRole and roles users still have return ErrRoleInUse.
+func DeleteRole(db *sql.DB, name string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockCustomRole(tx, name); err != nil {
+		return err
+	}
+	var inUse bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE role = $1)", name).Scan(&inUse); err != nil {
+		return err
+	}
+	if inUse {
+		return ErrRoleInUse
+	}
+	if _, err := tx.Exec("DELETE FROM roles WHERE name = $1", name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// lockCustomRole locks a role for changes, returning sql.ErrNoRows if there is
+// no such role and ErrBuiltInRole if it is built in.
+func lockCustomRole(tx *sql.Tx, name string) error {
+	var builtIn bool
+	if err := tx.QueryRow("SELECT built_in FROM roles WHERE name = $1 FOR UPDATE", name).Scan(&builtIn); err != nil {
+		return err
+	}
+	if builtIn {
+		return ErrBuiltInRole
+	}
+	return nil
+}
+
+func insertRolePermissions(tx *sql.Tx, role string, permissions []string) error {
+	for _, p := range permissions {
+		if _, err := tx.Exec(
+			"INSERT INTO role_permissions (role, permission) VALUES ($1, $2) ON CONFLICT DO NOTHING", role, p,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// adminRoleCase returns an SQL expression for the role of a user whose admin
+// flag is set to the given query parameter, which may be NULL to keep it: the
+// admin role when it is true, and the user role instead of the admin role when
+// it is false.
+func adminRoleCase(param string, column string) string {
+	return `CASE WHEN ` + param + ` THEN '` + models.RoleAdmin + `'
+		WHEN NOT ` + param + ` AND ` + column + ` = '` + models.RoleAdmin + `' THEN '` + models.RoleUser + `'
+		ELSE ` + column + ` END`
+}
+
+// queryRower is implemented by both *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// CheckRole returns ErrUnknownRole if there is no role with the given name.
+func CheckRole(q queryRower, name string) error {
+	var exists bool
+	if err := q.QueryRow("SELECT EXISTS (SELECT 1 FROM roles WHERE name = $1)", name).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUnknownRole
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetUserPermissions(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	mock.ExpectQuery("SELECT role, COALESCE\\(is_admin, FALSE\\) FROM users").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"role", "is_admin"}).AddRow("user", false))
+	mock.ExpectQuery("SELECT permission FROM role_permissions").
+		WithArgs("user").
+		WillReturnRows(sqlmock.NewRows([]string{"permission"}).AddRow("share").AddRow("download").AddRow("upload"))
+
+	role, permissions, err := GetUserPermissions(conn, 4)
+	require.NoError(t, err)
+	assert.Equal(t, "user", role)
+	assert.Equal(t, []string{"upload", "download", "share"}, permissions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetRoles(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	mock.ExpectQuery("FROM roles r").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "description", "built_in", "permission"}).
+			AddRow("guest", "Only listens", true, nil).
+			AddRow("listener", "Listens", true, "download").
+			AddRow("dj", "Plays music", false, "download").
+			AddRow("dj", "Plays music", false, "jukebox"))
+
+	roles, err := GetRoles(conn)
+	require.NoError(t, err)
+	require.Len(t, roles, 3)
+	assert.Equal(t, []string{}, roles[0].Permissions)
+	assert.Equal(t, []string{"download"}, roles[1].Permissions)
+	assert.Equal(t, "dj", roles[2].Name)
+	assert.False(t, roles[2].BuiltIn)
+	assert.Equal(t, []string{"download", "jukebox"}, roles[2].Permissions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteRoleInUse(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT built_in FROM roles").
+		WithArgs("dj").
+		WillReturnRows(sqlmock.NewRows([]string{"built_in"}).AddRow(false))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM users WHERE role").
+		WithArgs("dj").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	assert.ErrorIs(t, DeleteRole(conn, "dj"), ErrRoleInUse)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
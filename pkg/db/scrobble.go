@@ -0,0 +1,146 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"go-postgres-example/pkg/models"
+)
+
+// CreateScrobbleAccount links a scrobbling account to a user. Linking the same
+// API URL again replaces the stored token and re-enables the account.
+func CreateScrobbleAccount(db *sql.DB, userID int, service string, apiURL string, username string, tokenEncrypted string) (*models.ScrobbleAccount, error) {
+	query := `
+		INSERT INTO scrobble_accounts (user_id, service, api_url, username, token_encrypted)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, api_url)
+		DO UPDATE SET service = EXCLUDED.service, username = EXCLUDED.username,
+			token_encrypted = EXCLUDED.token_encrypted, enabled = TRUE
+		RETURNING id, user_id, service, api_url, username, token_encrypted, enabled, created_at
+	`
+	account := &models.ScrobbleAccount{}
+	err := db.QueryRow(query, userID, service, apiURL, username, tokenEncrypted).Scan(
+		&account.ID, &account.UserID, &account.Service, &account.APIURL, &account.Username,
+		&account.TokenEncrypted, &account.Enabled, &account.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// GetScrobbleAccounts retrieves a user's linked scrobbling accounts with the
+// number of listens still queued for each.
+func GetScrobbleAccounts(db *sql.DB, userID int) ([]models.ScrobbleAccount, error) {
+	query := `
+		SELECT a.id, a.user_id, a.service, a.api_url, a.username, a.token_encrypted, a.enabled, a.created_at,
+			(SELECT COUNT(*) FROM scrobble_queue q WHERE q.account_id = a.id)
+		FROM scrobble_accounts a
+		WHERE a.user_id = $1
+		ORDER BY a.created_at
+	`
+	rows, err := db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []models.ScrobbleAccount{}
+	for rows.Next() {
+		var account models.ScrobbleAccount
+		if err := rows.Scan(
+			&account.ID, &account.UserID, &account.Service, &account.APIURL, &account.Username,
+			&account.TokenEncrypted, &account.Enabled, &account.CreatedAt, &account.Pending,
+		); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, rows.Err()
+}
+
+// DeleteScrobbleAccount unlinks a scrobbling account, checking for user ownership.
+// Queued listens for the account are dropped with it.
+func DeleteScrobbleAccount(db *sql.DB, userID int, accountID int) error {
+	result, err := db.Exec("DELETE FROM scrobble_accounts WHERE id = $1 AND user_id = $2", accountID, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Not found or not authorized
+	}
+	return nil
+}
+
+// DisableScrobbleAccount stops forwarding to an account, e.g. after its token was rejected.
+func DisableScrobbleAccount(db *sql.DB, accountID int) error {
+	_, err := db.Exec("UPDATE scrobble_accounts SET enabled = FALSE WHERE id = $1", accountID)
+	return err
+}
+
+// EnqueueScrobble queues a listen or now playing notification for every enabled
+// scrobbling account of the user. It returns the number of rows queued.
+func EnqueueScrobble(db *sql.DB, userID int, songID int, listenType string, listenedAt time.Time) (int64, error) {
+	result, err := db.Exec(`
+		INSERT INTO scrobble_queue (account_id, song_id, listen_type, listened_at)
+		SELECT a.id, $2, $3, $4 FROM scrobble_accounts a
+		WHERE a.user_id = $1 AND a.enabled
+	`, userID, songID, listenType, listenedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetDueScrobbles retrieves queued scrobbles whose next attempt is due, oldest first.
+func GetDueScrobbles(db *sql.DB, limit int) ([]models.QueuedScrobble, error) {
+	query := `
+		SELECT q.id, q.account_id, a.api_url, a.token_encrypted, q.listen_type, q.listened_at, q.attempts,
+			s.id, s.title, s.artist, s.album, s.duration
+		FROM scrobble_queue q
+		JOIN scrobble_accounts a ON a.id = q.account_id
+		JOIN songs s ON s.id = q.song_id
+		WHERE q.next_attempt_at <= NOW() AND a.enabled
+		ORDER BY q.listened_at, q.id
+		LIMIT $1
+	`
+	rows, err := db.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.QueuedScrobble
+	for rows.Next() {
+		var item models.QueuedScrobble
+		if err := rows.Scan(
+			&item.ID, &item.AccountID, &item.APIURL, &item.TokenEncrypted, &item.ListenType, &item.ListenedAt, &item.Attempts,
+			&item.Song.ID, &item.Song.Title, &item.Song.Artist, &item.Song.Album, &item.Song.Duration,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// DeleteQueuedScrobble removes a scrobble from the queue once it has been
+// submitted or given up on.
+func DeleteQueuedScrobble(db *sql.DB, id int) error {
+	_, err := db.Exec("DELETE FROM scrobble_queue WHERE id = $1", id)
+	return err
+}
+
+// RescheduleQueuedScrobble records a failed attempt and when to try again.
+func RescheduleQueuedScrobble(db *sql.DB, id int, nextAttempt time.Time, lastError string) error {
+	_, err := db.Exec(`
+		UPDATE scrobble_queue
+		SET attempts = attempts + 1, next_attempt_at = $2, last_error = $3
+		WHERE id = $1
+	`, id, nextAttempt, lastError)
+	return err
+}
@@ -0,0 +1,183 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-postgres-example/pkg/models"
+)
+
+// ErrInvalidRefreshToken is returned for a refresh token that is unknown or
+// whose session has expired or been revoked.
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+// ErrRefreshTokenReused is returned when a refresh token that was already
+// rotated is presented again. Its session is revoked, as the token has leaked.
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+
+// ErrAccountDisabled is returned when starting or refreshing a session of a
+// disabled account.
+var ErrAccountDisabled = errors.New("account is disabled")
+
+// CreateSession starts a login session for a user with its first refresh token
+// and returns the session ID. Disabled accounts get ErrAccountDisabled.
+func CreateSession(db *sql.DB, userID int, deviceName, ipAddress, refreshTokenHash string, expiresAt time.Time) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var sessionID int
+	err = tx.QueryRow(
+		"INSERT INTO sessions (user_id, device_name, ip_address, expires_at) SELECT id, $2, $3, $4 FROM users WHERE id = $1 AND NOT disabled RETURNING id",
+		userID, deviceName, ipAddress, expiresAt,
+	).Scan(&sessionID)
+	if err == sql.ErrNoRows {
+		return 0, ErrAccountDisabled
+	}
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("INSERT INTO refresh_tokens (token_hash, session_id) VALUES ($1, $2)", refreshTokenHash, sessionID); err != nil {
+		return 0, err
+	}
+	return sessionID, tx.Commit()
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one, extending its
+// session to expiresAt, and returns the session. Reusing a rotated token
+// revokes the session and returns ErrRefreshTokenReused; disabled accounts get
+// ErrAccountDisabled.
+func RotateRefreshToken(db *sql.DB, oldHash, newHash, ipAddress string, expiresAt time.Time) (*models.Session, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var session models.Session
+	var used, revoked, disabled bool
+	err = tx.QueryRow(`
+		SELECT s.id, s.user_id, rt.used_at IS NOT NULL, s.revoked_at IS NOT NULL OR s.expires_at <= NOW(), u.disabled
+		FROM refresh_tokens rt
+		JOIN sessions s ON s.id = rt.session_id
+		JOIN users u ON u.id = s.user_id
+		WHERE rt.token_hash = $1
+		FOR UPDATE OF rt, s
+	`, oldHash).Scan(&session.ID, &session.UserID, &used, &revoked, &disabled)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrInvalidRefreshToken
+	}
+	if used {
+		if _, err := tx.Exec("UPDATE sessions SET revoked_at = NOW() WHERE id = $1", session.ID); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	if disabled {
+		return nil, ErrAccountDisabled
+	}
+
+	if _, err := tx.Exec("UPDATE refresh_tokens SET used_at = NOW() WHERE token_hash = $1", oldHash); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("INSERT INTO refresh_tokens (token_hash, session_id) VALUES ($1, $2)", newHash, session.ID); err != nil {
+		return nil, err
+	}
+	err = tx.QueryRow(`
+		UPDATE sessions SET last_used_at = NOW(), ip_address = $2, expires_at = $3
+		WHERE id = $1
+		RETURNING device_name, ip_address, created_at, last_used_at, expires_at
+	`, session.ID, ipAddress, expiresAt).Scan(&session.DeviceName, &session.IPAddress, &session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &session, tx.Commit()
+}
+
+// RevokeSessionByRefreshToken revokes the session a refresh token belongs to.
+// If the token is unknown, sql.ErrNoRows is returned.
+func RevokeSessionByRefreshToken(db *sql.DB, refreshTokenHash string) error {
+	result, err := db.Exec(`
+		UPDATE sessions SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id = (SELECT session_id FROM refresh_tokens WHERE token_hash = $1)
+	`, refreshTokenHash)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetUserSessions retrieves a user's active sessions, most recently used first.
+func GetUserSessions(db *sql.DB, userID int) ([]models.Session, error) {
+	rows, err := db.Query(`
+		SELECT id, user_id, device_name, ip_address, created_at, last_used_at, expires_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_used_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		if err := rows.Scan(
+			&session.ID, &session.UserID, &session.DeviceName, &session.IPAddress,
+			&session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeSession revokes one of a user's active sessions. If the session does
+// not exist, belongs to another user or is already revoked, sql.ErrNoRows is returned.
+func RevokeSession(db *sql.DB, userID int, sessionID int) error {
+	result, err := db.Exec(
+		"UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL",
+		sessionID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RevokeOtherSessions revokes all of a user's sessions except exceptID (0 to
+// revoke them all) and returns how many were revoked.
+func RevokeOtherSessions(db *sql.DB, userID int, exceptID int) (int64, error) {
+	result, err := db.Exec(
+		"UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL",
+		userID, exceptID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	expiresAt := time.Now().Add(time.Hour)
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM refresh_tokens rt").
+		WithArgs("old").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "used", "revoked", "disabled"}).AddRow(7, 1, false, false, false))
+	mock.ExpectExec("UPDATE refresh_tokens SET used_at").WithArgs("old").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO refresh_tokens").WithArgs("new", 7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE sessions SET last_used_at").
+		WithArgs(7, "10.0.0.2", expiresAt).
+		WillReturnRows(sqlmock.NewRows([]string{"device_name", "ip_address", "created_at", "last_used_at", "expires_at"}).
+			AddRow("Phone", "10.0.0.2", time.Now(), time.Now(), expiresAt))
+	mock.ExpectCommit()
+
+	session, err := RotateRefreshToken(conn, "old", "new", "10.0.0.2", expiresAt)
+	require.NoError(t, err)
+	assert.Equal(t, 7, session.ID)
+	assert.Equal(t, 1, session.UserID)
+	assert.Equal(t, "Phone", session.DeviceName)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRotateRefreshTokenReuseRevokesSession(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM refresh_tokens rt").
+		WithArgs("old").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "used", "revoked", "disabled"}).AddRow(7, 1, true, false, false))
+	mock.ExpectExec("UPDATE sessions SET revoked_at").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	_, err = RotateRefreshToken(conn, "old", "new", "10.0.0.2", time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRotateRefreshTokenRevokedSession(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM refresh_tokens rt").
+		WithArgs("old").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "used", "revoked", "disabled"}).AddRow(7, 1, true, true, false))
+	mock.ExpectRollback()
+
+	_, err = RotateRefreshToken(conn, "old", "new", "10.0.0.2", time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRotateRefreshTokenDisabledAccount(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("JOIN users u").
+		WithArgs("old").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "used", "revoked", "disabled"}).AddRow(7, 1, false, false, true))
+	mock.ExpectRollback()
+
+	_, err = RotateRefreshToken(conn, "old", "new", "10.0.0.2", time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, ErrAccountDisabled)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,230 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"go-postgres-example/pkg/models"
+)
+
+// shareColumns lists the columns read by scanShare, in order.
+const shareColumns = `sh.id, sh.user_id, sh.token, sh.item_type, sh.description, sh.password_hash,
+	sh.allow_download, sh.expires_at, sh.visit_count, sh.last_visited_at, sh.created_at,
+	(SELECT COALESCE(array_agg(si.item_id ORDER BY si.position), '{}') FROM share_items si WHERE si.share_id = sh.id)::text`
+
+// scanShare scans a row selected with shareColumns into a share.
+func scanShare(row rowScanner, share *models.Share) error {
+	var passwordHash sql.NullString
+	var itemIDs string
+	if err := row.Scan(
+		&share.ID, &share.UserID, &share.Token, &share.Type, &share.Description, &passwordHash,
+		&share.AllowDownload, &share.ExpiresAt, &share.VisitCount, &share.LastVisitedAt, &share.CreatedAt,
+		&itemIDs,
+	); err != nil {
+		return err
+	}
+	share.PasswordHash = passwordHash.String
+	share.HasPassword = passwordHash.Valid
+	ids, err := parseIntArray(itemIDs)
+	if err != nil {
+		return err
+	}
+	share.ItemIDs = ids
+	return nil
+}
+
+// CreateShare inserts a share and its items, filling in its ID and creation time.
+func CreateShare(db *sql.DB, share *models.Share) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO shares (user_id, token, item_type, description, password_hash, allow_download, expires_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)
+		RETURNING id, created_at
+	`, share.UserID, share.Token, share.Type, share.Description, share.PasswordHash, share.AllowDownload, share.ExpiresAt).
+		Scan(&share.ID, &share.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO share_items (share_id, position, item_id)
+		SELECT $1, q.ord, q.id FROM unnest($2::int[]) WITH ORDINALITY AS q(id, ord)
+	`, share.ID, intArrayToString(share.ItemIDs))
+	if err != nil {
+		return err
+	}
+
+	share.HasPassword = share.PasswordHash != ""
+	return tx.Commit()
+}
+
+// GetUserShares retrieves all shares created by a user, newest first.
+func GetUserShares(db *sql.DB, userID int) ([]models.Share, error) {
+	rows, err := db.Query(`
+		SELECT `+shareColumns+`
+		FROM shares sh
+		WHERE sh.user_id = $1
+		ORDER BY sh.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []models.Share{}
+	for rows.Next() {
+		var share models.Share
+		if err := scanShare(rows, &share); err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	return shares, rows.Err()
+}
+
+// GetShareByID retrieves a share, checking that the user created it.
+func GetShareByID(db *sql.DB, userID int, shareID int) (*models.Share, error) {
+	share := &models.Share{}
+	err := scanShare(db.QueryRow(`
+		SELECT `+shareColumns+`
+		FROM shares sh
+		WHERE sh.id = $1 AND sh.user_id = $2
+	`, shareID, userID), share)
+	if err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// GetShareByToken retrieves a share by the token in its public link.
+func GetShareByToken(db *sql.DB, token string) (*models.Share, error) {
+	share := &models.Share{}
+	err := scanShare(db.QueryRow(`
+		SELECT `+shareColumns+`
+		FROM shares sh
+		WHERE sh.token = $1
+	`, token), share)
+	if err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// UpdateShare saves a share's description, password, download permission and expiry,
+// checking that the user created it.
+func UpdateShare(db *sql.DB, share *models.Share) error {
+	res, err := db.Exec(`
+		UPDATE shares
+		SET description = $1, password_hash = NULLIF($2, ''), allow_download = $3, expires_at = $4
+		WHERE id = $5 AND user_id = $6
+	`, share.Description, share.PasswordHash, share.AllowDownload, share.ExpiresAt, share.ID, share.UserID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Not found or not authorized
+	}
+	share.HasPassword = share.PasswordHash != ""
+	return nil
+}
+
+// DeleteShare deletes a share, checking that the user created it.
+func DeleteShare(db *sql.DB, userID int, shareID int) error {
+	res, err := db.Exec("DELETE FROM shares WHERE id = $1 AND user_id = $2", shareID, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Not found or not authorized
+	}
+	return nil
+}
+
+// RecordShareVisit counts a visit to a share's public page.
+func RecordShareVisit(db *sql.DB, shareID int) error {
+	_, err := db.Exec("UPDATE shares SET visit_count = visit_count + 1, last_visited_at = NOW() WHERE id = $1", shareID)
+	return err
+}
+
+// GetShareSongs retrieves the songs a share gives access to, in order. Songs deleted
+// since the share was created are left out.
+func GetShareSongs(db *sql.DB, share *models.Share) ([]models.Song, error) {
+	var query string
+	switch share.Type {
+	case models.ShareSong:
+		query = `
+			SELECT
+				s.id, s.fingerprint_hash, s.file_path, s.title, s.artist, s.album, s.year,
+				s.genre_id, g.name as genre, s.duration, s.bitrate, s.file_size, s.last_modified
+			FROM share_items si
+			JOIN songs s ON s.id = si.item_id
+			LEFT JOIN genres g ON s.genre_id = g.id
+			WHERE si.share_id = $1
+			ORDER BY si.position ASC
+		`
+	case models.ShareAlbum:
+		query = `
+			SELECT
+				s.id, s.fingerprint_hash, s.file_path, s.title, s.artist, s.album, s.year,
+				s.genre_id, g.name as genre, s.duration, s.bitrate, s.file_size, s.last_modified
+			FROM share_items si
+			JOIN songs s ON s.album_id = si.item_id
+			LEFT JOIN genres g ON s.genre_id = g.id
+			WHERE si.share_id = $1
+			ORDER BY si.position ASC, s.id ASC
+		`
+	case models.SharePlaylist:
+		songs := []models.Song{}
+		for _, playlistID := range share.ItemIDs {
+			playlist, err := GetPlaylistByID(db, share.UserID, playlistID)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			entries, err := GetPlaylistContents(db, playlist)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				songs = append(songs, entry.Song)
+			}
+		}
+		return songs, nil
+	default:
+		return nil, fmt.Errorf("unknown share type %q", share.Type)
+	}
+
+	rows, err := db.Query(query, share.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	songs := []models.Song{}
+	for rows.Next() {
+		var song models.Song
+		if err := rows.Scan(
+			&song.ID, &song.FingerprintHash, &song.FilePath, &song.Title, &song.Artist, &song.Album, &song.Year,
+			&song.GenreID, &song.Genre, &song.Duration, &song.Bitrate, &song.FileSize, &song.LastModified,
+		); err != nil {
+			return nil, err
+		}
+		songs = append(songs, song)
+	}
+	return songs, rows.Err()
+}
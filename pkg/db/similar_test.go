@@ -171,3 +171,24 @@ func TestFindSimilarSongs(t *testing.T) {
 	}
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestRadioSeedsMatchWildcardsLiterally(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`WHERE s.artist ILIKE \$1 ESCAPE`).
+		WithArgs(`100\% Band\_`, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"song_id", "embedding"}))
+	mock.ExpectQuery(`WHERE g.name ILIKE \$1 ESCAPE`).
+		WithArgs(`\%`, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"song_id", "embedding"}))
+
+	_, _, err = GetArtistEmbeddings(db, "100% Band_", 10)
+	assert.NoError(t, err)
+	_, _, err = GetGenreEmbeddings(db, "%", 10)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,353 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"go-postgres-example/pkg/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRuleDepth bounds how deeply smart playlist groups may be nested.
+const maxRuleDepth = 8
+
+// smartStringFields maps string rule fields to their SQL expressions.
+var smartStringFields = map[string]string{
+	"title":  "s.title",
+	"artist": "s.artist",
+	"album":  "s.album",
+	"genre":  "g.name",
+}
+
+// smartNumberFields maps numeric rule fields to their SQL expressions.
+var smartNumberFields = map[string]string{
+	"year":       "s.year",
+	"rating":     "COALESCE(us.rating, 0)",
+	"duration":   "s.duration",
+	"play_count": "(SELECT COUNT(*) FROM plays p WHERE p.song_id = s.id AND p.user_id = us.user_id)",
+}
+
+// smartDateFields maps date rule fields to their SQL expressions.
+var smartDateFields = map[string]string{
+	"added": "us.created_at",
+}
+
+// smartSortColumns whitelists the sort options of a smart playlist.
+var smartSortColumns = map[string]string{
+	"title":      "s.title",
+	"artist":     "s.artist",
+	"album":      "s.album",
+	"genre":      "g.name",
+	"year":       "s.year",
+	"rating":     "COALESCE(us.rating, 0)",
+	"duration":   "s.duration",
+	"added":      "us.created_at",
+	"play_count": smartNumberFields["play_count"],
+	"random":     "RANDOM()",
+}
+
+// smartCompiler accumulates positional arguments while compiling a rule tree.
+type smartCompiler struct {
+	args []interface{}
+}
+
+func (c *smartCompiler) arg(v interface{}) string {
+	c.args = append(c.args, v)
+	return fmt.Sprintf("$%d", len(c.args))
+}
+
+// CompileSmartRules compiles a smart playlist definition into a query over the
+// given user's library, returning the SQL and its arguments.
+func CompileSmartRules(rules *models.SmartRules, userID int) (string, []interface{}, error) {
+	c := &smartCompiler{}
+	userArg := c.arg(userID)
+
+	where, err := c.compile(rules.Match, 0)
+	if err != nil {
+		return "", nil, err
+	}
+
+	query := `
+		SELECT
+			s.id, s.fingerprint_hash, s.file_path, s.title, s.artist, s.album, s.year,
+			s.genre_id, g.name as genre, s.duration, s.bitrate, s.file_size, s.last_modified
+		FROM songs s
+		LEFT JOIN genres g ON s.genre_id = g.id
+		JOIN user_songs us ON s.id = us.song_id
+		WHERE us.user_id = ` + userArg + ` AND ` + where
+
+	if rules.Sort != "" {
+		column, ok := smartSortColumns[rules.Sort]
+		if !ok {
+			return "", nil, fmt.Errorf("unsupported sort %q", rules.Sort)
+		}
+		direction := " ASC"
+		switch strings.ToLower(rules.Order) {
+		case "", "asc":
+		case "desc":
+			direction = " DESC"
+		default:
+			return "", nil, fmt.Errorf("unsupported order %q", rules.Order)
+		}
+		if rules.Sort == "random" {
+			direction = ""
+		}
+		query += fmt.Sprintf(" ORDER BY %s%s, s.id", column, direction)
+	} else {
+		query += " ORDER BY s.artist, s.album, s.title, s.id"
+	}
+
+	if rules.Limit < 0 {
+		return "", nil, fmt.Errorf("limit must not be negative")
+	}
+	if rules.Limit > 0 {
+		query += " LIMIT " + c.arg(rules.Limit)
+	}
+
+	return query, c.args, nil
+}
+
+// compile turns a single rule node into a SQL boolean expression.
+func (c *smartCompiler) compile(rule models.SmartRule, depth int) (string, error) {
+	if depth > maxRuleDepth {
+		return "", fmt.Errorf("rules are nested too deeply")
+	}
+
+	if rule.Field == "" {
+		return c.compileGroup(rule, depth)
+	}
+
+	if expr, ok := smartStringFields[rule.Field]; ok {
+		return c.compileString(expr, rule)
+	}
+	if expr, ok := smartNumberFields[rule.Field]; ok {
+		return c.compileNumber(expr, rule)
+	}
+	if expr, ok := smartDateFields[rule.Field]; ok {
+		return c.compileDate(expr, rule)
+	}
+	if rule.Field == "similarity" {
+		return c.compileSimilarity(rule)
+	}
+	return "", fmt.Errorf("unsupported field %q", rule.Field)
+}
+
+func (c *smartCompiler) compileGroup(rule models.SmartRule, depth int) (string, error) {
+	var joiner string
+	switch strings.ToLower(rule.Op) {
+	case "and", "":
+		joiner = " AND "
+	case "or":
+		joiner = " OR "
+	default:
+		return "", fmt.Errorf("unsupported group operator %q", rule.Op)
+	}
+
+	// An empty group matches everything, so "all songs sorted by X" is a valid playlist.
+	if len(rule.Rules) == 0 {
+		return "TRUE", nil
+	}
+
+	parts := make([]string, 0, len(rule.Rules))
+	for _, child := range rule.Rules {
+		part, err := c.compile(child, depth+1)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, part)
+	}
+	return "(" + strings.Join(parts, joiner) + ")", nil
+}
+
+func (c *smartCompiler) compileString(expr string, rule models.SmartRule) (string, error) {
+	value, ok := rule.Value.(string)
+	if !ok {
+		return "", fmt.Errorf("field %q requires a string value", rule.Field)
+	}
+
+	switch rule.Operator {
+	case "is":
+		return fmt.Sprintf("%s ILIKE %s", expr, c.arg(escapeLike(value))), nil
+	case "is_not":
+		return fmt.Sprintf("COALESCE(%s, '') NOT ILIKE %s", expr, c.arg(escapeLike(value))), nil
+	case "contains":
+		return fmt.Sprintf("%s ILIKE %s", expr, c.arg("%"+escapeLike(value)+"%")), nil
+	case "not_contains":
+		return fmt.Sprintf("COALESCE(%s, '') NOT ILIKE %s", expr, c.arg("%"+escapeLike(value)+"%")), nil
+	case "starts_with":
+		return fmt.Sprintf("%s ILIKE %s", expr, c.arg(escapeLike(value)+"%")), nil
+	case "ends_with":
+		return fmt.Sprintf("%s ILIKE %s", expr, c.arg("%"+escapeLike(value))), nil
+	}
+	return "", fmt.Errorf("unsupported operator %q for field %q", rule.Operator, rule.Field)
+}
+
+func (c *smartCompiler) compileNumber(expr string, rule models.SmartRule) (string, error) {
+	if rule.Operator == "between" {
+		bounds, ok := rule.Value.([]interface{})
+		if !ok || len(bounds) != 2 {
+			return "", fmt.Errorf("operator \"between\" requires a [min, max] value")
+		}
+		lo, err := toNumber(bounds[0])
+		if err != nil {
+			return "", err
+		}
+		hi, err := toNumber(bounds[1])
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s BETWEEN %s AND %s", expr, c.arg(lo), c.arg(hi)), nil
+	}
+
+	sqlOp, ok := comparisonOperators[rule.Operator]
+	if !ok {
+		return "", fmt.Errorf("unsupported operator %q for field %q", rule.Operator, rule.Field)
+	}
+	value, err := toNumber(rule.Value)
+	if err != nil {
+		return "", fmt.Errorf("field %q: %w", rule.Field, err)
+	}
+	return fmt.Sprintf("%s %s %s", expr, sqlOp, c.arg(value)), nil
+}
+
+func (c *smartCompiler) compileDate(expr string, rule models.SmartRule) (string, error) {
+	switch rule.Operator {
+	case "in_the_last", "not_in_the_last":
+		days, err := toNumber(rule.Value)
+		if err != nil {
+			return "", fmt.Errorf("field %q: %w", rule.Field, err)
+		}
+		op := ">="
+		if rule.Operator == "not_in_the_last" {
+			op = "<"
+		}
+		return fmt.Sprintf("%s %s NOW() - make_interval(days => %s)", expr, op, c.arg(int(days))), nil
+	case "before", "after":
+		str, ok := rule.Value.(string)
+		if !ok {
+			return "", fmt.Errorf("field %q requires a date value", rule.Field)
+		}
+		date, err := parseRuleDate(str)
+		if err != nil {
+			return "", err
+		}
+		op := "<"
+		if rule.Operator == "after" {
+			op = ">"
+		}
+		return fmt.Sprintf("%s %s %s", expr, op, c.arg(date)), nil
+	}
+	return "", fmt.Errorf("unsupported operator %q for field %q", rule.Operator, rule.Field)
+}
+
+func (c *smartCompiler) compileSimilarity(rule models.SmartRule) (string, error) {
+	if rule.SongID <= 0 {
+		return "", fmt.Errorf("field \"similarity\" requires a song_id")
+	}
+	sqlOp, ok := comparisonOperators[rule.Operator]
+	if !ok || rule.Operator == "is" || rule.Operator == "is_not" {
+		return "", fmt.Errorf("unsupported operator %q for field \"similarity\"", rule.Operator)
+	}
+	value, err := toNumber(rule.Value)
+	if err != nil {
+		return "", fmt.Errorf("field \"similarity\": %w", err)
+	}
+	expr := fmt.Sprintf(
+		"1 - ((SELECT embedding FROM song_embeddings WHERE song_id = s.id) <=> (SELECT embedding FROM song_embeddings WHERE song_id = %s))",
+		c.arg(rule.SongID),
+	)
+	return fmt.Sprintf("%s %s %s", expr, sqlOp, c.arg(value)), nil
+}
+
+var comparisonOperators = map[string]string{
+	"is":     "=",
+	"is_not": "<>",
+	"gt":     ">",
+	"gte":    ">=",
+	"lt":     "<",
+	"lte":    "<=",
+}
+
+// toNumber accepts JSON numbers as well as numeric strings.
+func toNumber(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case int:
+		return float64(n), nil
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number %q", n)
+		}
+		return f, nil
+	}
+	return 0, fmt.Errorf("a numeric value is required")
+}
+
+// parseRuleDate accepts either a full RFC 3339 timestamp or a plain date.
+func parseRuleDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+	return t, nil
+}
+
+// escapeLike escapes the LIKE wildcards in a user-supplied value.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// GetSmartPlaylistSongs evaluates a smart playlist against its owner's library.
+func GetSmartPlaylistSongs(db *sql.DB, playlist *models.Playlist) ([]models.PlaylistSong, error) {
+	if playlist.Rules == nil {
+		return nil, fmt.Errorf("playlist %d is not a smart playlist", playlist.ID)
+	}
+	query, args, err := CompileSmartRules(playlist.Rules, playlist.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var songs []models.PlaylistSong
+	for rows.Next() {
+		var song models.PlaylistSong
+		if err := rows.Scan(
+			&song.Song.ID, &song.Song.FingerprintHash, &song.Song.FilePath, &song.Song.Title, &song.Song.Artist, &song.Song.Album, &song.Song.Year,
+			&song.Song.GenreID, &song.Song.Genre, &song.Song.Duration, &song.Song.Bitrate, &song.Song.FileSize, &song.Song.LastModified,
+		); err != nil {
+			return nil, err
+		}
+		song.Position = len(songs) + 1
+		songs = append(songs, song)
+	}
+	return songs, nil
+}
+
+// CreateSmartPlaylist inserts a new smart playlist for a user.
+func CreateSmartPlaylist(db *sql.DB, userID int, name string, rules *models.SmartRules) (*models.Playlist, error) {
+	rulesJSON, err := json.Marshal(rules)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+		INSERT INTO playlists (user_id, name, rules)
+		VALUES ($1, $2, $3)
+		RETURNING ` + playlistColumns + `, ` + playlistOwnerRole
+	playlist := &models.Playlist{}
+	if err := scanPlaylist(db.QueryRow(query, userID, name, rulesJSON), playlist); err != nil {
+		return nil, err
+	}
+	return playlist, nil
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go-postgres-example/pkg/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func parseRules(t *testing.T, s string) *models.SmartRules {
+	t.Helper()
+	rules := &models.SmartRules{}
+	if err := json.Unmarshal([]byte(s), rules); err != nil {
+		t.Fatalf("failed to parse rules: %v", err)
+	}
+	return rules
+}
+
+func TestCompileSmartRules(t *testing.T) {
+	t.Run("nested groups with sort and limit", func(t *testing.T) {
+		rules := parseRules(t, `{
+			"match": {"op": "and", "rules": [
+				{"field": "genre", "operator": "is", "value": "Jazz"},
+				{"op": "or", "rules": [
+					{"field": "year", "operator": "between", "value": [1955, 1965]},
+					{"field": "rating", "operator": "gte", "value": 4}
+				]}
+			]},
+			"sort": "year", "order": "desc", "limit": 25
+		}`)
+
+		query, args, err := CompileSmartRules(rules, 7)
+		assert.NoError(t, err)
+		assert.Contains(t, query, "us.user_id = $1 AND (g.name ILIKE $2 AND (s.year BETWEEN $3 AND $4 OR COALESCE(us.rating, 0) >= $5))")
+		assert.Contains(t, query, "ORDER BY s.year DESC, s.id LIMIT $6")
+		assert.Equal(t, []interface{}{7, "Jazz", 1955.0, 1965.0, 4.0, 25}, args)
+	})
+
+	t.Run("string operators escape wildcards", func(t *testing.T) {
+		rules := parseRules(t, `{"match": {"rules": [{"field": "title", "operator": "contains", "value": "100%"}]}}`)
+
+		query, args, err := CompileSmartRules(rules, 1)
+		assert.NoError(t, err)
+		assert.Contains(t, query, "s.title ILIKE $2")
+		assert.Equal(t, `%100\%%`, args[1])
+	})
+
+	t.Run("date, play count and similarity", func(t *testing.T) {
+		rules := parseRules(t, `{"match": {"op": "and", "rules": [
+			{"field": "added", "operator": "in_the_last", "value": 30},
+			{"field": "play_count", "operator": "lt", "value": 3},
+			{"field": "similarity", "song_id": 12, "operator": "gte", "value": 0.8}
+		]}, "sort": "random"}`)
+
+		query, args, err := CompileSmartRules(rules, 1)
+		assert.NoError(t, err)
+		assert.Contains(t, query, "us.created_at >= NOW() - make_interval(days => $2)")
+		assert.Contains(t, query, "FROM plays p")
+		assert.Contains(t, query, "song_id = $4)) >= $5")
+		assert.Contains(t, query, "ORDER BY RANDOM(), s.id")
+		assert.Equal(t, []interface{}{1, 30, 3.0, 12, 0.8}, args)
+	})
+
+	t.Run("empty group matches everything", func(t *testing.T) {
+		query, args, err := CompileSmartRules(&models.SmartRules{}, 1)
+		assert.NoError(t, err)
+		assert.Contains(t, query, "AND TRUE")
+		assert.Equal(t, []interface{}{1}, args)
+	})
+
+	invalid := map[string]string{
+		"unknown field":       `{"match": {"rules": [{"field": "mood", "operator": "is", "value": "x"}]}}`,
+		"unknown operator":    `{"match": {"rules": [{"field": "artist", "operator": "gt", "value": "x"}]}}`,
+		"wrong value type":    `{"match": {"rules": [{"field": "year", "operator": "is", "value": "abc"}]}}`,
+		"bad group operator":  `{"match": {"op": "xor", "rules": []}}`,
+		"similarity w/o song": `{"match": {"rules": [{"field": "similarity", "operator": "gt", "value": 0.5}]}}`,
+		"unknown sort":        `{"match": {}, "sort": "file_path"}`,
+		"negative limit":      `{"match": {}, "limit": -1}`,
+		"bad date":            `{"match": {"rules": [{"field": "added", "operator": "before", "value": "yesterday"}]}}`,
+	}
+	for name, raw := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := CompileSmartRules(parseRules(t, raw), 1)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestGetSmartPlaylistSongs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	playlist := &models.Playlist{
+		ID:     3,
+		UserID: 7,
+		Smart:  true,
+		Rules:  parseRules(t, `{"match": {"rules": [{"field": "artist", "operator": "is", "value": "Miles Davis"}]}}`),
+	}
+
+	rows := sqlmock.NewRows([]string{
+		"id", "fingerprint_hash", "file_path", "title", "artist", "album", "year",
+		"genre_id", "genre", "duration", "bitrate", "file_size", "last_modified",
+	}).
+		AddRow(1, "h1", "/p/1", "So What", "Miles Davis", "Kind of Blue", 1959, 1, "Jazz", 562, 320, 100, time.Now()).
+		AddRow(2, "h2", "/p/2", "Freddie Freeloader", "Miles Davis", "Kind of Blue", 1959, 1, "Jazz", 589, 320, 100, time.Now())
+
+	mock.ExpectQuery("SELECT (.+) FROM songs s").
+		WithArgs(7, "Miles Davis").
+		WillReturnRows(rows)
+
+	songs, err := GetSmartPlaylistSongs(db, playlist)
+	assert.NoError(t, err)
+	assert.Len(t, songs, 2)
+	assert.Equal(t, 1, songs[0].Position)
+	assert.Equal(t, 2, songs[1].Position)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,183 @@
+package db
+
+import (
+	"database/sql"
+
+	"go-postgres-example/pkg/models"
+)
+
+// GetTOTP returns a user's encrypted TOTP secret ("" when none was set up) and
+// whether two-factor authentication is on.
+func GetTOTP(db *sql.DB, userID int) (string, bool, error) {
+	var secret sql.NullString
+	var enabled bool
+	err := db.QueryRow("SELECT totp_secret, totp_enabled FROM users WHERE id = $1", userID).Scan(&secret, &enabled)
+	return secret.String, enabled, err
+}
+
+// SetTOTPSecret stores a new encrypted TOTP secret for a user who is setting up
+// two-factor authentication. It is not used for logins until EnableTOTP.
+func SetTOTPSecret(db *sql.DB, userID int, encryptedSecret string) error {
+	_, err := db.Exec(
+		"UPDATE users SET totp_secret = $2, totp_enabled = FALSE, totp_last_step = 0 WHERE id = $1 AND NOT totp_enabled",
+		userID, encryptedSecret,
+	)
+	return err
+}
+
+// EnableTOTP turns on two-factor authentication after the user confirmed a code
+// for the given time step, replacing their recovery codes.
+func EnableTOTP(db *sql.DB, userID int, step int64, recoveryCodeHashes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE users SET totp_enabled = TRUE, totp_last_step = $2 WHERE id = $1", userID, step); err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DisableTOTP turns off two-factor authentication and deletes the secret and
+// recovery codes.
+func DisableTOTP(db *sql.DB, userID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = 0 WHERE id = $1", userID); err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(tx, userID, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseTOTPStep records that a code of the given time step was used. It returns
+// false if a code of that step or a later one was used already.
+func UseTOTPStep(db *sql.DB, userID int, step int64) (bool, error) {
+	result, err := db.Exec("UPDATE users SET totp_last_step = $2 WHERE id = $1 AND totp_last_step < $2", userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// UseRecoveryCode marks one of a user's unused recovery codes as used. It
+// returns false if no such code exists.
+func UseRecoveryCode(db *sql.DB, userID int, codeHash string) (bool, error) {
+	result, err := db.Exec(
+		"UPDATE recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL",
+		userID, codeHash,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// CountRecoveryCodes returns how many unused recovery codes a user has left.
+func CountRecoveryCodes(db *sql.DB, userID int) (int, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL", userID).Scan(&count)
+	return count, err
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID int, codeHashes []string) error {
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec("INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateAppPassword stores an encrypted app password and returns it without
+// the password.
+func CreateAppPassword(db *sql.DB, userID int, name, encryptedPassword string) (*models.AppPassword, error) {
+	appPassword := models.AppPassword{Name: name}
+	err := db.QueryRow(
+		"INSERT INTO app_passwords (user_id, name, password_encrypted) VALUES ($1, $2, $3) RETURNING id, created_at",
+		userID, name, encryptedPassword,
+	).Scan(&appPassword.ID, &appPassword.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &appPassword, nil
+}
+
+// GetAppPasswords lists a user's app passwords, newest first, without the passwords.
+func GetAppPasswords(db *sql.DB, userID int) ([]models.AppPassword, error) {
+	rows, err := db.Query(
+		"SELECT id, name, created_at, last_used_at FROM app_passwords WHERE user_id = $1 ORDER BY created_at DESC, id DESC",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appPasswords := []models.AppPassword{}
+	for rows.Next() {
+		var appPassword models.AppPassword
+		if err := rows.Scan(&appPassword.ID, &appPassword.Name, &appPassword.CreatedAt, &appPassword.LastUsedAt); err != nil {
+			return nil, err
+		}
+		appPasswords = append(appPasswords, appPassword)
+	}
+	return appPasswords, rows.Err()
+}
+
+// GetAppPasswordSecrets returns a user's encrypted app passwords by ID.
+func GetAppPasswordSecrets(db *sql.DB, userID int) (map[int]string, error) {
+	rows, err := db.Query("SELECT id, password_encrypted FROM app_passwords WHERE user_id = $1", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	secrets := map[int]string{}
+	for rows.Next() {
+		var id int
+		var secret string
+		if err := rows.Scan(&id, &secret); err != nil {
+			return nil, err
+		}
+		secrets[id] = secret
+	}
+	return secrets, rows.Err()
+}
+
+// TouchAppPassword records that an app password was used.
+func TouchAppPassword(db *sql.DB, appPasswordID int) error {
+	_, err := db.Exec("UPDATE app_passwords SET last_used_at = NOW() WHERE id = $1", appPasswordID)
+	return err
+}
+
+// DeleteAppPassword deletes one of a user's app passwords. If it does not
+// exist or belongs to another user, sql.ErrNoRows is returned.
+func DeleteAppPassword(db *sql.DB, userID int, appPasswordID int) error {
+	result, err := db.Exec("DELETE FROM app_passwords WHERE id = $1 AND user_id = $2", appPasswordID, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	"go-postgres-example/pkg/models"
+)
+
+// GetUsernameByID retrieves a user's username by their ID.
+func GetUsernameByID(db *sql.DB, userID int) (string, error) {
+	var username string
+	err := db.QueryRow("SELECT username FROM users WHERE id = $1", userID).Scan(&username)
+	return username, err
+}
+
+// HasJukeboxRole reports whether a user's role lets them control the jukebox.
+// Admins always may.
+func HasJukeboxRole(db *sql.DB, userID int) (bool, error) {
+	return HasPermission(db, userID, models.PermissionJukebox)
+}
+
+// HasDownloadRole reports whether a user's role lets them download songs and
+// archives. Admins always may.
+func HasDownloadRole(db *sql.DB, userID int) (bool, error) {
+	return HasPermission(db, userID, models.PermissionDownload)
+}
+
+// GetUserIDByUsername retrieves a user's ID by their username.
+func GetUserIDByUsername(db *sql.DB, username string) (int, error) {
+	var userID int
+	err := db.QueryRow("SELECT id FROM users WHERE username = $1", username).Scan(&userID)
+	return userID, err
+}
+
+// GetUserStatusByUsername retrieves a user's ID by their username and whether
+// their account is disabled.
+func GetUserStatusByUsername(db *sql.DB, username string) (int, bool, error) {
+	var userID int
+	var disabled bool
+	err := db.QueryRow("SELECT id, disabled FROM users WHERE username = $1", username).Scan(&userID, &disabled)
+	return userID, disabled, err
+}
+
+// ErrUsernameTaken is returned when a single sign-on user's username belongs to
+// an account that may not be linked to them.
+var ErrUsernameTaken = errors.New("username is already in use")
+
+// GetOIDCUser finds the user signed in through OpenID Connect as subject: the
+// user linked to it, or else an unlinked user with the given verified email, or
+// with the given username if linkByUsername is set, who is then linked to it.
+// Pass an empty email unless the provider verified it. If there is no such user,
+// ErrUsernameTaken is returned when another account has the username and
+// sql.ErrNoRows otherwise.
+func GetOIDCUser(db *sql.DB, subject, username, email string, linkByUsername bool) (int, error) {
+	var userID int
+	err := db.QueryRow("SELECT id FROM users WHERE oidc_subject = $1", subject).Scan(&userID)
+	if err != sql.ErrNoRows {
+		return userID, err
+	}
+
+	err = db.QueryRow(`
+		UPDATE users SET oidc_subject = $1
+		WHERE id = (
+			SELECT id FROM users
+			WHERE oidc_subject IS NULL AND (($4 AND username = $2) OR ($3 <> '' AND email = $3))
+			ORDER BY $3 <> '' AND email = $3 DESC
+			LIMIT 1
+		)
+		RETURNING id
+	`, subject, username, email, linkByUsername).Scan(&userID)
+	if err != sql.ErrNoRows {
+		return userID, err
+	}
+
+	var taken bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE username = $1)", username).Scan(&taken); err != nil {
+		return 0, err
+	}
+	if taken {
+		return 0, ErrUsernameTaken
+	}
+	return 0, sql.ErrNoRows
+}
+
+// CreateOIDCUser creates a user linked to an OpenID Connect subject. The user
+// has no password and can only sign in through the provider.
+func CreateOIDCUser(db *sql.DB, subject, username, email string, isAdmin bool) (int, error) {
+	role := models.RoleUser
+	if isAdmin {
+		role = models.RoleAdmin
+	}
+	var userID int
+	err := db.QueryRow(
+		"INSERT INTO users (username, email, password_hash, is_admin, role, oidc_subject) VALUES ($1, $2, '', $3, $4, $5) RETURNING id",
+		username, email, isAdmin, role, subject,
+	).Scan(&userID)
+	return userID, err
+}
+
+// CreatePasswordlessUser creates a user who signs in through a reverse proxy
+// and has no password of their own.
+func CreatePasswordlessUser(db *sql.DB, username, email string) (int, error) {
+	var userID int
+	err := db.QueryRow(
+		"INSERT INTO users (username, email, password_hash) VALUES ($1, $2, '') RETURNING id",
+		username, email,
+	).Scan(&userID)
+	return userID, err
+}
+
+// SetUserAdmin grants or revokes a user's admin rights, giving them the admin
+// role or taking it away in favour of the user role. Revoking the rights of the
+// last active admin returns ErrLastAdmin.
+func SetUserAdmin(db *sql.DB, userID int, isAdmin bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if !isAdmin {
+		if err := checkNotLastAdmin(tx, userID); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("UPDATE users SET is_admin = $2, role = "+adminRoleCase("$2", "role")+" WHERE id = $1", userID, isAdmin); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,220 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"go-postgres-example/pkg/models"
+)
+
+// ErrLastAdmin is returned when a change would leave no active admin.
+var ErrLastAdmin = errors.New("cannot remove the last admin")
+
+const userColumns = `id, username, email, created_at, COALESCE(is_admin, FALSE), role, totp_enabled, disabled`
+
+func scanUser(row interface{ Scan(...interface{}) error }, user *models.User) error {
+	return row.Scan(
+		&user.ID, &user.Username, &user.Email, &user.CreatedAt,
+		&user.IsAdmin, &user.Role, &user.TwoFactorEnabled, &user.Disabled,
+	)
+}
+
+// ListUsers returns a page of users ordered by username.
+func ListUsers(db *sql.DB, opts models.UserListOptions) (*models.UserList, error) {
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(opts.Query) + "%"
+
+	list := &models.UserList{Users: []models.User{}}
+	if err := db.QueryRow("SELECT COUNT(*) FROM users WHERE username ILIKE $1 OR email ILIKE $1", pattern).Scan(&list.Total); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(
+		"SELECT "+userColumns+" FROM users WHERE username ILIKE $1 OR email ILIKE $1 ORDER BY username LIMIT $2 OFFSET $3",
+		pattern, opts.Limit, opts.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user models.User
+		if err := scanUser(rows, &user); err != nil {
+			return nil, err
+		}
+		list.Users = append(list.Users, user)
+	}
+	return list, rows.Err()
+}
+
+// GetUser retrieves a user by ID. If there is no such user, sql.ErrNoRows is returned.
+func GetUser(db *sql.DB, userID int) (*models.User, error) {
+	var user models.User
+	if err := scanUser(db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = $1", userID), &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateUser changes a user's email, admin flag and role. The admin flag
+// follows the role when both are given. Revoking the rights of the last active
+// admin returns ErrLastAdmin, and a role that does not exist ErrUnknownRole.
+func UpdateUser(db *sql.DB, userID int, update models.UserUpdate) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if update.Role != nil {
+		if err := CheckRole(tx, *update.Role); err != nil {
+			return err
+		}
+		isAdmin := *update.Role == models.RoleAdmin
+		update.IsAdmin = &isAdmin
+	}
+	if update.IsAdmin != nil && !*update.IsAdmin {
+		if err := checkNotLastAdmin(tx, userID); err != nil {
+			return err
+		}
+	}
+	result, err := tx.Exec(
+		"UPDATE users SET email = COALESCE($2, email), is_admin = COALESCE($3, is_admin), role = COALESCE($4, "+adminRoleCase("$3", "role")+"), updated_at = NOW() WHERE id = $1",
+		userID, update.Email, update.IsAdmin, update.Role,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// SetUserPassword replaces a user's password hash and logs them out everywhere.
+func SetUserPassword(db *sql.DB, userID int, passwordHash string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE users SET password_hash = $2, updated_at = NOW() WHERE id = $1", userID, passwordHash)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec("UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetUserDisabled disables or enables a user's account. Disabling logs the user
+// out everywhere; disabling the last active admin returns ErrLastAdmin.
+func SetUserDisabled(db *sql.DB, userID int, disabled bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if disabled {
+		if err := checkNotLastAdmin(tx, userID); err != nil {
+			return err
+		}
+	}
+	result, err := tx.Exec("UPDATE users SET disabled = $2, updated_at = NOW() WHERE id = $1", userID, disabled)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	if disabled {
+		if _, err := tx.Exec("UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteUser deletes a user and everything they own. Their playlists are given
+// to reassignTo instead when it is not 0. Deleting the last active admin
+// returns ErrLastAdmin.
+func DeleteUser(db *sql.DB, userID int, reassignTo int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkNotLastAdmin(tx, userID); err != nil {
+		return err
+	}
+	if reassignTo != 0 {
+		// The new owner no longer needs to be a collaborator, and import keys
+		// are dropped as they are only unique per owner.
+		if _, err := tx.Exec(
+			"DELETE FROM playlist_collaborators WHERE user_id = $2 AND playlist_id IN (SELECT id FROM playlists WHERE user_id = $1)",
+			userID, reassignTo,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			"UPDATE playlists SET user_id = $2, import_key = NULL, updated_at = NOW() WHERE user_id = $1",
+			userID, reassignTo,
+		); err != nil {
+			return err
+		}
+	}
+	result, err := tx.Exec("DELETE FROM users WHERE id = $1", userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// checkNotLastAdmin returns ErrLastAdmin if the user is the only active admin.
+// The admins are locked so concurrent changes cannot remove them all.
+func checkNotLastAdmin(tx *sql.Tx, userID int) error {
+	rows, err := tx.Query("SELECT id FROM users WHERE is_admin AND NOT disabled FOR UPDATE")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	isAdmin, others := false, 0
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		if id == userID {
+			isAdmin = true
+		} else {
+			others++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if isAdmin && others == 0 {
+		return ErrLastAdmin
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+// Package download names downloaded songs and streams zip archives of albums
+// and playlists straight to the client, without temporary files.
+package download
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"go-postgres-example/pkg/models"
+	"go-postgres-example/pkg/playlistio"
+)
+
+// SafeName strips characters that are not safe in a file name, returning
+// fallback when nothing is left.
+func SafeName(name, fallback string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20, strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	// Leading dots would hide the file or walk up a directory in an archive.
+	cleaned = strings.TrimLeft(cleaned, ".")
+	if cleaned == "" {
+		return fallback
+	}
+	return cleaned
+}
+
+// FileName returns the name a song is downloaded as, "Artist - Title.ext" from
+// its tags, falling back to the name of the stored file when it has no title.
+func FileName(song *models.Song) string {
+	ext := filepath.Ext(song.FilePath)
+	if strings.TrimSpace(song.Title) == "" {
+		return SafeName(filepath.Base(song.FilePath), "song"+ext)
+	}
+	name := song.Title
+	if strings.TrimSpace(song.Artist) != "" {
+		name = song.Artist + " - " + song.Title
+	}
+	return SafeName(name, "song") + ext
+}
+
+// EntryPath returns the path of a song inside an archive,
+// "Artist/Album/NN - Title.ext", where NN is its 1-based position in the
+// download padded to width digits.
+func EntryPath(song *models.Song, position int, width int) string {
+	title := song.Title
+	if strings.TrimSpace(title) == "" {
+		title = strings.TrimSuffix(filepath.Base(song.FilePath), filepath.Ext(song.FilePath))
+	}
+	return path.Join(
+		SafeName(song.Artist, "Unknown Artist"),
+		SafeName(song.Album, "Unknown Album"),
+		fmt.Sprintf("%0*d - %s%s", width, position, SafeName(title, "Track"), filepath.Ext(song.FilePath)),
+	)
+}
+
+// Attachment returns a Content-Disposition header value that downloads the
+// response as filename, encoding non-ASCII names as RFC 2231 requires.
+func Attachment(filename string) string {
+	if value := mime.FormatMediaType("attachment", map[string]string{"filename": filename}); value != "" {
+		return value
+	}
+	return "attachment"
+}
+
+// WriteZip writes a zip archive of the songs to w, followed by an M3U8
+// playlist named after the archive that lists them in order. Audio is already
+// compressed, so files are stored rather than deflated; each one is copied
+// from disk as it is written. A song whose file cannot be read ends the
+// archive with an error, as the response has usually been started by then.
+func WriteZip(w io.Writer, name string, songs []models.Song) error {
+	zw := zip.NewWriter(w)
+
+	width := len(fmt.Sprint(len(songs)))
+	if width < 2 {
+		width = 2
+	}
+
+	playlist := &playlistio.Playlist{Title: name}
+	for i := range songs {
+		// Positions keep entry names unique even when a playlist repeats a song.
+		song := &songs[i]
+		entry := EntryPath(song, i+1, width)
+		if err := addFile(zw, entry, song); err != nil {
+			return err
+		}
+		playlist.Entries = append(playlist.Entries, playlistio.Entry{
+			Location: entry,
+			Title:    song.Title,
+			Artist:   song.Artist,
+			Album:    song.Album,
+			Duration: song.Duration,
+		})
+	}
+
+	m3u, err := zw.Create(SafeName(name, "playlist") + "." + playlistio.FormatM3U8)
+	if err != nil {
+		return err
+	}
+	if err := playlistio.Write(playlistio.FormatM3U8, m3u, playlist); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// addFile copies a song's file into the archive under the given name.
+func addFile(zw *zip.Writer, name string, song *models.Song) error {
+	file, err := os.Open(song.FilePath)
+	if err != nil {
+		return fmt.Errorf("song %d: %w", song.ID, err)
+	}
+	defer file.Close()
+
+	header := &zip.FileHeader{Name: name, Method: zip.Store}
+	if !song.LastModified.IsZero() {
+		header.Modified = song.LastModified
+	}
+	entry, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(entry, file); err != nil {
+		return fmt.Errorf("song %d: %w", song.ID, err)
+	}
+	return nil
+}
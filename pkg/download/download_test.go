@@ -0,0 +1,79 @@
+package download
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-postgres-example/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileName(t *testing.T) {
+	assert.Equal(t, "Miles Davis - So What.flac", FileName(&models.Song{FilePath: "/uploads/abc.flac", Title: "So What", Artist: "Miles Davis"}))
+	assert.Equal(t, "AC_DC - Back in Black.mp3", FileName(&models.Song{FilePath: "/uploads/def.mp3", Title: "Back in Black", Artist: "AC/DC"}))
+	assert.Equal(t, "def.mp3", FileName(&models.Song{FilePath: "/uploads/def.mp3"}))
+}
+
+func TestEntryPath(t *testing.T) {
+	song := &models.Song{FilePath: "/uploads/abc.flac", Title: "So What", Artist: "Miles Davis", Album: "Kind of Blue"}
+	assert.Equal(t, "Miles Davis/Kind of Blue/01 - So What.flac", EntryPath(song, 1, 2))
+	assert.Equal(t, "Unknown Artist/Unknown Album/007 - abc.flac", EntryPath(&models.Song{FilePath: "/uploads/abc.flac"}, 7, 3))
+	assert.Equal(t, "Unknown Artist/_ Album/01 - x.mp3", EntryPath(&models.Song{FilePath: "a.mp3", Title: "x", Album: "../ Album"}, 1, 2))
+}
+
+func TestAttachment(t *testing.T) {
+	assert.Equal(t, `attachment; filename="Kind of Blue.zip"`, Attachment("Kind of Blue.zip"))
+	assert.Equal(t, "attachment; filename*=utf-8''Bj%C3%B6rk.zip", Attachment("Björk.zip"))
+}
+
+func TestWriteZip(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "abc.flac")
+	second := filepath.Join(dir, "def.mp3")
+	require.NoError(t, os.WriteFile(first, []byte("flac audio"), 0o644))
+	require.NoError(t, os.WriteFile(second, []byte("mp3 audio"), 0o644))
+
+	songs := []models.Song{
+		{ID: 1, FilePath: first, Title: "So What", Artist: "Miles Davis", Album: "Kind of Blue", Duration: 562},
+		{ID: 2, FilePath: second, Title: "Freddie Freeloader", Artist: "Miles Davis", Album: "Kind of Blue", Duration: 589},
+		{ID: 1, FilePath: first, Title: "So What", Artist: "Miles Davis", Album: "Kind of Blue", Duration: 562},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteZip(&buf, "Road Trip", songs))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	var names []string
+	contents := map[string]string{}
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		rc, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		contents[f.Name] = string(data)
+	}
+
+	assert.Equal(t, []string{
+		"Miles Davis/Kind of Blue/01 - So What.flac",
+		"Miles Davis/Kind of Blue/02 - Freddie Freeloader.mp3",
+		"Miles Davis/Kind of Blue/03 - So What.flac",
+		"Road Trip.m3u8",
+	}, names)
+	assert.Equal(t, "flac audio", contents[names[0]])
+	assert.Contains(t, contents["Road Trip.m3u8"], "#EXTINF:589,Miles Davis - Freddie Freeloader\n#EXTALB:Kind of Blue\nMiles Davis/Kind of Blue/02 - Freddie Freeloader.mp3\n")
+}
+
+func TestWriteZipMissingFile(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteZip(&buf, "Broken", []models.Song{{ID: 4, FilePath: filepath.Join(t.TempDir(), "missing.mp3")}})
+	assert.ErrorContains(t, err, "song 4")
+}
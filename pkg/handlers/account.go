@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/middleware"
+	"go-postgres-example/pkg/notify"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// passwordResetTokenBytes is the number of random bytes in a password reset token.
+const passwordResetTokenBytes = 32
+
+// UpdateMeRequest represents the request body for changing the current user's
+// profile; omitted fields are kept. Changing the email needs the current password,
+// since the email receives password reset links.
+type UpdateMeRequest struct {
+	Email           *string `json:"email"`
+	DisplayName     *string `json:"display_name"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+// ChangePasswordRequest represents the request body for changing the current
+// user's password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// DeleteMeRequest represents the request body for deleting the current user's account
+type DeleteMeRequest struct {
+	Password string `json:"password"`
+}
+
+// PasswordResetRequest represents the request body for requesting a password
+// reset link by username or email
+type PasswordResetRequest struct {
+	Login string `json:"login"`
+}
+
+// PasswordResetConfirmRequest represents the request body for setting a new
+// password with a reset token
+type PasswordResetConfirmRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// UpdateMe changes the current user's email or display name and returns the user.
+func (h *AuthHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	var req UpdateMeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Email != nil {
+		email := strings.TrimSpace(*req.Email)
+		if !strings.Contains(email, "@") {
+			http.Error(w, "Invalid email", http.StatusBadRequest)
+			return
+		}
+		req.Email = &email
+	}
+	if req.DisplayName != nil {
+		name := strings.TrimSpace(*req.DisplayName)
+		if len(name) > 255 {
+			http.Error(w, "Display name is too long", http.StatusBadRequest)
+			return
+		}
+		req.DisplayName = &name
+	}
+	if req.Email != nil && !h.checkCurrentPassword(w, userID, req.CurrentPassword) {
+		return
+	}
+
+	if err := db.UpdateProfile(h.DB, userID, req.Email, req.DisplayName); err != nil {
+		switch {
+		case errors.Is(err, db.ErrEmailTaken):
+			http.Error(w, "Email is already in use", http.StatusConflict)
+		case err == sql.ErrNoRows:
+			http.Error(w, "User not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to update user", http.StatusInternalServerError)
+		}
+		return
+	}
+	h.Me(w, r)
+}
+
+// ChangePassword changes the current user's password after checking their
+// current one. Their other sessions are logged out.
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	sessionID, _ := middleware.GetSessionIDFromContext(r.Context())
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.NewPassword == "" {
+		http.Error(w, "New password is required", http.StatusBadRequest)
+		return
+	}
+	if !h.checkCurrentPassword(w, userID, req.CurrentPassword) {
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
+	if err := db.ChangePassword(h.DB, userID, hashedPassword, sessionID); err != nil {
+		http.Error(w, "Failed to change password", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password changed"})
+}
+
+// DeleteMe deletes the current user's account and everything they own, after
+// checking their password. The last admin cannot delete themselves.
+func (h *AuthHandler) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	var req DeleteMeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !h.checkCurrentPassword(w, userID, req.Password) {
+		return
+	}
+
+	if err := db.DeleteUser(h.DB, userID, 0); err != nil {
+		writeAdminUserError(w, err, "Failed to delete account")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Account deleted"})
+}
+
+// checkCurrentPassword checks the password a user confirmed a change with,
+// writing 403 Forbidden if it is wrong. Users without a password, who sign in
+// through single sign-on or a reverse proxy, need not give one.
+func (h *AuthHandler) checkCurrentPassword(w http.ResponseWriter, userID int, password string) bool {
+	hash, err := db.GetPasswordHash(h.DB, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to query user", http.StatusInternalServerError)
+		}
+		return false
+	}
+	if hash != "" && !auth.CheckPasswordHash(password, hash) {
+		http.Error(w, "Current password is incorrect", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// RequestPasswordReset sends a password reset link to the email of the user
+// with the given username or email. The response is the same whether or not
+// there is such a user, so it cannot be used to find accounts. Links are only
+// built from PUBLIC_URL, never from the request's Host, so without it resets are
+// refused with 503 Service Unavailable. Every request counts against the reset
+// limiter, for the login and the client address, whether or not the account
+// exists, so links cannot be sent in bulk.
+func (h *AuthHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	if h.Cfg.PublicURL == "" {
+		http.Error(w, "Password reset is not available: PUBLIC_URL is not set", http.StatusServiceUnavailable)
+		return
+	}
+	var req PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	login := strings.TrimSpace(req.Login)
+	if login == "" {
+		http.Error(w, "Username or email is required", http.StatusBadRequest)
+		return
+	}
+
+	ip := middleware.ClientIP(r, h.TrustedProxies)
+	wait, err := h.ResetLimiter.Check(login, ip)
+	if err != nil {
+		http.Error(w, "Failed to check password reset requests", http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		tooManyAttempts(w, wait)
+		return
+	}
+	if err := h.ResetLimiter.Fail(login, ip); err != nil {
+		log.Printf("Failed to record password reset request for %q: %v", login, err)
+	}
+
+	if err := h.sendPasswordReset(login); err != nil {
+		log.Printf("Failed to send password reset for %q: %v", login, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": "If the account exists, a password reset link has been sent"})
+}
+
+func (h *AuthHandler) sendPasswordReset(login string) error {
+	userID, username, email, err := db.FindUserForPasswordReset(h.DB, login)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	token, err := auth.GenerateRandomToken(passwordResetTokenBytes)
+	if err != nil {
+		return err
+	}
+	ttl := h.Cfg.PasswordResetTTL
+	if err := db.CreatePasswordResetToken(h.DB, userID, auth.HashToken(token), time.Now().Add(ttl)); err != nil {
+		return err
+	}
+
+	link := h.Cfg.PublicURL + "/reset-password?token=" + url.QueryEscape(token)
+	return h.Notifier.Notify(notify.Message{
+		To:      email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\nTo choose a new password, open this link within %s:\n\n%s\n\nIf you did not ask to reset your password, you can ignore this message.\n",
+			username, ttl, link,
+		),
+	})
+}
+
+// ConfirmPasswordReset sets a new password with a reset token. The token works
+// once; the user is logged out everywhere and any login lockout is lifted.
+func (h *AuthHandler) ConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req PasswordResetConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Token == "" || req.Password == "" {
+		http.Error(w, "Token and password are required", http.StatusBadRequest)
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(req.Password)
+	if err != nil {
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
+	userID, err := db.ResetPassword(h.DB, auth.HashToken(req.Token), hashedPassword)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidResetToken) {
+			http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+		} else {
+			http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		}
+		return
+	}
+	if h.Limiter.Enabled() {
+		if username, err := db.GetUsernameByID(h.DB, userID); err != nil {
+			log.Printf("Failed to get user %d: %v", userID, err)
+		} else if err := h.Limiter.Unlock(username); err != nil {
+			log.Printf("Failed to unlock %q: %v", username, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password reset"})
+}
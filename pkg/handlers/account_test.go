@@ -0,0 +1,268 @@
+package handlers_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/config"
+	"go-postgres-example/pkg/handlers"
+	"go-postgres-example/pkg/notify"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangePasswordWrongCurrentPassword(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	hash, err := auth.HashPassword("old-password")
+	require.NoError(t, err)
+	mock.ExpectQuery("SELECT password_hash FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"password_hash"}).AddRow(hash))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, adminRequest("PUT", "/api/me/password", `{"current_password": "guess", "new_password": "new-password"}`, token))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateMeEmailWrongCurrentPassword(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	hash, err := auth.HashPassword("old-password")
+	require.NoError(t, err)
+	mock.ExpectQuery("SELECT password_hash FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"password_hash"}).AddRow(hash))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, adminRequest("PUT", "/api/me", `{"email": "mallory@example.com"}`, token))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestChangePassword(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	hash, err := auth.HashPassword("old-password")
+	require.NoError(t, err)
+	mock.ExpectQuery("SELECT password_hash FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"password_hash"}).AddRow(hash))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET password_hash").
+		WithArgs(1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Every other session is logged out
+	mock.ExpectExec("UPDATE sessions SET revoked_at").
+		WithArgs(1, testSessionID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, adminRequest("PUT", "/api/me/password", `{"current_password": "old-password", "new_password": "new-password"}`, token))
+
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateMeEmailTaken(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("SELECT password_hash FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"password_hash"}).AddRow(""))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM users WHERE email").
+		WithArgs("bob@example.com", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, adminRequest("PUT", "/api/me", `{"email": " bob@example.com "}`, token))
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteMeLastAdmin(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("SELECT password_hash FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"password_hash"}).AddRow(""))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM users WHERE is_admin AND NOT disabled FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectRollback()
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, adminRequest("DELETE", "/api/me", `{}`, token))
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// expectResetRequest expects a password reset request for login to be let
+// through and counted.
+func expectResetRequest(mock sqlmock.Sqlmock, login string) {
+	mock.ExpectQuery("FROM login_failures").
+		WithArgs("reset:user:"+login, "reset:ip:192.0.2.1").
+		WillReturnRows(sqlmock.NewRows([]string{"seconds"}).AddRow(0))
+	mock.ExpectQuery("INSERT INTO login_failures").
+		WithArgs("reset:user:"+login, time.Hour.Seconds()).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(1))
+	mock.ExpectExec("UPDATE login_failures SET locked_until").
+		WithArgs("reset:user:"+login, time.Minute.Seconds()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO login_failures").
+		WithArgs("reset:ip:192.0.2.1", time.Hour.Seconds()).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(1))
+}
+
+func TestPasswordReset(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	h := handlers.NewAuthHandler(conn, &config.Config{PublicURL: "https://music.example.com", PasswordResetTTL: time.Hour})
+	notifier := &notify.Memory{}
+	h.Notifier = notifier
+
+	expectResetRequest(mock, "bob")
+	mock.ExpectQuery("SELECT id, username, email FROM users").
+		WithArgs("bob").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email"}).AddRow(4, "bob", "bob@example.com"))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM password_reset_tokens").WithArgs(4).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO password_reset_tokens").
+		WithArgs(4, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	rr := httptest.NewRecorder()
+	h.RequestPasswordReset(rr, httptest.NewRequest("POST", "/api/auth/password-reset", bytes.NewBufferString(`{"login": "bob"}`)))
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+
+	messages := notifier.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, "bob@example.com", messages[0].To)
+	i := strings.Index(messages[0].Body, "https://music.example.com/reset-password?token=")
+	require.GreaterOrEqual(t, i, 0, messages[0].Body)
+	link, err := url.Parse(strings.Fields(messages[0].Body[i:])[0])
+	require.NoError(t, err)
+	token := link.Query().Get("token")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE password_reset_tokens SET used_at").
+		WithArgs(auth.HashToken(token)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(4))
+	mock.ExpectExec("UPDATE users SET password_hash").
+		WithArgs(4, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE sessions SET revoked_at").WithArgs(4).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	rr = httptest.NewRecorder()
+	body := `{"token": "` + token + `", "password": "new-password"}`
+	h.ConfirmPasswordReset(rr, httptest.NewRequest("POST", "/api/auth/password-reset/confirm", bytes.NewBufferString(body)))
+
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPasswordResetUnknownUser(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	h := handlers.NewAuthHandler(conn, &config.Config{PublicURL: "https://music.example.com", PasswordResetTTL: time.Hour})
+	notifier := &notify.Memory{}
+	h.Notifier = notifier
+
+	expectResetRequest(mock, "nobody")
+	mock.ExpectQuery("SELECT id, username, email FROM users").
+		WithArgs("nobody").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email"}))
+
+	rr := httptest.NewRecorder()
+	h.RequestPasswordReset(rr, httptest.NewRequest("POST", "/api/auth/password-reset", bytes.NewBufferString(`{"login": "nobody"}`)))
+
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.Empty(t, notifier.Messages())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPasswordResetThrottled(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Reset requests are throttled even with login throttling turned off.
+	h := handlers.NewAuthHandler(conn, &config.Config{PublicURL: "https://music.example.com", PasswordResetTTL: time.Hour})
+	notifier := &notify.Memory{}
+	h.Notifier = notifier
+
+	mock.ExpectQuery("FROM login_failures").
+		WithArgs("reset:user:bob", "reset:ip:192.0.2.1").
+		WillReturnRows(sqlmock.NewRows([]string{"seconds"}).AddRow(60))
+
+	req := httptest.NewRequest("POST", "/api/auth/password-reset", bytes.NewBufferString(`{"login": "bob"}`))
+	req.RemoteAddr = "192.0.2.1:5000"
+	rr := httptest.NewRecorder()
+	h.RequestPasswordReset(rr, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Empty(t, notifier.Messages())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPasswordResetRequiresPublicURL(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	h := handlers.NewAuthHandler(conn, &config.Config{PasswordResetTTL: time.Hour})
+	notifier := &notify.Memory{}
+	h.Notifier = notifier
+
+	req := httptest.NewRequest("POST", "/api/auth/password-reset", bytes.NewBufferString(`{"login": "bob"}`))
+	req.Host = "attacker.example.net"
+	rr := httptest.NewRecorder()
+	h.RequestPasswordReset(rr, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Empty(t, notifier.Messages())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConfirmPasswordResetInvalidToken(t *testing.T) {
+	r, mock, _ := newTestRouter(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE password_reset_tokens SET used_at").
+		WithArgs(auth.HashToken("used")).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+	mock.ExpectRollback()
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("POST", "/api/auth/password-reset/confirm", bytes.NewBufferString(`{"token": "used", "password": "pw"}`)))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/middleware"
+	"go-postgres-example/pkg/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminResetPasswordRequest represents the request body for resetting a user's password
+type AdminResetPasswordRequest struct {
+	Password string `json:"password"`
+}
+
+// AdminListUsers lists users ordered by username. q filters by username or
+// email; limit (default 20, at most 500) and offset page through the list.
+func (h *AuthHandler) AdminListUsers(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	opts := models.UserListOptions{Query: strings.TrimSpace(queryParams.Get("q"))}
+
+	var err error
+	if opts.Limit, err = parseStatsLimit(queryParams.Get("limit")); err != nil {
+		http.Error(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+	if value := queryParams.Get("offset"); value != "" {
+		if opts.Offset, err = strconv.Atoi(value); err != nil || opts.Offset < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	users, err := db.ListUsers(h.DB, opts)
+	if err != nil {
+		http.Error(w, "Failed to list users", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
+
+// AdminGetUser returns a user.
+func (h *AuthHandler) AdminGetUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := adminUserID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := db.GetUser(h.DB, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// AdminUpdateUser changes a user's email, admin flag or role and returns the user.
+func (h *AuthHandler) AdminUpdateUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := adminUserID(w, r)
+	if !ok {
+		return
+	}
+	var update models.UserUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if update.Email != nil {
+		email := strings.TrimSpace(*update.Email)
+		if email == "" {
+			http.Error(w, "Email cannot be empty", http.StatusBadRequest)
+			return
+		}
+		update.Email = &email
+	}
+
+	if err := db.UpdateUser(h.DB, userID, update); err != nil {
+		writeAdminUserError(w, err, "Failed to update user")
+		return
+	}
+	h.AdminGetUser(w, r)
+}
+
+// AdminResetPassword sets a new password for a user and logs them out everywhere.
+func (h *AuthHandler) AdminResetPassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := adminUserID(w, r)
+	if !ok {
+		return
+	}
+	var req AdminResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Password == "" {
+		http.Error(w, "Password is required", http.StatusBadRequest)
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(req.Password)
+	if err != nil {
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
+	if err := db.SetUserPassword(h.DB, userID, hashedPassword); err != nil {
+		writeAdminUserError(w, err, "Failed to reset password")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password reset"})
+}
+
+// AdminDisableUser disables a user's account and logs them out everywhere.
+func (h *AuthHandler) AdminDisableUser(w http.ResponseWriter, r *http.Request) {
+	h.setUserDisabled(w, r, true)
+}
+
+// AdminEnableUser enables a disabled account.
+func (h *AuthHandler) AdminEnableUser(w http.ResponseWriter, r *http.Request) {
+	h.setUserDisabled(w, r, false)
+}
+
+func (h *AuthHandler) setUserDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	userID, ok := adminUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := db.SetUserDisabled(h.DB, userID, disabled); err != nil {
+		writeAdminUserError(w, err, "Failed to update user")
+		return
+	}
+	h.AdminGetUser(w, r)
+}
+
+// AdminDeleteUser deletes a user. Their playlists are deleted too unless
+// playlists=reassign, which gives them to the user reassign_to, by default the
+// admin making the request.
+func (h *AuthHandler) AdminDeleteUser(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, ok := adminUserID(w, r)
+	if !ok {
+		return
+	}
+
+	reassignTo := 0
+	queryParams := r.URL.Query()
+	switch queryParams.Get("playlists") {
+	case "", "delete":
+	case "reassign":
+		reassignTo = adminID
+		if value := queryParams.Get("reassign_to"); value != "" {
+			var err error
+			if reassignTo, err = strconv.Atoi(value); err != nil {
+				http.Error(w, "Invalid reassign_to", http.StatusBadRequest)
+				return
+			}
+		}
+		if reassignTo == userID {
+			http.Error(w, "Cannot reassign playlists to the deleted user", http.StatusBadRequest)
+			return
+		}
+		if _, err := db.GetUsernameByID(h.DB, reassignTo); err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "User to reassign playlists to not found", http.StatusBadRequest)
+			} else {
+				http.Error(w, "Failed to get user", http.StatusInternalServerError)
+			}
+			return
+		}
+	default:
+		http.Error(w, "playlists must be delete or reassign", http.StatusBadRequest)
+		return
+	}
+
+	if err := db.DeleteUser(h.DB, userID, reassignTo); err != nil {
+		writeAdminUserError(w, err, "Failed to delete user")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "User deleted"})
+}
+
+// adminUserID parses the user ID of an admin route, writing 400 if it is invalid.
+func adminUserID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return userID, true
+}
+
+// writeAdminUserError maps the errors of the user management queries to responses.
+func writeAdminUserError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, db.ErrLastAdmin):
+		http.Error(w, "Cannot remove the last admin", http.StatusConflict)
+	case errors.Is(err, db.ErrUnknownRole):
+		http.Error(w, "Unknown role", http.StatusBadRequest)
+	default:
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
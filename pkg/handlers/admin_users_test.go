@@ -0,0 +1,169 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-postgres-example/pkg/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var userColumns = []string{
+	"id", "username", "email", "created_at", "is_admin", "role", "totp_enabled", "disabled",
+}
+
+// expectAdmin expects AdminOnly to look up user 1, an admin.
+func expectAdmin(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT COALESCE\\(is_admin, FALSE\\), disabled FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"is_admin", "disabled"}).AddRow(true, false))
+}
+
+// expectPermission expects RequirePermission to check a permission of user 1.
+func expectPermission(mock sqlmock.Sqlmock, permission string, allowed bool) {
+	mock.ExpectQuery("FROM role_permissions").
+		WithArgs(1, permission).
+		WillReturnRows(sqlmock.NewRows([]string{"allowed"}).AddRow(allowed))
+}
+
+func adminRequest(method, target, body, token string) *http.Request {
+	req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestAdminCreateUserRoute(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	expectAdmin(mock)
+	mock.ExpectExec("INSERT INTO users").
+		WithArgs("bob", "bob@local", sqlmock.AnyArg(), false, "user").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, adminRequest("POST", "/api/admin/users", `{"username": "bob", "password": "pw"}`, token))
+
+	assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdminRoutesRequireAdmin(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("SELECT COALESCE\\(is_admin, FALSE\\), disabled FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"is_admin", "disabled"}).AddRow(false, false))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, adminRequest("GET", "/api/admin/users", "", token))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdminListUsers(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	expectAdmin(mock)
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users").
+		WithArgs("%bo%").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery("SELECT (.+) FROM users WHERE username ILIKE").
+		WithArgs("%bo%", 2, 2).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(4, "bob", "bob@example.com", time.Now(), false, "user", false, true))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, adminRequest("GET", "/api/admin/users?q=bo&limit=2&offset=2", "", token))
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var list models.UserList
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &list))
+	assert.Equal(t, 3, list.Total)
+	require.Len(t, list.Users, 1)
+	assert.Equal(t, "bob", list.Users[0].Username)
+	assert.True(t, list.Users[0].Disabled)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdminUpdateUserLastAdmin(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	expectAdmin(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM users WHERE is_admin AND NOT disabled FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectRollback()
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, adminRequest("PATCH", "/api/admin/users/1", `{"is_admin": false}`, token))
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdminDisableUser(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	expectAdmin(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM users WHERE is_admin AND NOT disabled FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("UPDATE users SET disabled").
+		WithArgs(4, true).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE sessions SET revoked_at").
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT (.+) FROM users WHERE id").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(4, "bob", "bob@example.com", time.Now(), false, "user", false, true))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, adminRequest("POST", "/api/admin/users/4/disable", "", token))
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), `"disabled":true`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdminDeleteUserReassignsPlaylists(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	expectAdmin(mock)
+	mock.ExpectQuery("SELECT username FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("admin"))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM users WHERE is_admin AND NOT disabled FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("DELETE FROM playlist_collaborators").
+		WithArgs(4, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE playlists SET user_id").
+		WithArgs(4, 1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM users").
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, adminRequest("DELETE", "/api/admin/users/4?playlists=reassign", "", token))
+
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/middleware"
+	"go-postgres-example/pkg/models"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetAlbumsHandler handles listing albums. The list query parameter selects the
+// order (random, newest, highest, frequent, recent, alphabeticalByName,
+// alphabeticalByArtist, starred, byYear or byGenre) and defaults to alphabeticalByName.
+// size (default 20, at most 500) and offset page through the list; byYear lists
+// take from_year and to_year, byGenre lists take genre.
+func (h *LibraryHandler) GetAlbumsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	queryParams := r.URL.Query()
+	opts := models.AlbumListOptions{
+		Type:  queryParams.Get("list"),
+		Genre: queryParams.Get("genre"),
+	}
+	if opts.Type == "" {
+		opts.Type = models.AlbumListAlphabeticalByName
+	}
+
+	var err error
+	if opts.Size, err = parseStatsLimit(queryParams.Get("size")); err != nil {
+		http.Error(w, "Invalid size", http.StatusBadRequest)
+		return
+	}
+	for param, dest := range map[string]*int{"offset": &opts.Offset, "from_year": &opts.FromYear, "to_year": &opts.ToYear} {
+		if value := queryParams.Get(param); value != "" {
+			if *dest, err = strconv.Atoi(value); err != nil || *dest < 0 {
+				http.Error(w, "Invalid "+param, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	albums, err := db.GetAlbumList(h.DB, userID, opts)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidAlbumList) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, "Failed to get albums", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(albums)
+}
+
+// GetAlbumCoverHandler serves the cover image of an album, taken from the
+// cover.jpg or similar file uploaded with its songs.
+func (h *LibraryHandler) GetAlbumCoverHandler(w http.ResponseWriter, r *http.Request) {
+	albumID, err := strconv.Atoi(chi.URLParam(r, "albumID"))
+	if err != nil {
+		http.Error(w, "Invalid album ID", http.StatusBadRequest)
+		return
+	}
+
+	coverPath, err := db.GetAlbumCover(h.DB, albumID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Album not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get album", http.StatusInternalServerError)
+		}
+		return
+	}
+	if coverPath == "" {
+		http.Error(w, "Album has no cover", http.StatusNotFound)
+		return
+	}
+	http.ServeFile(w, r, coverPath)
+}
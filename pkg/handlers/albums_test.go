@@ -0,0 +1,111 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-postgres-example/pkg/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var albumSummaryColumns = []string{
+	"id", "name", "artist", "artist_id", "year", "created_at",
+	"genre", "song_count", "duration", "play_count", "last_played", "rating", "starred_at",
+}
+
+func TestGetAlbumsHandlerByYearDescending(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery(`WHERE al.year BETWEEN \$2 AND \$3\s+ORDER BY al.year DESC`).
+		WithArgs(1, 1990, 1999, 5, 10).
+		WillReturnRows(sqlmock.NewRows(albumSummaryColumns).
+			AddRow(4, "Mezzanine", "Massive Attack", 2, 1998, time.Now(), "Trip Hop", 11, 3800, 7, time.Now(), 4.5, nil))
+
+	req, _ := http.NewRequest("GET", "/api/albums?list=byYear&from_year=1999&to_year=1990&size=5&offset=10", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	var albums []models.AlbumSummary
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &albums))
+	require.Len(t, albums, 1)
+	assert.Equal(t, "Mezzanine", albums[0].Name)
+	assert.Equal(t, 11, albums[0].SongCount)
+	assert.Equal(t, 4.5, albums[0].Rating)
+	assert.Nil(t, albums[0].StarredAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAlbumsHandlerDefaultList(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery(`ORDER BY LOWER\(al.name\)`).
+		WithArgs(1, 20, 0).
+		WillReturnRows(sqlmock.NewRows(albumSummaryColumns))
+
+	req, _ := http.NewRequest("GET", "/api/albums", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `[]`, rr.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAlbumsHandlerInvalidList(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	for _, query := range []string{"list=loudest", "list=byGenre", "list=byYear", "offset=-1"} {
+		expectSession(mock)
+		req, _ := http.NewRequest("GET", "/api/albums?"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
+func TestGetAlbumCoverHandler(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+	coverPath := filepath.Join(t.TempDir(), "cover.jpg")
+	require.NoError(t, os.WriteFile(coverPath, []byte("jpeg"), 0644))
+
+	expectSession(mock)
+	mock.ExpectQuery("SELECT COALESCE\\(cover_path, ''\\) FROM albums").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"cover_path"}).AddRow(coverPath))
+	expectSession(mock)
+	mock.ExpectQuery("SELECT COALESCE\\(cover_path, ''\\) FROM albums").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"cover_path"}).AddRow(""))
+
+	req, _ := http.NewRequest("GET", "/api/albums/4/cover", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "jpeg", rr.Body.String())
+	assert.Equal(t, "image/jpeg", rr.Header().Get("Content-Type"))
+
+	req, _ = http.NewRequest("GET", "/api/albums/5/cover", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/middleware"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CreateAppPasswordRequest represents the request body for creating an app password
+type CreateAppPasswordRequest struct {
+	Name string `json:"name"`
+}
+
+// GetAppPasswords lists the current user's app passwords.
+func (h *AuthHandler) GetAppPasswords(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	appPasswords, err := db.GetAppPasswords(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Failed to get app passwords", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(appPasswords)
+}
+
+// CreateAppPassword creates a password for a Subsonic client. It is returned
+// only in this response.
+func (h *AuthHandler) CreateAppPassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	var req CreateAppPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Name) > 255 {
+		http.Error(w, "Name is too long", http.StatusBadRequest)
+		return
+	}
+
+	password, err := auth.GenerateRandomToken(18)
+	if err != nil {
+		http.Error(w, "Failed to generate password", http.StatusInternalServerError)
+		return
+	}
+	encrypted, err := auth.EncryptSecret(h.Cfg.EncryptionKey, password)
+	if err != nil {
+		http.Error(w, "Failed to encrypt password", http.StatusInternalServerError)
+		return
+	}
+	appPassword, err := db.CreateAppPassword(h.DB, userID, req.Name, encrypted)
+	if err != nil {
+		http.Error(w, "Failed to create app password", http.StatusInternalServerError)
+		return
+	}
+	appPassword.Password = password
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(appPassword)
+}
+
+// DeleteAppPassword deletes one of the current user's app passwords.
+func (h *AuthHandler) DeleteAppPassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	appPasswordID, err := strconv.Atoi(chi.URLParam(r, "appPasswordID"))
+	if err != nil {
+		http.Error(w, "Invalid app password ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := db.DeleteAppPassword(h.DB, userID, appPasswordID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "App password not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete app password", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "App password deleted"})
+}
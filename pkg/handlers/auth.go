@@ -5,19 +5,73 @@ import (
 	"encoding/json"
 	"go-postgres-example/pkg/auth"
 	"go-postgres-example/pkg/config"
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/lockout"
 	"go-postgres-example/pkg/middleware"
+	"go-postgres-example/pkg/models"
+	"go-postgres-example/pkg/notify"
+	"go-postgres-example/pkg/oidc"
+	"log"
+	"net"
 	"net/http"
+	"time"
 )
 
+// resetPolicy throttles password reset requests. It does not follow the
+// LOGIN_* settings, so turning login throttling off never lets reset links be
+// sent in bulk.
+var resetPolicy = lockout.Policy{
+	MaxFailures:      3,
+	MaxFailuresPerIP: 10,
+	BaseDelay:        time.Minute,
+	Lockout:          time.Hour,
+}
+
 // AuthHandler holds the dependencies for the auth handlers
 type AuthHandler struct {
-	DB  *sql.DB
-	Cfg *config.Config
+	DB   *sql.DB
+	Cfg  *config.Config
+	OIDC *oidc.Provider // nil when single sign-on is not configured
+	// HeaderAuth signs in users by a reverse proxy's header; disabled when its Header is empty
+	HeaderAuth middleware.HeaderAuth
+	// TrustedProxies are the proxies whose X-Forwarded-For gives the client's address
+	TrustedProxies []*net.IPNet
+	// Limiter throttles password logins after failed attempts
+	Limiter *lockout.Limiter
+	// ResetLimiter throttles password reset requests, per login and per address
+	ResetLimiter *lockout.Limiter
+	// Notifier delivers password reset links
+	Notifier notify.Notifier
 }
 
 // NewAuthHandler creates a new AuthHandler
 func NewAuthHandler(db *sql.DB, cfg *config.Config) *AuthHandler {
-	return &AuthHandler{DB: db, Cfg: cfg}
+	h := &AuthHandler{DB: db, Cfg: cfg}
+	policy := lockout.Policy{
+		MaxFailures:      cfg.LoginMaxFailures,
+		MaxFailuresPerIP: cfg.LoginMaxFailuresPerIP,
+		BaseDelay:        cfg.LoginBaseDelay,
+		Lockout:          cfg.LoginLockout,
+	}
+	h.Limiter = lockout.New(db, policy)
+	h.ResetLimiter = lockout.New(db, resetPolicy)
+	h.ResetLimiter.Scope = "reset"
+	if cfg.Notifier == "smtp" {
+		h.Notifier = &notify.SMTP{Addr: cfg.SMTPAddr, From: cfg.SMTPFrom, Username: cfg.SMTPUsername, Password: cfg.SMTPPassword}
+	} else {
+		h.Notifier = notify.Log{}
+	}
+	if cfg.OIDCIssuerURL != "" {
+		h.OIDC = oidc.New(oidc.Config{
+			IssuerURL:    cfg.OIDCIssuerURL,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       cfg.OIDCScopes,
+			GroupsClaim:  cfg.OIDCGroupsClaim,
+		})
+	}
+	return h
 }
 
 // RegisterRequest represents the request body for user registration
@@ -64,6 +118,8 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 type LoginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	// DeviceName labels the session in the session list; defaults to the User-Agent
+	DeviceName string `json:"device_name,omitempty"`
 }
 
 // Login handles user login
@@ -79,11 +135,24 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := middleware.ClientIP(r, h.TrustedProxies)
+	wait, err := h.Limiter.Check(req.Username, ip)
+	if err != nil {
+		http.Error(w, "Failed to check login attempts", http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		tooManyAttempts(w, wait)
+		return
+	}
+
 	var userID int
 	var passwordHash string
-	err := h.DB.QueryRow("SELECT id, password_hash FROM users WHERE username = $1", req.Username).Scan(&userID, &passwordHash)
+	var totpEnabled bool
+	err = h.DB.QueryRow("SELECT id, password_hash, totp_enabled FROM users WHERE username = $1", req.Username).Scan(&userID, &passwordHash, &totpEnabled)
 	if err != nil {
 		if err == sql.ErrNoRows {
+			h.loginFailed(req.Username, ip)
 			http.Error(w, "Invalid username or password", http.StatusUnauthorized)
 			return
 		}
@@ -92,25 +161,37 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !auth.CheckPasswordHash(req.Password, passwordHash) {
+		h.loginFailed(req.Username, ip)
 		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
 		return
 	}
-
-	token, err := auth.GenerateJWT(userID, h.Cfg.JWTSecret)
-	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+	if totpEnabled {
+		h.writeChallenge(w, userID)
 		return
 	}
+	if err := h.Limiter.Succeed(req.Username); err != nil {
+		log.Printf("Failed to clear failed logins for %q: %v", req.Username, err)
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"token": token})
+	deviceName := req.DeviceName
+	if deviceName == "" {
+		deviceName = r.UserAgent()
+	}
+	h.startSession(w, r, userID, deviceName)
 }
 
 // MeResponse represents the authenticated user information
 type MeResponse struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
-	IsAdmin  bool   `json:"is_admin"`
+	ID          int    `json:"id"`
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name,omitempty"`
+	IsAdmin     bool   `json:"is_admin"`
+	// TwoFactorEnabled is whether the user logs in with a TOTP code
+	TwoFactorEnabled bool `json:"two_factor_enabled"`
+	// Role is the user's role and Permissions what it lets them do
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"`
 }
 
 // Me returns the current authenticated user's info
@@ -121,7 +202,9 @@ func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp MeResponse
-	err := h.DB.QueryRow("SELECT id, username, COALESCE(is_admin, FALSE) FROM users WHERE id = $1", userID).Scan(&resp.ID, &resp.Username, &resp.IsAdmin)
+	err := h.DB.QueryRow(
+		"SELECT id, username, email, COALESCE(display_name, ''), COALESCE(is_admin, FALSE), totp_enabled FROM users WHERE id = $1", userID,
+	).Scan(&resp.ID, &resp.Username, &resp.Email, &resp.DisplayName, &resp.IsAdmin, &resp.TwoFactorEnabled)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "User not found", http.StatusNotFound)
@@ -130,6 +213,11 @@ func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to query user", http.StatusInternalServerError)
 		return
 	}
+	resp.Role, resp.Permissions, err = db.GetUserPermissions(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Failed to query user", http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
@@ -137,12 +225,14 @@ func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 // PublicConfigResponse exposes non-sensitive configuration to the frontend
 type PublicConfigResponse struct {
 	AllowRegistration bool `json:"allow_registration"`
+	OIDCEnabled       bool `json:"oidc_enabled"`
+	ProxyAuth         bool `json:"proxy_auth"`
 }
 
 // PublicConfig returns public configuration flags (no auth required)
 func (h *AuthHandler) PublicConfig(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(PublicConfigResponse{AllowRegistration: h.Cfg.AllowRegistration})
+	json.NewEncoder(w).Encode(PublicConfigResponse{AllowRegistration: h.Cfg.AllowRegistration, OIDCEnabled: h.OIDC != nil, ProxyAuth: h.HeaderAuth.Header != ""})
 }
 
 // AdminCreateUserRequest represents the request body for admin-created users
@@ -151,6 +241,8 @@ type AdminCreateUserRequest struct {
 	Password string `json:"password"`
 	Email    string `json:"email,omitempty"`
 	IsAdmin  bool   `json:"is_admin,omitempty"`
+	// Role is the user's role; it defaults to admin or user following IsAdmin
+	Role string `json:"role,omitempty"`
 }
 
 // AdminCreateUser allows an admin to create a new user
@@ -175,7 +267,18 @@ func (h *AuthHandler) AdminCreateUser(w http.ResponseWriter, r *http.Request) {
 	}
 	// Only allow setting admin flag to true by admins; the route will already be protected by admin middleware
 	isAdmin := req.IsAdmin
-	_, err = h.DB.Exec("INSERT INTO users (username, email, password_hash, is_admin) VALUES ($1, $2, $3, $4)", req.Username, email, hashedPassword, isAdmin)
+	role := models.RoleUser
+	if req.Role != "" {
+		if err := db.CheckRole(h.DB, req.Role); err != nil {
+			writeAdminUserError(w, err, "Failed to check role")
+			return
+		}
+		role = req.Role
+		isAdmin = role == models.RoleAdmin
+	} else if isAdmin {
+		role = models.RoleAdmin
+	}
+	_, err = h.DB.Exec("INSERT INTO users (username, email, password_hash, is_admin, role) VALUES ($1, $2, $3, $4, $5)", req.Username, email, hashedPassword, isAdmin, role)
 	if err != nil {
 		http.Error(w, "Failed to create user", http.StatusInternalServerError)
 		return
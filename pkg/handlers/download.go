@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"database/sql"
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/download"
+	"go-postgres-example/pkg/middleware"
+	"go-postgres-example/pkg/models"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// DownloadSongHandler downloads a song's original file, named after its tags.
+func (h *SongHandler) DownloadSongHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := downloadUser(w, r, h.DB); !ok {
+		return
+	}
+
+	songID, err := strconv.Atoi(chi.URLParam(r, "songID"))
+	if err != nil {
+		http.Error(w, "Invalid song ID", http.StatusBadRequest)
+		return
+	}
+
+	songs, err := db.GetSongsByIDs(h.DB, []int{songID})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Song not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get song", http.StatusInternalServerError)
+		}
+		return
+	}
+	ServeSongDownload(w, r, &songs[0])
+}
+
+// DownloadAlbumHandler downloads an album as a zip archive.
+func (h *LibraryHandler) DownloadAlbumHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := downloadUser(w, r, h.DB); !ok {
+		return
+	}
+
+	albumID, err := strconv.Atoi(chi.URLParam(r, "albumID"))
+	if err != nil {
+		http.Error(w, "Invalid album ID", http.StatusBadRequest)
+		return
+	}
+
+	album, err := db.GetAlbumByID(h.DB, albumID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Album not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get album", http.StatusInternalServerError)
+		}
+		return
+	}
+	songs, err := db.GetAlbumSongs(h.DB, albumID)
+	if err != nil {
+		http.Error(w, "Failed to get album songs", http.StatusInternalServerError)
+		return
+	}
+
+	name := album.Name
+	if album.Artist != "" {
+		name = album.Artist + " - " + album.Name
+	}
+	ServeZipDownload(w, name, songs)
+}
+
+// DownloadPlaylistHandler downloads a playlist the user can access as a zip archive.
+func (h *PlaylistHandler) DownloadPlaylistHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := downloadUser(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	playlistID, err := strconv.Atoi(chi.URLParam(r, "playlistID"))
+	if err != nil {
+		http.Error(w, "Invalid playlist ID", http.StatusBadRequest)
+		return
+	}
+
+	playlist, err := db.GetPlaylistByID(h.DB, userID, playlistID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Playlist not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get playlist", http.StatusInternalServerError)
+		}
+		return
+	}
+	entries, err := db.GetPlaylistContents(h.DB, playlist)
+	if err != nil {
+		http.Error(w, "Failed to get playlist songs", http.StatusInternalServerError)
+		return
+	}
+
+	songs := make([]models.Song, 0, len(entries))
+	for _, entry := range entries {
+		songs = append(songs, entry.Song)
+	}
+	ServeZipDownload(w, playlist.Name, songs)
+}
+
+// ServeSongDownload serves a song's file as an attachment named after its tags.
+func ServeSongDownload(w http.ResponseWriter, r *http.Request, song *models.Song) {
+	file, err := os.Open(song.FilePath)
+	if err != nil {
+		http.Error(w, "Failed to open file", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Disposition", download.Attachment(download.FileName(song)))
+	http.ServeContent(w, r, file.Name(), time.Time{}, file)
+}
+
+// ServeZipDownload streams a zip archive of the songs named after name. The
+// archive is built while it is sent, so failures part way through can only be
+// logged; the client receives a truncated archive.
+func ServeZipDownload(w http.ResponseWriter, name string, songs []models.Song) {
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", download.Attachment(download.SafeName(name, "download")+".zip"))
+	if err := download.WriteZip(w, name, songs); err != nil {
+		log.Printf("Failed to write zip download %q: %v", name, err)
+	}
+}
+
+// downloadUser returns the authenticated user if they may download, writing
+// 403 Forbidden if they do not have the download role.
+func downloadUser(w http.ResponseWriter, r *http.Request, conn *sql.DB) (int, bool) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return 0, false
+	}
+	allowed, err := db.HasDownloadRole(conn, userID)
+	if err != nil {
+		http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		return 0, false
+	}
+	if !allowed {
+		http.Error(w, "Downloads are not allowed for this user", http.StatusForbidden)
+		return 0, false
+	}
+	return userID, true
+}
@@ -0,0 +1,114 @@
+package handlers_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadSongHandler(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	path := filepath.Join(t.TempDir(), "abc.flac")
+	require.NoError(t, os.WriteFile(path, []byte("audio"), 0o644))
+
+	mock.ExpectQuery("FROM role_permissions").
+		WithArgs(1, "download").
+		WillReturnRows(sqlmock.NewRows([]string{"allowed"}).AddRow(true))
+	mock.ExpectQuery("SELECT (.+) FROM songs s").
+		WithArgs("{9}").
+		WillReturnRows(sqlmock.NewRows(songColumns).
+			AddRow(9, "abc", path, "So What", "Miles Davis", "Kind of Blue", 1959, 1, "Jazz", 562, 900, 100, time.Now()))
+
+	req, _ := http.NewRequest("GET", "/api/songs/9/download", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "audio", rr.Body.String())
+	assert.Equal(t, `attachment; filename="Miles Davis - So What.flac"`, rr.Header().Get("Content-Disposition"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDownloadSongHandlerWithoutRole(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("FROM role_permissions").
+		WithArgs(1, "download").
+		WillReturnRows(sqlmock.NewRows([]string{"allowed"}).AddRow(false))
+
+	req, _ := http.NewRequest("GET", "/api/songs/9/download", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDownloadAlbumHandler(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	path := filepath.Join(t.TempDir(), "abc.flac")
+	require.NoError(t, os.WriteFile(path, []byte("audio"), 0o644))
+
+	mock.ExpectQuery("FROM role_permissions").
+		WithArgs(1, "download").
+		WillReturnRows(sqlmock.NewRows([]string{"allowed"}).AddRow(true))
+	mock.ExpectQuery("SELECT (.+) FROM albums").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "artist", "artist_id", "year"}).
+			AddRow(3, "Kind of Blue", "Miles Davis", 2, 1959))
+	mock.ExpectQuery("SELECT (.+) FROM songs s").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(songColumns).
+			AddRow(9, "abc", path, "So What", "Miles Davis", "Kind of Blue", 1959, 1, "Jazz", 562, 900, 100, time.Now()))
+
+	req, _ := http.NewRequest("GET", "/api/albums/3/download", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/zip", rr.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="Miles Davis - Kind of Blue.zip"`, rr.Header().Get("Content-Disposition"))
+	zr, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 2)
+	assert.Equal(t, "Miles Davis/Kind of Blue/01 - So What.flac", zr.File[0].Name)
+	assert.Equal(t, "Miles Davis - Kind of Blue.m3u8", zr.File[1].Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDownloadPlaylistHandlerNotFound(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("FROM role_permissions").
+		WithArgs(1, "download").
+		WillReturnRows(sqlmock.NewRows([]string{"allowed"}).AddRow(true))
+	mock.ExpectQuery("SELECT (.+) FROM playlists").
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows(playlistColumns))
+
+	req, _ := http.NewRequest("GET", "/api/playlists/5/download", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
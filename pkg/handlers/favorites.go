@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// favoriteKinds maps the {type} path segment of favorites routes to the kind of item starred.
+var favoriteKinds = map[string]string{
+	"songs":   db.StarSong,
+	"albums":  db.StarAlbum,
+	"artists": db.StarArtist,
+}
+
+// GetFavoritesHandler handles listing the songs, albums and artists the user has starred
+func (h *LibraryHandler) GetFavoritesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	favorites, err := db.GetFavorites(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Failed to get favorites", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(favorites)
+}
+
+// StarHandler handles starring a song, album or artist
+func (h *LibraryHandler) StarHandler(w http.ResponseWriter, r *http.Request) {
+	userID, kind, id, ok := favoriteParams(w, r)
+	if !ok {
+		return
+	}
+
+	starred, err := db.Star(h.DB, userID, kind, []int{id})
+	if err != nil {
+		http.Error(w, "Failed to star item", http.StatusInternalServerError)
+		return
+	}
+	if starred == 0 {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Starred successfully"})
+}
+
+// UnstarHandler handles removing the star from a song, album or artist
+func (h *LibraryHandler) UnstarHandler(w http.ResponseWriter, r *http.Request) {
+	userID, kind, id, ok := favoriteParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := db.Unstar(h.DB, userID, kind, []int{id}); err != nil {
+		http.Error(w, "Failed to unstar item", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// favoriteParams reads the user and the {type}/{id} path parameters of favorites routes,
+// writing an error response and returning false if they are invalid.
+func favoriteParams(w http.ResponseWriter, r *http.Request) (int, string, int, bool) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return 0, "", 0, false
+	}
+
+	kind, ok := favoriteKinds[chi.URLParam(r, "type")]
+	if !ok {
+		http.Error(w, "Type must be one of songs, albums or artists", http.StatusBadRequest)
+		return 0, "", 0, false
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return 0, "", 0, false
+	}
+	return userID, kind, id, true
+}
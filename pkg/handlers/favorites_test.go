@@ -0,0 +1,130 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-postgres-example/pkg/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStarHandler(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectExec("INSERT INTO starred_albums").
+		WithArgs(1, "{7}").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req, _ := http.NewRequest("PUT", "/api/favorites/albums/7", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStarHandlerNotFound(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectExec("INSERT INTO starred_songs").
+		WithArgs(1, "{404}").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req, _ := http.NewRequest("PUT", "/api/favorites/songs/404", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStarHandlerUnknownType(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	req, _ := http.NewRequest("PUT", "/api/favorites/genres/1", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestUnstarHandler(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectExec("DELETE FROM starred_artists").
+		WithArgs(1, "{3}").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req, _ := http.NewRequest("DELETE", "/api/favorites/artists/3", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetFavoritesHandler(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	starredAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("FROM starred_songs").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(append(songColumns, "starred_at")).
+			AddRow(9, "hash", "/music/a.mp3", "Teardrop", "Massive Attack", "Mezzanine", 1998, 1, "Trip Hop", 330, 320, 1000, time.Now(), starredAt))
+	mock.ExpectQuery("FROM starred_albums").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "artist", "artist_id", "year", "starred_at"}).
+			AddRow(4, "Mezzanine", "Massive Attack", 2, 1998, starredAt))
+	mock.ExpectQuery("FROM starred_artists").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "starred_at"}))
+
+	req, _ := http.NewRequest("GET", "/api/favorites", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	var favorites models.Favorites
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &favorites))
+	require.Len(t, favorites.Songs, 1)
+	assert.Equal(t, "Teardrop", favorites.Songs[0].Title)
+	assert.True(t, favorites.Songs[0].StarredAt.Equal(starredAt))
+	require.Len(t, favorites.Albums, 1)
+	assert.Equal(t, 2, favorites.Albums[0].ArtistID)
+	assert.Empty(t, favorites.Artists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRateSongHandlerClearsRating(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectExec("UPDATE user_songs SET rating = NULL").
+		WithArgs(1, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req, _ := http.NewRequest("POST", "/api/songs/5/rate", strings.NewReader(`{"rating": 0}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -6,6 +6,8 @@ import (
 	"go-postgres-example/pkg/config"
 	"go-postgres-example/pkg/db"
 	"go-postgres-example/pkg/middleware"
+	"go-postgres-example/pkg/playqueue"
+	"go-postgres-example/pkg/scrobbler"
 	"net/http"
 	"strconv"
 
@@ -14,13 +16,15 @@ import (
 
 // LibraryHandler holds the dependencies for the library handlers
 type LibraryHandler struct {
-	DB  *sql.DB
-	Cfg *config.Config
+	DB          *sql.DB
+	Cfg         *config.Config
+	Scrobbler   *scrobbler.Forwarder
+	QueueEvents *playqueue.Broker
 }
 
 // NewLibraryHandler creates a new LibraryHandler
 func NewLibraryHandler(db *sql.DB, cfg *config.Config) *LibraryHandler {
-	return &LibraryHandler{DB: db, Cfg: cfg}
+	return &LibraryHandler{DB: db, Cfg: cfg, Scrobbler: scrobbler.NewForwarder(db, cfg), QueueEvents: playqueue.NewBroker()}
 }
 
 // GetLibraryHandler handles fetching a user's library
@@ -77,8 +81,9 @@ func (h *LibraryHandler) RateSongHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if req.Rating < 1 || req.Rating > 5 {
-		http.Error(w, "Rating must be between 1 and 5", http.StatusBadRequest)
+	// A rating of 0 clears the song's rating.
+	if req.Rating < 0 || req.Rating > 5 {
+		http.Error(w, "Rating must be between 0 and 5", http.StatusBadRequest)
 		return
 	}
 
@@ -25,8 +25,9 @@ func TestGetLibraryHandler(t *testing.T) {
 	defer db.Close()
 
 	// Mock the database query
-	rows := sqlmock.NewRows([]string{"id", "fingerprint_hash", "file_path", "title", "artist", "album", "year", "genre_id", "genre", "duration", "bitrate", "file_size", "last_modified", "rating"}).
-		AddRow(1, "hash1", "path1", "title1", "artist1", "album1", 2021, 1, "genre1", 180, 320, 12345, time.Now(), 5)
+	expectSession(mock)
+	rows := sqlmock.NewRows([]string{"id", "fingerprint_hash", "file_path", "title", "artist", "album", "year", "genre_id", "genre", "duration", "bitrate", "file_size", "last_modified", "rating", "play_count", "last_played"}).
+		AddRow(1, "hash1", "path1", "title1", "artist1", "album1", 2021, 1, "genre1", 180, 320, 12345, time.Now(), 5, 3, time.Now())
 
 	mock.ExpectQuery("^SELECT (.+) FROM songs s").
 		WithArgs(1).
@@ -39,11 +40,12 @@ func TestGetLibraryHandler(t *testing.T) {
 	libraryHandler := handlers.NewLibraryHandler(db, cfg)
 	playlistHandler := handlers.NewPlaylistHandler(db, cfg)
 	songHandler := handlers.NewSongHandler(db, cfg)
+	shareHandler := handlers.NewShareHandler(db, cfg)
 	subsonicHandler := subsonic.NewHandler(db, cfg)
-	r := router.New(authHandler, uploadHandler, libraryHandler, playlistHandler, songHandler, subsonicHandler)
+	r := router.New(authHandler, uploadHandler, libraryHandler, playlistHandler, songHandler, shareHandler, subsonicHandler)
 
 	// Create a new request
-	token, _ := auth.GenerateJWT(1, "default-secret")
+	token, _ := auth.GenerateAccessToken(1, testSessionID, "default-secret", time.Minute)
 	req, _ := http.NewRequest("GET", "/api/library", nil)
 	req.Header.Set("Authorization", "Bearer "+token)
 
@@ -65,6 +67,7 @@ func TestRateSongHandler(t *testing.T) {
 	defer db.Close()
 
 	// Mock the database query
+	expectSession(mock)
 	mock.ExpectExec("^INSERT INTO user_songs").
 		WithArgs(1, 1, 5).
 		WillReturnResult(sqlmock.NewResult(1, 1))
@@ -76,11 +79,12 @@ func TestRateSongHandler(t *testing.T) {
 	libraryHandler := handlers.NewLibraryHandler(db, cfg)
 	playlistHandler := handlers.NewPlaylistHandler(db, cfg)
 	songHandler := handlers.NewSongHandler(db, cfg)
+	shareHandler := handlers.NewShareHandler(db, cfg)
 	subsonicHandler := subsonic.NewHandler(db, cfg)
-	r := router.New(authHandler, uploadHandler, libraryHandler, playlistHandler, songHandler, subsonicHandler)
+	r := router.New(authHandler, uploadHandler, libraryHandler, playlistHandler, songHandler, shareHandler, subsonicHandler)
 
 	// Create a new request
-	token, _ := auth.GenerateJWT(1, "default-secret")
+	token, _ := auth.GenerateAccessToken(1, testSessionID, "default-secret", time.Minute)
 	body := `{"rating": 5}`
 	req, _ := http.NewRequest("POST", "/api/songs/1/rate", bytes.NewBufferString(body))
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"go-postgres-example/pkg/db"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// tooManyAttempts responds 429 Too Many Requests, telling the client when it
+// may try again.
+func tooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, fmt.Sprintf("Too many failed attempts, try again in %s", wait.Round(time.Second)), http.StatusTooManyRequests)
+}
+
+// loginFailed records a failed login. Errors are only logged, so a broken
+// limiter does not stop users from logging in.
+func (h *AuthHandler) loginFailed(username, ip string) {
+	if err := h.Limiter.Fail(username, ip); err != nil {
+		log.Printf("Failed to record failed login for %q: %v", username, err)
+	}
+}
+
+// AdminUnlockUser clears a user's failed logins and lifts any lockout.
+func (h *AuthHandler) AdminUnlockUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	username, err := db.GetUsernameByID(h.DB, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := h.Limiter.Unlock(username); err != nil {
+		http.Error(w, "Failed to unlock user", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "User unlocked"})
+}
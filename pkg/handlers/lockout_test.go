@@ -0,0 +1,99 @@
+package handlers_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/config"
+	"go-postgres-example/pkg/handlers"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLockoutTestHandler(t *testing.T) (*handlers.AuthHandler, sqlmock.Sqlmock) {
+	t.Helper()
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	cfg := &config.Config{
+		JWTSecret: "default-secret", AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Hour,
+		LoginMaxFailures: 5, LoginMaxFailuresPerIP: 20, LoginBaseDelay: time.Second, LoginLockout: 15 * time.Minute,
+	}
+	return handlers.NewAuthHandler(conn, cfg), mock
+}
+
+func TestLoginLockedOut(t *testing.T) {
+	h, mock := newLockoutTestHandler(t)
+
+	mock.ExpectQuery("FROM login_failures").
+		WithArgs("user:alice", "ip:192.0.2.1").
+		WillReturnRows(sqlmock.NewRows([]string{"seconds"}).AddRow(29.2))
+
+	req := httptest.NewRequest("POST", "/login", bytes.NewBufferString(`{"username": "alice", "password": "secret"}`))
+	req.RemoteAddr = "192.0.2.1:5000"
+	rr := httptest.NewRecorder()
+	h.Login(rr, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "30", rr.Header().Get("Retry-After"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLoginWrongPasswordRecordsFailure(t *testing.T) {
+	h, mock := newLockoutTestHandler(t)
+	hash, err := auth.HashPassword("secret")
+	require.NoError(t, err)
+
+	mock.ExpectQuery("FROM login_failures").
+		WillReturnRows(sqlmock.NewRows([]string{"seconds"}).AddRow(0))
+	mock.ExpectQuery("SELECT id, password_hash, totp_enabled FROM users").
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "password_hash", "totp_enabled"}).AddRow(1, hash, false))
+	mock.ExpectQuery("INSERT INTO login_failures").
+		WithArgs("user:alice", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(2))
+	mock.ExpectExec("UPDATE login_failures SET locked_until").
+		WithArgs("user:alice", 2.0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO login_failures").
+		WithArgs("ip:192.0.2.1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(2))
+
+	req := httptest.NewRequest("POST", "/login", bytes.NewBufferString(`{"username": "alice", "password": "wrong"}`))
+	req.RemoteAddr = "192.0.2.1:5000"
+	rr := httptest.NewRecorder()
+	h.Login(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdminUnlockUser(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("SELECT COALESCE\\(is_admin, FALSE\\), disabled FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"is_admin", "disabled"}).AddRow(true, false))
+	mock.ExpectQuery("SELECT username FROM users").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("Bob"))
+	mock.ExpectExec("DELETE FROM login_failures").
+		WithArgs("user:bob").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest("POST", "/api/admin/users/4/unlock", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/oidc"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// oidcCookie holds the state, nonce and PKCE verifier of a sign-in in progress.
+	oidcCookie = "oidc_flow"
+	// oidcCookieMaxAge is how long a user has to sign in at the provider, in seconds.
+	oidcCookieMaxAge = 600
+)
+
+// OIDCLogin starts single sign-on: it redirects to the identity provider,
+// remembering the state, nonce and PKCE verifier of the request in a cookie.
+func (h *AuthHandler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if h.OIDC == nil {
+		http.Error(w, "Single sign-on is not configured", http.StatusNotFound)
+		return
+	}
+
+	state, err := auth.GenerateRandomToken(16)
+	if err != nil {
+		http.Error(w, "Failed to start sign-on", http.StatusInternalServerError)
+		return
+	}
+	nonce, err := auth.GenerateRandomToken(16)
+	if err != nil {
+		http.Error(w, "Failed to start sign-on", http.StatusInternalServerError)
+		return
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		http.Error(w, "Failed to start sign-on", http.StatusInternalServerError)
+		return
+	}
+
+	authURL, err := h.OIDC.AuthCodeURL(r.Context(), state, nonce, challenge)
+	if err != nil {
+		log.Printf("OIDC login failed: %v", err)
+		http.Error(w, "Identity provider is unavailable", http.StatusBadGateway)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookie,
+		Value:    strings.Join([]string{state, nonce, verifier}, "."),
+		Path:     "/auth/oidc",
+		MaxAge:   oidcCookieMaxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || strings.HasPrefix(h.Cfg.PublicURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback completes single sign-on. The signed-in user is matched to an
+// account by subject, username or verified email, or created if auto
+// provisioning is on, and a session is started. The browser is sent to the
+// login page with the tokens in the URL fragment.
+func (h *AuthHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if h.OIDC == nil {
+		http.Error(w, "Single sign-on is not configured", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		http.Error(w, "Sign-on failed: "+errCode+" "+query.Get("error_description"), http.StatusUnauthorized)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcCookie)
+	if err != nil {
+		http.Error(w, "Sign-on expired; please try again", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcCookie, Path: "/auth/oidc", MaxAge: -1})
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 3 || query.Get("state") == "" || query.Get("state") != parts[0] {
+		http.Error(w, "Invalid sign-on state", http.StatusBadRequest)
+		return
+	}
+	if query.Get("code") == "" {
+		http.Error(w, "Authorization code is missing", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := h.OIDC.Exchange(r.Context(), query.Get("code"), parts[2], parts[1])
+	if err != nil {
+		log.Printf("OIDC callback failed: %v", err)
+		http.Error(w, "Sign-on failed", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := h.oidcUser(claims)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			http.Error(w, "No account exists for this user", http.StatusForbidden)
+		case db.ErrUsernameTaken:
+			http.Error(w, "An account with this username already exists and cannot be linked", http.StatusConflict)
+		case errNoOIDCUsername:
+			http.Error(w, "Identity provider did not return a username or email", http.StatusForbidden)
+		default:
+			http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	tokens, err := h.createSession(r, userID, "Single sign-on: "+r.UserAgent())
+	if err != nil {
+		if errors.Is(err, db.ErrAccountDisabled) {
+			http.Error(w, "Account is disabled", http.StatusForbidden)
+		} else {
+			http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		}
+		return
+	}
+	fragment := url.Values{
+		"token":         {tokens.Token},
+		"refresh_token": {tokens.RefreshToken},
+		"expires_in":    {strconv.Itoa(tokens.ExpiresIn)},
+	}
+	http.Redirect(w, r, h.Cfg.PublicURL+"/login#"+fragment.Encode(), http.StatusFound)
+}
+
+// errNoOIDCUsername is returned when the ID token has neither a username nor an email.
+var errNoOIDCUsername = errors.New("no username in ID token")
+
+// oidcUser returns the account of a signed-in user, creating it when auto
+// provisioning is on, and syncs its admin rights with the admin group. The
+// last admin keeps their rights.
+func (h *AuthHandler) oidcUser(claims *oidc.Claims) (int, error) {
+	username := claims.PreferredUsername
+	if username == "" {
+		username = claims.Email
+	}
+	if username == "" {
+		return 0, errNoOIDCUsername
+	}
+	email := ""
+	if claims.EmailVerified {
+		email = claims.Email
+	}
+
+	isAdmin := false
+	if h.Cfg.OIDCAdminGroup != "" {
+		for _, group := range claims.Groups {
+			if group == h.Cfg.OIDCAdminGroup {
+				isAdmin = true
+				break
+			}
+		}
+	}
+
+	userID, err := db.GetOIDCUser(h.DB, claims.Subject, username, email, h.Cfg.OIDCLinkByUsername)
+	if err == sql.ErrNoRows && h.Cfg.OIDCAutoProvision {
+		if email == "" {
+			email = username + "@local"
+		}
+		return db.CreateOIDCUser(h.DB, claims.Subject, username, email, isAdmin)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if h.Cfg.OIDCAdminGroup != "" {
+		err := db.SetUserAdmin(h.DB, userID, isAdmin)
+		if err == db.ErrLastAdmin {
+			log.Printf("Keeping admin rights of user %d, who is the last admin, though not in %q", userID, h.Cfg.OIDCAdminGroup)
+		} else if err != nil {
+			return 0, err
+		}
+	}
+	return userID, nil
+}
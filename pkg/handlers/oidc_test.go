@@ -0,0 +1,236 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/config"
+	"go-postgres-example/pkg/handlers"
+	"go-postgres-example/pkg/oidc"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startOIDCLogin runs the login handler and signs in at the fake provider,
+// returning the callback request the browser would make.
+func startOIDCLogin(t *testing.T, h *handlers.AuthHandler) *http.Request {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	h.OIDCLogin(rr, httptest.NewRequest("GET", "/auth/oidc/login", nil))
+	require.Equal(t, http.StatusFound, rr.Code, rr.Body.String())
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(rr.Header().Get("Location"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	req := httptest.NewRequest("GET", resp.Header.Get("Location"), nil)
+	req.AddCookie(cookies[0])
+	return req
+}
+
+func newOIDCAuthHandler(t *testing.T, claims map[string]interface{}, autoProvision bool) (*handlers.AuthHandler, sqlmock.Sqlmock) {
+	t.Helper()
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	provider := oidc.NewFakeProvider("biomuzak", "secret", claims)
+	t.Cleanup(provider.Close)
+
+	cfg := &config.Config{
+		JWTSecret:         "default-secret",
+		PublicURL:         "https://music.example.com",
+		AccessTokenTTL:    time.Minute,
+		RefreshTokenTTL:   time.Hour,
+		OIDCIssuerURL:     provider.Issuer(),
+		OIDCClientID:      "biomuzak",
+		OIDCClientSecret:  "secret",
+		OIDCRedirectURL:   "https://music.example.com/auth/oidc/callback",
+		OIDCAdminGroup:    "admins",
+		OIDCAutoProvision: autoProvision,
+	}
+	return handlers.NewAuthHandler(conn, cfg), mock
+}
+
+func TestOIDCCallbackProvisionsUser(t *testing.T) {
+	h, mock := newOIDCAuthHandler(t, map[string]interface{}{
+		"sub":                "user-1",
+		"preferred_username": "alice",
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"groups":             []string{"admins"},
+	}, true)
+
+	mock.ExpectQuery("SELECT id FROM users WHERE oidc_subject").
+		WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("UPDATE users SET oidc_subject").
+		WithArgs("user-1", "alice", "alice@example.com", false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("INSERT INTO users").
+		WithArgs("alice", "alice@example.com", true, "admin", "user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO sessions").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectExec("INSERT INTO refresh_tokens").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	rr := httptest.NewRecorder()
+	h.OIDCCallback(rr, startOIDCLogin(t, h))
+
+	require.Equal(t, http.StatusFound, rr.Code, rr.Body.String())
+	location, err := url.Parse(rr.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "https://music.example.com/login", location.Scheme+"://"+location.Host+location.Path)
+	fragment, err := url.ParseQuery(location.Fragment)
+	require.NoError(t, err)
+	assert.NotEmpty(t, fragment.Get("refresh_token"))
+
+	token, err := auth.ValidateJWT(fragment.Get("token"), "default-secret")
+	require.NoError(t, err)
+	claims := token.Claims.(*auth.Claims)
+	assert.Equal(t, 4, claims.UserID)
+	assert.Equal(t, 9, claims.SessionID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOIDCCallbackUnknownUserWithoutProvisioning(t *testing.T) {
+	h, mock := newOIDCAuthHandler(t, map[string]interface{}{
+		"sub":                "user-2",
+		"preferred_username": "bob",
+		"email":              "bob@example.com",
+	}, false)
+
+	mock.ExpectQuery("SELECT id FROM users WHERE oidc_subject").
+		WithArgs("user-2").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	// The email is not verified, so nothing can be matched.
+	mock.ExpectQuery("UPDATE users SET oidc_subject").
+		WithArgs("user-2", "bob", "", false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs("bob").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	rr := httptest.NewRecorder()
+	h.OIDCCallback(rr, startOIDCLogin(t, h))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOIDCCallbackDoesNotTakeOverLocalAccount(t *testing.T) {
+	h, mock := newOIDCAuthHandler(t, map[string]interface{}{
+		"sub":                "user-3",
+		"preferred_username": "admin",
+		"groups":             []string{"admins"},
+	}, true)
+
+	mock.ExpectQuery("SELECT id FROM users WHERE oidc_subject").
+		WithArgs("user-3").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("UPDATE users SET oidc_subject").
+		WithArgs("user-3", "admin", "", false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs("admin").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	rr := httptest.NewRecorder()
+	h.OIDCCallback(rr, startOIDCLogin(t, h))
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOIDCCallbackSyncsAdminGroup(t *testing.T) {
+	h, mock := newOIDCAuthHandler(t, map[string]interface{}{
+		"sub":                "user-1",
+		"preferred_username": "alice",
+		"groups":             "music",
+	}, false)
+
+	mock.ExpectQuery("SELECT id FROM users WHERE oidc_subject").
+		WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM users WHERE is_admin").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(4))
+	mock.ExpectExec("UPDATE users SET is_admin").
+		WithArgs(4, false).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO sessions").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectExec("INSERT INTO refresh_tokens").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	rr := httptest.NewRecorder()
+	h.OIDCCallback(rr, startOIDCLogin(t, h))
+
+	assert.Equal(t, http.StatusFound, rr.Code, rr.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOIDCCallbackKeepsLastAdmin(t *testing.T) {
+	h, mock := newOIDCAuthHandler(t, map[string]interface{}{
+		"sub":                "user-1",
+		"preferred_username": "alice",
+		"groups":             "music",
+	}, false)
+
+	mock.ExpectQuery("SELECT id FROM users WHERE oidc_subject").
+		WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM users WHERE is_admin").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO sessions").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectExec("INSERT INTO refresh_tokens").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	rr := httptest.NewRecorder()
+	h.OIDCCallback(rr, startOIDCLogin(t, h))
+
+	assert.Equal(t, http.StatusFound, rr.Code, rr.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOIDCCallbackRejectsWrongState(t *testing.T) {
+	h, mock := newOIDCAuthHandler(t, map[string]interface{}{"sub": "user-1"}, false)
+
+	req := startOIDCLogin(t, h)
+	q := req.URL.Query()
+	q.Set("state", "forged")
+	req.URL.RawQuery = q.Encode()
+
+	rr := httptest.NewRecorder()
+	h.OIDCCallback(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOIDCLoginNotConfigured(t *testing.T) {
+	r, _, _ := newTestRouter(t)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/auth/oidc/login", nil))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -3,12 +3,15 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"go-postgres-example/pkg/config"
 	"go-postgres-example/pkg/db"
 	"go-postgres-example/pkg/middleware"
 	"go-postgres-example/pkg/models"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -25,8 +28,11 @@ func NewPlaylistHandler(db *sql.DB, cfg *config.Config) *PlaylistHandler {
 }
 
 // CreatePlaylistRequest defines the structure for the create playlist request.
+// Setting Rules creates a smart playlist. Visibility defaults to private.
 type CreatePlaylistRequest struct {
-	Name string `json:"name"`
+	Name       string             `json:"name"`
+	Rules      *models.SmartRules `json:"rules,omitempty"`
+	Visibility string             `json:"visibility,omitempty"`
 }
 
 // CreatePlaylistHandler handles the creation of a new playlist.
@@ -47,19 +53,42 @@ func (h *PlaylistHandler) CreatePlaylistHandler(w http.ResponseWriter, r *http.R
 		http.Error(w, "Playlist name cannot be empty", http.StatusBadRequest)
 		return
 	}
+	if req.Visibility != "" && !validVisibility(req.Visibility) {
+		http.Error(w, "Invalid visibility, must be private or public", http.StatusBadRequest)
+		return
+	}
 
-	playlist, err := db.CreatePlaylist(h.DB, userID, req.Name)
+	var playlist *models.Playlist
+	var err error
+	if req.Rules != nil {
+		if _, _, err := db.CompileSmartRules(req.Rules, userID); err != nil {
+			http.Error(w, "Invalid smart playlist rules: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		playlist, err = db.CreateSmartPlaylist(h.DB, userID, req.Name, req.Rules)
+	} else {
+		playlist, err = db.CreatePlaylist(h.DB, userID, req.Name)
+	}
 	if err != nil {
 		http.Error(w, "Failed to create playlist", http.StatusInternalServerError)
 		return
 	}
+	if req.Visibility == models.PlaylistPublic {
+		if err := db.SetPlaylistVisibility(h.DB, userID, playlist.ID, req.Visibility); err != nil {
+			http.Error(w, "Failed to create playlist", http.StatusInternalServerError)
+			return
+		}
+		playlist.Visibility = req.Visibility
+		playlist.Version++
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(playlist)
 }
 
-// GetUserPlaylistsHandler handles fetching all playlists for the authenticated user.
+// GetUserPlaylistsHandler handles fetching all playlists the authenticated user can
+// see: their own, those shared with them and public ones. Each has the user's role.
 func (h *PlaylistHandler) GetUserPlaylistsHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
@@ -102,31 +131,42 @@ func (h *PlaylistHandler) GetPlaylistHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	songs, err := db.GetPlaylistSongs(h.DB, playlistID)
+	songs, err := db.GetPlaylistContents(h.DB, playlist)
 	if err != nil {
 		http.Error(w, "Failed to get playlist songs", http.StatusInternalServerError)
 		return
 	}
 
 	fullPlaylist := models.FullPlaylist{
-		ID:        playlist.ID,
-		UserID:    playlist.UserID,
-		Name:      playlist.Name,
-		CreatedAt: playlist.CreatedAt,
-		UpdatedAt: playlist.UpdatedAt,
-		Songs:     songs,
+		ID:         playlist.ID,
+		UserID:     playlist.UserID,
+		Owner:      playlist.Owner,
+		Name:       playlist.Name,
+		Smart:      playlist.Smart,
+		ReadOnly:   playlist.ReadOnly,
+		Rules:      playlist.Rules,
+		Version:    playlist.Version,
+		Visibility: playlist.Visibility,
+		Role:       playlist.Role,
+		CreatedAt:  playlist.CreatedAt,
+		UpdatedAt:  playlist.UpdatedAt,
+		Songs:      songs,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", playlistETag(playlist.Version))
 	json.NewEncoder(w).Encode(fullPlaylist)
 }
 
 // UpdatePlaylistRequest defines the structure for the update playlist request.
+// Rules may only be set on smart playlists; only the owner may change Visibility.
 type UpdatePlaylistRequest struct {
-	Name string `json:"name"`
+	Name       string             `json:"name"`
+	Rules      *models.SmartRules `json:"rules,omitempty"`
+	Visibility string             `json:"visibility,omitempty"`
 }
 
-// UpdatePlaylistHandler handles updating a playlist's details (e.g., name).
+// UpdatePlaylistHandler handles updating a playlist's details (e.g., name, smart rules or visibility).
 func (h *PlaylistHandler) UpdatePlaylistHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
@@ -147,26 +187,66 @@ func (h *PlaylistHandler) UpdatePlaylistHandler(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	if req.Name == "" {
+	if req.Name == "" && req.Rules == nil && req.Visibility == "" {
 		http.Error(w, "Playlist name cannot be empty", http.StatusBadRequest)
 		return
 	}
+	if req.Visibility != "" && !validVisibility(req.Visibility) {
+		http.Error(w, "Invalid visibility, must be private or public", http.StatusBadRequest)
+		return
+	}
 
-	err = db.UpdatePlaylistName(h.DB, userID, playlistID, req.Name)
+	version, err := expectedVersion(r)
+	if err != nil {
+		http.Error(w, "Invalid If-Match header", http.StatusBadRequest)
+		return
+	}
+
+	playlist, err := db.GetPlaylistByID(h.DB, userID, playlistID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			http.Error(w, "Playlist not found or you don't have permission to update it", http.StatusNotFound)
+			http.Error(w, "Playlist not found", http.StatusNotFound)
 		} else {
+			http.Error(w, "Failed to verify playlist", http.StatusInternalServerError)
+		}
+		return
+	}
+	if !playlist.CanEdit() || (req.Visibility != "" && !playlist.IsOwner()) {
+		http.Error(w, "You don't have permission to update this playlist", http.StatusForbidden)
+		return
+	}
+	if req.Rules != nil {
+		if _, _, err := db.CompileSmartRules(req.Rules, playlist.UserID); err != nil {
+			http.Error(w, "Invalid smart playlist rules: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	update := models.PlaylistUpdate{Name: req.Name, Rules: req.Rules}
+	if req.Visibility != playlist.Visibility {
+		update.Visibility = req.Visibility
+	}
+	// The version is checked again as the playlist is updated, so concurrent
+	// edits based on the same version cannot both succeed
+	newVersion, err := db.UpdatePlaylist(h.DB, userID, playlistID, version, update)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrVersionConflict):
+			http.Error(w, "Playlist has been modified; reload it and try again", http.StatusPreconditionFailed)
+		case err == sql.ErrNoRows:
+			http.Error(w, "Playlist not found or you don't have permission to update it", http.StatusNotFound)
+		default:
 			http.Error(w, "Failed to update playlist", http.StatusInternalServerError)
 		}
 		return
 	}
 
+	w.Header().Set("ETag", playlistETag(newVersion))
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Playlist updated successfully"})
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": "Playlist updated successfully", "version": newVersion})
 }
 
-// DeletePlaylistHandler handles deleting a playlist.
+// DeletePlaylistHandler handles deleting a playlist. Only the owner may delete it.
 func (h *PlaylistHandler) DeletePlaylistHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
@@ -195,13 +275,15 @@ func (h *PlaylistHandler) DeletePlaylistHandler(w http.ResponseWriter, r *http.R
 	json.NewEncoder(w).Encode(map[string]string{"message": "Playlist deleted successfully"})
 }
 
-// AddSongToPlaylistRequest defines the structure for adding a song to a playlist.
+// AddSongToPlaylistRequest defines the structure for adding songs to a playlist.
+// Either SongID or SongIDs (for a bulk add) must be set.
 type AddSongToPlaylistRequest struct {
-	SongID   int `json:"song_id"`
-	Position int `json:"position"` // Optional, defaults to end if 0 or less
+	SongID   int   `json:"song_id"`
+	SongIDs  []int `json:"song_ids"`
+	Position int   `json:"position"` // Optional, defaults to end if 0 or less
 }
 
-// AddSongToPlaylistHandler handles adding a song to a playlist.
+// AddSongToPlaylistHandler handles adding one or more songs to a playlist.
 func (h *PlaylistHandler) AddSongToPlaylistHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
@@ -216,7 +298,7 @@ func (h *PlaylistHandler) AddSongToPlaylistHandler(w http.ResponseWriter, r *htt
 		return
 	}
 
-	_, err = db.GetPlaylistByID(h.DB, userID, playlistID)
+	playlist, err := db.GetPlaylistByID(h.DB, userID, playlistID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Playlist not found", http.StatusNotFound)
@@ -225,6 +307,14 @@ func (h *PlaylistHandler) AddSongToPlaylistHandler(w http.ResponseWriter, r *htt
 		}
 		return
 	}
+	if !playlist.CanEdit() {
+		http.Error(w, "You don't have permission to edit this playlist", http.StatusForbidden)
+		return
+	}
+	if playlist.ReadOnly {
+		http.Error(w, "Smart playlists are read-only", http.StatusConflict)
+		return
+	}
 
 	var req AddSongToPlaylistRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -232,19 +322,44 @@ func (h *PlaylistHandler) AddSongToPlaylistHandler(w http.ResponseWriter, r *htt
 		return
 	}
 
-	if req.SongID <= 0 {
+	songIDs := req.SongIDs
+	if req.SongID != 0 {
+		songIDs = append([]int{req.SongID}, songIDs...)
+	}
+	if len(songIDs) == 0 {
 		http.Error(w, "Invalid song ID", http.StatusBadRequest)
 		return
 	}
 
-	err = db.AddSongToPlaylist(h.DB, playlistID, req.SongID, req.Position)
+	version, err := expectedVersion(r)
 	if err != nil {
-		http.Error(w, "Failed to add song to playlist", http.StatusInternalServerError)
+		http.Error(w, "Invalid If-Match header", http.StatusBadRequest)
 		return
 	}
 
+	ops := make([]models.PlaylistOperation, 0, len(songIDs))
+	for i, songID := range songIDs {
+		if songID <= 0 {
+			http.Error(w, "Invalid song ID", http.StatusBadRequest)
+			return
+		}
+		// Keep bulk-added songs together, in the order given.
+		position := req.Position
+		if position > 0 {
+			position += i
+		}
+		ops = append(ops, models.PlaylistOperation{Op: "insert", SongID: songID, Position: position})
+	}
+
+	newVersion, err := db.EditPlaylistSongs(h.DB, userID, playlistID, version, ops)
+	if err != nil {
+		writePlaylistEditError(w, err, "Failed to add song to playlist")
+		return
+	}
+
+	w.Header().Set("ETag", playlistETag(newVersion))
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Song added to playlist successfully"})
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": "Song added to playlist successfully", "version": newVersion})
 }
 
 // RemoveSongFromPlaylistHandler handles removing a song from a playlist.
@@ -262,7 +377,7 @@ func (h *PlaylistHandler) RemoveSongFromPlaylistHandler(w http.ResponseWriter, r
 		return
 	}
 
-	_, err = db.GetPlaylistByID(h.DB, userID, playlistID)
+	playlist, err := db.GetPlaylistByID(h.DB, userID, playlistID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Playlist not found", http.StatusNotFound)
@@ -271,6 +386,14 @@ func (h *PlaylistHandler) RemoveSongFromPlaylistHandler(w http.ResponseWriter, r
 		}
 		return
 	}
+	if !playlist.CanEdit() {
+		http.Error(w, "You don't have permission to edit this playlist", http.StatusForbidden)
+		return
+	}
+	if playlist.ReadOnly {
+		http.Error(w, "Smart playlists are read-only", http.StatusConflict)
+		return
+	}
 
 	songIDStr := chi.URLParam(r, "songID")
 	songID, err := strconv.Atoi(songIDStr)
@@ -279,12 +402,121 @@ func (h *PlaylistHandler) RemoveSongFromPlaylistHandler(w http.ResponseWriter, r
 		return
 	}
 
-	err = db.RemoveSongFromPlaylist(h.DB, playlistID, songID)
+	version, err := expectedVersion(r)
+	if err != nil {
+		http.Error(w, "Invalid If-Match header", http.StatusBadRequest)
+		return
+	}
+
+	newVersion, err := db.EditPlaylistSongs(h.DB, userID, playlistID, version, []models.PlaylistOperation{
+		{Op: "remove", SongID: songID},
+	})
+	if err != nil {
+		writePlaylistEditError(w, err, "Failed to remove song from playlist")
+		return
+	}
+
+	w.Header().Set("ETag", playlistETag(newVersion))
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": "Song removed from playlist successfully", "version": newVersion})
+}
+
+// EditPlaylistSongsRequest defines the structure for a batch of playlist edits.
+type EditPlaylistSongsRequest struct {
+	Operations []models.PlaylistOperation `json:"operations"`
+}
+
+// EditPlaylistSongsHandler applies move, insert and remove operations to a playlist
+// in one transaction. Clients should send the playlist's ETag in If-Match so that
+// edits based on a stale copy are rejected with 412 Precondition Failed.
+func (h *PlaylistHandler) EditPlaylistSongsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	playlistIDStr := chi.URLParam(r, "playlistID")
+	playlistID, err := strconv.Atoi(playlistIDStr)
+	if err != nil {
+		http.Error(w, "Invalid playlist ID", http.StatusBadRequest)
+		return
+	}
+
+	playlist, err := db.GetPlaylistByID(h.DB, userID, playlistID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Playlist not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to verify playlist", http.StatusInternalServerError)
+		}
+		return
+	}
+	if !playlist.CanEdit() {
+		http.Error(w, "You don't have permission to edit this playlist", http.StatusForbidden)
+		return
+	}
+	if playlist.ReadOnly {
+		http.Error(w, "Smart playlists are read-only", http.StatusConflict)
+		return
+	}
+
+	var req EditPlaylistSongsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Operations) == 0 {
+		http.Error(w, "No operations given", http.StatusBadRequest)
+		return
+	}
+
+	version, err := expectedVersion(r)
 	if err != nil {
-		http.Error(w, "Failed to remove song from playlist", http.StatusInternalServerError)
+		http.Error(w, "Invalid If-Match header", http.StatusBadRequest)
 		return
 	}
 
+	newVersion, err := db.EditPlaylistSongs(h.DB, userID, playlistID, version, req.Operations)
+	if err != nil {
+		writePlaylistEditError(w, err, "Failed to update playlist songs")
+		return
+	}
+
+	w.Header().Set("ETag", playlistETag(newVersion))
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Song removed from playlist successfully"})
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": "Playlist updated successfully", "version": newVersion})
+}
+
+// validVisibility reports whether v is a known playlist visibility.
+func validVisibility(v string) bool {
+	return v == models.PlaylistPrivate || v == models.PlaylistPublic
+}
+
+// playlistETag formats a playlist version as an HTTP entity tag.
+func playlistETag(version int) string {
+	return fmt.Sprintf("%q", strconv.Itoa(version))
+}
+
+// expectedVersion reads the playlist version a client based its edit on from the
+// If-Match header. It returns 0 when the header is absent or "*".
+func expectedVersion(r *http.Request) (int, error) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return 0, nil
+	}
+	ifMatch = strings.TrimPrefix(ifMatch, "W/")
+	return strconv.Atoi(strings.Trim(ifMatch, `"`))
+}
+
+// writePlaylistEditError maps errors from playlist edits to HTTP responses.
+func writePlaylistEditError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, db.ErrVersionConflict):
+		http.Error(w, "Playlist has been modified; reload it and try again", http.StatusPreconditionFailed)
+	case errors.Is(err, db.ErrInvalidPlaylistOperation):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, message, http.StatusInternalServerError)
+	}
 }
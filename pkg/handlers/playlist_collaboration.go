@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/middleware"
+	"go-postgres-example/pkg/models"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// SetCollaboratorRequest defines the structure for sharing a playlist with a user.
+type SetCollaboratorRequest struct {
+	Username string `json:"username"`
+	Role     string `json:"role"` // "viewer" or "editor"
+}
+
+// GetCollaboratorsHandler lists the users a playlist is shared with.
+func (h *PlaylistHandler) GetCollaboratorsHandler(w http.ResponseWriter, r *http.Request) {
+	playlist, ok := h.loadPlaylist(w, r)
+	if !ok {
+		return
+	}
+
+	collaborators, err := db.GetPlaylistCollaborators(h.DB, playlist.ID)
+	if err != nil {
+		http.Error(w, "Failed to get collaborators", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collaborators)
+}
+
+// SetCollaboratorHandler shares a playlist with a user as a viewer or editor, or
+// changes their role. Only the owner may do this.
+func (h *PlaylistHandler) SetCollaboratorHandler(w http.ResponseWriter, r *http.Request) {
+	playlist, ok := h.loadPlaylist(w, r)
+	if !ok {
+		return
+	}
+	if !playlist.IsOwner() {
+		http.Error(w, "Only the owner can manage collaborators", http.StatusForbidden)
+		return
+	}
+
+	var req SetCollaboratorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Role != models.PlaylistViewer && req.Role != models.PlaylistEditor {
+		http.Error(w, "Invalid role, must be viewer or editor", http.StatusBadRequest)
+		return
+	}
+
+	collaboratorID, err := db.GetUserIDByUsername(h.DB, req.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		}
+		return
+	}
+	if collaboratorID == playlist.UserID {
+		http.Error(w, "The owner cannot be a collaborator", http.StatusBadRequest)
+		return
+	}
+
+	if err := db.SetPlaylistCollaborator(h.DB, playlist.UserID, playlist.ID, collaboratorID, req.Role); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Playlist not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to share playlist", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Collaborator saved successfully"})
+}
+
+// RemoveCollaboratorHandler stops sharing a playlist with a user. The owner may
+// remove anyone; collaborators may leave a playlist by removing themselves.
+func (h *PlaylistHandler) RemoveCollaboratorHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	playlistID, err := strconv.Atoi(chi.URLParam(r, "playlistID"))
+	if err != nil {
+		http.Error(w, "Invalid playlist ID", http.StatusBadRequest)
+		return
+	}
+	collaboratorID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := db.RemovePlaylistCollaborator(h.DB, userID, playlistID, collaboratorID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Collaborator not found or you don't have permission to remove them", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to remove collaborator", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetPlaylistActivityHandler lists the most recent changes made to a playlist and
+// who made them. The optional limit query parameter defaults to 50.
+func (h *PlaylistHandler) GetPlaylistActivityHandler(w http.ResponseWriter, r *http.Request) {
+	playlist, ok := h.loadPlaylist(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 || l > 500 {
+			http.Error(w, "Invalid limit, must be between 1 and 500", http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+
+	activity, err := db.GetPlaylistActivity(h.DB, playlist.ID, limit)
+	if err != nil {
+		http.Error(w, "Failed to get playlist activity", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(activity)
+}
+
+// loadPlaylist reads the playlist named in the URL, checking that the user can see
+// it. It writes an error response and returns false on failure.
+func (h *PlaylistHandler) loadPlaylist(w http.ResponseWriter, r *http.Request) (*models.Playlist, bool) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	playlistID, err := strconv.Atoi(chi.URLParam(r, "playlistID"))
+	if err != nil {
+		http.Error(w, "Invalid playlist ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	playlist, err := db.GetPlaylistByID(h.DB, userID, playlistID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Playlist not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get playlist", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return playlist, true
+}
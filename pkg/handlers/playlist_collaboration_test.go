@@ -0,0 +1,215 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-postgres-example/pkg/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddSongToPlaylistHandlerViewerForbidden(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("SELECT (.+) FROM playlists").
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows(playlistColumns).AddRow(5, 2, "Office", nil, 1, time.Now(), time.Now(), "public", "bob", "viewer"))
+
+	req, _ := http.NewRequest("POST", "/api/playlists/5/songs", bytes.NewBufferString(`{"song_id": 9}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddSongToPlaylistHandlerEditor(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("SELECT (.+) FROM playlists").
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows(playlistColumns).AddRow(5, 2, "Office", nil, 1, time.Now(), time.Now(), "private", "bob", "editor"))
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE playlists").WithArgs(5, 0, 1).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+	mock.ExpectQuery("SELECT id, song_id, position FROM playlist_songs").WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "song_id", "position"}))
+	mock.ExpectQuery("FROM unnest").WithArgs("{9}").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec("INSERT INTO playlist_songs").WithArgs(5, 9, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO playlist_activity").WithArgs(5, 1, "add", "{9}").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	req, _ := http.NewRequest("POST", "/api/playlists/5/songs", bytes.NewBufferString(`{"song_id": 9}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdatePlaylistHandlerVisibilityOwnerOnly(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("SELECT (.+) FROM playlists").
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows(playlistColumns).AddRow(5, 2, "Office", nil, 1, time.Now(), time.Now(), "private", "bob", "editor"))
+
+	req, _ := http.NewRequest("PUT", "/api/playlists/5", bytes.NewBufferString(`{"visibility": "public"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdatePlaylistHandlerVisibility(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("SELECT (.+) FROM playlists").
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows(playlistColumns).AddRow(5, 1, "Office", nil, 1, time.Now(), time.Now(), "private", "alice", "owner"))
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE playlists").WithArgs(5, 0, 1).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+	mock.ExpectExec("UPDATE playlists SET visibility").
+		WithArgs("public", 5, 1, "visibility").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	req, _ := http.NewRequest("PUT", "/api/playlists/5", bytes.NewBufferString(`{"visibility": "public"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, `"2"`, rr.Header().Get("ETag"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdatePlaylistHandlerVersionConflict(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	// The playlist was at the expected version when read, but another edit
+	// changed it before the update
+	mock.ExpectQuery("SELECT (.+) FROM playlists").
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows(playlistColumns).AddRow(5, 1, "Office", nil, 3, time.Now(), time.Now(), "private", "alice", "owner"))
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE playlists").WithArgs(5, 3, 1).WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectRollback()
+
+	req, _ := http.NewRequest("PUT", "/api/playlists/5", bytes.NewBufferString(`{"name": "Work"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-Match", `"3"`)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddSongToPlaylistHandlerUnknownSong(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("SELECT (.+) FROM playlists").
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows(playlistColumns).AddRow(5, 1, "Office", nil, 1, time.Now(), time.Now(), "private", "alice", "owner"))
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE playlists").WithArgs(5, 0, 1).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+	mock.ExpectQuery("SELECT id, song_id, position FROM playlist_songs").WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "song_id", "position"}))
+	mock.ExpectQuery("FROM unnest").WithArgs("{404}").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(404))
+	mock.ExpectRollback()
+
+	req, _ := http.NewRequest("POST", "/api/playlists/5/songs", bytes.NewBufferString(`{"song_id": 404}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "song 404 not found")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetCollaboratorHandler(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("SELECT (.+) FROM playlists").
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows(playlistColumns).AddRow(5, 1, "Office", nil, 1, time.Now(), time.Now(), "private", "alice", "owner"))
+	mock.ExpectQuery("SELECT id FROM users").
+		WithArgs("bob").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectExec("INSERT INTO playlist_collaborators").
+		WithArgs(5, 1, 2, "editor", "collaborator").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req, _ := http.NewRequest("PUT", "/api/playlists/5/collaborators", bytes.NewBufferString(`{"username": "bob", "role": "editor"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetCollaboratorHandlerInvalidRole(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("SELECT (.+) FROM playlists").
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows(playlistColumns).AddRow(5, 1, "Office", nil, 1, time.Now(), time.Now(), "private", "alice", "owner"))
+
+	req, _ := http.NewRequest("PUT", "/api/playlists/5/collaborators", bytes.NewBufferString(`{"username": "bob", "role": "owner"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetPlaylistActivityHandler(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("SELECT (.+) FROM playlists").
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows(playlistColumns).AddRow(5, 2, "Office", nil, 3, time.Now(), time.Now(), "private", "bob", "viewer"))
+	mock.ExpectQuery("FROM playlist_activity a").
+		WithArgs(5, 50).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "username", "action", "song_id", "title", "details", "created_at"}).
+			AddRow(2, 2, "bob", "add", 9, "So What", "", time.Now()).
+			AddRow(1, 2, "bob", "rename", nil, nil, "Office", time.Now()))
+
+	req, _ := http.NewRequest("GET", "/api/playlists/5/activity", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var activity []models.PlaylistActivity
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &activity))
+	require.Len(t, activity, 2)
+	assert.Equal(t, "So What", activity[0].SongTitle)
+	assert.Equal(t, "bob", activity[0].Username)
+	assert.Nil(t, activity[1].SongID)
+	assert.Equal(t, "Office", activity[1].Details)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
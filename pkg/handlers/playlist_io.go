@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/download"
+	"go-postgres-example/pkg/middleware"
+	"go-postgres-example/pkg/playlistio"
+	"io"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// maxPlaylistFileSize caps the size of an imported playlist file.
+	maxPlaylistFileSize = 10 << 20
+	// durationTolerance is how far apart (in seconds) an entry and a song may be to still match.
+	durationTolerance = 5
+	// minTitleScore and minTitleArtistScore are the trigram similarity thresholds for fuzzy
+	// matching on the title alone, or on the title and artist combined.
+	minTitleScore       = 0.6
+	minTitleArtistScore = 1.2
+)
+
+// ExportPlaylistHandler handles exporting a playlist as M3U8, PLS, XSPF or JSPF.
+func (h *PlaylistHandler) ExportPlaylistHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	playlistIDStr := chi.URLParam(r, "playlistID")
+	playlistID, err := strconv.Atoi(playlistIDStr)
+	if err != nil {
+		http.Error(w, "Invalid playlist ID", http.StatusBadRequest)
+		return
+	}
+
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = playlistio.FormatM3U8
+	}
+	if !playlistio.IsSupported(format) {
+		http.Error(w, "Unsupported format; use m3u8, pls, xspf or jspf", http.StatusBadRequest)
+		return
+	}
+
+	playlist, err := db.GetPlaylistByID(h.DB, userID, playlistID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Playlist not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get playlist", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	songs, err := db.GetPlaylistContents(h.DB, playlist)
+	if err != nil {
+		http.Error(w, "Failed to get playlist songs", http.StatusInternalServerError)
+		return
+	}
+
+	out := &playlistio.Playlist{Title: playlist.Name}
+	for _, song := range songs {
+		// Locations are relative to the upload directory so an exported playlist
+		// can be re-imported on another server with the same library.
+		location, err := filepath.Rel(h.Cfg.UploadDir, song.FilePath)
+		if err != nil || strings.HasPrefix(location, "..") {
+			location = filepath.Base(song.FilePath)
+		}
+		out.Entries = append(out.Entries, playlistio.Entry{
+			Location: filepath.ToSlash(location),
+			Title:    song.Title,
+			Artist:   song.Artist,
+			Album:    song.Album,
+			Duration: song.Duration,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := playlistio.Write(format, &buf, out); err != nil {
+		http.Error(w, "Failed to export playlist", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", playlistio.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", safeFileName(playlist.Name)+"."+format))
+	w.Write(buf.Bytes())
+}
+
+// UnmatchedEntry describes a playlist file entry that could not be found in the library.
+type UnmatchedEntry struct {
+	Index int `json:"index"`
+	playlistio.Entry
+}
+
+// ImportPlaylistResponse reports the outcome of a playlist import.
+type ImportPlaylistResponse struct {
+	PlaylistID int              `json:"playlist_id"`
+	Name       string           `json:"name"`
+	Created    bool             `json:"created"`
+	Total      int              `json:"total"`
+	Matched    int              `json:"matched"`
+	Unmatched  []UnmatchedEntry `json:"unmatched"`
+}
+
+// ImportPlaylistHandler handles importing an M3U8, PLS, XSPF or JSPF playlist.
+// The file is sent either as the "file" field of a multipart form or as the raw
+// request body. Re-importing an unchanged file updates the playlist it created
+// before.
+func (h *PlaylistHandler) ImportPlaylistHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPlaylistFileSize)
+
+	var data []byte
+	var fileName string
+	name := r.URL.Query().Get("name")
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(maxPlaylistFileSize); err != nil {
+			http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "No playlist file was uploaded", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		if data, err = io.ReadAll(file); err != nil {
+			http.Error(w, "Failed to read playlist file", http.StatusBadRequest)
+			return
+		}
+		fileName = header.Filename
+		if formName := r.FormValue("name"); formName != "" {
+			name = formName
+		}
+	} else {
+		var err error
+		if data, err = io.ReadAll(r.Body); err != nil {
+			http.Error(w, "Failed to read playlist file", http.StatusBadRequest)
+			return
+		}
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		http.Error(w, "Playlist file is empty", http.StatusBadRequest)
+		return
+	}
+
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = playlistio.DetectFormat(fileName, data)
+	}
+	if !playlistio.IsSupported(format) {
+		http.Error(w, "Unsupported format; use m3u8, pls, xspf or jspf", http.StatusBadRequest)
+		return
+	}
+
+	parsed, err := playlistio.Parse(format, bytes.NewReader(data))
+	if err != nil {
+		http.Error(w, "Failed to parse playlist: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if name == "" {
+		name = parsed.Title
+	}
+	if name == "" && fileName != "" {
+		name = strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	}
+	if name == "" {
+		name = "Imported playlist"
+	}
+
+	resp := ImportPlaylistResponse{Name: name, Total: len(parsed.Entries), Unmatched: []UnmatchedEntry{}}
+	var songIDs []int
+	for i, entry := range parsed.Entries {
+		songID, found, err := resolveEntry(h.DB, entry)
+		if err != nil {
+			http.Error(w, "Failed to match playlist entries", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			resp.Unmatched = append(resp.Unmatched, UnmatchedEntry{Index: i, Entry: entry})
+			continue
+		}
+		songIDs = append(songIDs, songID)
+		resp.Matched++
+	}
+
+	importKey := importKeyFor(format, fileName, data)
+	playlist, err := db.GetPlaylistByImportKey(h.DB, userID, importKey)
+	if err == sql.ErrNoRows {
+		playlist, err = db.CreateImportedPlaylist(h.DB, userID, name, importKey)
+		resp.Created = true
+	}
+	if err != nil {
+		http.Error(w, "Failed to save playlist", http.StatusInternalServerError)
+		return
+	}
+	resp.PlaylistID = playlist.ID
+	resp.Name = playlist.Name
+
+	if err := db.ReplacePlaylistSongs(h.DB, userID, playlist.ID, songIDs); err != nil {
+		http.Error(w, "Failed to save playlist songs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// resolveEntry finds the library song for a playlist entry, first by path and
+// then by fuzzy artist/title matching checked against the duration.
+func resolveEntry(conn *sql.DB, entry playlistio.Entry) (int, bool, error) {
+	if entry.Location != "" {
+		location := playlistio.NormalizeLocation(entry.Location)
+		songID, err := db.FindSongIDByPath(conn, location, path.Base(location))
+		if err == nil {
+			return songID, true, nil
+		}
+		if err != sql.ErrNoRows {
+			return 0, false, err
+		}
+	}
+
+	entry.GuessTags()
+	if entry.Title == "" {
+		return 0, false, nil
+	}
+
+	matches, err := db.FindSongsByTags(conn, entry.Artist, entry.Title, 5)
+	if err != nil {
+		return 0, false, err
+	}
+
+	threshold := minTitleScore
+	if entry.Artist != "" {
+		threshold = minTitleArtistScore
+	}
+	for _, m := range matches {
+		if m.Score < threshold {
+			break
+		}
+		if entry.Duration > 0 && m.Duration > 0 {
+			diff := entry.Duration - m.Duration
+			if diff < -durationTolerance || diff > durationTolerance {
+				continue
+			}
+		}
+		return m.ID, true, nil
+	}
+	return 0, false, nil
+}
+
+// importKeyFor identifies the source of an import by its content and, for
+// uploaded files, their name, so that re-running it is idempotent while
+// different playlists never replace each other.
+func importKeyFor(format, fileName string, data []byte) string {
+	sum := sha256.Sum256(data)
+	if fileName != "" {
+		return format + ":file:" + fileName + ":sha256:" + hex.EncodeToString(sum[:])
+	}
+	return format + ":sha256:" + hex.EncodeToString(sum[:])
+}
+
+// safeFileName strips characters that are not safe in a download file name.
+func safeFileName(name string) string {
+	return download.SafeName(name, "playlist")
+}
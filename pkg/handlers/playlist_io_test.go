@@ -0,0 +1,210 @@
+package handlers_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/config"
+	"go-postgres-example/pkg/handlers"
+	"go-postgres-example/pkg/router"
+	"go-postgres-example/pkg/subsonic"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var playlistColumns = []string{"id", "user_id", "name", "rules", "version", "created_at", "updated_at", "visibility", "owner", "role"}
+
+var songColumns = []string{
+	"id", "fingerprint_hash", "file_path", "title", "artist", "album", "year",
+	"genre_id", "genre", "duration", "bitrate", "file_size", "last_modified",
+}
+
+func newTestRouter(t *testing.T) (http.Handler, sqlmock.Sqlmock, string) {
+	t.Helper()
+	return newTestRouterWithConfig(t, func(*config.Config) {})
+}
+
+// newTestRouterWithConfig is newTestRouter with the config changed by configure.
+func newTestRouterWithConfig(t *testing.T, configure func(*config.Config)) (http.Handler, sqlmock.Sqlmock, string) {
+	t.Helper()
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	cfg := &config.Config{
+		JWTSecret: "default-secret", EncryptionKey: "default-secret", UploadDir: "/uploads",
+		AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Hour,
+	}
+	configure(cfg)
+	r := router.New(
+		handlers.NewAuthHandler(conn, cfg),
+		handlers.NewUploadHandler(conn, cfg),
+		handlers.NewLibraryHandler(conn, cfg),
+		handlers.NewPlaylistHandler(conn, cfg),
+		handlers.NewSongHandler(conn, cfg),
+		handlers.NewShareHandler(conn, cfg),
+		subsonic.NewHandler(conn, cfg),
+	)
+	token, _ := auth.GenerateAccessToken(1, testSessionID, "default-secret", time.Minute)
+	return r, mock, token
+}
+
+// testSessionID is the login session of the tokens of newTestRouter.
+const testSessionID = 7
+
+// expectSession expects the check that the login session of a token from
+// newTestRouter is still active.
+func expectSession(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("FROM sessions").
+		WithArgs(testSessionID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+}
+
+func TestExportPlaylistHandler(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("SELECT (.+) FROM playlists").
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows(playlistColumns).AddRow(5, 1, "Road Trip", nil, 1, time.Now(), time.Now(), "private", "alice", "owner"))
+	mock.ExpectQuery("SELECT (.+) FROM songs s").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(append(songColumns, "entry_id", "position")).
+			AddRow(9, "abc", "/uploads/abc.flac", "So What", "Miles Davis", "Kind of Blue", 1959, 1, "Jazz", 562, 900, 100, time.Now(), 1, 1))
+
+	req, _ := http.NewRequest("GET", "/api/playlists/5/export?format=m3u8", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Header().Get("Content-Disposition"), `filename="Road Trip.m3u8"`)
+	assert.Contains(t, rr.Body.String(), "#EXTINF:562,Miles Davis - So What\n#EXTALB:Kind of Blue\nabc.flac\n")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestImportPlaylistHandler(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	body := "#EXTM3U\n#EXTINF:562,Miles Davis - So What\nabc.flac\n#EXTINF:200,Nobody - Missing\n/music/missing.mp3\n"
+
+	// First entry resolves by file name.
+	mock.ExpectQuery("SELECT id FROM songs").
+		WithArgs("abc.flac", "abc.flac").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	// Second entry falls through to fuzzy matching and finds nothing.
+	mock.ExpectQuery("SELECT id FROM songs").
+		WithArgs("/music/missing.mp3", "missing.mp3").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("SELECT (.+) FROM songs s").
+		WithArgs("Nobody", "Missing", 5).
+		WillReturnRows(sqlmock.NewRows(append(songColumns, "score")))
+
+	mock.ExpectQuery("SELECT (.+) FROM playlists").
+		WithArgs(1, "m3u8:sha256:"+sha256Hex(body)).
+		WillReturnRows(sqlmock.NewRows(playlistColumns))
+	mock.ExpectQuery("INSERT INTO playlists").
+		WithArgs(1, "Office", "m3u8:sha256:"+sha256Hex(body)).
+		WillReturnRows(sqlmock.NewRows(playlistColumns).AddRow(11, 1, "Office", nil, 1, time.Now(), time.Now(), "private", "alice", "owner"))
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE playlists").WithArgs(11, 0, 1).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+	mock.ExpectQuery("DELETE FROM playlist_songs").WithArgs(11).WillReturnRows(sqlmock.NewRows([]string{"song_id"}))
+	mock.ExpectExec("INSERT INTO playlist_songs").WithArgs(11, 9, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO playlist_activity").WithArgs(11, 1, "add", "{9}").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	req, _ := http.NewRequest("POST", "/api/playlists/import?name=Office", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var resp handlers.ImportPlaylistResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, 11, resp.PlaylistID)
+	assert.True(t, resp.Created)
+	assert.Equal(t, 2, resp.Total)
+	assert.Equal(t, 1, resp.Matched)
+	require.Len(t, resp.Unmatched, 1)
+	assert.Equal(t, 1, resp.Unmatched[0].Index)
+	assert.Equal(t, "Missing", resp.Unmatched[0].Title)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestImportPlaylistFileKeyedOnNameAndContent(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	content := "#EXTM3U\nabc.flac\n"
+	mock.ExpectQuery("SELECT id FROM songs").
+		WithArgs("abc.flac", "abc.flac").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	// Another playlist.m3u8 with different content would not find this one
+	mock.ExpectQuery("SELECT (.+) FROM playlists").
+		WithArgs(1, "m3u8:file:playlist.m3u8:sha256:"+sha256Hex(content)).
+		WillReturnRows(sqlmock.NewRows(playlistColumns).AddRow(11, 1, "playlist", nil, 1, time.Now(), time.Now(), "private", "alice", "owner"))
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE playlists").WithArgs(11, 0, 1).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+	mock.ExpectQuery("DELETE FROM playlist_songs").WithArgs(11).WillReturnRows(sqlmock.NewRows([]string{"song_id"}).AddRow(9))
+	mock.ExpectExec("INSERT INTO playlist_songs").WithArgs(11, 9, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "playlist.m3u8")
+	require.NoError(t, err)
+	part.Write([]byte(content))
+	require.NoError(t, form.Close())
+	req, _ := http.NewRequest("POST", "/api/playlists/import", &body)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp handlers.ImportPlaylistResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, 11, resp.PlaylistID)
+	assert.False(t, resp.Created)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestEditPlaylistSongsHandlerStaleVersion(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("SELECT (.+) FROM playlists").
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows(playlistColumns).AddRow(5, 1, "Road Trip", nil, 4, time.Now(), time.Now(), "private", "alice", "owner"))
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE playlists").WithArgs(5, 3, 1).WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectRollback()
+
+	body := `{"operations": [{"op": "move", "entry_id": 2, "position": 1}]}`
+	req, _ := http.NewRequest("PATCH", "/api/playlists/5/songs", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-Match", `"3"`)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/middleware"
+	"go-postgres-example/pkg/scrobbler"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// defaultStatsLimit and maxStatsLimit bound the length of history and stats lists.
+	defaultStatsLimit = 20
+	maxStatsLimit     = 500
+)
+
+// ScrobbleRequest represents the request body for scrobbling a song.
+// Submission defaults to true; false only marks the song as now playing.
+type ScrobbleRequest struct {
+	Submission       *bool      `json:"submission"`
+	Timestamp        *time.Time `json:"timestamp"`         // When the song was played, defaults to now
+	DurationListened int        `json:"duration_listened"` // Seconds, optional
+}
+
+// ScrobbleHandler handles recording a play or a now playing notification for a song
+func (h *LibraryHandler) ScrobbleHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	songIDStr := chi.URLParam(r, "songID")
+	songID, err := strconv.Atoi(songIDStr)
+	if err != nil {
+		http.Error(w, "Invalid song ID", http.StatusBadRequest)
+		return
+	}
+
+	var req ScrobbleRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.DurationListened < 0 {
+		http.Error(w, "Duration listened cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	playedAt := time.Now()
+	if req.Timestamp != nil {
+		if req.Timestamp.After(playedAt.Add(time.Minute)) {
+			http.Error(w, "Timestamp cannot be in the future", http.StatusBadRequest)
+			return
+		}
+		playedAt = *req.Timestamp
+	}
+
+	listenType := scrobbler.ListenTypeSingle
+	if req.Submission == nil || *req.Submission {
+		err = db.RecordPlay(h.DB, userID, songID, playedAt, req.DurationListened)
+	} else {
+		listenType = scrobbler.ListenTypePlayingNow
+		err = db.SetNowPlaying(h.DB, userID, songID)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Song not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to scrobble song", http.StatusInternalServerError)
+		}
+		return
+	}
+	h.Scrobbler.Enqueue(userID, songID, listenType, playedAt)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Song scrobbled successfully"})
+}
+
+// RecentPlaysHandler handles fetching the user's listening history, newest first
+func (h *LibraryHandler) RecentPlaysHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	limit, err := parseStatsLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		http.Error(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+
+	plays, err := db.GetRecentPlays(h.DB, userID, limit)
+	if err != nil {
+		http.Error(w, "Failed to get play history", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plays)
+}
+
+// TopSongsHandler handles fetching the user's most played songs over a window
+func (h *LibraryHandler) TopSongsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, since, limit, ok := statsParams(w, r)
+	if !ok {
+		return
+	}
+
+	songs, err := db.GetTopPlayedSongs(h.DB, userID, since, limit)
+	if err != nil {
+		http.Error(w, "Failed to get top songs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(songs)
+}
+
+// TopArtistsHandler handles fetching the user's most played artists over a window
+func (h *LibraryHandler) TopArtistsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, since, limit, ok := statsParams(w, r)
+	if !ok {
+		return
+	}
+
+	artists, err := db.GetTopPlayedArtists(h.DB, userID, since, limit)
+	if err != nil {
+		http.Error(w, "Failed to get top artists", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(artists)
+}
+
+// TopGenresHandler handles fetching the user's most played genres over a window
+func (h *LibraryHandler) TopGenresHandler(w http.ResponseWriter, r *http.Request) {
+	userID, since, limit, ok := statsParams(w, r)
+	if !ok {
+		return
+	}
+
+	genres, err := db.GetTopPlayedGenres(h.DB, userID, since, limit)
+	if err != nil {
+		http.Error(w, "Failed to get top genres", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(genres)
+}
+
+// statsParams reads the user and the window and limit query parameters shared by
+// the stats endpoints. It writes an error response and returns false on failure.
+func statsParams(w http.ResponseWriter, r *http.Request) (int, time.Time, int, bool) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return 0, time.Time{}, 0, false
+	}
+
+	queryParams := r.URL.Query()
+	window, err := parseStatsWindow(queryParams.Get("window"))
+	if err != nil {
+		http.Error(w, "Invalid window; use e.g. 7d, 4w, 6m, 1y or all", http.StatusBadRequest)
+		return 0, time.Time{}, 0, false
+	}
+	limit, err := parseStatsLimit(queryParams.Get("limit"))
+	if err != nil {
+		http.Error(w, "Invalid limit", http.StatusBadRequest)
+		return 0, time.Time{}, 0, false
+	}
+
+	var since time.Time
+	if window > 0 {
+		since = time.Now().Add(-window)
+	}
+	return userID, since, limit, true
+}
+
+// parseStatsWindow parses a window such as "7d", "4w", "6m" or "1y" (or any Go
+// duration). An empty window or "all" means all time and is returned as 0.
+func parseStatsWindow(s string) (time.Duration, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || s == "all" {
+		return 0, nil
+	}
+
+	units := map[byte]time.Duration{
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+		'm': 30 * 24 * time.Hour,
+		'y': 365 * 24 * time.Hour,
+	}
+	if unit, ok := units[s[len(s)-1]]; ok {
+		if n, err := strconv.Atoi(s[:len(s)-1]); err == nil {
+			if n <= 0 {
+				return 0, errors.New("window must be positive")
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, errors.New("window must be positive")
+	}
+	return d, nil
+}
+
+// parseStatsLimit parses the limit query parameter, capping it at maxStatsLimit.
+func parseStatsLimit(s string) (int, error) {
+	if s == "" {
+		return defaultStatsLimit, nil
+	}
+	limit, err := strconv.Atoi(s)
+	if err != nil || limit <= 0 {
+		return 0, errors.New("limit must be a positive integer")
+	}
+	if limit > maxStatsLimit {
+		limit = maxStatsLimit
+	}
+	return limit, nil
+}
@@ -0,0 +1,90 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-postgres-example/pkg/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrobbleHandler(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	playedAt := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	mock.ExpectQuery("INSERT INTO plays").
+		WithArgs(1, 9, playedAt, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO scrobble_queue").
+		WithArgs(1, 9, "single", playedAt).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	body := `{"timestamp": "2026-01-02T15:04:05Z", "duration_listened": 180}`
+	req, _ := http.NewRequest("POST", "/api/songs/9/scrobble", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScrobbleHandlerNowPlayingUnknownSong(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("INSERT INTO now_playing").
+		WithArgs(1, 404).
+		WillReturnRows(sqlmock.NewRows([]string{"song_id"}))
+
+	req, _ := http.NewRequest("POST", "/api/songs/404/scrobble", bytes.NewBufferString(`{"submission": false}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTopArtistsHandler(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("SELECT s.artist AS name, COUNT").
+		WithArgs(1, sqlmock.AnyArg(), 5).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "play_count"}).
+			AddRow("Miles Davis", 12).
+			AddRow("Portishead", 4))
+
+	req, _ := http.NewRequest("GET", "/api/stats/top-artists?window=30d&limit=5", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	var artists []models.PlayCount
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &artists))
+	require.Len(t, artists, 2)
+	assert.Equal(t, models.PlayCount{Name: "Miles Davis", PlayCount: 12}, artists[0])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatsInvalidWindow(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	req, _ := http.NewRequest("GET", "/api/stats/top-songs?window=lately", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/middleware"
+	"go-postgres-example/pkg/models"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// queueKeepAlive is how often an idle queue event stream sends a comment, so
+// proxies do not close the connection.
+const queueKeepAlive = 30 * time.Second
+
+// SaveQueueRequest represents the request body for saving the play queue
+type SaveQueueRequest struct {
+	SongIDs      []int  `json:"song_ids"`
+	CurrentIndex int    `json:"current_index"`
+	PositionMs   int64  `json:"position_ms"`
+	Client       string `json:"client"`
+}
+
+// GetQueueHandler handles fetching the user's play queue. A user who never saved
+// a queue gets an empty one.
+func (h *LibraryHandler) GetQueueHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	queue, err := db.GetPlayQueue(h.DB, userID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			http.Error(w, "Failed to get play queue", http.StatusInternalServerError)
+			return
+		}
+		queue = &models.PlayQueue{Songs: []models.Song{}}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(queue)
+}
+
+// SaveQueueHandler handles replacing the user's play queue. The user's other
+// clients are notified through the queue event stream.
+func (h *LibraryHandler) SaveQueueHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	var req SaveQueueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.CurrentIndex < 0 || (req.CurrentIndex > 0 && req.CurrentIndex >= len(req.SongIDs)) {
+		http.Error(w, "Current index is out of range", http.StatusBadRequest)
+		return
+	}
+	if req.PositionMs < 0 {
+		http.Error(w, "Position cannot be negative", http.StatusBadRequest)
+		return
+	}
+	req.Client = strings.TrimSpace(req.Client)
+
+	if err := db.SavePlayQueue(h.DB, userID, req.SongIDs, req.CurrentIndex, req.PositionMs, req.Client); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Song not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to save play queue", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	queue, err := db.GetPlayQueue(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Failed to get play queue", http.StatusInternalServerError)
+		return
+	}
+	h.QueueEvents.Publish(userID, *queue)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(queue)
+}
+
+// QueueEventsHandler streams the user's play queue as server-sent events. A "queue"
+// event carrying the full queue is sent whenever any of the user's clients saves it.
+func (h *LibraryHandler) QueueEventsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := h.QueueEvents.Subscribe(userID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Stop nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(queueKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case queue := <-events:
+			data, err := json.Marshal(queue)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "event: queue\ndata: %s\n\n", data)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
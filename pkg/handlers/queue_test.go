@@ -0,0 +1,155 @@
+package handlers_test
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/config"
+	"go-postgres-example/pkg/handlers"
+	"go-postgres-example/pkg/middleware"
+	"go-postgres-example/pkg/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetQueueHandlerEmpty(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("FROM play_queues").
+		WithArgs(1).
+		WillReturnError(sql.ErrNoRows)
+
+	req, _ := http.NewRequest("GET", "/api/queue", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	var queue models.PlayQueue
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &queue))
+	assert.Empty(t, queue.Songs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveQueueHandler(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	updatedAt := time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO play_queues").
+		WithArgs(1, 1, int64(61000), "phone").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM play_queue_songs").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO play_queue_songs").
+		WithArgs(1, "{9,3}").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectQuery("FROM play_queues").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"current_index", "position_ms", "client", "updated_at"}).
+			AddRow(1, 61000, "phone", updatedAt))
+	mock.ExpectQuery("FROM play_queue_songs").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(songColumns).
+			AddRow(9, "hash9", "/music/9.mp3", "Teardrop", "Massive Attack", "Mezzanine", 1998, 1, "Trip Hop", 330, 320, 1000, time.Now()).
+			AddRow(3, "hash3", "/music/3.mp3", "So What", "Miles Davis", "Kind of Blue", 1959, 2, "Jazz", 545, 320, 1000, time.Now()))
+
+	body := `{"song_ids": [9, 3], "current_index": 1, "position_ms": 61000, "client": " phone "}`
+	req, _ := http.NewRequest("PUT", "/api/queue", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	var queue models.PlayQueue
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &queue))
+	require.Len(t, queue.Songs, 2)
+	assert.Equal(t, "So What", queue.Songs[queue.CurrentIndex].Title)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveQueueHandlerUnknownSong(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO play_queues").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM play_queue_songs").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO play_queue_songs").
+		WithArgs(1, "{9,404}").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	req, _ := http.NewRequest("PUT", "/api/queue", bytes.NewBufferString(`{"song_ids": [9, 404]}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveQueueHandlerIndexOutOfRange(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	req, _ := http.NewRequest("PUT", "/api/queue", bytes.NewBufferString(`{"song_ids": [9], "current_index": 1}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestQueueEventsHandler(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+	expectSession(mock)
+
+	h := handlers.NewLibraryHandler(conn, &config.Config{})
+	server := httptest.NewServer(middleware.Authenticator("default-secret", conn)(http.HandlerFunc(h.QueueEventsHandler)))
+	defer server.Close()
+
+	token, _ := auth.GenerateAccessToken(1, testSessionID, "default-secret", time.Minute)
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, ": connected\n", line)
+	reader.ReadString('\n')
+
+	h.QueueEvents.Publish(1, models.PlayQueue{Songs: []models.Song{{ID: 9}}, Client: "phone"})
+
+	line, err = reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "event: queue\n", line)
+	line, err = reader.ReadString('\n')
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(line, "data: "))
+
+	var queue models.PlayQueue
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &queue))
+	assert.Equal(t, "phone", queue.Client)
+	assert.Equal(t, 9, queue.Songs[0].ID)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/middleware"
+	"go-postgres-example/pkg/models"
+	"net/http"
+)
+
+// UsageResponse is the current user's storage usage with the upload limits
+type UsageResponse struct {
+	models.Usage
+	MaxUploadSize int64    `json:"max_upload_size"`
+	UploadFormats []string `json:"upload_formats"`
+}
+
+// GetUsage returns the storage taken by the current user's uploads, their
+// quotas and the limits on upload requests.
+func (h *UploadHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	usage, err := db.GetUsage(h.DB, userID, h.Cfg.DefaultQuotaBytes, h.Cfg.DefaultQuotaTracks)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get storage usage", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(UsageResponse{
+		Usage:         *usage,
+		MaxUploadSize: h.Cfg.MaxUploadSize,
+		UploadFormats: h.Cfg.UploadFormats,
+	})
+}
+
+// AdminGetQuota returns a user's storage usage and quotas.
+func (h *AuthHandler) AdminGetQuota(w http.ResponseWriter, r *http.Request) {
+	userID, ok := adminUserID(w, r)
+	if !ok {
+		return
+	}
+
+	usage, err := db.GetUsage(h.DB, userID, h.Cfg.DefaultQuotaBytes, h.Cfg.DefaultQuotaTracks)
+	if err != nil {
+		writeAdminUserError(w, err, "Failed to get storage usage")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usage)
+}
+
+// AdminSetQuota replaces a user's quotas and returns their usage. A null
+// quota follows the server default and 0 is unlimited.
+func (h *AuthHandler) AdminSetQuota(w http.ResponseWriter, r *http.Request) {
+	userID, ok := adminUserID(w, r)
+	if !ok {
+		return
+	}
+	var update models.QuotaUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if (update.QuotaBytes != nil && *update.QuotaBytes < 0) || (update.QuotaTracks != nil && *update.QuotaTracks < 0) {
+		http.Error(w, "Quotas cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	if err := db.SetUserQuota(h.DB, userID, update); err != nil {
+		writeAdminUserError(w, err, "Failed to set quota")
+		return
+	}
+	h.AdminGetQuota(w, r)
+}
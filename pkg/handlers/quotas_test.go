@@ -0,0 +1,268 @@
+package handlers_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go-postgres-example/pkg/config"
+	"go-postgres-example/pkg/handlers"
+	"go-postgres-example/pkg/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var usageColumns = []string{"quota_bytes", "quota_tracks", "tracks", "bytes"}
+
+func withUploadLimits(cfg *config.Config) {
+	cfg.MaxUploadSize = 1 << 20
+	cfg.UploadFormats = []string{"mp3", "flac"}
+	cfg.DefaultQuotaBytes = 1 << 30
+}
+
+// uploadRequest builds a multipart upload of files, by name, with the given content.
+func uploadRequest(t *testing.T, token string, files map[string]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, content := range files {
+		part, err := writer.CreateFormFile("files", name)
+		require.NoError(t, err)
+		part.Write([]byte(content))
+	}
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest("POST", "/api/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestGetUsage(t *testing.T) {
+	r, mock, token := newTestRouterWithConfig(t, withUploadLimits)
+
+	expectSession(mock)
+	mock.ExpectQuery("FROM users u").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(usageColumns).AddRow(nil, 100, 3, 12345))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, adminRequest("GET", "/api/me/usage", "", token))
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var usage handlers.UsageResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &usage))
+	assert.Equal(t, int64(12345), usage.Bytes)
+	assert.Equal(t, 3, usage.Tracks)
+	assert.Equal(t, int64(1<<30), usage.QuotaBytes)
+	assert.Nil(t, usage.UserQuotaBytes)
+	assert.Equal(t, 100, usage.QuotaTracks)
+	require.NotNil(t, usage.UserQuotaTracks)
+	assert.Equal(t, 100, *usage.UserQuotaTracks)
+	assert.Equal(t, int64(1<<20), usage.MaxUploadSize)
+	assert.Equal(t, []string{"mp3", "flac"}, usage.UploadFormats)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUploadRejectsUnsupportedFormat(t *testing.T) {
+	r, mock, token := newTestRouterWithConfig(t, withUploadLimits)
+
+	expectSession(mock)
+	expectPermission(mock, models.PermissionUpload, true)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, uploadRequest(t, token, map[string]string{"song.wav": "RIFF"}))
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+	assert.Contains(t, rr.Body.String(), "song.wav")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUploadRejectsTooBigRequest(t *testing.T) {
+	r, mock, token := newTestRouterWithConfig(t, func(cfg *config.Config) {
+		withUploadLimits(cfg)
+		cfg.MaxUploadSize = 1024
+	})
+	expectSession(mock)
+
+	expectPermission(mock, models.PermissionUpload, true)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, uploadRequest(t, token, map[string]string{"song.mp3": strings.Repeat("a", 4096)}))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	assert.Contains(t, rr.Body.String(), "1 KB")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUploadRejectsOverQuota(t *testing.T) {
+	r, mock, token := newTestRouterWithConfig(t, withUploadLimits)
+
+	expectSession(mock)
+	expectPermission(mock, models.PermissionUpload, true)
+	mock.ExpectQuery("FROM users u").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(usageColumns).AddRow(nil, 2, 2, 100))
+	// The first song is already the user's and takes nothing more
+	mock.ExpectQuery("FROM song_uploads su JOIN songs s").
+		WithArgs(1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("FROM song_uploads su JOIN songs s").
+		WithArgs(1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, uploadRequest(t, token, map[string]string{"a.mp3": "one", "b.flac": "two"}))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	assert.Contains(t, rr.Body.String(), "3 of your 2 tracks")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// zipContent returns a zip archive of files, by name, with the given content.
+func zipContent(t *testing.T, files [][2]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range files {
+		w, err := zw.Create(file[0])
+		require.NoError(t, err)
+		w.Write([]byte(file[1]))
+	}
+	require.NoError(t, zw.Close())
+	return buf.String()
+}
+
+func TestUploadChecksArchives(t *testing.T) {
+	r, mock, token := newTestRouterWithConfig(t, func(cfg *config.Config) {
+		withUploadLimits(cfg)
+		cfg.UploadFormats = append(cfg.UploadFormats, "zip")
+		cfg.ArchiveMaxEntries = 10
+	})
+	upload := func(files map[string]string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, uploadRequest(t, token, files))
+		return rr
+	}
+
+	expectSession(mock)
+	expectPermission(mock, models.PermissionUpload, true)
+	rr := upload(map[string]string{"album.tar": "tar"})
+	assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+
+	expectSession(mock)
+	expectPermission(mock, models.PermissionUpload, true)
+	rr = upload(map[string]string{"album.zip": zipContent(t, [][2]string{{"../../evil.mp3", "evil"}})})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "album.zip: archive entry escapes the destination")
+
+	expectSession(mock)
+	expectPermission(mock, models.PermissionUpload, true)
+	rr = upload(map[string]string{"album.zip": zipContent(t, [][2]string{{"notes.txt", "notes"}})})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "No audio files were uploaded")
+
+	// The songs in the archive count against the quota
+	expectSession(mock)
+	expectPermission(mock, models.PermissionUpload, true)
+	mock.ExpectQuery("FROM users u").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(usageColumns).AddRow(nil, 2, 0, 0))
+	for i := 0; i < 3; i++ {
+		mock.ExpectQuery("FROM song_uploads su JOIN songs s").
+			WithArgs(1, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	}
+	rr = upload(map[string]string{"album.zip": zipContent(t, [][2]string{
+		{"Album/01.mp3", "one"}, {"Album/02.mp3", "two"}, {"Album/03.flac", "three"}, {"Album/cover.jpg", "jpeg"},
+	})})
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	assert.Contains(t, rr.Body.String(), "3 of your 2 tracks")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdminSetQuota(t *testing.T) {
+	r, mock, token := newTestRouterWithConfig(t, withUploadLimits)
+
+	expectSession(mock)
+	expectAdmin(mock)
+	mock.ExpectExec("UPDATE users SET quota_bytes").
+		WithArgs(2, int64(5<<30), nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("FROM users u").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(usageColumns).AddRow(int64(5<<30), nil, 0, 0))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, adminRequest("PUT", "/api/admin/users/2/quota", `{"quota_bytes": 5368709120, "quota_tracks": null}`, token))
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var usage models.Usage
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &usage))
+	assert.Equal(t, int64(5<<30), usage.QuotaBytes)
+	assert.Equal(t, 0, usage.QuotaTracks)
+	assert.Nil(t, usage.UserQuotaTracks)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdminSetQuotaRejectsNegative(t *testing.T) {
+	r, mock, token := newTestRouterWithConfig(t, withUploadLimits)
+
+	expectSession(mock)
+	expectAdmin(mock)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, adminRequest("PUT", "/api/admin/users/2/quota", `{"quota_tracks": -1}`, token))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResumableUploadChecksFormatAndQuota(t *testing.T) {
+	r, mock, token := newTestRouterWithConfig(t, func(cfg *config.Config) {
+		withUploadLimits(cfg)
+		cfg.PartialUploadDir = t.TempDir()
+	})
+	create := func(filename string, size int) *httptest.ResponseRecorder {
+		req := adminRequest("POST", "/api/uploads", "", token)
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		req.Header.Set("Upload-Length", strconv.Itoa(size))
+		req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte(filename)))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	expectSession(mock)
+	expectPermission(mock, models.PermissionUpload, true)
+	assert.Equal(t, http.StatusUnsupportedMediaType, create("song.wav", 100).Code)
+
+	// The content is not known yet, so the upload counts as a new song
+	expectSession(mock)
+	expectPermission(mock, models.PermissionUpload, true)
+	mock.ExpectQuery("FROM users u").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(usageColumns).AddRow(int64(1000), nil, 1, 900))
+	rr := create("song.flac", 200)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Storage quota exceeded")
+
+	expectSession(mock)
+	expectPermission(mock, models.PermissionUpload, true)
+	mock.ExpectQuery("FROM users u").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(usageColumns).AddRow(int64(1000), nil, 1, 900))
+	rr = create("song.flac", 100)
+	assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	assert.True(t, strings.HasPrefix(rr.Header().Get("Location"), "/api/uploads/"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
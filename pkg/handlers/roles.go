@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/models"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// roleNamePattern restricts role names to short lowercase identifiers.
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// AdminListRoles lists the roles with their permissions.
+func (h *AuthHandler) AdminListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := db.GetRoles(h.DB)
+	if err != nil {
+		http.Error(w, "Failed to list roles", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"roles": roles, "permissions": models.Permissions})
+}
+
+// AdminCreateRole adds a role with the given name, description and permissions.
+func (h *AuthHandler) AdminCreateRole(w http.ResponseWriter, r *http.Request) {
+	var role models.Role
+	if err := json.NewDecoder(r.Body).Decode(&role); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	role.Name = strings.TrimSpace(role.Name)
+	if !roleNamePattern.MatchString(role.Name) {
+		http.Error(w, "Role names are 1-32 lowercase letters, digits, '-' or '_'", http.StatusBadRequest)
+		return
+	}
+	if !validRolePermissions(w, role.Permissions) {
+		return
+	}
+
+	if err := db.CreateRole(h.DB, role); err != nil {
+		writeRoleError(w, err, "Failed to create role")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(role)
+}
+
+// AdminUpdateRole replaces the description and permissions of a role. Built-in
+// roles cannot be changed.
+func (h *AuthHandler) AdminUpdateRole(w http.ResponseWriter, r *http.Request) {
+	var role models.Role
+	if err := json.NewDecoder(r.Body).Decode(&role); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	role.Name = chi.URLParam(r, "role")
+	if !validRolePermissions(w, role.Permissions) {
+		return
+	}
+
+	if err := db.UpdateRole(h.DB, role); err != nil {
+		writeRoleError(w, err, "Failed to update role")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(role)
+}
+
+// AdminDeleteRole deletes a role that no user has. Built-in roles cannot be deleted.
+func (h *AuthHandler) AdminDeleteRole(w http.ResponseWriter, r *http.Request) {
+	if err := db.DeleteRole(h.DB, chi.URLParam(r, "role")); err != nil {
+		writeRoleError(w, err, "Failed to delete role")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Role deleted"})
+}
+
+// validRolePermissions checks that every permission is known and not admin,
+// which only the built-in admin role grants, writing 400 Bad Request if not.
+func validRolePermissions(w http.ResponseWriter, permissions []string) bool {
+	for _, p := range permissions {
+		if !models.IsPermission(p) {
+			http.Error(w, "Unknown permission: "+p, http.StatusBadRequest)
+			return false
+		}
+		if p == models.PermissionAdmin {
+			http.Error(w, "Only the admin role grants the admin permission", http.StatusBadRequest)
+			return false
+		}
+	}
+	return true
+}
+
+func writeRoleError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, "Role not found", http.StatusNotFound)
+	case errors.Is(err, db.ErrRoleExists):
+		http.Error(w, "Role already exists", http.StatusConflict)
+	case errors.Is(err, db.ErrBuiltInRole):
+		http.Error(w, "Built-in roles cannot be changed", http.StatusConflict)
+	case errors.Is(err, db.ErrRoleInUse):
+		http.Error(w, "Role is still assigned to users", http.StatusConflict)
+	default:
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
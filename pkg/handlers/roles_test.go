@@ -0,0 +1,143 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminListRoles(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	expectAdmin(mock)
+	mock.ExpectQuery("FROM roles r").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "description", "built_in", "permission"}).
+			AddRow("listener", "Listens", true, "download").
+			AddRow("dj", "Plays music", false, "jukebox"))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, adminRequest("GET", "/api/admin/roles", "", token))
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp struct {
+		Roles []struct {
+			Name        string   `json:"name"`
+			Permissions []string `json:"permissions"`
+		} `json:"roles"`
+		Permissions []string `json:"permissions"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Len(t, resp.Roles, 2)
+	assert.Equal(t, []string{"jukebox"}, resp.Roles[1].Permissions)
+	assert.Contains(t, resp.Permissions, "manage_playlists")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdminCreateRole(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	expectAdmin(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO roles").
+		WithArgs("dj", "Plays music").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO role_permissions").
+		WithArgs("dj", "jukebox").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, adminRequest("POST", "/api/admin/roles", `{"name": "dj", "description": "Plays music", "permissions": ["jukebox"]}`, token))
+
+	assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdminCreateRoleValidation(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	for _, body := range []string{
+		`{"name": "Not A Name", "permissions": []}`,
+		`{"name": "dj", "permissions": ["fly"]}`,
+		`{"name": "boss", "permissions": ["admin"]}`,
+	} {
+		expectSession(mock)
+		expectAdmin(mock)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, adminRequest("POST", "/api/admin/roles", body, token))
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, body)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdminUpdateBuiltInRole(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	expectAdmin(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT built_in FROM roles").
+		WithArgs("user").
+		WillReturnRows(sqlmock.NewRows([]string{"built_in"}).AddRow(true))
+	mock.ExpectRollback()
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, adminRequest("PUT", "/api/admin/roles/user", `{"permissions": ["upload"]}`, token))
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdminUpdateUserRole(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	expectAdmin(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM roles").
+		WithArgs("listener").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT id FROM users WHERE is_admin AND NOT disabled FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("UPDATE users SET email").
+		WithArgs(4, nil, false, "listener").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT (.+) FROM users WHERE id").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(4, "bob", "bob@example.com", time.Now(), false, "listener", false, false))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, adminRequest("PATCH", "/api/admin/users/4", `{"role": "listener"}`, token))
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), `"role":"listener"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdminUpdateUserUnknownRole(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	expectAdmin(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM roles").
+		WithArgs("wizard").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectRollback()
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, adminRequest("PATCH", "/api/admin/users/4", `{"role": "wizard"}`, token))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
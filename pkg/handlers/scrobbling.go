@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/middleware"
+	"go-postgres-example/pkg/scrobbler"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// LinkScrobbleAccountRequest represents the request body for linking a scrobbling account.
+// APIURL defaults to ListenBrainz; set it to the ListenBrainz-compatible API of a
+// self-hosted service (e.g. "https://maloja.example.com/apis/listenbrainz") that
+// the administrator allowed.
+type LinkScrobbleAccountRequest struct {
+	APIURL string `json:"api_url"`
+	Token  string `json:"token"`
+}
+
+// GetScrobbleAccountsHandler handles listing the user's linked scrobbling accounts
+func (h *LibraryHandler) GetScrobbleAccountsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	accounts, err := db.GetScrobbleAccounts(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Failed to get scrobbling accounts", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(accounts)
+}
+
+// LinkScrobbleAccountHandler handles linking a ListenBrainz-compatible account.
+// The token is checked against the service before it is stored.
+func (h *LibraryHandler) LinkScrobbleAccountHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	var req LinkScrobbleAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.Token = strings.TrimSpace(req.Token)
+	if req.Token == "" {
+		http.Error(w, "Token is required", http.StatusBadRequest)
+		return
+	}
+	apiURL := strings.TrimRight(strings.TrimSpace(req.APIURL), "/")
+	if apiURL == "" {
+		apiURL = strings.TrimRight(h.Cfg.ListenBrainzURL, "/")
+	}
+	if u, err := url.Parse(apiURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		http.Error(w, "Invalid API URL", http.StatusBadRequest)
+		return
+	}
+	if !scrobbler.AllowedURL(apiURL, h.Cfg.ListenBrainzURL, h.Cfg.ScrobbleAllowedURLs) {
+		http.Error(w, "This scrobbling service is not allowed on this server", http.StatusForbidden)
+		return
+	}
+
+	username, err := h.Scrobbler.Client.ValidateToken(r.Context(), apiURL, req.Token)
+	if err != nil {
+		if errors.Is(err, scrobbler.ErrInvalidToken) {
+			http.Error(w, "The service rejected the token", http.StatusBadRequest)
+		} else {
+			http.Error(w, "Failed to reach the scrobbling service", http.StatusBadGateway)
+		}
+		return
+	}
+
+	tokenEncrypted, err := auth.EncryptSecret(h.Cfg.EncryptionKey, req.Token)
+	if err != nil {
+		http.Error(w, "Failed to store token", http.StatusInternalServerError)
+		return
+	}
+
+	account, err := db.CreateScrobbleAccount(h.DB, userID, "listenbrainz", apiURL, username, tokenEncrypted)
+	if err != nil {
+		http.Error(w, "Failed to link scrobbling account", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(account)
+}
+
+// DeleteScrobbleAccountHandler handles unlinking a scrobbling account
+func (h *LibraryHandler) DeleteScrobbleAccountHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	accountIDStr := chi.URLParam(r, "accountID")
+	accountID, err := strconv.Atoi(accountIDStr)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := db.DeleteScrobbleAccount(h.DB, userID, accountID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Scrobbling account not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to unlink scrobbling account", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Scrobbling account unlinked successfully"})
+}
@@ -0,0 +1,89 @@
+package handlers_test
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/config"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encryptedToken matches a token encrypted with the test router's key.
+type encryptedToken struct {
+	plaintext string
+}
+
+func (e encryptedToken) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	plaintext, err := auth.DecryptSecret("default-secret", s)
+	return err == nil && plaintext == e.plaintext
+}
+
+func TestLinkScrobbleAccountHandlerRejectsOtherHosts(t *testing.T) {
+	r, mock, token := newTestRouterWithConfig(t, func(cfg *config.Config) {
+		cfg.ListenBrainzURL = "https://api.listenbrainz.org"
+	})
+
+	for _, apiURL := range []string{"http://127.0.0.1:5432", "http://169.254.169.254/latest", "https://api.listenbrainz.org.evil.test"} {
+		expectSession(mock)
+		req, _ := http.NewRequest("POST", "/api/scrobbling/accounts", bytes.NewBufferString(`{"api_url": "`+apiURL+`", "token": "secret"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusForbidden, rr.Code, apiURL)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLinkScrobbleAccountHandler(t *testing.T) {
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		valid := r.URL.Path == "/apis/listenbrainz/1/validate-token" && r.Header.Get("Authorization") == "Token secret"
+		json.NewEncoder(w).Encode(map[string]interface{}{"valid": valid, "user_name": "rob"})
+	}))
+	defer service.Close()
+
+	r, mock, token := newTestRouterWithConfig(t, func(cfg *config.Config) {
+		cfg.ScrobbleAllowedURLs = []string{service.URL}
+	})
+	expectSession(mock)
+
+	apiURL := service.URL + "/apis/listenbrainz"
+	mock.ExpectQuery("INSERT INTO scrobble_accounts").
+		WithArgs(1, "listenbrainz", apiURL, "rob", encryptedToken{"secret"}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "service", "api_url", "username", "token_encrypted", "enabled", "created_at"}).
+			AddRow(3, 1, "listenbrainz", apiURL, "rob", "ciphertext", true, time.Now()))
+
+	body := `{"api_url": "` + apiURL + `/", "token": "secret"}`
+	req, _ := http.NewRequest("POST", "/api/scrobbling/accounts", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	assert.NotContains(t, rr.Body.String(), "ciphertext")
+	assert.Contains(t, rr.Body.String(), `"username":"rob"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// A token the service rejects is not stored.
+	expectSession(mock)
+	req, _ = http.NewRequest("POST", "/api/scrobbling/accounts", bytes.NewBufferString(`{"api_url": "`+apiURL+`", "token": "wrong"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
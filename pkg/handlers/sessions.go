@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/middleware"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxDeviceNameLength caps the stored device name of a session.
+const maxDeviceNameLength = 255
+
+// TokenResponse is returned when logging in or refreshing a session.
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // Lifetime of the access token in seconds
+}
+
+// RefreshRequest represents the request body for refreshing or ending a session
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// startSession creates a login session for the user and writes its tokens.
+func (h *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, userID int, deviceName string) {
+	tokens, err := h.createSession(r, userID, deviceName)
+	if err != nil {
+		if errors.Is(err, db.ErrAccountDisabled) {
+			http.Error(w, "Account is disabled", http.StatusForbidden)
+		} else {
+			http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// createSession creates a login session for the user and returns its tokens.
+func (h *AuthHandler) createSession(r *http.Request, userID int, deviceName string) (*TokenResponse, error) {
+	refreshToken, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	if len(deviceName) > maxDeviceNameLength {
+		deviceName = deviceName[:maxDeviceNameLength]
+	}
+
+	expiresAt := time.Now().Add(h.Cfg.RefreshTokenTTL)
+	sessionID, err := db.CreateSession(h.DB, userID, deviceName, middleware.ClientIP(r, h.TrustedProxies), auth.HashToken(refreshToken), expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return h.issueTokens(userID, sessionID, refreshToken)
+}
+
+// issueTokens issues an access token for the session to go with its refresh token.
+func (h *AuthHandler) issueTokens(userID int, sessionID int, refreshToken string) (*TokenResponse, error) {
+	token, err := auth.GenerateAccessToken(userID, sessionID, h.Cfg.JWTSecret, h.Cfg.AccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(h.Cfg.AccessTokenTTL / time.Second),
+	}, nil
+}
+
+// Refresh exchanges a refresh token for a new access token and refresh token.
+// Each refresh token can be used once; reusing one revokes its session.
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, "Refresh token is required", http.StatusBadRequest)
+		return
+	}
+
+	refreshToken, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	expiresAt := time.Now().Add(h.Cfg.RefreshTokenTTL)
+	session, err := db.RotateRefreshToken(h.DB, auth.HashToken(req.RefreshToken), auth.HashToken(refreshToken), middleware.ClientIP(r, h.TrustedProxies), expiresAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrRefreshTokenReused):
+			http.Error(w, "Refresh token has already been used; the session has been revoked", http.StatusUnauthorized)
+		case errors.Is(err, db.ErrInvalidRefreshToken):
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		case errors.Is(err, db.ErrAccountDisabled):
+			http.Error(w, "Account is disabled", http.StatusForbidden)
+		default:
+			http.Error(w, "Failed to refresh session", http.StatusInternalServerError)
+		}
+		return
+	}
+	tokens, err := h.issueTokens(session.UserID, session.ID, refreshToken)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// Logout ends the session of a refresh token.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, "Refresh token is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := db.RevokeSessionByRefreshToken(h.DB, auth.HashToken(req.RefreshToken)); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		} else {
+			http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Logged out"})
+}
+
+// ProxySession starts a session for a user signed in by the reverse proxy, so
+// the web app and other clients can use tokens like everyone else.
+func (h *AuthHandler) ProxySession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	if !middleware.IsProxyAuthenticated(r.Context()) {
+		http.Error(w, "Request was not signed in by the reverse proxy", http.StatusBadRequest)
+		return
+	}
+	h.startSession(w, r, userID, "Reverse proxy: "+r.UserAgent())
+}
+
+// GetSessions lists the current user's active sessions.
+func (h *AuthHandler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	currentID, _ := middleware.GetSessionIDFromContext(r.Context())
+
+	sessions, err := db.GetUserSessions(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Failed to get sessions", http.StatusInternalServerError)
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// RevokeOtherSessions logs the current user out everywhere except the session making the request.
+func (h *AuthHandler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	currentID, _ := middleware.GetSessionIDFromContext(r.Context())
+
+	revoked, err := db.RevokeOtherSessions(h.DB, userID, currentID)
+	if err != nil {
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"revoked": revoked})
+}
+
+// RevokeSession revokes one of the current user's sessions.
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	sessionID, err := strconv.Atoi(chi.URLParam(r, "sessionID"))
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := db.RevokeSession(h.DB, userID, sessionID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Session not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Session revoked"})
+}
@@ -0,0 +1,141 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/handlers"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshHandler(t *testing.T) {
+	r, mock, _ := newTestRouter(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM refresh_tokens rt").
+		WithArgs(auth.HashToken("old-token")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "used", "revoked", "disabled"}).AddRow(7, 1, false, false, false))
+	mock.ExpectExec("UPDATE refresh_tokens SET used_at").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO refresh_tokens").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE sessions SET last_used_at").
+		WillReturnRows(sqlmock.NewRows([]string{"device_name", "ip_address", "created_at", "last_used_at", "expires_at"}).
+			AddRow("Phone", "192.0.2.1", time.Now(), time.Now(), time.Now().Add(time.Hour)))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest("POST", "/api/auth/refresh", bytes.NewBufferString(`{"refresh_token": "old-token"}`))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp handlers.TokenResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp.RefreshToken)
+	assert.NotEqual(t, "old-token", resp.RefreshToken)
+
+	token, err := auth.ValidateJWT(resp.Token, "default-secret")
+	require.NoError(t, err)
+	claims := token.Claims.(*auth.Claims)
+	assert.Equal(t, 1, claims.UserID)
+	assert.Equal(t, 7, claims.SessionID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshHandlerReusedToken(t *testing.T) {
+	r, mock, _ := newTestRouter(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM refresh_tokens rt").
+		WithArgs(auth.HashToken("old-token")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "used", "revoked", "disabled"}).AddRow(7, 1, true, false, false))
+	mock.ExpectExec("UPDATE sessions SET revoked_at").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest("POST", "/api/auth/refresh", bytes.NewBufferString(`{"refresh_token": "old-token"}`))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshHandlerDisabledAccount(t *testing.T) {
+	r, mock, _ := newTestRouter(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM refresh_tokens rt").
+		WithArgs(auth.HashToken("old-token")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "used", "revoked", "disabled"}).AddRow(7, 1, false, false, true))
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("POST", "/api/auth/refresh", bytes.NewBufferString(`{"refresh_token": "old-token"}`))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Account is disabled")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLogoutHandler(t *testing.T) {
+	r, mock, _ := newTestRouter(t)
+
+	mock.ExpectExec("UPDATE sessions SET revoked_at").
+		WithArgs(auth.HashToken("refresh")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest("POST", "/api/auth/logout", bytes.NewBufferString(`{"refresh_token": "refresh"}`))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSessionsHandler(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("FROM sessions").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "device_name", "ip_address", "created_at", "last_used_at", "expires_at"}).
+			AddRow(7, 1, "Firefox", "192.0.2.1", time.Now(), time.Now(), time.Now().Add(time.Hour)).
+			AddRow(3, 1, "Phone", "192.0.2.9", time.Now(), time.Now(), time.Now().Add(time.Hour)))
+
+	req := httptest.NewRequest("GET", "/api/me/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var sessions []map[string]interface{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &sessions))
+	require.Len(t, sessions, 2)
+	assert.Equal(t, true, sessions[0]["current"])
+	assert.Equal(t, false, sessions[1]["current"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRevokeSessionHandlerNotFound(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectExec("UPDATE sessions SET revoked_at").
+		WithArgs(3, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := httptest.NewRequest("DELETE", "/api/me/sessions/3", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,522 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/config"
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/lockout"
+	"go-postgres-example/pkg/middleware"
+	"go-postgres-example/pkg/models"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// shareTokenBytes is the amount of randomness in a share link's token.
+const shareTokenBytes = 16
+
+// sharePasswordHeader carries the password of a password-protected share.
+const sharePasswordHeader = "X-Share-Password"
+
+// ShareHandler holds the dependencies for the share handlers.
+type ShareHandler struct {
+	DB  *sql.DB
+	Cfg *config.Config
+	// TrustedProxies are the proxies whose X-Forwarded-For gives the client's address
+	TrustedProxies []*net.IPNet
+	// Limiter throttles share password guesses, per share and per address
+	Limiter *lockout.Limiter
+}
+
+// NewShareHandler creates a new ShareHandler.
+func NewShareHandler(db *sql.DB, cfg *config.Config) *ShareHandler {
+	limiter := lockout.New(db, lockout.Policy{
+		MaxFailures:      cfg.LoginMaxFailures,
+		MaxFailuresPerIP: cfg.LoginMaxFailuresPerIP,
+		BaseDelay:        cfg.LoginBaseDelay,
+		Lockout:          cfg.LoginLockout,
+	})
+	limiter.Scope = "share"
+	return &ShareHandler{DB: db, Cfg: cfg, Limiter: limiter}
+}
+
+// CreateShareRequest defines the structure for the create share request. Song
+// shares may list several songs; album and playlist shares take a single ID.
+type CreateShareRequest struct {
+	Type          string     `json:"type"`
+	IDs           []int      `json:"ids"`
+	Description   string     `json:"description"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	Password      string     `json:"password,omitempty"`
+	AllowDownload bool       `json:"allow_download"`
+}
+
+// UpdateShareRequest defines the structure for the update share request. Omitted
+// fields are left unchanged; an empty password removes the password and
+// no_expiry removes the expiry date.
+type UpdateShareRequest struct {
+	Description   *string    `json:"description,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	NoExpiry      bool       `json:"no_expiry,omitempty"`
+	Password      *string    `json:"password,omitempty"`
+	AllowDownload *bool      `json:"allow_download,omitempty"`
+}
+
+// SharedSong is a song as shown on a public share page, without server details
+// such as its file path.
+type SharedSong struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Artist      string `json:"artist"`
+	Album       string `json:"album"`
+	Year        int    `json:"year"`
+	Genre       string `json:"genre,omitempty"`
+	Duration    int    `json:"duration"`
+	StreamURL   string `json:"stream_url"`
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+// SharePage is the data shown on a share's public page.
+type SharePage struct {
+	Description   string       `json:"description"`
+	Owner         string       `json:"owner"`
+	Type          string       `json:"type"`
+	ExpiresAt     *time.Time   `json:"expires_at,omitempty"`
+	AllowDownload bool         `json:"allow_download"`
+	Songs         []SharedSong `json:"songs"`
+}
+
+// GetSharesHandler lists the shares created by the user.
+func (h *ShareHandler) GetSharesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	shares, err := db.GetUserShares(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Failed to get shares", http.StatusInternalServerError)
+		return
+	}
+	for i := range shares {
+		shares[i].URL = ShareURL(h.Cfg, r, shares[i].Token)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shares)
+}
+
+// CreateShareHandler creates a public link to songs, an album or a playlist.
+func (h *ShareHandler) CreateShareHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	var req CreateShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	switch req.Type {
+	case models.ShareSong:
+		if len(req.IDs) == 0 {
+			http.Error(w, "At least one song ID is required", http.StatusBadRequest)
+			return
+		}
+	case models.ShareAlbum, models.SharePlaylist:
+		if len(req.IDs) != 1 {
+			http.Error(w, "Exactly one ID is required for album and playlist shares", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Invalid share type, must be one of song, album or playlist", http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		http.Error(w, "Expiry date must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	found, err := h.shareItemsExist(userID, req.Type, req.IDs)
+	if err != nil {
+		http.Error(w, "Failed to look up shared items", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Shared item not found", http.StatusNotFound)
+		return
+	}
+
+	share := &models.Share{
+		UserID:        userID,
+		Type:          req.Type,
+		ItemIDs:       req.IDs,
+		Description:   req.Description,
+		AllowDownload: req.AllowDownload,
+		ExpiresAt:     req.ExpiresAt,
+	}
+	if req.Password != "" {
+		if share.PasswordHash, err = auth.HashPassword(req.Password); err != nil {
+			http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+			return
+		}
+	}
+	if share.Token, err = NewShareToken(); err != nil {
+		http.Error(w, "Failed to generate share token", http.StatusInternalServerError)
+		return
+	}
+	if err := db.CreateShare(h.DB, share); err != nil {
+		http.Error(w, "Failed to create share", http.StatusInternalServerError)
+		return
+	}
+	share.URL = ShareURL(h.Cfg, r, share.Token)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(share)
+}
+
+// UpdateShareHandler changes a share's description, password, download permission
+// or expiry date.
+func (h *ShareHandler) UpdateShareHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	shareID, err := strconv.Atoi(chi.URLParam(r, "shareID"))
+	if err != nil {
+		http.Error(w, "Invalid share ID", http.StatusBadRequest)
+		return
+	}
+
+	var req UpdateShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	share, err := db.GetShareByID(h.DB, userID, shareID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Share not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get share", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if req.Description != nil {
+		share.Description = *req.Description
+	}
+	if req.AllowDownload != nil {
+		share.AllowDownload = *req.AllowDownload
+	}
+	if req.NoExpiry {
+		share.ExpiresAt = nil
+	} else if req.ExpiresAt != nil {
+		share.ExpiresAt = req.ExpiresAt
+	}
+	if req.Password != nil {
+		share.PasswordHash = ""
+		if *req.Password != "" {
+			if share.PasswordHash, err = auth.HashPassword(*req.Password); err != nil {
+				http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	if err := db.UpdateShare(h.DB, share); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Share not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to update share", http.StatusInternalServerError)
+		}
+		return
+	}
+	share.URL = ShareURL(h.Cfg, r, share.Token)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(share)
+}
+
+// DeleteShareHandler deletes a share, disabling its link.
+func (h *ShareHandler) DeleteShareHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	shareID, err := strconv.Atoi(chi.URLParam(r, "shareID"))
+	if err != nil {
+		http.Error(w, "Invalid share ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := db.DeleteShare(h.DB, userID, shareID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Share not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete share", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SharePageHandler returns the public page data of a share and counts the visit.
+// Password-protected shares need the password in the X-Share-Password header; the
+// returned stream and download URLs then carry a key so they work without it.
+func (h *ShareHandler) SharePageHandler(w http.ResponseWriter, r *http.Request) {
+	share, ok := h.openShare(w, r)
+	if !ok {
+		return
+	}
+
+	songs, err := db.GetShareSongs(h.DB, share)
+	if err != nil {
+		http.Error(w, "Failed to get shared songs", http.StatusInternalServerError)
+		return
+	}
+	owner, err := db.GetUsernameByID(h.DB, share.UserID)
+	if err != nil {
+		http.Error(w, "Failed to get share owner", http.StatusInternalServerError)
+		return
+	}
+	if err := db.RecordShareVisit(h.DB, share.ID); err != nil {
+		http.Error(w, "Failed to record visit", http.StatusInternalServerError)
+		return
+	}
+
+	query := ""
+	if share.HasPassword {
+		query = "?key=" + shareAccessKey(h.Cfg.JWTSecret, share)
+	}
+	page := SharePage{
+		Description:   share.Description,
+		Owner:         owner,
+		Type:          share.Type,
+		ExpiresAt:     share.ExpiresAt,
+		AllowDownload: share.AllowDownload,
+		Songs:         make([]SharedSong, 0, len(songs)),
+	}
+	for _, song := range songs {
+		shared := SharedSong{
+			ID:        song.ID,
+			Title:     song.Title,
+			Artist:    song.Artist,
+			Album:     song.Album,
+			Year:      song.Year,
+			Genre:     song.Genre,
+			Duration:  song.Duration,
+			StreamURL: fmt.Sprintf("/share/%s/stream/%d%s", share.Token, song.ID, query),
+		}
+		if share.AllowDownload {
+			shared.DownloadURL = fmt.Sprintf("/share/%s/download/%d%s", share.Token, song.ID, query)
+		}
+		page.Songs = append(page.Songs, shared)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// ShareStreamHandler streams a song of a share.
+func (h *ShareHandler) ShareStreamHandler(w http.ResponseWriter, r *http.Request) {
+	h.serveSharedSong(w, r, false)
+}
+
+// ShareDownloadHandler downloads a song of a share that allows downloads.
+func (h *ShareHandler) ShareDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	h.serveSharedSong(w, r, true)
+}
+
+// serveSharedSong serves the file of a song, only if it belongs to the share.
+func (h *ShareHandler) serveSharedSong(w http.ResponseWriter, r *http.Request, download bool) {
+	share, ok := h.openShare(w, r)
+	if !ok {
+		return
+	}
+	if download && !share.AllowDownload {
+		http.Error(w, "Downloads are not allowed for this share", http.StatusForbidden)
+		return
+	}
+
+	songID, err := strconv.Atoi(chi.URLParam(r, "songID"))
+	if err != nil {
+		http.Error(w, "Invalid song ID", http.StatusBadRequest)
+		return
+	}
+
+	songs, err := db.GetShareSongs(h.DB, share)
+	if err != nil {
+		http.Error(w, "Failed to get shared songs", http.StatusInternalServerError)
+		return
+	}
+	var song *models.Song
+	for i := range songs {
+		if songs[i].ID == songID {
+			song = &songs[i]
+			break
+		}
+	}
+	if song == nil {
+		http.Error(w, "Song not found in share", http.StatusNotFound)
+		return
+	}
+
+	if download {
+		ServeSongDownload(w, r, song)
+		return
+	}
+
+	file, err := os.Open(song.FilePath)
+	if err != nil {
+		http.Error(w, "Failed to open file", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	http.ServeContent(w, r, file.Name(), time.Time{}, file)
+}
+
+// openShare loads the share named in the URL and checks that it may be accessed,
+// writing an error response if not: 404 when it does not exist, 410 when it has
+// expired, 401 when the password or key is missing or wrong and 429 after too
+// many wrong passwords.
+func (h *ShareHandler) openShare(w http.ResponseWriter, r *http.Request) (*models.Share, bool) {
+	share, err := db.GetShareByToken(h.DB, chi.URLParam(r, "token"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Share not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get share", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	if share.Expired() {
+		http.Error(w, "Share has expired", http.StatusGone)
+		return nil, false
+	}
+	if share.HasPassword {
+		key := r.URL.Query().Get("key")
+		validKey := key != "" && hmac.Equal([]byte(key), []byte(shareAccessKey(h.Cfg.JWTSecret, share)))
+		if !validKey && !h.checkSharePassword(w, r, share) {
+			return nil, false
+		}
+	}
+	return share, true
+}
+
+// checkSharePassword checks the password sent for share, throttling guesses
+// per share and per client address, and writes an error response if it is
+// wrong or the share is locked.
+func (h *ShareHandler) checkSharePassword(w http.ResponseWriter, r *http.Request, share *models.Share) bool {
+	password := r.Header.Get(sharePasswordHeader)
+	if password == "" {
+		http.Error(w, "Share password required", http.StatusUnauthorized)
+		return false
+	}
+
+	ip := middleware.ClientIP(r, h.TrustedProxies)
+	wait, err := h.Limiter.Check(share.Token, ip)
+	if err != nil {
+		http.Error(w, "Failed to check share password attempts", http.StatusInternalServerError)
+		return false
+	}
+	if wait > 0 {
+		tooManyAttempts(w, wait)
+		return false
+	}
+
+	if !auth.CheckPasswordHash(password, share.PasswordHash) {
+		if err := h.Limiter.Fail(share.Token, ip); err != nil {
+			log.Printf("Failed to record failed password for share %d: %v", share.ID, err)
+		}
+		http.Error(w, "Share password required", http.StatusUnauthorized)
+		return false
+	}
+	if err := h.Limiter.Succeed(share.Token); err != nil {
+		log.Printf("Failed to clear failed passwords for share %d: %v", share.ID, err)
+	}
+	return true
+}
+
+// shareItemsExist reports whether all items to be shared exist. Playlists must be
+// owned by the user; being able to see a playlist is not enough to publish it.
+func (h *ShareHandler) shareItemsExist(userID int, shareType string, ids []int) (bool, error) {
+	var err error
+	switch shareType {
+	case models.ShareSong:
+		_, err = db.GetSongsByIDs(h.DB, ids)
+	case models.ShareAlbum:
+		return db.AlbumExists(h.DB, ids[0])
+	case models.SharePlaylist:
+		var playlist *models.Playlist
+		playlist, err = db.GetPlaylistByID(h.DB, userID, ids[0])
+		if err == nil && !playlist.IsOwner() {
+			return false, nil
+		}
+	}
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// shareAccessKey derives the key that lets stream and download URLs of a
+// password-protected share skip the password. Changing the password invalidates it.
+func shareAccessKey(secret string, share *models.Share) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(share.Token + share.PasswordHash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewShareToken generates the unguessable token identifying a share's public link.
+func NewShareToken() (string, error) {
+	return auth.GenerateRandomToken(shareTokenBytes)
+}
+
+// ShareURL builds the public link of a share, based on PUBLIC_URL or, when that is
+// not set, on the host the request was made to.
+func ShareURL(cfg *config.Config, r *http.Request, token string) string {
+	return publicBaseURL(cfg, r) + "/share/" + token
+}
+
+// publicBaseURL returns PUBLIC_URL or, when that is not set, the scheme and
+// host the request was made to. X-Forwarded-Proto is only believed from the
+// trusted proxies.
+func publicBaseURL(cfg *config.Config, r *http.Request) string {
+	if cfg.PublicURL != "" {
+		return cfg.PublicURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	// Invalid proxy CIDRs stop the server at startup
+	trusted, _ := middleware.ParseCIDRs(cfg.TrustedProxies)
+	if proto := r.Header.Get("X-Forwarded-Proto"); (proto == "http" || proto == "https") && middleware.FromTrustedProxy(r, trusted) {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,301 @@
+package handlers_test
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/config"
+	"go-postgres-example/pkg/handlers"
+	"go-postgres-example/pkg/models"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var shareColumns = []string{
+	"id", "user_id", "token", "item_type", "description", "password_hash",
+	"allow_download", "expires_at", "visit_count", "last_visited_at", "created_at", "item_ids",
+}
+
+func TestCreateShareHandler(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	expectPermission(mock, models.PermissionShare, true)
+	mock.ExpectQuery("FROM songs s").
+		WithArgs("{9,4}").
+		WillReturnRows(sqlmock.NewRows(songColumns).
+			AddRow(9, "abc", "/uploads/abc.flac", "So What", "Miles Davis", "Kind of Blue", 1959, 1, "Jazz", 562, 900, 100, time.Now()).
+			AddRow(4, "def", "/uploads/def.flac", "Blue in Green", "Miles Davis", "Kind of Blue", 1959, 1, "Jazz", 337, 900, 100, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO shares").
+		WithArgs(1, sqlmock.AnyArg(), "song", "For the road", "", true, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(10, time.Now()))
+	mock.ExpectExec("INSERT INTO share_items").
+		WithArgs(10, "{9,4}").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	body := `{"type": "song", "ids": [9, 4], "description": "For the road", "allow_download": true}`
+	req := httptest.NewRequest("POST", "/api/shares", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	// Not from a trusted proxy, so not believed
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var share models.Share
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &share))
+	assert.Equal(t, 10, share.ID)
+	assert.Equal(t, "http://example.com/share/"+share.Token, share.URL)
+	assert.Equal(t, []int{9, 4}, share.ItemIDs)
+	assert.False(t, share.HasPassword)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateShareHandlerValidation(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	for _, body := range []string{
+		`{"type": "artist", "ids": [1]}`,
+		`{"type": "song", "ids": []}`,
+		`{"type": "playlist", "ids": [1, 2]}`,
+		`{"type": "album", "ids": [1], "expires_at": "2001-01-01T00:00:00Z"}`,
+	} {
+		expectSession(mock)
+		expectPermission(mock, models.PermissionShare, true)
+		req := httptest.NewRequest("POST", "/api/shares", bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, body)
+	}
+}
+
+func TestCreateShareHandlerRequiresPermission(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	expectPermission(mock, models.PermissionShare, false)
+
+	req := httptest.NewRequest("POST", "/api/shares", bytes.NewBufferString(`{"type": "song", "ids": [9]}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSharePageHandler(t *testing.T) {
+	r, mock, _ := newTestRouter(t)
+
+	mock.ExpectQuery("FROM shares sh").
+		WithArgs("tok").
+		WillReturnRows(sqlmock.NewRows(shareColumns).
+			AddRow(10, 1, "tok", "song", "For the road", nil, false, nil, 2, nil, time.Now(), "{9}"))
+	mock.ExpectQuery("FROM share_items si").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows(songColumns).
+			AddRow(9, "abc", "/uploads/abc.flac", "So What", "Miles Davis", "Kind of Blue", 1959, 1, "Jazz", 562, 900, 100, time.Now()))
+	mock.ExpectQuery("SELECT username FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("miles"))
+	mock.ExpectExec("UPDATE shares SET visit_count").
+		WithArgs(10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest("GET", "/share/tok", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.NotContains(t, rr.Body.String(), "/uploads/abc.flac")
+	var page handlers.SharePage
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	assert.Equal(t, "miles", page.Owner)
+	require.Len(t, page.Songs, 1)
+	assert.Equal(t, "/share/tok/stream/9", page.Songs[0].StreamURL)
+	assert.Empty(t, page.Songs[0].DownloadURL)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSharePageHandlerAccessDenied(t *testing.T) {
+	hash, err := auth.HashPassword("secret")
+	require.NoError(t, err)
+	expired := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name     string
+		row      []driver.Value
+		password string
+		want     int
+	}{
+		{"expired", []driver.Value{10, 1, "tok", "song", "", nil, false, expired, 0, nil, time.Now(), "{9}"}, "", http.StatusGone},
+		{"password missing", []driver.Value{10, 1, "tok", "song", "", hash, false, nil, 0, nil, time.Now(), "{9}"}, "", http.StatusUnauthorized},
+		{"password wrong", []driver.Value{10, 1, "tok", "song", "", hash, false, nil, 0, nil, time.Now(), "{9}"}, "guess", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, mock, _ := newTestRouter(t)
+			mock.ExpectQuery("FROM shares sh").
+				WithArgs("tok").
+				WillReturnRows(sqlmock.NewRows(shareColumns).AddRow(tt.row...))
+
+			req := httptest.NewRequest("GET", "/share/tok", nil)
+			if tt.password != "" {
+				req.Header.Set("X-Share-Password", tt.password)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.want, rr.Code)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func enableShareLockout(cfg *config.Config) {
+	cfg.LoginMaxFailures = 5
+	cfg.LoginMaxFailuresPerIP = 20
+	cfg.LoginBaseDelay = time.Second
+	cfg.LoginLockout = 15 * time.Minute
+}
+
+func TestSharePageHandlerWrongPasswordRecordsFailure(t *testing.T) {
+	r, mock, _ := newTestRouterWithConfig(t, enableShareLockout)
+	hash, err := auth.HashPassword("secret")
+	require.NoError(t, err)
+
+	mock.ExpectQuery("FROM shares sh").
+		WithArgs("tok").
+		WillReturnRows(sqlmock.NewRows(shareColumns).
+			AddRow(10, 1, "tok", "song", "", hash, false, nil, 0, nil, time.Now(), "{9}"))
+	mock.ExpectQuery("FROM login_failures").
+		WithArgs("share:user:tok", "share:ip:192.0.2.1").
+		WillReturnRows(sqlmock.NewRows([]string{"seconds"}).AddRow(0))
+	mock.ExpectQuery("INSERT INTO login_failures").
+		WithArgs("share:user:tok", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(1))
+	mock.ExpectExec("UPDATE login_failures SET locked_until").
+		WithArgs("share:user:tok", 1.0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO login_failures").
+		WithArgs("share:ip:192.0.2.1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(1))
+
+	req := httptest.NewRequest("GET", "/share/tok", nil)
+	req.RemoteAddr = "192.0.2.1:5000"
+	req.Header.Set("X-Share-Password", "guess")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSharePageHandlerLockedOut(t *testing.T) {
+	r, mock, _ := newTestRouterWithConfig(t, enableShareLockout)
+	hash, err := auth.HashPassword("secret")
+	require.NoError(t, err)
+
+	mock.ExpectQuery("FROM shares sh").
+		WithArgs("tok").
+		WillReturnRows(sqlmock.NewRows(shareColumns).
+			AddRow(10, 1, "tok", "song", "", hash, false, nil, 0, nil, time.Now(), "{9}"))
+	mock.ExpectQuery("FROM login_failures").
+		WithArgs("share:user:tok", "share:ip:192.0.2.1").
+		WillReturnRows(sqlmock.NewRows([]string{"seconds"}).AddRow(9.5))
+
+	req := httptest.NewRequest("GET", "/share/tok", nil)
+	req.RemoteAddr = "192.0.2.1:5000"
+	req.Header.Set("X-Share-Password", "secret")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "10", rr.Header().Get("Retry-After"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShareStreamHandlerWithKey(t *testing.T) {
+	r, mock, _ := newTestRouter(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "abc.mp3")
+	require.NoError(t, os.WriteFile(path, []byte("audio"), 0o644))
+
+	hash, err := auth.HashPassword("secret")
+	require.NoError(t, err)
+	mac := hmac.New(sha256.New, []byte("default-secret"))
+	mac.Write([]byte("tok" + hash))
+	key := hex.EncodeToString(mac.Sum(nil))
+
+	mock.ExpectQuery("FROM shares sh").
+		WithArgs("tok").
+		WillReturnRows(sqlmock.NewRows(shareColumns).
+			AddRow(10, 1, "tok", "song", "", hash, true, nil, 0, nil, time.Now(), "{9}"))
+	mock.ExpectQuery("FROM share_items si").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows(songColumns).
+			AddRow(9, "abc", path, "So What", "Miles Davis", "Kind of Blue", 1959, 1, "Jazz", 562, 900, 100, time.Now()))
+
+	req := httptest.NewRequest("GET", "/share/tok/download/9?key="+key, nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "audio", rr.Body.String())
+	assert.Equal(t, `attachment; filename="Miles Davis - So What.mp3"`, rr.Header().Get("Content-Disposition"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShareStreamHandlerOnlyServesSharedSongs(t *testing.T) {
+	r, mock, _ := newTestRouter(t)
+
+	mock.ExpectQuery("FROM shares sh").
+		WithArgs("tok").
+		WillReturnRows(sqlmock.NewRows(shareColumns).
+			AddRow(10, 1, "tok", "song", "", nil, false, nil, 0, nil, time.Now(), "{9}"))
+	mock.ExpectQuery("FROM share_items si").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows(songColumns).
+			AddRow(9, "abc", "/uploads/abc.flac", "So What", "Miles Davis", "Kind of Blue", 1959, 1, "Jazz", 562, 900, 100, time.Now()))
+
+	req := httptest.NewRequest("GET", "/share/tok/stream/12", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShareDownloadHandlerNotAllowed(t *testing.T) {
+	r, mock, _ := newTestRouter(t)
+
+	mock.ExpectQuery("FROM shares sh").
+		WithArgs("tok").
+		WillReturnRows(sqlmock.NewRows(shareColumns).
+			AddRow(10, 1, "tok", "song", "", nil, false, nil, 0, nil, time.Now(), "{9}"))
+
+	req := httptest.NewRequest("GET", "/share/tok/download/9", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
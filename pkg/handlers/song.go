@@ -5,21 +5,25 @@ import (
 	"encoding/json"
 	"go-postgres-example/pkg/config"
 	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/middleware"
+	"go-postgres-example/pkg/radio"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 )
 
 // SongHandler holds the dependencies for the song handlers.
 type SongHandler struct {
-	DB  *sql.DB
-	Cfg *config.Config
+	DB    *sql.DB
+	Cfg   *config.Config
+	Radio *radio.Generator
 }
 
 // NewSongHandler creates a new SongHandler.
 func NewSongHandler(db *sql.DB, cfg *config.Config) *SongHandler {
-	return &SongHandler{DB: db, Cfg: cfg}
+	return &SongHandler{DB: db, Cfg: cfg, Radio: radio.NewGenerator(db)}
 }
 
 // GetSimilarSongsHandler handles finding songs similar to a given song.
@@ -53,3 +57,62 @@ func (h *SongHandler) GetSimilarSongsHandler(w http.ResponseWriter, r *http.Requ
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(similarSongs)
 }
+
+// RadioHandler generates a radio queue from a seed song, artist, genre or playlist.
+// Query parameters: seed (e.g. "song:12", "artist:Name"), count, drift (0-1) and
+// exclude (comma-separated song IDs, such as tracks already queued). The user's
+// recently played songs are always left out.
+func (h *SongHandler) RadioHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	queryParams := r.URL.Query()
+	seed, err := radio.ParseSeed(queryParams.Get("seed"))
+	if err != nil {
+		http.Error(w, "Invalid seed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts := radio.Options{}
+	if countStr := queryParams.Get("count"); countStr != "" {
+		if opts.Count, err = strconv.Atoi(countStr); err != nil {
+			http.Error(w, "Invalid count", http.StatusBadRequest)
+			return
+		}
+	}
+	if driftStr := queryParams.Get("drift"); driftStr != "" {
+		if opts.Drift, err = strconv.ParseFloat(driftStr, 64); err != nil {
+			http.Error(w, "Invalid drift", http.StatusBadRequest)
+			return
+		}
+	}
+	if excludeStr := queryParams.Get("exclude"); excludeStr != "" {
+		for _, idStr := range strings.Split(excludeStr, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(idStr))
+			if err != nil {
+				http.Error(w, "Invalid exclude list", http.StatusBadRequest)
+				return
+			}
+			opts.Exclude = append(opts.Exclude, id)
+		}
+	}
+
+	songs, err := h.Radio.Generate(userID, seed, opts)
+	if err != nil {
+		if err == radio.ErrSeedNotFound {
+			http.Error(w, "No songs found for the given seed", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to generate radio", http.StatusInternalServerError)
+		}
+		return
+	}
+	if songs == nil {
+		songs = []db.RadioCandidate{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(songs)
+}
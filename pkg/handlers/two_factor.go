@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/middleware"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// totpIssuer names the server in authenticator apps
+	totpIssuer = "Biomuzak"
+	// challengeTTL is how long a user has to enter their code after the password
+	challengeTTL = 5 * time.Minute
+	// recoveryCodeCount is how many recovery codes are issued at a time
+	recoveryCodeCount = 10
+)
+
+// TwoFactorChallenge is returned by Login instead of tokens when the user has
+// two-factor authentication on. The challenge token and a code are exchanged
+// for tokens at /api/auth/2fa.
+type TwoFactorChallenge struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int    `json:"expires_in"`
+}
+
+// TwoFactorLoginRequest represents the request body for the second login step
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"` // A TOTP code or a recovery code
+	DeviceName     string `json:"device_name"`
+}
+
+// TwoFactorCodeRequest represents a request confirmed with a TOTP or recovery code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// TwoFactorDisableRequest represents the request body for turning off
+// two-factor authentication
+type TwoFactorDisableRequest struct {
+	CurrentPassword string `json:"current_password"`
+	Code            string `json:"code"` // A TOTP code or a recovery code
+}
+
+// TwoFactorSetupResponse holds a new TOTP secret to add to an authenticator app
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// TwoFactorStatus describes the current user's two-factor authentication
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// writeChallenge responds to a correct password with a two-factor challenge.
+func (h *AuthHandler) writeChallenge(w http.ResponseWriter, userID int) {
+	token, err := auth.GenerateChallengeToken(userID, h.Cfg.JWTSecret, challengeTTL)
+	if err != nil {
+		http.Error(w, "Failed to generate challenge", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TwoFactorChallenge{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresIn:         int(challengeTTL.Seconds()),
+	})
+}
+
+// LoginTwoFactor finishes a login with a TOTP or recovery code. Wrong codes
+// count as failed logins.
+func (h *AuthHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.ChallengeToken == "" || req.Code == "" {
+		http.Error(w, "Challenge token and code are required", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := auth.ValidateChallengeToken(req.ChallengeToken, h.Cfg.JWTSecret)
+	if err != nil {
+		http.Error(w, "Invalid or expired challenge", http.StatusUnauthorized)
+		return
+	}
+	username, err := db.GetUsernameByID(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Invalid or expired challenge", http.StatusUnauthorized)
+		return
+	}
+
+	ip := middleware.ClientIP(r, h.TrustedProxies)
+	wait, err := h.Limiter.Check(username, ip)
+	if err != nil {
+		http.Error(w, "Failed to check login attempts", http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		tooManyAttempts(w, wait)
+		return
+	}
+
+	secret, enabled, err := db.GetTOTP(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		return
+	}
+	if enabled {
+		ok, err := h.checkSecondFactor(userID, secret, req.Code)
+		if err != nil {
+			http.Error(w, "Failed to check code", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			h.loginFailed(username, ip)
+			http.Error(w, "Invalid code", http.StatusUnauthorized)
+			return
+		}
+	}
+	if err := h.Limiter.Succeed(username); err != nil {
+		log.Printf("Failed to clear failed logins for %q: %v", username, err)
+	}
+
+	deviceName := req.DeviceName
+	if deviceName == "" {
+		deviceName = r.UserAgent()
+	}
+	h.startSession(w, r, userID, deviceName)
+}
+
+// GetTwoFactor returns whether the current user has two-factor authentication on.
+func (h *AuthHandler) GetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	var status TwoFactorStatus
+	_, enabled, err := db.GetTOTP(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Failed to get two-factor status", http.StatusInternalServerError)
+		return
+	}
+	status.Enabled = enabled
+	if enabled {
+		if status.RecoveryCodesLeft, err = db.CountRecoveryCodes(h.DB, userID); err != nil {
+			http.Error(w, "Failed to get two-factor status", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// SetupTwoFactor creates a new TOTP secret for the current user. It is only
+// used once the user confirms a code from it with VerifyTwoFactor.
+func (h *AuthHandler) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	_, enabled, err := db.GetTOTP(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Failed to get two-factor status", http.StatusInternalServerError)
+		return
+	}
+	if enabled {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+	username, err := db.GetUsernameByID(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		http.Error(w, "Failed to generate secret", http.StatusInternalServerError)
+		return
+	}
+	encrypted, err := auth.EncryptSecret(h.Cfg.EncryptionKey, secret)
+	if err != nil {
+		http.Error(w, "Failed to encrypt secret", http.StatusInternalServerError)
+		return
+	}
+	if err := db.SetTOTPSecret(h.DB, userID, encrypted); err != nil {
+		http.Error(w, "Failed to save secret", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(totpIssuer, username, secret),
+	})
+}
+
+// VerifyTwoFactor turns on two-factor authentication once the user enters a
+// code from the secret of SetupTwoFactor, and returns new recovery codes.
+func (h *AuthHandler) VerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	var req TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	encrypted, enabled, err := db.GetTOTP(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Failed to get two-factor status", http.StatusInternalServerError)
+		return
+	}
+	if enabled {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+	if encrypted == "" {
+		http.Error(w, "Set up two-factor authentication first", http.StatusBadRequest)
+		return
+	}
+	secret, err := auth.DecryptSecret(h.Cfg.EncryptionKey, encrypted)
+	if err != nil {
+		http.Error(w, "Failed to decrypt secret", http.StatusInternalServerError)
+		return
+	}
+	step, ok := auth.ValidateTOTP(secret, req.Code, time.Now())
+	if !ok {
+		http.Error(w, "Invalid code", http.StatusBadRequest)
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		http.Error(w, "Failed to generate recovery codes", http.StatusInternalServerError)
+		return
+	}
+	if err := db.EnableTOTP(h.DB, userID, step, hashes); err != nil {
+		http.Error(w, "Failed to enable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"recovery_codes": codes})
+}
+
+// DisableTwoFactor turns off two-factor authentication, confirmed with the
+// current password and a TOTP or recovery code. Wrong passwords and codes count
+// as failed logins of the user, so a stolen token cannot be used to guess them.
+func (h *AuthHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	var req TwoFactorDisableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	username, err := db.GetUsernameByID(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		return
+	}
+
+	ip := middleware.ClientIP(r, h.TrustedProxies)
+	wait, err := h.Limiter.Check(username, ip)
+	if err != nil {
+		http.Error(w, "Failed to check login attempts", http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		tooManyAttempts(w, wait)
+		return
+	}
+	if !h.checkCurrentPassword(w, userID, req.CurrentPassword) {
+		h.loginFailed(username, ip)
+		return
+	}
+
+	secret, enabled, err := db.GetTOTP(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Failed to get two-factor status", http.StatusInternalServerError)
+		return
+	}
+	if !enabled {
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusBadRequest)
+		return
+	}
+	ok, err = h.checkSecondFactor(userID, secret, req.Code)
+	if err != nil {
+		http.Error(w, "Failed to check code", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		h.loginFailed(username, ip)
+		http.Error(w, "Invalid code", http.StatusBadRequest)
+		return
+	}
+	if err := h.Limiter.Succeed(username); err != nil {
+		log.Printf("Failed to clear failed logins for %q: %v", username, err)
+	}
+	if err := db.DisableTOTP(h.DB, userID); err != nil {
+		http.Error(w, "Failed to disable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+// checkSecondFactor checks a TOTP code, which is accepted once, or else uses
+// up a recovery code.
+func (h *AuthHandler) checkSecondFactor(userID int, encryptedSecret, code string) (bool, error) {
+	secret, err := auth.DecryptSecret(h.Cfg.EncryptionKey, encryptedSecret)
+	if err != nil {
+		return false, err
+	}
+	if step, ok := auth.ValidateTOTP(secret, code, time.Now()); ok {
+		return db.UseTOTPStep(h.DB, userID, step)
+	}
+	return db.UseRecoveryCode(h.DB, userID, auth.HashToken(normalizeRecoveryCode(code)))
+}
+
+// generateRecoveryCodes returns new recovery codes, formatted as
+// "xxxxx-xxxxx", and their hashes for storage.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(b)
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = auth.HashToken(code)
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode drops the separators and case of an entered recovery code.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -0,0 +1,289 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/config"
+	"go-postgres-example/pkg/handlers"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestLoginReturnsTwoFactorChallenge(t *testing.T) {
+	r, mock, _ := newTestRouter(t)
+	hash, err := auth.HashPassword("secret")
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT id, password_hash, totp_enabled FROM users").
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "password_hash", "totp_enabled"}).AddRow(1, hash, true))
+
+	req := httptest.NewRequest("POST", "/login", bytes.NewBufferString(`{"username": "alice", "password": "secret"}`))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp handlers.TwoFactorChallenge
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.True(t, resp.TwoFactorRequired)
+	assert.NotContains(t, rr.Body.String(), "refresh_token")
+
+	userID, err := auth.ValidateChallengeToken(resp.ChallengeToken, "default-secret")
+	require.NoError(t, err)
+	assert.Equal(t, 1, userID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+	r, mock, _ := newTestRouter(t)
+	challenge, err := auth.GenerateChallengeToken(1, "default-secret", time.Minute)
+	require.NoError(t, err)
+	encrypted, err := auth.EncryptSecret("default-secret", testTOTPSecret)
+	require.NoError(t, err)
+	step := time.Now().Unix() / 30
+	code, err := auth.TOTPCode(testTOTPSecret, step)
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT username FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	mock.ExpectQuery("SELECT totp_secret, totp_enabled FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"totp_secret", "totp_enabled"}).AddRow(encrypted, true))
+	mock.ExpectExec("UPDATE users SET totp_last_step").
+		WithArgs(1, step).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO sessions").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectExec("INSERT INTO refresh_tokens").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	body := `{"challenge_token": "` + challenge + `", "code": "` + code + `"}`
+	req := httptest.NewRequest("POST", "/api/auth/2fa", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp handlers.TokenResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp.RefreshToken)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLoginTwoFactorRecoveryCode(t *testing.T) {
+	r, mock, _ := newTestRouter(t)
+	challenge, err := auth.GenerateChallengeToken(1, "default-secret", time.Minute)
+	require.NoError(t, err)
+	encrypted, err := auth.EncryptSecret("default-secret", testTOTPSecret)
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT username FROM users").
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	mock.ExpectQuery("SELECT totp_secret, totp_enabled FROM users").
+		WillReturnRows(sqlmock.NewRows([]string{"totp_secret", "totp_enabled"}).AddRow(encrypted, true))
+	mock.ExpectExec("UPDATE recovery_codes SET used_at").
+		WithArgs(1, auth.HashToken("0a1b2c3d4e")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	body := `{"challenge_token": "` + challenge + `", "code": "0A1B2-C3D4E"}`
+	req := httptest.NewRequest("POST", "/api/auth/2fa", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "used or unknown recovery code")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetupAndVerifyTwoFactor(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("SELECT totp_secret, totp_enabled FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"totp_secret", "totp_enabled"}).AddRow(nil, false))
+	mock.ExpectQuery("SELECT username FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	mock.ExpectExec("UPDATE users SET totp_secret").
+		WithArgs(1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest("POST", "/api/me/2fa/setup", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var setup handlers.TwoFactorSetupResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &setup))
+	assert.True(t, strings.HasPrefix(setup.OTPAuthURI, "otpauth://totp/Biomuzak:alice?"))
+	assert.Contains(t, setup.OTPAuthURI, "secret="+setup.Secret)
+
+	encrypted, err := auth.EncryptSecret("default-secret", setup.Secret)
+	require.NoError(t, err)
+	code, err := auth.TOTPCode(setup.Secret, time.Now().Unix()/30)
+	require.NoError(t, err)
+
+	expectSession(mock)
+	mock.ExpectQuery("SELECT totp_secret, totp_enabled FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"totp_secret", "totp_enabled"}).AddRow(encrypted, false))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET totp_enabled = TRUE").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM recovery_codes").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	for i := 0; i < 10; i++ {
+		mock.ExpectExec("INSERT INTO recovery_codes").WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	req = httptest.NewRequest("POST", "/api/me/2fa/verify", bytes.NewBufferString(`{"code": "`+code+`"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp map[string][]string
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Len(t, resp["recovery_codes"], 10)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// newTwoFactorLockoutRouter returns a test router that throttles failed logins.
+func newTwoFactorLockoutRouter(t *testing.T) (http.Handler, sqlmock.Sqlmock, string) {
+	return newTestRouterWithConfig(t, func(cfg *config.Config) {
+		cfg.LoginMaxFailures, cfg.LoginMaxFailuresPerIP = 5, 20
+		cfg.LoginBaseDelay, cfg.LoginLockout = time.Second, 15*time.Minute
+	})
+}
+
+func TestDisableTwoFactor(t *testing.T) {
+	r, mock, token := newTwoFactorLockoutRouter(t)
+	hash, err := auth.HashPassword("secret")
+	require.NoError(t, err)
+	encrypted, err := auth.EncryptSecret("default-secret", testTOTPSecret)
+	require.NoError(t, err)
+	code, err := auth.TOTPCode(testTOTPSecret, time.Now().Unix()/30)
+	require.NoError(t, err)
+
+	expectSession(mock)
+	mock.ExpectQuery("SELECT username FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	mock.ExpectQuery("FROM login_failures").
+		WithArgs("user:alice", "ip:192.0.2.1").
+		WillReturnRows(sqlmock.NewRows([]string{"seconds"}).AddRow(0))
+	mock.ExpectQuery("SELECT password_hash FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"password_hash"}).AddRow(hash))
+	mock.ExpectQuery("SELECT totp_secret, totp_enabled FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"totp_secret", "totp_enabled"}).AddRow(encrypted, true))
+	mock.ExpectExec("UPDATE users SET totp_last_step").
+		WithArgs(1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM login_failures").
+		WithArgs("user:alice").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET totp_secret = NULL").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM recovery_codes").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 10))
+	mock.ExpectCommit()
+
+	body := `{"current_password": "secret", "code": "` + code + `"}`
+	req := httptest.NewRequest("POST", "/api/me/2fa/disable", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDisableTwoFactorWrongPassword(t *testing.T) {
+	r, mock, token := newTwoFactorLockoutRouter(t)
+	hash, err := auth.HashPassword("secret")
+	require.NoError(t, err)
+
+	expectSession(mock)
+	mock.ExpectQuery("SELECT username FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	mock.ExpectQuery("FROM login_failures").
+		WithArgs("user:alice", "ip:192.0.2.1").
+		WillReturnRows(sqlmock.NewRows([]string{"seconds"}).AddRow(0))
+	mock.ExpectQuery("SELECT password_hash FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"password_hash"}).AddRow(hash))
+	mock.ExpectQuery("INSERT INTO login_failures").
+		WithArgs("user:alice", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(1))
+	mock.ExpectExec("UPDATE login_failures SET locked_until").
+		WithArgs("user:alice", 1.0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO login_failures").
+		WithArgs("ip:192.0.2.1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(1))
+
+	req := httptest.NewRequest("POST", "/api/me/2fa/disable", bytes.NewBufferString(`{"current_password": "wrong", "code": "123456"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDisableTwoFactorLockedOut(t *testing.T) {
+	r, mock, token := newTwoFactorLockoutRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("SELECT username FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	mock.ExpectQuery("FROM login_failures").
+		WithArgs("user:alice", "ip:192.0.2.1").
+		WillReturnRows(sqlmock.NewRows([]string{"seconds"}).AddRow(60))
+
+	req := httptest.NewRequest("POST", "/api/me/2fa/disable", bytes.NewBufferString(`{"current_password": "secret", "code": "123456"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateAppPassword(t *testing.T) {
+	r, mock, token := newTestRouter(t)
+
+	expectSession(mock)
+	mock.ExpectQuery("INSERT INTO app_passwords").
+		WithArgs(1, "Phone", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(8, time.Now()))
+
+	req := httptest.NewRequest("POST", "/api/me/app-passwords", bytes.NewBufferString(`{"name": "Phone"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var resp struct {
+		ID       int    `json:"id"`
+		Password string `json:"password"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, 8, resp.ID)
+	assert.Len(t, resp.Password, 24)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,10 +1,17 @@
 package handlers
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"go-postgres-example/pkg/archive"
 	"go-postgres-example/pkg/config"
+	"go-postgres-example/pkg/db"
 	"go-postgres-example/pkg/metadata"
+	"go-postgres-example/pkg/middleware"
+	"go-postgres-example/pkg/tus"
 	"io"
 	"log"
 	"mime/multipart"
@@ -18,19 +25,52 @@ import (
 type UploadHandler struct {
 	DB  *sql.DB
 	Cfg *config.Config
+	// Tus serves resumable uploads, which are processed like multipart ones
+	Tus *tus.Handler
 }
 
 // NewUploadHandler creates a new UploadHandler
 func NewUploadHandler(db *sql.DB, cfg *config.Config) *UploadHandler {
-	return &UploadHandler{DB: db, Cfg: cfg}
+	h := &UploadHandler{DB: db, Cfg: cfg}
+	h.Tus = &tus.Handler{
+		Store:        tus.NewStore(cfg.PartialUploadDir),
+		MaxSize:      cfg.MaxUploadSize,
+		Expiry:       cfg.PartialUploadExpiry,
+		BeforeCreate: h.checkResumableUpload,
+		Complete:     h.processResumableUpload,
+	}
+	return h
+}
+
+// uploadedFile is an audio file of an upload, by its content hash, which is
+// empty when the content is not known yet.
+type uploadedFile struct {
+	Hash string
+	Size int64
 }
 
-// Upload handles file uploads and starts the processing.
+// Upload handles file uploads and starts the processing. Zip and tar archives
+// are extracted with their folders, so that whole albums can be uploaded at
+// once. Requests over the size limit, files in formats that are not accepted,
+// invalid archives and uploads that would take the user over their quota are
+// refused before anything is processed.
 func (h *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
-	// Set a reasonable limit for the file size. Here, 500 MB.
-	r.Body = http.MaxBytesReader(w, r.Body, 500<<20)
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	if h.Cfg.MaxUploadSize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.Cfg.MaxUploadSize)
+	}
 	if err := r.ParseMultipartForm(32 << 20); err != nil {
-		http.Error(w, "The uploaded file is too big. Please choose a file less than 500MB.", http.StatusBadRequest)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, fmt.Sprintf("The upload is too big. Please upload less than %s at once.", formatSize(h.Cfg.MaxUploadSize)), http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "Invalid upload", http.StatusBadRequest)
+		}
 		return
 	}
 
@@ -39,6 +79,12 @@ func (h *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "No files were uploaded", http.StatusBadRequest)
 		return
 	}
+	for _, fileHeader := range files {
+		if !h.acceptedFormat(fileHeader.Filename) {
+			http.Error(w, "Unsupported file format: "+fileHeader.Filename, http.StatusUnsupportedMediaType)
+			return
+		}
+	}
 
 	tempDir, err := os.MkdirTemp("", "music-uploads-*")
 	if err != nil {
@@ -58,14 +104,272 @@ func (h *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if err := h.extractArchives(tempDir); err != nil {
+		log.Printf("Error extracting archives in %s: %v", tempDir, err)
+		os.RemoveAll(tempDir)
+		if errors.Is(err, archive.ErrTooLarge) || errors.Is(err, archive.ErrTooManyEntries) {
+			http.Error(w, fmt.Sprintf("Invalid archive %v. Please upload at most %d files and %s at once.", err, h.Cfg.ArchiveMaxEntries, formatSize(h.Cfg.ArchiveMaxSize)), http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, fmt.Sprintf("Invalid archive %v", err), http.StatusBadRequest)
+		}
+		return
+	}
+
+	uploads, err := collectUploads(tempDir)
+	if err != nil {
+		log.Printf("Error reading uploaded files in %s: %v", tempDir, err)
+		http.Error(w, "Failed to save uploaded file", http.StatusInternalServerError)
+		os.RemoveAll(tempDir)
+		return
+	}
+	if len(uploads) == 0 {
+		http.Error(w, "No audio files were uploaded", http.StatusBadRequest)
+		os.RemoveAll(tempDir)
+		return
+	}
+
+	if !h.checkQuota(w, userID, uploads) {
+		os.RemoveAll(tempDir)
+		return
+	}
+
+	h.startProcessing(tempDir, userID)
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Successfully uploaded %d files. Processing has started in the background.", len(uploads))
+}
+
+// extractArchives extracts the archives uploaded into a temporary directory,
+// each into a folder named after it, and removes them. Only audio files, cue
+// sheets and cover images are extracted, within the configured limits for
+// the whole upload. Errors name the archive they come from.
+func (h *UploadHandler) extractArchives(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	extractor := &archive.Extractor{
+		MaxEntries: h.Cfg.ArchiveMaxEntries,
+		MaxSize:    h.Cfg.ArchiveMaxSize,
+		Keep: func(name string) bool {
+			return isSupportedAudioFile(name) || metadata.IsCueSheet(name) || metadata.IsCoverImage(name)
+		},
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || archive.Format(entry.Name()) == "" {
+			continue
+		}
+		src := filepath.Join(dir, entry.Name())
+		n, err := extractor.Extract(src, filepath.Join(dir, archive.BaseName(entry.Name())))
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		log.Printf("Extracted %d files from %s", n, entry.Name())
+		if err := os.Remove(src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectUploads returns the audio files in a temporary directory, once for
+// each content.
+func collectUploads(dir string) ([]uploadedFile, error) {
+	var uploads []uploadedFile
+	seen := make(map[string]bool)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() || !isSupportedAudioFile(path) {
+			return nil
+		}
+		hash, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		if !seen[hash] {
+			seen[hash] = true
+			uploads = append(uploads, uploadedFile{Hash: hash, Size: info.Size()})
+		}
+		return nil
+	})
+	return uploads, err
+}
+
+// hashFile returns the SHA-256 hash of a file's content.
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// startProcessing processes the files in a temporary directory in the
+// background, recording the user as the songs' owner, and then removes it.
+func (h *UploadHandler) startProcessing(dir string, userID int) {
 	// Create a new metadata processor
 	processor := metadata.NewProcessor(h.DB, h.Cfg)
+	processor.UserID = userID
 
 	// Run the processing in a background goroutine
-	go h.processDirectory(tempDir, processor)
+	go h.processDirectory(dir, processor)
+}
 
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "Successfully uploaded %d files. Processing has started in the background.", len(files))
+// checkResumableUpload checks a new resumable upload like the files of a
+// multipart one. Its content is not known yet, so it counts as a new song.
+func (h *UploadHandler) checkResumableUpload(w http.ResponseWriter, r *http.Request, info tus.Info) bool {
+	filename := info.Filename()
+	if filename == "" {
+		http.Error(w, "Upload-Metadata must include the filename", http.StatusBadRequest)
+		return false
+	}
+	if !h.acceptedFormat(filename) {
+		http.Error(w, "Unsupported file format: "+filename, http.StatusUnsupportedMediaType)
+		return false
+	}
+	return h.checkQuota(w, info.UserID, []uploadedFile{{Size: info.Size}})
+}
+
+// processResumableUpload moves a completed resumable upload into a temporary
+// directory, under the name the client gave it, and processes it. Archives
+// are extracted first, and rejected with the same messages as multipart
+// uploads if they are invalid or what they hold would take the user over
+// their quota.
+func (h *UploadHandler) processResumableUpload(info tus.Info, path string) error {
+	// Next to the upload, so that it can be renamed rather than copied
+	tempDir, err := os.MkdirTemp(filepath.Dir(path), "music-uploads-*")
+	if err != nil {
+		log.Printf("Error creating temp dir for upload %s: %v", info.ID, err)
+		return errors.New("Failed to create temporary directory for uploads")
+	}
+	if err := os.Rename(path, filepath.Join(tempDir, filepath.Base(info.Filename()))); err != nil {
+		log.Printf("Error moving upload %s: %v", info.ID, err)
+		os.RemoveAll(tempDir)
+		return errors.New("Failed to save uploaded file")
+	}
+
+	if archive.Format(info.Filename()) != "" {
+		if err := h.extractArchives(tempDir); err != nil {
+			os.RemoveAll(tempDir)
+			if errors.Is(err, archive.ErrTooLarge) || errors.Is(err, archive.ErrTooManyEntries) {
+				return fmt.Errorf("Invalid archive %v. Please upload at most %d files and %s at once.", err, h.Cfg.ArchiveMaxEntries, formatSize(h.Cfg.ArchiveMaxSize))
+			}
+			return fmt.Errorf("Invalid archive %v", err)
+		}
+		uploads, err := collectUploads(tempDir)
+		if err != nil {
+			log.Printf("Error reading upload %s: %v", info.ID, err)
+			os.RemoveAll(tempDir)
+			return errors.New("Failed to save uploaded file")
+		}
+		if len(uploads) == 0 {
+			os.RemoveAll(tempDir)
+			return errors.New("No audio files were uploaded")
+		}
+		message, err := h.overQuota(info.UserID, uploads)
+		if err != nil {
+			log.Printf("Error checking quota for upload %s: %v", info.ID, err)
+			os.RemoveAll(tempDir)
+			return errors.New("Failed to get storage usage")
+		}
+		if message != "" {
+			os.RemoveAll(tempDir)
+			return errors.New(message)
+		}
+	}
+	h.startProcessing(tempDir, info.UserID)
+	return nil
+}
+
+// acceptedFormat checks that a file is a supported audio file or archive in
+// one of the configured upload formats.
+func (h *UploadHandler) acceptedFormat(filename string) bool {
+	format := archive.Format(filename)
+	if format == "" {
+		if !isSupportedAudioFile(filename) {
+			return false
+		}
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+	}
+	for _, accepted := range h.Cfg.UploadFormats {
+		if format == accepted {
+			return true
+		}
+	}
+	return false
+}
+
+// checkQuota checks that a user may add the uploaded files, writing 413
+// Request Entity Too Large if they would take the user over a quota. Songs
+// the user already uploaded take nothing more.
+func (h *UploadHandler) checkQuota(w http.ResponseWriter, userID int, uploads []uploadedFile) bool {
+	message, err := h.overQuota(userID, uploads)
+	if err != nil {
+		http.Error(w, "Failed to get storage usage", http.StatusInternalServerError)
+		return false
+	}
+	if message != "" {
+		http.Error(w, message, http.StatusRequestEntityTooLarge)
+		return false
+	}
+	return true
+}
+
+// overQuota returns why the uploaded files would take a user over a quota,
+// or "" if they would not.
+func (h *UploadHandler) overQuota(userID int, uploads []uploadedFile) (string, error) {
+	usage, err := db.GetUsage(h.DB, userID, h.Cfg.DefaultQuotaBytes, h.Cfg.DefaultQuotaTracks)
+	if err != nil {
+		return "", err
+	}
+	if usage.QuotaBytes == 0 && usage.QuotaTracks == 0 {
+		return "", nil
+	}
+
+	bytes, tracks := usage.Bytes, usage.Tracks
+	for _, upload := range uploads {
+		owned := false
+		if upload.Hash != "" {
+			if owned, err = db.OwnsSong(h.DB, userID, upload.Hash); err != nil {
+				return "", err
+			}
+		}
+		if !owned {
+			bytes += upload.Size
+			tracks++
+		}
+	}
+
+	if usage.QuotaBytes > 0 && bytes > usage.QuotaBytes {
+		return fmt.Sprintf("Storage quota exceeded: this upload would use %s of your %s", formatSize(bytes), formatSize(usage.QuotaBytes)), nil
+	}
+	if usage.QuotaTracks > 0 && tracks > usage.QuotaTracks {
+		return fmt.Sprintf("Track quota exceeded: this upload would make %d of your %d tracks", tracks, usage.QuotaTracks), nil
+	}
+	return "", nil
+}
+
+// formatSize formats a number of bytes for messages, such as "500 MB".
+func formatSize(bytes int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	size, unit := float64(bytes), 0
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+	if size == float64(int64(size)) {
+		return fmt.Sprintf("%d %s", int64(size), units[unit])
+	}
+	return fmt.Sprintf("%.1f %s", size, units[unit])
 }
 
 // saveUploadedFile saves a single multipart file to the destination directory.
@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"archive/zip"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"go-postgres-example/pkg/archive"
+	"go-postgres-example/pkg/config"
 	"go-postgres-example/pkg/metadata"
 
 	"github.com/stretchr/testify/assert"
@@ -56,3 +59,50 @@ func TestProcessDirectory(t *testing.T) {
 	// 3. Assert
 	assert.Equal(t, 2, mockProc.ProcessFileCalls, "ProcessFile should be called for two supported files")
 }
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "512 B", formatSize(512))
+	assert.Equal(t, "500 MB", formatSize(500<<20))
+	assert.Equal(t, "1.5 GB", formatSize(3<<29))
+}
+
+func TestExtractArchives(t *testing.T) {
+	dir := t.TempDir()
+	file, err := os.Create(filepath.Join(dir, "Kind of Blue.zip"))
+	require.NoError(t, err)
+	zw := zip.NewWriter(file)
+	for _, entry := range [][2]string{
+		{"Jazz/01 So What.mp3", "so what"},
+		{"Jazz/cover.jpg", "jpeg"},
+		{"Jazz/album.cue", "cue"},
+		{"Jazz/notes.txt", "notes"},
+		{"Jazz/01 So What (copy).mp3", "so what"},
+	} {
+		w, err := zw.Create(entry[0])
+		require.NoError(t, err)
+		w.Write([]byte(entry[1]))
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, file.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "single.flac"), []byte("flac"), 0644))
+
+	handler := &UploadHandler{Cfg: &config.Config{ArchiveMaxEntries: 10}}
+	require.NoError(t, handler.extractArchives(dir))
+
+	albumDir := filepath.Join(dir, "Kind of Blue", "Jazz")
+	assert.FileExists(t, filepath.Join(albumDir, "01 So What.mp3"))
+	assert.FileExists(t, filepath.Join(albumDir, "cover.jpg"))
+	assert.FileExists(t, filepath.Join(albumDir, "album.cue"))
+	assert.NoFileExists(t, filepath.Join(albumDir, "notes.txt"))
+	assert.NoFileExists(t, filepath.Join(dir, "Kind of Blue.zip"))
+
+	// The copy of the first song counts once
+	uploads, err := collectUploads(dir)
+	require.NoError(t, err)
+	assert.Len(t, uploads, 2)
+
+	require.NoError(t, os.Rename(filepath.Join(dir, "single.flac"), filepath.Join(dir, "fake.zip")))
+	err = handler.extractArchives(dir)
+	assert.ErrorIs(t, err, archive.ErrUnknownFormat)
+	assert.Contains(t, err.Error(), "fake.zip")
+}
@@ -0,0 +1,87 @@
+package jukebox
+
+import (
+	"sync"
+	"time"
+)
+
+// FakePlayer is a Player that only records what it is asked to do. It is meant
+// for tests and for running the jukebox without an audio device.
+type FakePlayer struct {
+	mu       sync.Mutex
+	loaded   string
+	paused   bool
+	gain     float64
+	position time.Duration
+	loads    []string
+	ended    chan struct{}
+}
+
+// NewFakePlayer creates a new FakePlayer
+func NewFakePlayer() *FakePlayer {
+	return &FakePlayer{gain: 1, ended: make(chan struct{})}
+}
+
+// Load records the file as playing from offset.
+func (p *FakePlayer) Load(path string, offset time.Duration) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loaded, p.paused, p.position = path, false, offset
+	p.loads = append(p.loads, path)
+	return nil
+}
+
+// SetPaused records whether playback is paused.
+func (p *FakePlayer) SetPaused(paused bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = paused
+	return nil
+}
+
+// Stop unloads the current file.
+func (p *FakePlayer) Stop() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loaded, p.paused, p.position = "", false, 0
+	return nil
+}
+
+// SetGain records the volume.
+func (p *FakePlayer) SetGain(gain float64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gain = gain
+	return nil
+}
+
+// Position returns the offset the current file was loaded at.
+func (p *FakePlayer) Position() (time.Duration, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.position, nil
+}
+
+// Ended receives a value each time Finish is called.
+func (p *FakePlayer) Ended() <-chan struct{} {
+	return p.ended
+}
+
+// Finish simulates the current file playing to its end.
+func (p *FakePlayer) Finish() {
+	p.ended <- struct{}{}
+}
+
+// State returns the loaded file (empty when stopped), whether it is paused and the volume.
+func (p *FakePlayer) State() (loaded string, paused bool, gain float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.loaded, p.paused, p.gain
+}
+
+// Loads returns every file loaded so far, in order.
+func (p *FakePlayer) Loads() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.loads...)
+}
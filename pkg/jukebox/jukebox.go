@@ -0,0 +1,278 @@
+// Package jukebox plays a shared playlist on the server's own audio output, so
+// that users can control what plays through speakers attached to the server.
+package jukebox
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go-postgres-example/pkg/models"
+)
+
+// ErrInvalidIndex is returned when a playlist index is out of range.
+var ErrInvalidIndex = errors.New("playlist index out of range")
+
+// ErrInvalidGain is returned when a gain is not between 0 and 1.
+var ErrInvalidGain = errors.New("gain must be between 0 and 1")
+
+// defaultGain is the volume the jukebox starts at.
+const defaultGain = 0.75
+
+// Player plays audio files on the server's output device.
+type Player interface {
+	// Load starts playing a file from the given offset, replacing the current one.
+	Load(path string, offset time.Duration) error
+	// SetPaused pauses or resumes the current file.
+	SetPaused(paused bool) error
+	// Stop stops playback and unloads the current file.
+	Stop() error
+	// SetGain sets the volume, from 0 to 1.
+	SetGain(gain float64) error
+	// Position returns how far into the current file playback is.
+	Position() (time.Duration, error)
+	// Ended receives a value each time the current file plays to its end.
+	Ended() <-chan struct{}
+}
+
+// Status describes the state of the jukebox. CurrentIndex is -1 when nothing is selected.
+type Status struct {
+	CurrentIndex int
+	Playing      bool
+	Gain         float64
+	Position     time.Duration
+}
+
+// Jukebox is a playlist played by a Player. It is safe for concurrent use.
+type Jukebox struct {
+	player Player
+
+	mu      sync.Mutex
+	songs   []models.Song
+	current int
+	playing bool
+	loaded  bool // Whether the current song is loaded in the player, playing or paused
+	gain    float64
+}
+
+// New creates a Jukebox driving the given player and starts following the
+// player so that the next song plays when one ends.
+func New(player Player) *Jukebox {
+	j := &Jukebox{player: player, current: -1, gain: defaultGain}
+	go j.advanceOnEnd()
+	return j
+}
+
+// Status returns the current state of the jukebox.
+func (j *Jukebox) Status() Status {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status()
+}
+
+// Playlist returns the songs in the jukebox playlist along with its state.
+func (j *Jukebox) Playlist() ([]models.Song, Status) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]models.Song(nil), j.songs...), j.status()
+}
+
+// Set replaces the playlist. If the jukebox was playing, it starts over with the first song.
+func (j *Jukebox) Set(songs []models.Song) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.songs = append([]models.Song(nil), songs...)
+	j.current = -1
+	if len(j.songs) == 0 {
+		return j.stop()
+	}
+	j.current = 0
+	if j.playing {
+		return j.load(0)
+	}
+	j.loaded = false
+	return j.player.Stop()
+}
+
+// Start starts or resumes playback of the current song.
+func (j *Jukebox) Start() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if len(j.songs) == 0 {
+		return nil
+	}
+	if j.loaded {
+		if err := j.player.SetPaused(false); err != nil {
+			return err
+		}
+		j.playing = true
+		return nil
+	}
+	if j.current < 0 {
+		j.current = 0
+	}
+	return j.load(0)
+}
+
+// Stop pauses playback. Start resumes it where it stopped.
+func (j *Jukebox) Stop() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if !j.loaded {
+		j.playing = false
+		return nil
+	}
+	if err := j.player.SetPaused(true); err != nil {
+		return err
+	}
+	j.playing = false
+	return nil
+}
+
+// Skip starts playing the song at index, offset into the song.
+func (j *Jukebox) Skip(index int, offset time.Duration) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if index < 0 || index >= len(j.songs) {
+		return ErrInvalidIndex
+	}
+	j.current = index
+	return j.load(offset)
+}
+
+// Add appends songs to the playlist.
+func (j *Jukebox) Add(songs []models.Song) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.songs = append(j.songs, songs...)
+	if j.current < 0 && len(j.songs) > 0 {
+		j.current = 0
+	}
+}
+
+// Clear empties the playlist and stops playback.
+func (j *Jukebox) Clear() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.songs = nil
+	j.current = -1
+	return j.stop()
+}
+
+// Remove removes the song at index from the playlist. Removing the current song
+// moves on to the song that takes its place.
+func (j *Jukebox) Remove(index int) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if index < 0 || index >= len(j.songs) {
+		return ErrInvalidIndex
+	}
+	j.songs = append(j.songs[:index], j.songs[index+1:]...)
+
+	switch {
+	case index < j.current:
+		j.current--
+	case index == j.current:
+		if j.current >= len(j.songs) {
+			j.current = len(j.songs) - 1
+			return j.stop()
+		}
+		if j.playing {
+			return j.load(0)
+		}
+		j.loaded = false
+		return j.player.Stop()
+	}
+	return nil
+}
+
+// Shuffle shuffles the playlist. The current song keeps playing.
+func (j *Jukebox) Shuffle() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var currentID int
+	if j.current >= 0 {
+		currentID = j.songs[j.current].ID
+	}
+	rand.Shuffle(len(j.songs), func(a, b int) {
+		j.songs[a], j.songs[b] = j.songs[b], j.songs[a]
+	})
+	if j.current >= 0 {
+		for i, song := range j.songs {
+			if song.ID == currentID {
+				j.current = i
+				break
+			}
+		}
+	}
+}
+
+// SetGain sets the volume, from 0 to 1.
+func (j *Jukebox) SetGain(gain float64) error {
+	if gain < 0 || gain > 1 {
+		return ErrInvalidGain
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.player.SetGain(gain); err != nil {
+		return err
+	}
+	j.gain = gain
+	return nil
+}
+
+// status returns the state of the jukebox; j.mu must be held.
+func (j *Jukebox) status() Status {
+	status := Status{CurrentIndex: j.current, Playing: j.playing, Gain: j.gain}
+	if j.loaded {
+		if position, err := j.player.Position(); err == nil {
+			status.Position = position
+		}
+	}
+	return status
+}
+
+// load plays the current song from offset; j.mu must be held.
+func (j *Jukebox) load(offset time.Duration) error {
+	if err := j.player.Load(j.songs[j.current].FilePath, offset); err != nil {
+		j.playing, j.loaded = false, false
+		return err
+	}
+	j.playing, j.loaded = true, true
+	return j.player.SetGain(j.gain)
+}
+
+// stop stops the player; j.mu must be held.
+func (j *Jukebox) stop() error {
+	j.playing, j.loaded = false, false
+	return j.player.Stop()
+}
+
+// advanceOnEnd plays the next song each time the player finishes one, and stops
+// at the end of the playlist.
+func (j *Jukebox) advanceOnEnd() {
+	for range j.player.Ended() {
+		j.mu.Lock()
+		if j.loaded && j.current+1 < len(j.songs) {
+			j.current++
+			if err := j.load(0); err != nil {
+				log.Printf("Jukebox failed to play the next song: %v", err)
+			}
+		} else {
+			j.playing, j.loaded = false, false
+		}
+		j.mu.Unlock()
+	}
+}
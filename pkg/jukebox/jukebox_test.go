@@ -0,0 +1,109 @@
+package jukebox
+
+import (
+	"testing"
+	"time"
+
+	"go-postgres-example/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSongs(ids ...int) []models.Song {
+	songs := make([]models.Song, 0, len(ids))
+	for _, id := range ids {
+		songs = append(songs, models.Song{ID: id, FilePath: "/music/" + string(rune('a'+id)) + ".mp3"})
+	}
+	return songs
+}
+
+func TestJukeboxStartStopAndSkip(t *testing.T) {
+	player := NewFakePlayer()
+	j := New(player)
+
+	require.NoError(t, j.Set(testSongs(0, 1, 2)))
+	loaded, _, _ := player.State()
+	assert.Empty(t, loaded, "set does not start playback")
+
+	require.NoError(t, j.Start())
+	loaded, paused, gain := player.State()
+	assert.Equal(t, "/music/a.mp3", loaded)
+	assert.False(t, paused)
+	assert.Equal(t, defaultGain, gain)
+
+	require.NoError(t, j.Stop())
+	_, paused, _ = player.State()
+	assert.True(t, paused)
+	assert.False(t, j.Status().Playing)
+
+	require.NoError(t, j.Start())
+	assert.Len(t, player.Loads(), 1, "start resumes the paused song")
+
+	require.NoError(t, j.Skip(2, 30*time.Second))
+	status := j.Status()
+	assert.Equal(t, 2, status.CurrentIndex)
+	assert.Equal(t, 30*time.Second, status.Position)
+	assert.ErrorIs(t, j.Skip(3, 0), ErrInvalidIndex)
+}
+
+func TestJukeboxAdvancesWhenSongEnds(t *testing.T) {
+	player := NewFakePlayer()
+	j := New(player)
+
+	require.NoError(t, j.Set(testSongs(0, 1)))
+	require.NoError(t, j.Start())
+
+	player.Finish()
+	assert.Eventually(t, func() bool { return j.Status().CurrentIndex == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"/music/a.mp3", "/music/b.mp3"}, player.Loads())
+
+	player.Finish()
+	assert.Eventually(t, func() bool { return !j.Status().Playing }, time.Second, 10*time.Millisecond)
+}
+
+func TestJukeboxRemove(t *testing.T) {
+	player := NewFakePlayer()
+	j := New(player)
+
+	require.NoError(t, j.Set(testSongs(0, 1, 2)))
+	require.NoError(t, j.Skip(1, 0))
+
+	require.NoError(t, j.Remove(0))
+	assert.Equal(t, 0, j.Status().CurrentIndex, "the current song keeps playing")
+
+	require.NoError(t, j.Remove(0))
+	songs, status := j.Playlist()
+	require.Len(t, songs, 1)
+	assert.Equal(t, 2, songs[0].ID)
+	assert.Equal(t, 0, status.CurrentIndex)
+	loaded, _, _ := player.State()
+	assert.Equal(t, "/music/c.mp3", loaded, "removing the current song plays the next one")
+
+	require.NoError(t, j.Remove(0))
+	assert.False(t, j.Status().Playing)
+	assert.ErrorIs(t, j.Remove(0), ErrInvalidIndex)
+}
+
+func TestJukeboxShuffleKeepsCurrentSong(t *testing.T) {
+	j := New(NewFakePlayer())
+
+	require.NoError(t, j.Set(testSongs(0, 1, 2, 3, 4, 5)))
+	require.NoError(t, j.Skip(3, 0))
+	j.Shuffle()
+
+	songs, status := j.Playlist()
+	assert.Len(t, songs, 6)
+	assert.Equal(t, 3, songs[status.CurrentIndex].ID)
+}
+
+func TestJukeboxSetGain(t *testing.T) {
+	player := NewFakePlayer()
+	j := New(player)
+
+	require.NoError(t, j.SetGain(0.5))
+	_, _, gain := player.State()
+	assert.Equal(t, 0.5, gain)
+	assert.ErrorIs(t, j.SetGain(1.5), ErrInvalidGain)
+	assert.Equal(t, 0.5, j.Status().Gain)
+}
@@ -0,0 +1,228 @@
+package jukebox
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// mpvStartTimeout bounds how long to wait for a new mpv process to open its IPC socket.
+	mpvStartTimeout = 5 * time.Second
+	// mpvCommandTimeout bounds how long to wait for mpv to answer a command.
+	mpvCommandTimeout = 5 * time.Second
+)
+
+// errPropertyUnavailable is mpv's answer when reading the position while nothing is loaded.
+const errPropertyUnavailable = "property unavailable"
+
+// MPVPlayer is a Player that drives an mpv process through its JSON IPC socket.
+type MPVPlayer struct {
+	cmd  *exec.Cmd
+	conn io.ReadWriteCloser
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan mpvResponse
+	ended   chan struct{}
+}
+
+// mpvResponse is mpv's reply to a command, or an event when Event is set.
+type mpvResponse struct {
+	RequestID int             `json:"request_id"`
+	Error     string          `json:"error"`
+	Data      json.RawMessage `json:"data"`
+	Event     string          `json:"event"`
+	Reason    string          `json:"reason"`
+}
+
+// StartMPV starts an idle mpv process listening on socketPath and connects to it.
+func StartMPV(binary string, socketPath string) (*MPVPlayer, error) {
+	os.Remove(socketPath) // A stale socket from a previous run would make the dial below fail
+
+	cmd := exec.Command(binary,
+		"--idle=yes",
+		"--no-video",
+		"--no-terminal",
+		"--input-ipc-server="+socketPath,
+	)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start mpv: %w", err)
+	}
+
+	deadline := time.Now().Add(mpvStartTimeout)
+	for {
+		conn, err := net.Dial("unix", socketPath)
+		if err == nil {
+			p := newMPVPlayer(conn)
+			p.cmd = cmd
+			return p, nil
+		}
+		if time.Now().After(deadline) {
+			cmd.Process.Kill()
+			return nil, fmt.Errorf("failed to connect to mpv: %w", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// newMPVPlayer creates an MPVPlayer talking to mpv over conn.
+func newMPVPlayer(conn io.ReadWriteCloser) *MPVPlayer {
+	p := &MPVPlayer{
+		conn:    conn,
+		pending: map[int]chan mpvResponse{},
+		ended:   make(chan struct{}, 1),
+	}
+	go p.readLoop()
+	return p
+}
+
+// Load starts playing a file from the given offset, replacing the current one.
+func (p *MPVPlayer) Load(path string, offset time.Duration) error {
+	// Named arguments keep working across mpv versions that changed loadfile's positional ones.
+	command := map[string]interface{}{
+		"name":  "loadfile",
+		"url":   path,
+		"flags": "replace",
+	}
+	if offset > 0 {
+		command["options"] = fmt.Sprintf("start=%.3f", offset.Seconds())
+	}
+	if _, err := p.command(command); err != nil {
+		return err
+	}
+	return p.SetPaused(false)
+}
+
+// SetPaused pauses or resumes the current file.
+func (p *MPVPlayer) SetPaused(paused bool) error {
+	_, err := p.command([]interface{}{"set_property", "pause", paused})
+	return err
+}
+
+// Stop stops playback and unloads the current file.
+func (p *MPVPlayer) Stop() error {
+	_, err := p.command([]interface{}{"stop"})
+	return err
+}
+
+// SetGain sets the volume, from 0 to 1.
+func (p *MPVPlayer) SetGain(gain float64) error {
+	_, err := p.command([]interface{}{"set_property", "volume", gain * 100})
+	return err
+}
+
+// Position returns how far into the current file playback is, or 0 if nothing is loaded.
+func (p *MPVPlayer) Position() (time.Duration, error) {
+	data, err := p.command([]interface{}{"get_property", "time-pos"})
+	if err != nil {
+		if strings.Contains(err.Error(), errPropertyUnavailable) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// Ended receives a value each time the current file plays to its end.
+func (p *MPVPlayer) Ended() <-chan struct{} {
+	return p.ended
+}
+
+// Close disconnects from mpv and stops the process if it was started by StartMPV.
+func (p *MPVPlayer) Close() error {
+	err := p.conn.Close()
+	if p.cmd != nil {
+		p.cmd.Process.Kill()
+		p.cmd.Wait()
+	}
+	return err
+}
+
+// command sends a command to mpv and waits for its reply.
+func (p *MPVPlayer) command(command interface{}) (json.RawMessage, error) {
+	p.mu.Lock()
+	p.nextID++
+	id := p.nextID
+	reply := make(chan mpvResponse, 1)
+	p.pending[id] = reply
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		delete(p.pending, id)
+		p.mu.Unlock()
+	}()
+
+	line, err := json.Marshal(map[string]interface{}{"command": command, "request_id": id})
+	if err != nil {
+		return nil, err
+	}
+	p.writeMu.Lock()
+	_, err = p.conn.Write(append(line, '\n'))
+	p.writeMu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to send command to mpv: %w", err)
+	}
+
+	select {
+	case resp, ok := <-reply:
+		if !ok {
+			return nil, errors.New("mpv connection closed")
+		}
+		if resp.Error != "success" {
+			return nil, fmt.Errorf("mpv: %s", resp.Error)
+		}
+		return resp.Data, nil
+	case <-time.After(mpvCommandTimeout):
+		return nil, errors.New("mpv did not answer in time")
+	}
+}
+
+// readLoop dispatches replies to waiting commands and turns end-of-file events
+// into Ended notifications, until the connection closes.
+func (p *MPVPlayer) readLoop() {
+	scanner := bufio.NewScanner(p.conn)
+	for scanner.Scan() {
+		var resp mpvResponse
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			continue
+		}
+		if resp.Event != "" {
+			if resp.Event == "end-file" && resp.Reason == "eof" {
+				// Never block replies behind an unread notification.
+				select {
+				case p.ended <- struct{}{}:
+				default:
+				}
+			}
+			continue
+		}
+		p.mu.Lock()
+		if reply, ok := p.pending[resp.RequestID]; ok {
+			reply <- resp
+		}
+		p.mu.Unlock()
+	}
+
+	p.mu.Lock()
+	for id, reply := range p.pending {
+		close(reply)
+		delete(p.pending, id)
+	}
+	p.mu.Unlock()
+	close(p.ended)
+}
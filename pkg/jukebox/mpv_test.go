@@ -0,0 +1,97 @@
+package jukebox
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMPV answers IPC commands on conn the way mpv does and records them.
+func fakeMPV(t *testing.T, conn net.Conn, commands chan<- interface{}) {
+	t.Helper()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var req struct {
+			Command   interface{} `json:"command"`
+			RequestID int         `json:"request_id"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			t.Errorf("invalid command: %s", scanner.Text())
+			return
+		}
+		commands <- req.Command
+
+		reply := fmt.Sprintf(`{"error":"success","request_id":%d}`, req.RequestID)
+		if args, ok := req.Command.([]interface{}); ok && len(args) == 2 && args[1] == "time-pos" {
+			reply = fmt.Sprintf(`{"data":12.5,"error":"success","request_id":%d}`, req.RequestID)
+		}
+		fmt.Fprintln(conn, reply)
+	}
+}
+
+func TestMPVPlayer(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	commands := make(chan interface{}, 10)
+	go fakeMPV(t, server, commands)
+
+	p := newMPVPlayer(client)
+	defer p.Close()
+
+	require.NoError(t, p.Load("/music/a.mp3", 90*time.Second))
+	assert.Equal(t, map[string]interface{}{
+		"name":    "loadfile",
+		"url":     "/music/a.mp3",
+		"flags":   "replace",
+		"options": "start=90.000",
+	}, <-commands)
+	assert.Equal(t, []interface{}{"set_property", "pause", false}, <-commands)
+
+	require.NoError(t, p.SetGain(0.5))
+	assert.Equal(t, []interface{}{"set_property", "volume", 50.0}, <-commands)
+
+	position, err := p.Position()
+	require.NoError(t, err)
+	assert.Equal(t, 12500*time.Millisecond, position)
+	<-commands
+
+	// Only files that play to their end are reported.
+	fmt.Fprintln(server, `{"event":"end-file","reason":"stop"}`)
+	fmt.Fprintln(server, `{"event":"end-file","reason":"eof"}`)
+	select {
+	case <-p.Ended():
+	case <-time.After(time.Second):
+		t.Fatal("end of file was not reported")
+	}
+}
+
+func TestMPVPlayerPositionWhenIdle(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	go func() {
+		scanner := bufio.NewScanner(server)
+		for scanner.Scan() {
+			var req struct {
+				RequestID int `json:"request_id"`
+			}
+			json.Unmarshal(scanner.Bytes(), &req)
+			fmt.Fprintf(server, "{\"error\":\"property unavailable\",\"request_id\":%d}\n", req.RequestID)
+		}
+	}()
+
+	p := newMPVPlayer(client)
+	defer p.Close()
+
+	position, err := p.Position()
+	require.NoError(t, err)
+	assert.Zero(t, position)
+	assert.Error(t, p.Stop())
+}
@@ -0,0 +1,153 @@
+// Package lockout slows down password guessing. Failed logins are counted per
+// username and per client address in Postgres, so limits hold across restarts
+// and between replicas: each failure of a username doubles the time before it
+// may be tried again, and too many failures lock it for a while.
+package lockout
+
+import (
+	"database/sql"
+	"math"
+	"strings"
+	"time"
+)
+
+// Policy sets the thresholds. A zero threshold turns that limit off.
+type Policy struct {
+	MaxFailures      int           // Failures of one username before it is locked
+	MaxFailuresPerIP int           // Failures from one address before it is locked
+	BaseDelay        time.Duration // Wait after a username's first failure, doubled after each one
+	Lockout          time.Duration // How long a lock lasts and how long failures are remembered
+}
+
+// Limiter tracks failed logins under a Policy.
+type Limiter struct {
+	DB     *sql.DB
+	Policy Policy
+	// Scope keeps the failures of one kind of secret apart from the others,
+	// e.g. share passwords from logins; empty for logins
+	Scope string
+}
+
+// New creates a Limiter.
+func New(db *sql.DB, policy Policy) *Limiter {
+	return &Limiter{DB: db, Policy: policy}
+}
+
+// Enabled reports whether any limit is on.
+func (l *Limiter) Enabled() bool {
+	return l != nil && (l.Policy.MaxFailures > 0 || l.Policy.MaxFailuresPerIP > 0)
+}
+
+// Check returns how long a login for username from ip must wait, zero when it
+// may go ahead.
+func (l *Limiter) Check(username, ip string) (time.Duration, error) {
+	if !l.Enabled() {
+		return 0, nil
+	}
+	var seconds float64
+	err := l.DB.QueryRow(
+		"SELECT COALESCE(EXTRACT(EPOCH FROM MAX(locked_until) - NOW()), 0) FROM login_failures WHERE key IN ($1, $2) AND locked_until > NOW()",
+		l.userKey(username), l.ipKey(ip),
+	).Scan(&seconds)
+	if err != nil {
+		return 0, err
+	}
+	if seconds <= 0 {
+		return 0, nil
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// Fail records a failed login for username from ip.
+func (l *Limiter) Fail(username, ip string) error {
+	if !l.Enabled() {
+		return nil
+	}
+	if l.Policy.MaxFailures > 0 {
+		failures, err := l.record(l.userKey(username))
+		if err != nil {
+			return err
+		}
+		if err := l.lock(l.userKey(username), l.userDelay(failures)); err != nil {
+			return err
+		}
+	}
+	if l.Policy.MaxFailuresPerIP > 0 && ip != "" {
+		failures, err := l.record(l.ipKey(ip))
+		if err != nil {
+			return err
+		}
+		if failures >= l.Policy.MaxFailuresPerIP {
+			return l.lock(l.ipKey(ip), l.Policy.Lockout)
+		}
+	}
+	return nil
+}
+
+// Succeed clears the failures of username after a successful login. Failures
+// of the address are kept, so one known password does not reset its limit.
+func (l *Limiter) Succeed(username string) error {
+	if !l.Enabled() {
+		return nil
+	}
+	_, err := l.DB.Exec("DELETE FROM login_failures WHERE key = $1", l.userKey(username))
+	return err
+}
+
+// Unlock clears the failures and any lock of username.
+func (l *Limiter) Unlock(username string) error {
+	_, err := l.DB.Exec("DELETE FROM login_failures WHERE key = $1", l.userKey(username))
+	return err
+}
+
+// record counts a failure for key and returns the failures so far. Failures
+// older than the lockout period are forgotten.
+func (l *Limiter) record(key string) (int, error) {
+	var failures int
+	err := l.DB.QueryRow(`
+		INSERT INTO login_failures (key, failures, last_failure_at) VALUES ($1, 1, NOW())
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_failures.last_failure_at < NOW() - make_interval(secs => $2) THEN 1 ELSE login_failures.failures + 1 END,
+			last_failure_at = NOW()
+		RETURNING failures`,
+		key, l.Policy.Lockout.Seconds(),
+	).Scan(&failures)
+	return failures, err
+}
+
+// lock locks key for d.
+func (l *Limiter) lock(key string, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	_, err := l.DB.Exec("UPDATE login_failures SET locked_until = NOW() + make_interval(secs => $2) WHERE key = $1", key, d.Seconds())
+	return err
+}
+
+// userDelay returns the wait after a username's nth failure: the base delay
+// doubled for each earlier failure, and the full lockout once the limit is hit.
+func (l *Limiter) userDelay(failures int) time.Duration {
+	if failures >= l.Policy.MaxFailures {
+		return l.Policy.Lockout
+	}
+	delay := float64(l.Policy.BaseDelay) * math.Pow(2, float64(failures-1))
+	if delay > float64(l.Policy.Lockout) {
+		return l.Policy.Lockout
+	}
+	return time.Duration(delay)
+}
+
+func (l *Limiter) userKey(username string) string {
+	return l.scoped("user:" + strings.ToLower(strings.TrimSpace(username)))
+}
+
+func (l *Limiter) ipKey(ip string) string {
+	return l.scoped("ip:" + ip)
+}
+
+func (l *Limiter) scoped(key string) string {
+	if l.Scope == "" {
+		return key
+	}
+	return l.Scope + ":" + key
+}
@@ -0,0 +1,94 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testPolicy = Policy{MaxFailures: 5, MaxFailuresPerIP: 20, BaseDelay: time.Second, Lockout: 15 * time.Minute}
+
+func newTestLimiter(t *testing.T, policy Policy) (*Limiter, sqlmock.Sqlmock) {
+	t.Helper()
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return New(conn, policy), mock
+}
+
+func TestUserDelay(t *testing.T) {
+	l := &Limiter{Policy: testPolicy}
+	assert.Equal(t, time.Second, l.userDelay(1))
+	assert.Equal(t, 2*time.Second, l.userDelay(2))
+	assert.Equal(t, 8*time.Second, l.userDelay(4))
+	assert.Equal(t, 15*time.Minute, l.userDelay(5))
+	assert.Equal(t, 15*time.Minute, l.userDelay(9))
+}
+
+func TestCheck(t *testing.T) {
+	l, mock := newTestLimiter(t, testPolicy)
+	mock.ExpectQuery("FROM login_failures WHERE key IN").
+		WithArgs("user:alice", "ip:192.0.2.1").
+		WillReturnRows(sqlmock.NewRows([]string{"seconds"}).AddRow(4.5))
+
+	wait, err := l.Check("Alice", "192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, 4500*time.Millisecond, wait)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFailLocksAtThreshold(t *testing.T) {
+	l, mock := newTestLimiter(t, testPolicy)
+	mock.ExpectQuery("INSERT INTO login_failures").
+		WithArgs("user:alice", testPolicy.Lockout.Seconds()).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(5))
+	mock.ExpectExec("UPDATE login_failures SET locked_until").
+		WithArgs("user:alice", testPolicy.Lockout.Seconds()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO login_failures").
+		WithArgs("ip:192.0.2.1", testPolicy.Lockout.Seconds()).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(3))
+
+	require.NoError(t, l.Fail("alice", "192.0.2.1"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFailLocksAddress(t *testing.T) {
+	l, mock := newTestLimiter(t, Policy{MaxFailuresPerIP: 20, Lockout: time.Minute})
+	mock.ExpectQuery("INSERT INTO login_failures").
+		WithArgs("ip:192.0.2.1", 60.0).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(20))
+	mock.ExpectExec("UPDATE login_failures SET locked_until").
+		WithArgs("ip:192.0.2.1", 60.0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, l.Fail("alice", "192.0.2.1"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDisabledLimiter(t *testing.T) {
+	l, mock := newTestLimiter(t, Policy{})
+
+	wait, err := l.Check("alice", "192.0.2.1")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+	assert.NoError(t, l.Fail("alice", "192.0.2.1"))
+	assert.NoError(t, l.Succeed("alice"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScopeKeepsKeysApart(t *testing.T) {
+	l, mock := newTestLimiter(t, testPolicy)
+	l.Scope = "share"
+	mock.ExpectQuery("FROM login_failures WHERE key IN").
+		WithArgs("share:user:tok", "share:ip:192.0.2.1").
+		WillReturnRows(sqlmock.NewRows([]string{"seconds"}).AddRow(0))
+
+	wait, err := l.Check("tok", "192.0.2.1")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,194 @@
+package metadata
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go-postgres-example/pkg/models"
+)
+
+// cueSheet holds the fields of a cue sheet used to complete tags.
+type cueSheet struct {
+	Title     string
+	Performer string
+	Genre     string
+	Year      int
+	Tracks    []cueTrack
+}
+
+// cueTrack is a track of a cue sheet, in the audio file named File.
+type cueTrack struct {
+	File      string
+	Number    int
+	Title     string
+	Performer string
+}
+
+// parseCueSheet reads the album and track titles and performers of a cue sheet.
+func parseCueSheet(r io.Reader) (*cueSheet, error) {
+	sheet := &cueSheet{}
+	var file string
+	var track *cueTrack
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := cueFields(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if len(fields) < 2 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "FILE":
+			file = fields[1]
+			track = nil
+		case "TRACK":
+			number, _ := strconv.Atoi(fields[1])
+			sheet.Tracks = append(sheet.Tracks, cueTrack{File: file, Number: number})
+			track = &sheet.Tracks[len(sheet.Tracks)-1]
+		case "TITLE":
+			if track != nil {
+				track.Title = fields[1]
+			} else {
+				sheet.Title = fields[1]
+			}
+		case "PERFORMER":
+			if track != nil {
+				track.Performer = fields[1]
+			} else {
+				sheet.Performer = fields[1]
+			}
+		case "REM":
+			if len(fields) < 3 {
+				continue
+			}
+			switch strings.ToUpper(fields[1]) {
+			case "GENRE":
+				sheet.Genre = fields[2]
+			case "DATE":
+				if len(fields[2]) >= 4 {
+					sheet.Year, _ = strconv.Atoi(fields[2][:4])
+				}
+			}
+		}
+	}
+	return sheet, scanner.Err()
+}
+
+// cueFields splits a cue sheet line into words and double-quoted strings.
+func cueFields(line string) []string {
+	var fields []string
+	line = strings.TrimSpace(line)
+	for line != "" {
+		var field string
+		if line[0] == '"' {
+			end := strings.IndexByte(line[1:], '"')
+			if end < 0 {
+				field, line = line[1:], ""
+			} else {
+				field, line = line[1:end+1], line[end+2:]
+			}
+		} else if end := strings.IndexAny(line, " \t"); end >= 0 {
+			field, line = line[:end], line[end:]
+		} else {
+			field, line = line, ""
+		}
+		fields = append(fields, field)
+		line = strings.TrimSpace(line)
+	}
+	return fields
+}
+
+// applyCueSheet fills in the tags a song is missing from a cue sheet next to
+// its file that lists it. Track titles are only used when the file holds a
+// single track, as whole-album files are not split.
+func applyCueSheet(song *models.Song, filePath string) {
+	cueFiles, _ := filepath.Glob(filepath.Join(filepath.Dir(filePath), "*.[cC][uU][eE]"))
+	for _, cuePath := range cueFiles {
+		f, err := os.Open(cuePath)
+		if err != nil {
+			continue
+		}
+		sheet, err := parseCueSheet(f)
+		f.Close()
+		if err != nil {
+			continue
+		}
+
+		var tracks []cueTrack
+		for _, track := range sheet.Tracks {
+			// Cue sheets written on Windows separate folders with backslashes
+			if strings.EqualFold(path.Base(strings.ReplaceAll(track.File, `\`, "/")), filepath.Base(filePath)) {
+				tracks = append(tracks, track)
+			}
+		}
+		if len(tracks) == 0 {
+			continue
+		}
+
+		performer := sheet.Performer
+		if len(tracks) == 1 {
+			if song.Title == "" {
+				song.Title = tracks[0].Title
+			}
+			if tracks[0].Performer != "" {
+				performer = tracks[0].Performer
+			}
+		}
+		if song.Artist == "" {
+			song.Artist = performer
+		}
+		if song.Album == "" {
+			song.Album = sheet.Title
+		}
+		if song.Genre == "" {
+			song.Genre = sheet.Genre
+		}
+		if song.Year == 0 {
+			song.Year = sheet.Year
+		}
+		return
+	}
+}
+
+// coverImageNames are the names of album cover images, without extensions.
+var coverImageNames = []string{"cover", "folder", "front"}
+
+// IsCoverImage reports whether a file is an album cover image by its name,
+// such as cover.jpg or folder.png.
+func IsCoverImage(filePath string) bool {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
+		return false
+	}
+	name := strings.ToLower(strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath)))
+	for _, coverName := range coverImageNames {
+		if name == coverName {
+			return true
+		}
+	}
+	return false
+}
+
+// IsCueSheet reports whether a file is a cue sheet by its name.
+func IsCueSheet(filePath string) bool {
+	return strings.EqualFold(filepath.Ext(filePath), ".cue")
+}
+
+// findCoverImage returns the path of the cover image next to an audio file,
+// or "" if there is none.
+func findCoverImage(filePath string) string {
+	entries, err := os.ReadDir(filepath.Dir(filePath))
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && IsCoverImage(entry.Name()) {
+			return filepath.Join(filepath.Dir(filePath), entry.Name())
+		}
+	}
+	return ""
+}
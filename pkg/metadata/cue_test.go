@@ -0,0 +1,133 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-postgres-example/pkg/config"
+	"go-postgres-example/pkg/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCueSheet = "\ufeffREM GENRE \"Jazz\"\r\n" +
+	"REM DATE 1959\r\n" +
+	"PERFORMER \"Miles Davis\"\r\n" +
+	"TITLE \"Kind of Blue\"\r\n" +
+	"FILE \"01 So What.flac\" WAVE\r\n" +
+	"  TRACK 01 AUDIO\r\n" +
+	"    TITLE \"So What\"\r\n" +
+	"    INDEX 01 00:00:00\r\n" +
+	"FILE \"CD1\\02 Freddie Freeloader.flac\" WAVE\r\n" +
+	"  TRACK 02 AUDIO\r\n" +
+	"    TITLE \"Freddie Freeloader\"\r\n" +
+	"    PERFORMER \"Miles Davis Sextet\"\r\n"
+
+func TestParseCueSheet(t *testing.T) {
+	sheet, err := parseCueSheet(strings.NewReader(testCueSheet))
+
+	require.NoError(t, err)
+	assert.Equal(t, "Kind of Blue", sheet.Title)
+	assert.Equal(t, "Miles Davis", sheet.Performer)
+	assert.Equal(t, "Jazz", sheet.Genre)
+	assert.Equal(t, 1959, sheet.Year)
+	require.Len(t, sheet.Tracks, 2)
+	assert.Equal(t, cueTrack{File: "01 So What.flac", Number: 1, Title: "So What"}, sheet.Tracks[0])
+	assert.Equal(t, "Miles Davis Sextet", sheet.Tracks[1].Performer)
+}
+
+func TestApplyCueSheet(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "album.cue"), []byte(testCueSheet), 0644))
+
+	song := &models.Song{Title: "Tagged Title"}
+	applyCueSheet(song, filepath.Join(dir, "01 So What.flac"))
+	assert.Equal(t, "Tagged Title", song.Title)
+	assert.Equal(t, "Miles Davis", song.Artist)
+	assert.Equal(t, "Kind of Blue", song.Album)
+	assert.Equal(t, "Jazz", song.Genre)
+	assert.Equal(t, 1959, song.Year)
+
+	song = &models.Song{}
+	applyCueSheet(song, filepath.Join(dir, "02 Freddie Freeloader.flac"))
+	assert.Equal(t, "Freddie Freeloader", song.Title)
+	assert.Equal(t, "Miles Davis Sextet", song.Artist)
+
+	song = &models.Song{}
+	applyCueSheet(song, filepath.Join(dir, "bonus.flac"))
+	assert.Equal(t, &models.Song{}, song)
+}
+
+func TestCoverImages(t *testing.T) {
+	assert.True(t, IsCoverImage("Album/Cover.JPG"))
+	assert.True(t, IsCoverImage("folder.png"))
+	assert.False(t, IsCoverImage("back.jpg"))
+	assert.False(t, IsCoverImage("cover.txt"))
+
+	dir := t.TempDir()
+	assert.Equal(t, "", findCoverImage(filepath.Join(dir, "01.mp3")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "front.jpeg"), []byte("jpeg"), 0644))
+	assert.Equal(t, filepath.Join(dir, "front.jpeg"), findCoverImage(filepath.Join(dir, "01.mp3")))
+}
+
+func TestSaveAlbumCover(t *testing.T) {
+	// newCover returns a processor for a user and the song next to a cover
+	newCover := func(t *testing.T) (*Processor, sqlmock.Sqlmock, string) {
+		dir := t.TempDir()
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "album"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "album", "cover.jpg"), []byte("jpeg"), 0644))
+		mockDB, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		t.Cleanup(func() { mockDB.Close() })
+		p := &Processor{DB: mockDB, Cfg: &config.Config{UploadDir: filepath.Join(dir, "uploads")}, UserID: 2}
+		return p, mock, filepath.Join(dir, "album", "01.mp3")
+	}
+	uploaded := func(p *Processor) []os.DirEntry {
+		entries, _ := os.ReadDir(p.Cfg.UploadDir)
+		return entries
+	}
+
+	t.Run("uploader may not edit metadata", func(t *testing.T) {
+		p, mock, song := newCover(t)
+		mock.ExpectQuery("SELECT COALESCE\\(u.is_admin").WithArgs(2, models.PermissionEditMetadata).
+			WillReturnRows(sqlmock.NewRows([]string{"allowed"}).AddRow(false))
+
+		p.saveAlbumCover(7, song)
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Empty(t, uploaded(p))
+	})
+
+	t.Run("another upload set the cover first", func(t *testing.T) {
+		p, mock, song := newCover(t)
+		mock.ExpectQuery("SELECT COALESCE\\(u.is_admin").WithArgs(2, models.PermissionEditMetadata).
+			WillReturnRows(sqlmock.NewRows([]string{"allowed"}).AddRow(true))
+		mock.ExpectQuery("SELECT COALESCE\\(cover_path").WithArgs(7).
+			WillReturnRows(sqlmock.NewRows([]string{"cover_path"}).AddRow(""))
+		mock.ExpectExec("UPDATE albums SET cover_path").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT EXISTS").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		p.saveAlbumCover(7, song)
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Empty(t, uploaded(p))
+	})
+
+	t.Run("cover set", func(t *testing.T) {
+		p, mock, song := newCover(t)
+		mock.ExpectQuery("SELECT COALESCE\\(u.is_admin").WithArgs(2, models.PermissionEditMetadata).
+			WillReturnRows(sqlmock.NewRows([]string{"allowed"}).AddRow(true))
+		mock.ExpectQuery("SELECT COALESCE\\(cover_path").WithArgs(7).
+			WillReturnRows(sqlmock.NewRows([]string{"cover_path"}).AddRow(""))
+		mock.ExpectExec("UPDATE albums SET cover_path").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		p.saveAlbumCover(7, song)
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Len(t, uploaded(p), 1)
+	})
+}
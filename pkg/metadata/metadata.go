@@ -32,6 +32,8 @@ type Processor struct {
 	DB         *sql.DB
 	Cfg        *config.Config
 	MBClient   musicbrainz.Clienter
+	// UserID, when set, is recorded as an owner of the songs processed
+	UserID int
 }
 
 // NewProcessor creates a new Processor.
@@ -75,12 +77,25 @@ func (p *Processor) getGenre(song *models.Song, filePath string) (string, error)
 }
 
 func (p *Processor) ProcessFile(filePath string) error {
+	if p.UserID != 0 {
+		unlock, err := db.LockUploads(p.DB, p.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to lock uploads of user %d: %w", p.UserID, err)
+		}
+		defer unlock()
+	}
+
 	// 1. Generate file hash
 	hash, err := generateFileHash(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to generate hash for %s: %w", filePath, err)
 	}
 
+	// 1b. Check the uploader's quota again, as other uploads may have used it up
+	if err := p.checkQuota(filePath, hash); err != nil {
+		return err
+	}
+
 	// 2. Check for duplicates
 	exists, err := p.songExists(hash)
 	if err != nil {
@@ -88,7 +103,7 @@ func (p *Processor) ProcessFile(filePath string) error {
 	}
 	if exists {
 		log.Printf("Song with hash %s already exists. Skipping.", hash)
-		return nil
+		return p.addOwner(hash)
 	}
 
 	// 3. Extract metadata from file
@@ -97,6 +112,7 @@ func (p *Processor) ProcessFile(filePath string) error {
 		return fmt.Errorf("failed to extract metadata from %s: %w", filePath, err)
 	}
 	song.FingerprintHash = hash
+	applyCueSheet(song, filePath)
 
 	// 4. Move file to permanent storage location
 	permanentPath, err := p.moveToUploadDir(filePath, hash)
@@ -126,12 +142,32 @@ func (p *Processor) ProcessFile(filePath string) error {
 		song.GenreID = sql.NullInt64{Int64: int64(genreID), Valid: true}
 	}
 
+	// 7b. Find or create the artist and album entities
+	if song.Artist != "" {
+		artistID, err := p.findOrCreateArtist(song.Artist)
+		if err != nil {
+			return fmt.Errorf("failed to find or create artist: %w", err)
+		}
+		song.ArtistID = sql.NullInt64{Int64: int64(artistID), Valid: true}
+	}
+	if song.Album != "" {
+		albumID, err := p.findOrCreateAlbum(song)
+		if err != nil {
+			return fmt.Errorf("failed to find or create album: %w", err)
+		}
+		song.AlbumID = sql.NullInt64{Int64: int64(albumID), Valid: true}
+		p.saveAlbumCover(albumID, filePath)
+	}
+
 	// 8. Save song to database
 	songID, err := p.saveSong(song)
 	if err != nil {
 		return fmt.Errorf("failed to save song %s: %w", song.Title, err)
 	}
 	song.ID = songID
+	if err := p.addOwner(hash); err != nil {
+		return err
+	}
 
 	// 9. Get and save song embedding (using the original temp file path)
 	embedding, err := p.getEmbeddingsFromService(filePath)
@@ -150,6 +186,82 @@ func (p *Processor) ProcessFile(filePath string) error {
 	return nil
 }
 
+// saveAlbumCover keeps the cover image next to an audio file as the cover of
+// its album, unless the album has one. Albums are shared by all users, so only
+// uploaders who may edit metadata set covers. Failures are only logged, as
+// covers are an enhancement.
+func (p *Processor) saveAlbumCover(albumID int, filePath string) {
+	imagePath := findCoverImage(filePath)
+	if imagePath == "" {
+		return
+	}
+	if p.UserID != 0 {
+		allowed, err := db.HasPermission(p.DB, p.UserID, models.PermissionEditMetadata)
+		if err != nil {
+			log.Printf("Failed to check permissions of user %d: %v", p.UserID, err)
+			return
+		}
+		if !allowed {
+			return
+		}
+	}
+	if coverPath, err := db.GetAlbumCover(p.DB, albumID); err != nil || coverPath != "" {
+		return
+	}
+	hash, err := generateFileHash(imagePath)
+	if err != nil {
+		log.Printf("Failed to hash cover %s: %v", imagePath, err)
+		return
+	}
+	coverPath, err := p.moveToUploadDir(imagePath, hash)
+	if err != nil {
+		log.Printf("Failed to save cover %s: %v", imagePath, err)
+		return
+	}
+	set, err := db.SetAlbumCover(p.DB, albumID, coverPath)
+	if err != nil {
+		log.Printf("Failed to set cover of album %d: %v", albumID, err)
+	}
+	if set {
+		return
+	}
+	// Another upload set the album's cover first; the same image may still be
+	// another album's cover
+	if inUse, err := db.AlbumCoverInUse(p.DB, coverPath); err != nil || inUse {
+		return
+	}
+	if err := os.Remove(coverPath); err != nil {
+		log.Printf("Failed to remove unused cover %s: %v", coverPath, err)
+	}
+}
+
+// checkQuota checks that the processor's user may add the file with the given
+// hash without going over a quota.
+func (p *Processor) checkQuota(filePath, hash string) error {
+	if p.UserID == 0 {
+		return nil
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	if err := db.CheckQuota(p.DB, p.UserID, hash, info.Size(), p.Cfg.DefaultQuotaBytes, p.Cfg.DefaultQuotaTracks); err != nil {
+		return fmt.Errorf("not adding %s for user %d: %w", filePath, p.UserID, err)
+	}
+	return nil
+}
+
+// addOwner records the processor's user as an owner of the song with the given hash.
+func (p *Processor) addOwner(hash string) error {
+	if p.UserID == 0 {
+		return nil
+	}
+	if err := db.AddSongUpload(p.DB, p.UserID, hash); err != nil {
+		return fmt.Errorf("failed to record upload of song %s: %w", hash, err)
+	}
+	return nil
+}
+
 // getEmbeddingsFromService calls the audio processor microservice to generate embeddings.
 func (p *Processor) getEmbeddingsFromService(filePath string) ([]float64, error) {
 	// Open the audio file
@@ -320,12 +432,39 @@ func (p *Processor) findOrCreateGenre(name string) (int, error) {
 	return genreID, nil
 }
 
+func (p *Processor) findOrCreateArtist(name string) (int, error) {
+	var artistID int
+	err := p.DB.QueryRow(`
+		INSERT INTO artists (name) VALUES ($1)
+		ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id
+	`, name).Scan(&artistID)
+	return artistID, err
+}
+
+// findOrCreateAlbum finds the album of a song by name and artist, creating it if needed.
+func (p *Processor) findOrCreateAlbum(song *models.Song) (int, error) {
+	var year sql.NullInt64
+	if song.Year > 0 {
+		year = sql.NullInt64{Int64: int64(song.Year), Valid: true}
+	}
+	var albumID int
+	err := p.DB.QueryRow(`
+		INSERT INTO albums (name, artist, artist_id, year) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (name, artist) DO UPDATE SET
+			artist_id = COALESCE(albums.artist_id, EXCLUDED.artist_id),
+			year = COALESCE(albums.year, EXCLUDED.year)
+		RETURNING id
+	`, song.Album, song.Artist, song.ArtistID, year).Scan(&albumID)
+	return albumID, err
+}
+
 func (p *Processor) saveSong(song *models.Song) (int, error) {
 	query := `
 		INSERT INTO songs (
 			fingerprint_hash, file_path, title, artist, album, year, genre_id,
-			duration, bitrate, file_size, last_modified
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			duration, bitrate, file_size, last_modified, artist_id, album_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 		RETURNING id
 	`
 	var songID int
@@ -342,6 +481,8 @@ func (p *Processor) saveSong(song *models.Song) (int, error) {
 		song.Bitrate,
 		song.FileSize,
 		song.LastModified,
+		song.ArtistID,
+		song.AlbumID,
 	).Scan(&songID)
 
 	if err != nil {
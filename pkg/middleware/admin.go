@@ -14,12 +14,16 @@ func AdminOnly(db *sql.DB) func(next http.Handler) http.Handler {
 				http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
 				return
 			}
-			var isAdmin bool
-			err := db.QueryRow("SELECT COALESCE(is_admin, FALSE) FROM users WHERE id = $1", userID).Scan(&isAdmin)
+			var isAdmin, disabled bool
+			err := db.QueryRow("SELECT COALESCE(is_admin, FALSE), disabled FROM users WHERE id = $1", userID).Scan(&isAdmin, &disabled)
 			if err != nil {
 				http.Error(w, "Failed to verify admin", http.StatusForbidden)
 				return
 			}
+			if disabled {
+				http.Error(w, "Account is disabled", http.StatusForbidden)
+				return
+			}
 			if !isAdmin {
 				http.Error(w, "Admin access required", http.StatusForbidden)
 				return
@@ -28,3 +32,29 @@ func AdminOnly(db *sql.DB) func(next http.Handler) http.Handler {
 		})
 	}
 }
+
+// RequireTwoFactor refuses users who have not turned on two-factor
+// authentication. It guards the admin routes when REQUIRE_ADMIN_2FA is set;
+// admins can still reach their own account settings to set it up.
+func RequireTwoFactor(db *sql.DB) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := GetUserIDFromContext(r.Context())
+			if !ok {
+				http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+				return
+			}
+			var enabled bool
+			err := db.QueryRow("SELECT totp_enabled FROM users WHERE id = $1", userID).Scan(&enabled)
+			if err != nil {
+				http.Error(w, "Failed to verify two-factor authentication", http.StatusForbidden)
+				return
+			}
+			if !enabled {
+				http.Error(w, "Two-factor authentication is required for admins", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"database/sql"
 	"go-postgres-example/pkg/auth"
 	"net/http"
 	"strings"
@@ -10,11 +11,20 @@ import (
 type contextKey string
 
 const userContextKey = contextKey("userID")
+const sessionContextKey = contextKey("sessionID")
 
-// Authenticator is a middleware to protect routes
-func Authenticator(jwtSecret string) func(next http.Handler) http.Handler {
+// Authenticator is a middleware to protect routes. Only tokens issued for a
+// login session are accepted, until the session is revoked or expires, or the
+// account is disabled; tokens from before sessions existed are refused.
+// Requests already signed in by ProxyAuthenticator are let through.
+func Authenticator(jwtSecret string, db *sql.DB) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if IsProxyAuthenticated(r.Context()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
 				http.Error(w, "Authorization header is required", http.StatusUnauthorized)
@@ -33,12 +43,32 @@ func Authenticator(jwtSecret string) func(next http.Handler) http.Handler {
 				return
 			}
 
-			if claims, ok := token.Claims.(*auth.Claims); ok && token.Valid {
-				ctx := context.WithValue(r.Context(), userContextKey, claims.UserID)
-				next.ServeHTTP(w, r.WithContext(ctx))
-			} else {
+			claims, ok := token.Claims.(*auth.Claims)
+			if !ok || !token.Valid || claims.SessionID == 0 {
 				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				return
 			}
+
+			var active bool
+			err = db.QueryRow(
+				`SELECT EXISTS (
+					SELECT 1 FROM sessions s JOIN users u ON u.id = s.user_id
+					WHERE s.id = $1 AND s.user_id = $2 AND s.revoked_at IS NULL AND s.expires_at > NOW() AND NOT u.disabled
+				)`,
+				claims.SessionID, claims.UserID,
+			).Scan(&active)
+			if err != nil {
+				http.Error(w, "Failed to verify session", http.StatusInternalServerError)
+				return
+			}
+			if !active {
+				http.Error(w, "Session has been revoked", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userContextKey, claims.UserID)
+			ctx = context.WithValue(ctx, sessionContextKey, claims.SessionID)
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
@@ -48,3 +78,10 @@ func GetUserIDFromContext(ctx context.Context) (int, bool) {
 	userID, ok := ctx.Value(userContextKey).(int)
 	return userID, ok
 }
+
+// GetSessionIDFromContext retrieves the login session ID from the request
+// context; it is 0 for requests signed in by ProxyAuthenticator
+func GetSessionIDFromContext(ctx context.Context) (int, bool) {
+	sessionID, ok := ctx.Value(sessionContextKey).(int)
+	return sessionID, ok
+}
@@ -6,7 +6,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,6 +16,12 @@ func TestAuthenticator(t *testing.T) {
 	jwtSecret := "test-secret"
 	userID := 123
 
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer conn.Close()
+
 	// Create a test handler that will be protected
 	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Verify user ID is in context
@@ -25,11 +33,14 @@ func TestAuthenticator(t *testing.T) {
 	})
 
 	// Wrap with authenticator middleware
-	protectedHandler := Authenticator(jwtSecret)(testHandler)
+	protectedHandler := Authenticator(jwtSecret, conn)(testHandler)
 
 	t.Run("valid token", func(t *testing.T) {
-		token, err := auth.GenerateJWT(userID, jwtSecret)
+		token, err := auth.GenerateAccessToken(userID, 7, jwtSecret, time.Minute)
 		assert.NoError(t, err)
+		mock.ExpectQuery("FROM sessions").
+			WithArgs(7, userID).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
 
 		req := httptest.NewRequest("GET", "/protected", nil)
 		req.Header.Set("Authorization", "Bearer "+token)
@@ -52,7 +63,7 @@ func TestAuthenticator(t *testing.T) {
 	})
 
 	t.Run("missing Bearer prefix", func(t *testing.T) {
-		token, err := auth.GenerateJWT(userID, jwtSecret)
+		token, err := auth.GenerateAccessToken(userID, 7, jwtSecret, time.Minute)
 		assert.NoError(t, err)
 
 		req := httptest.NewRequest("GET", "/protected", nil)
@@ -77,7 +88,20 @@ func TestAuthenticator(t *testing.T) {
 	})
 
 	t.Run("token with wrong secret", func(t *testing.T) {
-		token, err := auth.GenerateJWT(userID, "wrong-secret")
+		token, err := auth.GenerateAccessToken(userID, 7, "wrong-secret", time.Minute)
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+
+		protectedHandler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("token not tied to a session", func(t *testing.T) {
+		token, err := auth.GenerateAccessToken(userID, 0, jwtSecret, time.Minute)
 		assert.NoError(t, err)
 
 		req := httptest.NewRequest("GET", "/protected", nil)
@@ -87,7 +111,45 @@ func TestAuthenticator(t *testing.T) {
 		protectedHandler.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Contains(t, rr.Body.String(), "Invalid token")
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthenticatorRejectsRevokedSession(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer conn.Close()
+
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sessionID, ok := GetSessionIDFromContext(r.Context())
+		assert.True(t, ok)
+		assert.Equal(t, 7, sessionID)
+		w.WriteHeader(http.StatusOK)
 	})
+	protectedHandler := Authenticator("test-secret", conn)(testHandler)
+
+	token, err := auth.GenerateAccessToken(123, 7, "test-secret", time.Minute)
+	assert.NoError(t, err)
+
+	mock.ExpectQuery("FROM sessions").
+		WithArgs(7, 123).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("FROM sessions").
+		WithArgs(7, 123).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	for _, want := range []int{http.StatusOK, http.StatusUnauthorized} {
+		req := httptest.NewRequest("GET", "/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		protectedHandler.ServeHTTP(rr, req)
+		assert.Equal(t, want, rr.Code)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestGetUserIDFromContext(t *testing.T) {
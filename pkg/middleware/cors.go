@@ -7,11 +7,17 @@ func CORS() func(next http.Handler) http.Handler {
     return func(next http.Handler) http.Handler {
         return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
             w.Header().Set("Access-Control-Allow-Origin", "*")
-            w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-            w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+            w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
+            w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-Match, "+
+                "Tus-Resumable, Upload-Length, Upload-Metadata, Upload-Offset, Upload-Checksum, X-HTTP-Method-Override")
+            w.Header().Set("Access-Control-Expose-Headers", "ETag, Location, "+
+                "Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size, Tus-Checksum-Algorithm, "+
+                "Upload-Offset, Upload-Length, Upload-Metadata, Upload-Expires, Upload-Status, Upload-Error")
             w.Header().Set("Access-Control-Max-Age", "86400")
 
-            if r.Method == http.MethodOptions {
+            // Answer preflight requests; other OPTIONS requests, such as tus
+            // discovery, reach the routes
+            if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
                 w.WriteHeader(http.StatusNoContent)
                 return
             }
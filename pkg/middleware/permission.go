@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"database/sql"
+	"net/http"
+
+	"go-postgres-example/pkg/db"
+)
+
+// RequirePermission ensures the authenticated user's role grants a permission,
+// one of the models.Permission* constants.
+func RequirePermission(conn *sql.DB, permission string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := GetUserIDFromContext(r.Context())
+			if !ok {
+				http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+				return
+			}
+			allowed, err := db.HasPermission(conn, userID, permission)
+			if err != nil {
+				http.Error(w, "Failed to verify permissions", http.StatusForbidden)
+				return
+			}
+			if !allowed {
+				http.Error(w, "Permission required: "+permission, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequirePermission(t *testing.T) {
+	for _, allowed := range []bool{true, false} {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock: %v", err)
+		}
+
+		mock.ExpectQuery("FROM role_permissions").
+			WithArgs(3, "upload").
+			WillReturnRows(sqlmock.NewRows([]string{"allowed"}).AddRow(allowed))
+
+		handler := RequirePermission(db, "upload")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		req := httptest.NewRequest("POST", "/api/upload", nil)
+		req = req.WithContext(context.WithValue(req.Context(), userContextKey, 3))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if allowed {
+			assert.Equal(t, http.StatusNoContent, rr.Code)
+		} else {
+			assert.Equal(t, http.StatusForbidden, rr.Code)
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+		db.Close()
+	}
+}
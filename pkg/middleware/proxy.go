@@ -0,0 +1,212 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go-postgres-example/pkg/db"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const proxyAuthContextKey = contextKey("proxyAuth")
+
+// HeaderAuth configures authentication by a reverse proxy such as Authelia or
+// oauth2-proxy, which signs users in and passes their username in a header.
+type HeaderAuth struct {
+	Header         string       // Header holding the username, such as Remote-User
+	EmailHeader    string       // Optional header holding the user's email
+	TrustedProxies []*net.IPNet // Only requests from these addresses may use the header
+	AutoCreate     bool         // Create unknown users
+}
+
+// ParseCIDRs parses a list of CIDRs; plain IP addresses are taken as single hosts.
+func ParseCIDRs(values []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", value)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", value)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// ProxyAuthenticator signs requests in by the username in the configured
+// header. It runs before Authenticator, which lets requests signed in this way
+// through and checks the bearer token of the others. The header is refused
+// with 401 Unauthorized unless the request comes straight from a trusted proxy,
+// so clients cannot sign in as anyone by sending it themselves. Since browsers
+// send the proxy's session cookie with any request, requests that change state
+// are refused with 403 Forbidden unless they come from the same origin or carry
+// the X-Requested-With header, which cross-site forms cannot send.
+func ProxyAuthenticator(conn *sql.DB, cfg HeaderAuth) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg.Header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			username := strings.TrimSpace(r.Header.Get(cfg.Header))
+			if username == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !trustedProxy(r, cfg.TrustedProxies) {
+				log.Printf("Refused %s header from untrusted address %s", cfg.Header, r.RemoteAddr)
+				http.Error(w, "Authentication header is not accepted from this address", http.StatusUnauthorized)
+				return
+			}
+			if !safeMethod(r.Method) && !sameOrigin(r) {
+				log.Printf("Refused cross-site %s %s for proxy user %q", r.Method, r.URL.Path, username)
+				http.Error(w, "Cross-site request refused", http.StatusForbidden)
+				return
+			}
+
+			userID, disabled, err := db.GetUserStatusByUsername(conn, username)
+			if err == sql.ErrNoRows && cfg.AutoCreate {
+				email := ""
+				if cfg.EmailHeader != "" {
+					email = strings.TrimSpace(r.Header.Get(cfg.EmailHeader))
+				}
+				if email == "" {
+					email = username + "@local"
+				}
+				userID, err = db.CreatePasswordlessUser(conn, username, email)
+			}
+			if err != nil {
+				if err == sql.ErrNoRows {
+					http.Error(w, "No account exists for this user", http.StatusForbidden)
+				} else {
+					http.Error(w, "Failed to get user", http.StatusInternalServerError)
+				}
+				return
+			}
+			if disabled {
+				http.Error(w, "Account is disabled", http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userContextKey, userID)
+			ctx = context.WithValue(ctx, proxyAuthContextKey, true)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// IsProxyAuthenticated reports whether the request was signed in by the reverse proxy header
+func IsProxyAuthenticated(ctx context.Context) bool {
+	proxied, _ := ctx.Value(proxyAuthContextKey).(bool)
+	return proxied
+}
+
+// safeMethod reports whether requests with method must not change state.
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// sameOrigin reports whether a browser sent the request from a page of this
+// server, by its Sec-Fetch-Site or Origin header, or whether it carries
+// X-Requested-With. That header is not allowed cross-origin without a CORS
+// preflight, which credentialed requests cannot pass here.
+func sameOrigin(r *http.Request) bool {
+	if r.Header.Get("X-Requested-With") != "" {
+		return true
+	}
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site == "same-origin"
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	host := r.Header.Get("X-Forwarded-Host")
+	if host == "" {
+		host = r.Host
+	}
+	return strings.EqualFold(u.Host, host)
+}
+
+// ClientIP returns the address of the client that made the request. When the
+// request comes from a trusted proxy, it is the nearest untrusted address the
+// proxies added to X-Forwarded-For.
+func ClientIP(r *http.Request, trusted []*net.IPNet) string {
+	host := remoteHost(r)
+	if !isTrusted(host, trusted) {
+		return host
+	}
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(addr) == nil {
+			break
+		}
+		host = addr
+		if !isTrusted(addr, trusted) {
+			break
+		}
+	}
+	return host
+}
+
+// trustedProxy reports whether the request comes straight from a trusted proxy.
+func trustedProxy(r *http.Request, trusted []*net.IPNet) bool {
+	return isTrusted(remoteHost(r), trusted)
+}
+
+// remoteHost returns the address of the peer that sent the request.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// FromTrustedProxy reports whether a request was sent by one of the trusted
+// proxies, so that the X-Forwarded-* headers it set can be believed.
+func FromTrustedProxy(r *http.Request, trusted []*net.IPNet) bool {
+	return isTrusted(remoteHost(r), trusted)
+}
+
+// isTrusted reports whether an address is within the trusted CIDRs.
+func isTrusted(addr string, trusted []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
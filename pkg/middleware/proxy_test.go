@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newProxyTestHandler(t *testing.T, autoCreate bool) (http.Handler, sqlmock.Sqlmock) {
+	t.Helper()
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8", "192.0.2.7"})
+	require.NoError(t, err)
+	cfg := HeaderAuth{Header: "Remote-User", EmailHeader: "Remote-Email", TrustedProxies: trusted, AutoCreate: autoCreate}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := GetUserIDFromContext(r.Context())
+		assert.True(t, IsProxyAuthenticated(r.Context()))
+		assert.Equal(t, 5, userID)
+		w.WriteHeader(http.StatusOK)
+	})
+	return ProxyAuthenticator(conn, cfg)(Authenticator("test-secret", conn)(handler)), mock
+}
+
+func TestProxyAuthenticatorTrustedProxy(t *testing.T) {
+	handler, mock := newProxyTestHandler(t, false)
+
+	for _, addr := range []string{"10.1.2.3:5000", "192.0.2.7:443"} {
+		mock.ExpectQuery("SELECT id, disabled FROM users WHERE username").
+			WithArgs("alice").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "disabled"}).AddRow(5, false))
+		req := httptest.NewRequest("GET", "/protected", nil)
+		req.RemoteAddr = addr
+		req.Header.Set("Remote-User", "alice")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code, addr)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProxyAuthenticatorRefusesUntrustedAddress(t *testing.T) {
+	handler, mock := newProxyTestHandler(t, true)
+
+	for _, addr := range []string{"203.0.113.9:5000", "192.0.2.8:443"} {
+		req := httptest.NewRequest("GET", "/protected", nil)
+		req.RemoteAddr = addr
+		req.Header.Set("Remote-User", "admin")
+		// Forwarding headers are set by the client here and must not be trusted.
+		req.Header.Set("X-Forwarded-For", "10.0.0.1")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, addr)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProxyAuthenticatorAutoCreate(t *testing.T) {
+	handler, mock := newProxyTestHandler(t, true)
+
+	mock.ExpectQuery("SELECT id, disabled FROM users WHERE username").
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "disabled"}))
+	mock.ExpectQuery("INSERT INTO users").
+		WithArgs("alice", "alice@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+
+	req := httptest.NewRequest("GET", "/protected", nil)
+	req.RemoteAddr = "10.1.2.3:5000"
+	req.Header.Set("Remote-User", "alice")
+	req.Header.Set("Remote-Email", "alice@example.com")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProxyAuthenticatorDisabledUser(t *testing.T) {
+	handler, mock := newProxyTestHandler(t, false)
+
+	mock.ExpectQuery("SELECT id, disabled FROM users WHERE username").
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "disabled"}).AddRow(5, true))
+
+	req := httptest.NewRequest("GET", "/protected", nil)
+	req.RemoteAddr = "10.1.2.3:5000"
+	req.Header.Set("Remote-User", "alice")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProxyAuthenticatorUnknownUser(t *testing.T) {
+	handler, mock := newProxyTestHandler(t, false)
+
+	mock.ExpectQuery("SELECT id, disabled FROM users WHERE username").
+		WithArgs("mallory").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "disabled"}))
+
+	req := httptest.NewRequest("GET", "/protected", nil)
+	req.RemoteAddr = "10.1.2.3:5000"
+	req.Header.Set("Remote-User", "mallory")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProxyAuthenticatorCrossSiteRequests(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		want    int
+	}{
+		{"safe method", "GET", map[string]string{"Sec-Fetch-Site": "cross-site"}, http.StatusOK},
+		{"same-origin fetch", "POST", map[string]string{"Sec-Fetch-Site": "same-origin"}, http.StatusOK},
+		{"cross-site fetch", "POST", map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "https://music.example.com"}, http.StatusForbidden},
+		{"same origin", "DELETE", map[string]string{"Origin": "https://music.example.com"}, http.StatusOK},
+		{"other origin", "PUT", map[string]string{"Origin": "https://evil.example.net"}, http.StatusForbidden},
+		{"no origin", "POST", nil, http.StatusForbidden},
+		{"custom header", "POST", map[string]string{"X-Requested-With": "XMLHttpRequest"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, mock := newProxyTestHandler(t, false)
+			if tt.want == http.StatusOK {
+				mock.ExpectQuery("SELECT id, disabled FROM users WHERE username").
+					WithArgs("alice").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "disabled"}).AddRow(5, false))
+			}
+
+			req := httptest.NewRequest(tt.method, "https://music.example.com/protected", nil)
+			req.RemoteAddr = "10.1.2.3:5000"
+			req.Header.Set("Remote-User", "alice")
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.want, rr.Code)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestProxyAuthenticatorFallsBackToToken(t *testing.T) {
+	handler, mock := newProxyTestHandler(t, false)
+
+	req := httptest.NewRequest("GET", "/protected", nil)
+	req.RemoteAddr = "10.1.2.3:5000"
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Authorization header is required")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestParseCIDRs(t *testing.T) {
+	nets, err := ParseCIDRs([]string{"10.0.0.0/8", " 127.0.0.1 ", "", "::1"})
+	require.NoError(t, err)
+	require.Len(t, nets, 3)
+	assert.Equal(t, "127.0.0.1/32", nets[1].String())
+	assert.Equal(t, "::1/128", nets[2].String())
+
+	_, err = ParseCIDRs([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "198.51.100.4:5000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9")
+	assert.Equal(t, "198.51.100.4", ClientIP(req, trusted), "untrusted peers cannot set the address")
+
+	req.RemoteAddr = "10.0.0.2:5000"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 203.0.113.9, 10.0.0.3")
+	assert.Equal(t, "203.0.113.9", ClientIP(req, trusted), "nearest untrusted address")
+
+	req.Header.Del("X-Forwarded-For")
+	assert.Equal(t, "10.0.0.2", ClientIP(req, trusted))
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Album list types, as used by the Subsonic getAlbumList2 endpoint.
+const (
+	AlbumListRandom               = "random"
+	AlbumListNewest               = "newest"
+	AlbumListHighest              = "highest"
+	AlbumListFrequent             = "frequent"
+	AlbumListRecent               = "recent"
+	AlbumListAlphabeticalByName   = "alphabeticalByName"
+	AlbumListAlphabeticalByArtist = "alphabeticalByArtist"
+	AlbumListStarred              = "starred"
+	AlbumListByYear               = "byYear"
+	AlbumListByGenre              = "byGenre"
+)
+
+// AlbumListOptions selects and pages an album list. FromYear and ToYear are
+// required for byYear lists (ToYear before FromYear lists in reverse order),
+// and Genre for byGenre lists.
+type AlbumListOptions struct {
+	Type     string
+	Size     int
+	Offset   int
+	FromYear int
+	ToYear   int
+	Genre    string
+}
+
+// AlbumSummary is an album with the song totals and per-user statistics shown in album lists
+type AlbumSummary struct {
+	Album
+	Genre      string     `json:"genre,omitempty"`
+	SongCount  int        `json:"song_count"`
+	Duration   int        `json:"duration"`
+	CreatedAt  time.Time  `json:"created_at"`
+	PlayCount  int        `json:"play_count"`
+	LastPlayed *time.Time `json:"last_played,omitempty"`
+	Rating     float64    `json:"rating,omitempty"` // Average of the user's ratings of the album's songs
+	StarredAt  *time.Time `json:"starred_at,omitempty"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// FavoriteSong is a song starred by a user
+type FavoriteSong struct {
+	Song
+	StarredAt time.Time `json:"starred_at"`
+}
+
+// FavoriteAlbum is an album starred by a user
+type FavoriteAlbum struct {
+	Album
+	StarredAt time.Time `json:"starred_at"`
+}
+
+// FavoriteArtist is an artist starred by a user
+type FavoriteArtist struct {
+	Artist
+	StarredAt time.Time `json:"starred_at"`
+}
+
+// Favorites holds everything a user has starred, most recently starred first
+type Favorites struct {
+	Songs   []FavoriteSong   `json:"songs"`
+	Albums  []FavoriteAlbum  `json:"albums"`
+	Artists []FavoriteArtist `json:"artists"`
+}
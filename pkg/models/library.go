@@ -6,6 +6,7 @@ import (
 )
 
 // LibrarySong represents a song in the user's library, including the user's rating
+// and how often and when they last played it
 type LibrarySong struct {
 	ID              int           `json:"id"`
 	FingerprintHash string        `json:"fingerprint_hash"`
@@ -21,4 +22,6 @@ type LibrarySong struct {
 	FileSize        int64         `json:"file_size"`
 	LastModified    time.Time     `json:"last_modified"`
 	Rating          sql.NullInt64 `json:"rating"`
+	PlayCount       int           `json:"play_count"`
+	LastPlayed      *time.Time    `json:"last_played"`
 }
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Play is a single listen of a song by a user
+type Play struct {
+	ID               int       `json:"id"`
+	Song             Song      `json:"song"`
+	PlayedAt         time.Time `json:"played_at"`
+	DurationListened int       `json:"duration_listened,omitempty"` // Seconds, 0 if unknown
+}
+
+// PlayCount is a song, artist or genre with the number of times it was played
+type PlayCount struct {
+	Name      string `json:"name"`
+	PlayCount int    `json:"play_count"`
+}
+
+// SongPlayCount is a song with the number of times it was played
+type SongPlayCount struct {
+	Song
+	PlayCount int `json:"play_count"`
+}
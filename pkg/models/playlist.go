@@ -4,29 +4,141 @@ import (
 	"time"
 )
 
+// Playlist visibilities: private playlists are only visible to their owner and
+// collaborators, public ones to every user of the server.
+const (
+	PlaylistPrivate = "private"
+	PlaylistPublic  = "public"
+)
+
+// Roles a user can have on a playlist. Viewers may read it; editors may also
+// rename it and change its songs or rules; only the owner may delete it, change
+// its visibility or manage collaborators.
+const (
+	PlaylistOwner  = "owner"
+	PlaylistEditor = "editor"
+	PlaylistViewer = "viewer"
+)
+
 // Playlist represents a playlist in the database
 type Playlist struct {
+	ID         int         `json:"id"`
+	UserID     int         `json:"user_id"`
+	Owner      string      `json:"owner"` // Username of the owner
+	Name       string      `json:"name"`
+	Smart      bool        `json:"smart"`
+	ReadOnly   bool        `json:"read_only"` // Songs cannot be added or removed directly
+	Rules      *SmartRules `json:"rules,omitempty"`
+	Version    int         `json:"version"` // Incremented on every change, exposed as the ETag
+	Visibility string      `json:"visibility"`
+	Role       string      `json:"role"` // The requesting user's role on the playlist
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+// CanEdit reports whether the requesting user may rename the playlist and change its songs or rules.
+func (p *Playlist) CanEdit() bool {
+	return p.Role == PlaylistOwner || p.Role == PlaylistEditor
+}
+
+// IsOwner reports whether the requesting user owns the playlist.
+func (p *Playlist) IsOwner() bool {
+	return p.Role == PlaylistOwner
+}
+
+// PlaylistUpdate holds the details of a playlist to change; empty fields are
+// left as they are. Rules may only be set on smart playlists.
+type PlaylistUpdate struct {
+	Name       string
+	Rules      *SmartRules
+	Visibility string
+}
+
+// PlaylistCollaborator is a user invited to a playlist.
+type PlaylistCollaborator struct {
+	UserID   int       `json:"user_id"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	AddedAt  time.Time `json:"added_at"`
+}
+
+// Playlist activity actions.
+const (
+	PlaylistActivityAdd                 = "add"
+	PlaylistActivityRemove              = "remove"
+	PlaylistActivityMove                = "move"
+	PlaylistActivityRename              = "rename"
+	PlaylistActivityRules               = "rules"
+	PlaylistActivityVisibility          = "visibility"
+	PlaylistActivityCollaborator        = "collaborator"
+	PlaylistActivityCollaboratorRemoved = "collaborator_removed"
+)
+
+// PlaylistActivity records a change made to a playlist. SongID is set for song
+// additions, removals and moves; Details holds the new name, visibility or the
+// collaborator and role for other changes.
+type PlaylistActivity struct {
 	ID        int       `json:"id"`
-	UserID    int       `json:"user_id"`
-	Name      string    `json:"name"`
+	UserID    *int      `json:"user_id,omitempty"` // Nil once the user has been deleted
+	Username  string    `json:"username,omitempty"`
+	Action    string    `json:"action"`
+	SongID    *int      `json:"song_id,omitempty"`
+	SongTitle string    `json:"song_title,omitempty"`
+	Details   string    `json:"details,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SmartRules defines a smart playlist: a rule tree plus sort and limit options.
+type SmartRules struct {
+	Match SmartRule `json:"match"`
+	Sort  string    `json:"sort,omitempty"`  // e.g. "artist", "year", "random"
+	Order string    `json:"order,omitempty"` // "asc" (default) or "desc"
+	Limit int       `json:"limit,omitempty"` // 0 means no limit
+}
+
+// SmartRule is a node in a smart playlist rule tree.
+// A group node sets Op ("and"/"or") and Rules; a condition node sets Field, Operator and Value.
+type SmartRule struct {
+	Op    string      `json:"op,omitempty"`
+	Rules []SmartRule `json:"rules,omitempty"`
+
+	Field    string      `json:"field,omitempty"`
+	Operator string      `json:"operator,omitempty"`
+	Value    interface{} `json:"value,omitempty"`
+	SongID   int         `json:"song_id,omitempty"` // Reference song for the "similarity" field
 }
 
 // PlaylistSong represents a song in a playlist, with its position.
 // It embeds the Song model to include all song details.
 type PlaylistSong struct {
 	Song
+	EntryID  int `json:"entry_id"` // Identifies this occurrence; 0 for smart playlists
 	Position int `json:"position"`
 }
 
+// PlaylistOperation is a single edit applied to a playlist's songs.
+// Positions are 1-based; a position of 0 (or past the end) means the end of the playlist.
+type PlaylistOperation struct {
+	Op       string `json:"op"`                 // "insert", "move" or "remove"
+	EntryID  int    `json:"entry_id,omitempty"` // Entry to move or remove
+	SongID   int    `json:"song_id,omitempty"`  // Song to insert, or remove every occurrence of
+	Position int    `json:"position,omitempty"` // Target position for insert and move
+}
+
 // FullPlaylist represents a playlist along with its songs.
 // This is likely what we'll return from the GetPlaylist handler.
 type FullPlaylist struct {
-	ID        int            `json:"id"`
-	UserID    int            `json:"user_id"`
-	Name      string         `json:"name"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	Songs     []PlaylistSong `json:"songs"`
+	ID         int            `json:"id"`
+	UserID     int            `json:"user_id"`
+	Owner      string         `json:"owner"`
+	Name       string         `json:"name"`
+	Smart      bool           `json:"smart"`
+	ReadOnly   bool           `json:"read_only"`
+	Rules      *SmartRules    `json:"rules,omitempty"`
+	Version    int            `json:"version"`
+	Visibility string         `json:"visibility"`
+	Role       string         `json:"role"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	Songs      []PlaylistSong `json:"songs"`
 }
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// PlayQueue is a user's play queue, shared between their devices. Client names
+// the player that saved it last.
+type PlayQueue struct {
+	Songs        []Song    `json:"songs"`
+	CurrentIndex int       `json:"current_index"`
+	PositionMs   int64     `json:"position_ms"`
+	Client       string    `json:"client"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+// Usage is the storage taken by the songs a user uploaded, and the quotas
+// limiting it. A quota of 0 is unlimited.
+type Usage struct {
+	Bytes       int64 `json:"bytes"`
+	Tracks      int   `json:"tracks"`
+	QuotaBytes  int64 `json:"quota_bytes"`
+	QuotaTracks int   `json:"quota_tracks"`
+	// The user's own quotas; nil ones follow the server defaults
+	UserQuotaBytes  *int64 `json:"user_quota_bytes"`
+	UserQuotaTracks *int   `json:"user_quota_tracks"`
+}
+
+// QuotaUpdate represents an admin's change of a user's quotas. Both are
+// replaced; null makes a quota follow the server default.
+type QuotaUpdate struct {
+	QuotaBytes  *int64 `json:"quota_bytes"`
+	QuotaTracks *int   `json:"quota_tracks"`
+}
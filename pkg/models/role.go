@@ -0,0 +1,51 @@
+package models
+
+// Permissions a role can grant. PermissionManagePlaylists lets a user edit and
+// delete other users' playlists; PermissionAdmin is only granted by RoleAdmin.
+const (
+	PermissionUpload          = "upload"
+	PermissionEditMetadata    = "edit_metadata"
+	PermissionManagePlaylists = "manage_playlists"
+	PermissionDownload        = "download"
+	PermissionJukebox         = "jukebox"
+	PermissionShare           = "share"
+	PermissionAdmin           = "admin"
+)
+
+// Permissions lists every permission, in the order they are shown to admins.
+var Permissions = []string{
+	PermissionUpload,
+	PermissionEditMetadata,
+	PermissionManagePlaylists,
+	PermissionDownload,
+	PermissionJukebox,
+	PermissionShare,
+	PermissionAdmin,
+}
+
+// Built-in roles. New users get RoleUser; the admin role is the only one that
+// grants PermissionAdmin and is kept in step with a user's is_admin flag.
+const (
+	RoleAdmin    = "admin"
+	RoleUser     = "user"
+	RoleListener = "listener"
+	RoleGuest    = "guest"
+)
+
+// IsPermission reports whether name is a known permission.
+func IsPermission(name string) bool {
+	for _, p := range Permissions {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Role is a named set of permissions.
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	BuiltIn     bool     `json:"built_in"`
+	Permissions []string `json:"permissions"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// ScrobbleAccount is a user's account on a ListenBrainz-compatible service that
+// their plays are forwarded to. The token is never sent back to clients.
+type ScrobbleAccount struct {
+	ID             int       `json:"id"`
+	UserID         int       `json:"user_id"`
+	Service        string    `json:"service"`
+	APIURL         string    `json:"api_url"`
+	Username       string    `json:"username"`
+	TokenEncrypted string    `json:"-"`
+	Enabled        bool      `json:"enabled"`
+	Pending        int       `json:"pending"` // Listens waiting in the outbound queue
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// QueuedScrobble is an outbound listen or now playing notification together
+// with what is needed to submit it.
+type QueuedScrobble struct {
+	ID             int
+	AccountID      int
+	APIURL         string
+	TokenEncrypted string
+	ListenType     string
+	ListenedAt     time.Time
+	Attempts       int
+	Song           Song
+}
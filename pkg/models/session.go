@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Session is a login on one device. It stays valid while its refresh token is
+// used before ExpiresAt, until it is revoked.
+type Session struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"-"`
+	DeviceName string    `json:"device_name"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"` // Whether this is the session making the request
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Kinds of items that can be shared.
+const (
+	ShareSong     = "song"
+	SharePlaylist = "playlist"
+	ShareAlbum    = "album"
+)
+
+// Share is a public link to songs, an album or a playlist. A share of songs may
+// hold several songs; album and playlist shares hold a single ID and always
+// serve the current songs of that album or playlist.
+type Share struct {
+	ID            int        `json:"id"`
+	UserID        int        `json:"user_id"`
+	Token         string     `json:"token"`
+	URL           string     `json:"url"`
+	Type          string     `json:"type"`
+	ItemIDs       []int      `json:"ids"`
+	Description   string     `json:"description"`
+	PasswordHash  string     `json:"-"`
+	HasPassword   bool       `json:"has_password"`
+	AllowDownload bool       `json:"allow_download"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	VisitCount    int        `json:"visit_count"`
+	LastVisitedAt *time.Time `json:"last_visited_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// Expired reports whether the share's expiry date has passed.
+func (s *Share) Expired() bool {
+	return s.ExpiresAt != nil && time.Now().After(*s.ExpiresAt)
+}
@@ -12,7 +12,9 @@ type Song struct {
 	FilePath        string        `json:"file_path"`
 	Title           string        `json:"title"`
 	Artist          string        `json:"artist"`
+	ArtistID        sql.NullInt64 `json:"-"`
 	Album           string        `json:"album"`
+	AlbumID         sql.NullInt64 `json:"-"`
 	Year            int           `json:"year"`
 	GenreID         sql.NullInt64 `json:"-"` // Use Genre for input, GenreID for DB
 	Genre           string        `json:"genre"`
@@ -30,9 +32,11 @@ type Genre struct {
 
 // Album represents an album in the database
 type Album struct {
-	ID     int    `json:"id"`
-	Name   string `json:"name"`
-	Artist string `json:"artist"`
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Artist   string `json:"artist"`
+	ArtistID int    `json:"artist_id,omitempty"`
+	Year     int    `json:"year,omitempty"`
 }
 
 // Artist represents an artist in the database
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// AppPassword is a password for a Subsonic client. The password itself is
+// only returned when it is created.
+type AppPassword struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Password   string     `json:"password,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
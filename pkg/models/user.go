@@ -4,10 +4,34 @@ import "time"
 
 // User represents a user in the database
 type User struct {
-	ID           int       `json:"id"`
-	Username     string    `json:"username"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"` // Do not expose password hash
-	CreatedAt    time.Time `json:"created_at"`
-	IsAdmin      bool      `json:"is_admin"`
+	ID               int       `json:"id"`
+	Username         string    `json:"username"`
+	Email            string    `json:"email"`
+	PasswordHash     string    `json:"-"` // Do not expose password hash
+	CreatedAt        time.Time `json:"created_at"`
+	IsAdmin          bool      `json:"is_admin"`
+	Role             string    `json:"role"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+	Disabled         bool      `json:"disabled"`
+}
+
+// UserListOptions selects a page of users, optionally those whose username or
+// email contains Query.
+type UserListOptions struct {
+	Query  string
+	Limit  int
+	Offset int
+}
+
+// UserList is a page of users and the number of users matching in total.
+type UserList struct {
+	Users []User `json:"users"`
+	Total int    `json:"total"`
+}
+
+// UserUpdate holds the fields of a user that an admin changes; nil fields are kept.
+type UserUpdate struct {
+	Email   *string `json:"email"`
+	IsAdmin *bool   `json:"is_admin"`
+	Role    *string `json:"role"`
 }
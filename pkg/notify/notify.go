@@ -0,0 +1,100 @@
+// Package notify delivers messages to users, such as password reset links.
+// SMTP sends them by email; Log only writes them to the server log, for
+// development; Memory keeps them for tests.
+package notify
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a plain text message to a user's email address.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages.
+type Notifier interface {
+	Notify(msg Message) error
+}
+
+// Log writes messages to the server log instead of delivering them.
+type Log struct{}
+
+// Notify logs the message.
+func (Log) Notify(msg Message) error {
+	log.Printf("Notification to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// SMTP sends messages by email through a mail server. Username and Password
+// are used for PLAIN authentication when Username is set.
+type SMTP struct {
+	Addr     string // host:port of the mail server
+	From     string
+	Username string
+	Password string
+
+	// SendMail sends the message; it defaults to smtp.SendMail
+	SendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// Notify sends the message.
+func (s *SMTP) Notify(msg Message) error {
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return fmt.Errorf("notify: invalid recipient or subject")
+	}
+	var a smtp.Auth
+	if s.Username != "" {
+		host := s.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		a = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	send := s.SendMail
+	if send == nil {
+		send = smtp.SendMail
+	}
+	return send(s.Addr, a, s.From, []string{msg.To}, s.format(msg))
+}
+
+// format builds the email with its headers.
+func (s *SMTP) format(msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// Memory keeps messages in memory, standing in for a mail server in tests.
+type Memory struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// Notify records the message.
+func (m *Memory) Notify(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns the messages recorded so far.
+func (m *Memory) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
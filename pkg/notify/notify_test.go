@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"net/smtp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSMTPNotify(t *testing.T) {
+	var gotAddr, gotFrom string
+	var gotTo []string
+	var gotMsg []byte
+	var gotAuth smtp.Auth
+	s := &SMTP{
+		Addr:     "mail.example.com:587",
+		From:     "music@example.com",
+		Username: "music",
+		Password: "secret",
+		SendMail: func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+			gotAddr, gotAuth, gotFrom, gotTo, gotMsg = addr, a, from, to, msg
+			return nil
+		},
+	}
+
+	require.NoError(t, s.Notify(Message{To: "bob@example.com", Subject: "Reset", Body: "Line 1\nLine 2"}))
+	assert.Equal(t, "mail.example.com:587", gotAddr)
+	assert.NotNil(t, gotAuth)
+	assert.Equal(t, "music@example.com", gotFrom)
+	assert.Equal(t, []string{"bob@example.com"}, gotTo)
+	msg := string(gotMsg)
+	assert.True(t, strings.HasPrefix(msg, "From: music@example.com\r\nTo: bob@example.com\r\nSubject: Reset\r\n"))
+	assert.True(t, strings.HasSuffix(msg, "\r\n\r\nLine 1\r\nLine 2"))
+}
+
+func TestSMTPNotifyRejectsHeaderInjection(t *testing.T) {
+	s := &SMTP{SendMail: func(string, smtp.Auth, string, []string, []byte) error {
+		t.Fatal("message must not be sent")
+		return nil
+	}}
+	assert.Error(t, s.Notify(Message{To: "bob@example.com\r\nBcc: eve@example.com", Subject: "Reset"}))
+}
+
+func TestMemory(t *testing.T) {
+	m := &Memory{}
+	require.NoError(t, m.Notify(Message{To: "bob@example.com", Subject: "Hello"}))
+	assert.Equal(t, []Message{{To: "bob@example.com", Subject: "Hello"}}, m.Messages())
+}
@@ -0,0 +1,178 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// FakeProvider is a minimal OpenID provider running on a local httptest
+// server. Its authorization endpoint signs in the user described by Claims at
+// once and redirects back with a code; the token endpoint checks the client
+// credentials and PKCE verifier before issuing an RS256-signed ID token. It is
+// meant for tests.
+type FakeProvider struct {
+	Server       *httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	mu     sync.Mutex
+	claims map[string]interface{}
+	key    *rsa.PrivateKey
+	codes  map[string]fakeCode
+}
+
+// fakeCode is an issued authorization code.
+type fakeCode struct {
+	redirectURI string
+	nonce       string
+	challenge   string
+}
+
+// NewFakeProvider starts a FakeProvider that signs in a user with the given
+// claims (such as preferred_username, email or groups). Close it when done.
+func NewFakeProvider(clientID, clientSecret string, claims map[string]interface{}) *FakeProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	f := &FakeProvider{ClientID: clientID, ClientSecret: clientSecret, claims: claims, key: key, codes: map[string]fakeCode{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", f.handleDiscovery)
+	mux.HandleFunc("/authorize", f.handleAuthorize)
+	mux.HandleFunc("/token", f.handleToken)
+	mux.HandleFunc("/jwks", f.handleJWKS)
+	f.Server = httptest.NewServer(mux)
+	return f
+}
+
+// Issuer returns the provider's issuer URL.
+func (f *FakeProvider) Issuer() string {
+	return f.Server.URL
+}
+
+// SetClaims changes the user signed in by the provider.
+func (f *FakeProvider) SetClaims(claims map[string]interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.claims = claims
+}
+
+// Close shuts the provider down.
+func (f *FakeProvider) Close() {
+	f.Server.Close()
+}
+
+func (f *FakeProvider) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 f.Issuer(),
+		"authorization_endpoint": f.Issuer() + "/authorize",
+		"token_endpoint":         f.Issuer() + "/token",
+		"jwks_uri":               f.Issuer() + "/jwks",
+	})
+}
+
+func (f *FakeProvider) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != f.ClientID || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString()
+	f.mu.Lock()
+	f.codes[code] = fakeCode{redirectURI: q.Get("redirect_uri"), nonce: q.Get("nonce"), challenge: q.Get("code_challenge")}
+	f.mu.Unlock()
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (f *FakeProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, _ := r.BasicAuth()
+	clientID, _ = url.QueryUnescape(clientID)
+	clientSecret, _ = url.QueryUnescape(clientSecret)
+	if clientID != f.ClientID || clientSecret != f.ClientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	f.mu.Lock()
+	code, ok := f.codes[r.FormValue("code")]
+	delete(f.codes, r.FormValue("code"))
+	claims := jwt.MapClaims{}
+	for k, v := range f.claims {
+		claims[k] = v
+	}
+	f.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if !ok || r.FormValue("grant_type") != "authorization_code" || code.redirectURI != r.FormValue("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != code.challenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	claims["iss"] = f.Issuer()
+	claims["aud"] = f.ClientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(5 * time.Minute).Unix()
+	claims["nonce"] = code.nonce
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "fake"
+	idToken, err := token.SignedString(f.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (f *FakeProvider) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": "fake",
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(f.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(f.key.E)).Bytes()),
+		}},
+	})
+}
+
+// randomString returns a random code or token.
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
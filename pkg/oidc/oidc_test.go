@@ -0,0 +1,119 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// authorize follows the provider's login page and returns the code it redirects back with.
+func authorize(t *testing.T, p *Provider, state, nonce, challenge string) string {
+	t.Helper()
+	authURL, err := p.AuthCodeURL(context.Background(), state, nonce, challenge)
+	require.NoError(t, err)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "https://music.example.com/auth/oidc/callback", location.Scheme+"://"+location.Host+location.Path)
+	assert.Equal(t, state, location.Query().Get("state"))
+	return location.Query().Get("code")
+}
+
+func newTestProvider(t *testing.T) (*FakeProvider, *Provider) {
+	fake := NewFakeProvider("biomuzak", "secret", map[string]interface{}{
+		"sub":                "user-1",
+		"preferred_username": "alice",
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"groups":             []string{"music", "admins"},
+	})
+	t.Cleanup(fake.Close)
+	p := New(Config{
+		IssuerURL:    fake.Issuer(),
+		ClientID:     "biomuzak",
+		ClientSecret: "secret",
+		RedirectURL:  "https://music.example.com/auth/oidc/callback",
+	})
+	return fake, p
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	_, p := newTestProvider(t)
+
+	verifier, challenge, err := NewPKCE()
+	require.NoError(t, err)
+	code := authorize(t, p, "state-1", "nonce-1", challenge)
+
+	claims, err := p.Exchange(context.Background(), code, verifier, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+	assert.Equal(t, "alice", claims.PreferredUsername)
+	assert.Equal(t, "alice@example.com", claims.Email)
+	assert.True(t, claims.EmailVerified)
+	assert.Equal(t, []string{"music", "admins"}, claims.Groups)
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	_, p := newTestProvider(t)
+
+	_, challenge, err := NewPKCE()
+	require.NoError(t, err)
+	code := authorize(t, p, "state-1", "nonce-1", challenge)
+
+	_, err = p.Exchange(context.Background(), code, "not-the-verifier", "nonce-1")
+	assert.ErrorContains(t, err, "invalid_grant")
+}
+
+func TestExchangeRejectsWrongNonce(t *testing.T) {
+	_, p := newTestProvider(t)
+
+	verifier, challenge, err := NewPKCE()
+	require.NoError(t, err)
+	code := authorize(t, p, "state-1", "nonce-1", challenge)
+
+	_, err = p.Exchange(context.Background(), code, verifier, "other-nonce")
+	assert.ErrorContains(t, err, "nonce")
+}
+
+func TestVerifyRejectsForgedToken(t *testing.T) {
+	fake, p := newTestProvider(t)
+
+	// A token with all the right claims, signed by a key that is not the provider's.
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   fake.Issuer(),
+		"aud":   "biomuzak",
+		"sub":   "admin",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"nonce": "n",
+	})
+	token.Header["kid"] = "fake"
+	forged, err := token.SignedString(key)
+	require.NoError(t, err)
+
+	_, err = p.Verify(context.Background(), forged, "n")
+	assert.ErrorContains(t, err, "signature")
+}
+
+func TestDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	fake, _ := newTestProvider(t)
+	p := New(Config{IssuerURL: fake.Issuer() + "/realms/other", ClientID: "biomuzak"})
+
+	_, err := p.AuthCodeURL(context.Background(), "s", "n", "c")
+	assert.Error(t, err)
+}
@@ -0,0 +1,282 @@
+package playlistio
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// parseM3U reads an extended or plain M3U playlist.
+func parseM3U(r io.Reader) (*Playlist, error) {
+	pl := &Playlist{}
+	var pending Entry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\xef\xbb\xbf"))
+		switch {
+		case line == "" || line == "#EXTM3U":
+		case strings.HasPrefix(line, "#PLAYLIST:"):
+			pl.Title = strings.TrimSpace(strings.TrimPrefix(line, "#PLAYLIST:"))
+		case strings.HasPrefix(line, "#EXTINF:"):
+			info := strings.TrimPrefix(line, "#EXTINF:")
+			durationStr, display, _ := strings.Cut(info, ",")
+			// Drop any key="value" attributes that follow the duration.
+			durationStr, _, _ = strings.Cut(durationStr, " ")
+			if d, err := strconv.Atoi(strings.TrimSpace(durationStr)); err == nil && d > 0 {
+				pending.Duration = d
+			}
+			if artist, title, found := strings.Cut(display, " - "); found {
+				pending.Artist = strings.TrimSpace(artist)
+				pending.Title = strings.TrimSpace(title)
+			} else {
+				pending.Title = strings.TrimSpace(display)
+			}
+		case strings.HasPrefix(line, "#EXTALB:"):
+			pending.Album = strings.TrimSpace(strings.TrimPrefix(line, "#EXTALB:"))
+		case strings.HasPrefix(line, "#EXTART:"):
+			pending.Artist = strings.TrimSpace(strings.TrimPrefix(line, "#EXTART:"))
+		case strings.HasPrefix(line, "#"):
+			// Unknown directive or comment.
+		default:
+			pending.Location = line
+			pl.Entries = append(pl.Entries, pending)
+			pending = Entry{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return pl, nil
+}
+
+func writeM3U(w io.Writer, pl *Playlist) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "#EXTM3U")
+	if pl.Title != "" {
+		fmt.Fprintf(bw, "#PLAYLIST:%s\n", pl.Title)
+	}
+	for _, e := range pl.Entries {
+		duration := e.Duration
+		if duration == 0 {
+			duration = -1
+		}
+		fmt.Fprintf(bw, "#EXTINF:%d,%s\n", duration, displayName(e))
+		if e.Album != "" {
+			fmt.Fprintf(bw, "#EXTALB:%s\n", e.Album)
+		}
+		fmt.Fprintln(bw, e.Location)
+	}
+	return bw.Flush()
+}
+
+// parsePLS reads a PLS (version 2) playlist.
+func parsePLS(r io.Reader) (*Playlist, error) {
+	pl := &Playlist{}
+	entries := map[int]*Entry{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\xef\xbb\xbf"))
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		var field string
+		for _, prefix := range []string{"file", "title", "length"} {
+			if strings.HasPrefix(key, prefix) {
+				field = prefix
+				break
+			}
+		}
+		if field == "" {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimPrefix(key, field))
+		if err != nil {
+			continue
+		}
+		e, ok := entries[n]
+		if !ok {
+			e = &Entry{}
+			entries[n] = e
+		}
+		switch field {
+		case "file":
+			e.Location = value
+		case "title":
+			if artist, title, found := strings.Cut(value, " - "); found {
+				e.Artist, e.Title = strings.TrimSpace(artist), strings.TrimSpace(title)
+			} else {
+				e.Title = value
+			}
+		case "length":
+			if d, err := strconv.Atoi(value); err == nil && d > 0 {
+				e.Duration = d
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	numbers := make([]int, 0, len(entries))
+	for n := range entries {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	for _, n := range numbers {
+		if entries[n].Location != "" {
+			pl.Entries = append(pl.Entries, *entries[n])
+		}
+	}
+	return pl, nil
+}
+
+func writePLS(w io.Writer, pl *Playlist) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "[playlist]")
+	for i, e := range pl.Entries {
+		n := i + 1
+		duration := e.Duration
+		if duration == 0 {
+			duration = -1
+		}
+		fmt.Fprintf(bw, "File%d=%s\n", n, e.Location)
+		fmt.Fprintf(bw, "Title%d=%s\n", n, displayName(e))
+		fmt.Fprintf(bw, "Length%d=%d\n", n, duration)
+	}
+	fmt.Fprintf(bw, "NumberOfEntries=%d\n", len(pl.Entries))
+	fmt.Fprintln(bw, "Version=2")
+	return bw.Flush()
+}
+
+// xspfPlaylist mirrors the XSPF document structure.
+type xspfPlaylist struct {
+	XMLName xml.Name    `xml:"playlist"`
+	Version string      `xml:"version,attr"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title,omitempty"`
+	Tracks  []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location []string `xml:"location"`
+	Title    string   `xml:"title,omitempty"`
+	Creator  string   `xml:"creator,omitempty"`
+	Album    string   `xml:"album,omitempty"`
+	Duration int      `xml:"duration,omitempty"` // Milliseconds
+}
+
+func parseXSPF(r io.Reader) (*Playlist, error) {
+	var doc xspfPlaylist
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid XSPF: %w", err)
+	}
+	pl := &Playlist{Title: doc.Title}
+	for _, t := range doc.Tracks {
+		pl.Entries = append(pl.Entries, Entry{
+			Location: first(t.Location),
+			Title:    t.Title,
+			Artist:   t.Creator,
+			Album:    t.Album,
+			Duration: t.Duration / 1000,
+		})
+	}
+	return pl, nil
+}
+
+func writeXSPF(w io.Writer, pl *Playlist) error {
+	doc := xspfPlaylist{Version: "1", XMLNS: "http://xspf.org/ns/0/", Title: pl.Title}
+	for _, e := range pl.Entries {
+		doc.Tracks = append(doc.Tracks, xspfTrack{
+			Location: []string{e.Location},
+			Title:    e.Title,
+			Creator:  e.Artist,
+			Album:    e.Album,
+			Duration: e.Duration * 1000,
+		})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(doc)
+}
+
+// jspfDocument mirrors the JSPF document structure.
+type jspfDocument struct {
+	Playlist struct {
+		Title string      `json:"title,omitempty"`
+		Track []jspfTrack `json:"track"`
+	} `json:"playlist"`
+}
+
+type jspfTrack struct {
+	Location []string `json:"location,omitempty"`
+	Title    string   `json:"title,omitempty"`
+	Creator  string   `json:"creator,omitempty"`
+	Album    string   `json:"album,omitempty"`
+	Duration int      `json:"duration,omitempty"` // Milliseconds
+}
+
+func parseJSPF(r io.Reader) (*Playlist, error) {
+	var doc jspfDocument
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid JSPF: %w", err)
+	}
+	pl := &Playlist{Title: doc.Playlist.Title}
+	for _, t := range doc.Playlist.Track {
+		pl.Entries = append(pl.Entries, Entry{
+			Location: first(t.Location),
+			Title:    t.Title,
+			Artist:   t.Creator,
+			Album:    t.Album,
+			Duration: t.Duration / 1000,
+		})
+	}
+	return pl, nil
+}
+
+func writeJSPF(w io.Writer, pl *Playlist) error {
+	var doc jspfDocument
+	doc.Playlist.Title = pl.Title
+	doc.Playlist.Track = []jspfTrack{}
+	for _, e := range pl.Entries {
+		doc.Playlist.Track = append(doc.Playlist.Track, jspfTrack{
+			Location: []string{e.Location},
+			Title:    e.Title,
+			Creator:  e.Artist,
+			Album:    e.Album,
+			Duration: e.Duration * 1000,
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// displayName formats an entry as "Artist - Title" for M3U and PLS titles.
+func displayName(e Entry) string {
+	if e.Artist == "" {
+		return e.Title
+	}
+	return e.Artist + " - " + e.Title
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(values[0])
+}
@@ -0,0 +1,148 @@
+package playlistio
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Supported playlist formats.
+const (
+	FormatM3U8 = "m3u8"
+	FormatPLS  = "pls"
+	FormatXSPF = "xspf"
+	FormatJSPF = "jspf"
+)
+
+// Entry is a single track in a playlist file.
+type Entry struct {
+	Location string `json:"location,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Artist   string `json:"artist,omitempty"`
+	Album    string `json:"album,omitempty"`
+	Duration int    `json:"duration,omitempty"` // In seconds, 0 if unknown
+}
+
+// Playlist is the format-independent representation of a playlist file.
+type Playlist struct {
+	Title   string
+	Entries []Entry
+}
+
+// Parse reads a playlist in the given format.
+func Parse(format string, r io.Reader) (*Playlist, error) {
+	switch format {
+	case FormatM3U8:
+		return parseM3U(r)
+	case FormatPLS:
+		return parsePLS(r)
+	case FormatXSPF:
+		return parseXSPF(r)
+	case FormatJSPF:
+		return parseJSPF(r)
+	}
+	return nil, fmt.Errorf("unsupported playlist format %q", format)
+}
+
+// Write serializes a playlist in the given format.
+func Write(format string, w io.Writer, pl *Playlist) error {
+	switch format {
+	case FormatM3U8:
+		return writeM3U(w, pl)
+	case FormatPLS:
+		return writePLS(w, pl)
+	case FormatXSPF:
+		return writeXSPF(w, pl)
+	case FormatJSPF:
+		return writeJSPF(w, pl)
+	}
+	return fmt.Errorf("unsupported playlist format %q", format)
+}
+
+// ContentType returns the MIME type of a playlist format.
+func ContentType(format string) string {
+	switch format {
+	case FormatM3U8:
+		return "audio/x-mpegurl; charset=utf-8"
+	case FormatPLS:
+		return "audio/x-scpls"
+	case FormatXSPF:
+		return "application/xspf+xml"
+	case FormatJSPF:
+		return "application/jspf+json"
+	}
+	return "application/octet-stream"
+}
+
+// IsSupported reports whether a format name is known.
+func IsSupported(format string) bool {
+	switch format {
+	case FormatM3U8, FormatPLS, FormatXSPF, FormatJSPF:
+		return true
+	}
+	return false
+}
+
+// DetectFormat guesses the format of a playlist from its file name, falling back to its content.
+func DetectFormat(filename string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".m3u", ".m3u8":
+		return FormatM3U8
+	case ".pls":
+		return FormatPLS
+	case ".xspf":
+		return FormatXSPF
+	case ".jspf":
+		return FormatJSPF
+	}
+
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("#EXTM3U")):
+		return FormatM3U8
+	case bytes.HasPrefix(bytes.ToLower(trimmed), []byte("[playlist]")):
+		return FormatPLS
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return FormatXSPF
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return FormatJSPF
+	}
+	// Plain M3U files are just a list of paths.
+	return FormatM3U8
+}
+
+// NormalizeLocation turns a playlist location (a path, a file:// URL or a
+// Windows path) into a slash-separated path.
+func NormalizeLocation(location string) string {
+	location = strings.TrimSpace(location)
+	if u, err := url.Parse(location); err == nil && u.Scheme == "file" {
+		location = u.Path
+	} else if unescaped, err := url.PathUnescape(location); err == nil && strings.Contains(location, "%") {
+		location = unescaped
+	}
+	return strings.ReplaceAll(location, `\`, "/")
+}
+
+// GuessTags fills in the artist and title of an entry without tags from its
+// file name, which is commonly "Artist - Title.ext" or "NN - Title.ext".
+func (e *Entry) GuessTags() {
+	if e.Title != "" || e.Location == "" {
+		return
+	}
+	base := path.Base(NormalizeLocation(e.Location))
+	base = strings.TrimSuffix(base, path.Ext(base))
+
+	artist, title, found := strings.Cut(base, " - ")
+	if !found {
+		e.Title = base
+		return
+	}
+	if e.Artist == "" && strings.Trim(artist, "0123456789. ") != "" {
+		e.Artist = strings.TrimSpace(artist)
+	}
+	e.Title = strings.TrimSpace(title)
+}
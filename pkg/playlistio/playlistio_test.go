@@ -0,0 +1,100 @@
+package playlistio
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	pl := &Playlist{
+		Title: "Road Trip",
+		Entries: []Entry{
+			{Location: "abc123.flac", Title: "So What", Artist: "Miles Davis", Album: "Kind of Blue", Duration: 562},
+			{Location: "def456.mp3", Title: "Untagged"},
+		},
+	}
+
+	for _, format := range []string{FormatM3U8, FormatPLS, FormatXSPF, FormatJSPF} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, Write(format, &buf, pl))
+
+			assert.Equal(t, format, DetectFormat("", buf.Bytes()))
+
+			parsed, err := Parse(format, &buf)
+			require.NoError(t, err)
+			require.Len(t, parsed.Entries, 2)
+			assert.Equal(t, pl.Entries[0].Location, parsed.Entries[0].Location)
+			assert.Equal(t, pl.Entries[0].Title, parsed.Entries[0].Title)
+			assert.Equal(t, pl.Entries[0].Artist, parsed.Entries[0].Artist)
+			assert.Equal(t, pl.Entries[0].Duration, parsed.Entries[0].Duration)
+			assert.Equal(t, "Untagged", parsed.Entries[1].Title)
+			assert.Equal(t, 0, parsed.Entries[1].Duration)
+			if format != FormatPLS {
+				assert.Equal(t, "Road Trip", parsed.Title)
+			}
+		})
+	}
+}
+
+func TestParseM3UFromOtherPlayers(t *testing.T) {
+	data := "\xef\xbb\xbf#EXTM3U\r\n" +
+		"#EXTINF:245,Boards of Canada - Roygbiv\r\n" +
+		"Music\\Boards of Canada\\Music Has the Right to Children\\07 - Roygbiv.flac\r\n" +
+		"# a comment\r\n" +
+		"file:///home/me/Music/Aphex%20Twin/Xtal.mp3\r\n"
+
+	pl, err := Parse(FormatM3U8, strings.NewReader(data))
+	require.NoError(t, err)
+	require.Len(t, pl.Entries, 2)
+
+	assert.Equal(t, "Boards of Canada", pl.Entries[0].Artist)
+	assert.Equal(t, "Roygbiv", pl.Entries[0].Title)
+	assert.Equal(t, 245, pl.Entries[0].Duration)
+	assert.Equal(t, "Music/Boards of Canada/Music Has the Right to Children/07 - Roygbiv.flac", NormalizeLocation(pl.Entries[0].Location))
+
+	assert.Equal(t, "/home/me/Music/Aphex Twin/Xtal.mp3", NormalizeLocation(pl.Entries[1].Location))
+	pl.Entries[1].GuessTags()
+	assert.Equal(t, "Xtal", pl.Entries[1].Title)
+}
+
+func TestParsePLSOutOfOrder(t *testing.T) {
+	data := "[playlist]\nFile2=b.mp3\nTitle2=Second\nFile1=a.mp3\nLength1=60\nNumberOfEntries=2\nVersion=2\n"
+
+	pl, err := Parse(FormatPLS, strings.NewReader(data))
+	require.NoError(t, err)
+	require.Len(t, pl.Entries, 2)
+	assert.Equal(t, "a.mp3", pl.Entries[0].Location)
+	assert.Equal(t, 60, pl.Entries[0].Duration)
+	assert.Equal(t, "Second", pl.Entries[1].Title)
+}
+
+func TestGuessTags(t *testing.T) {
+	e := Entry{Location: "/music/Portishead - Roads.mp3"}
+	e.GuessTags()
+	assert.Equal(t, "Portishead", e.Artist)
+	assert.Equal(t, "Roads", e.Title)
+
+	// A leading track number is not an artist.
+	e = Entry{Location: "/music/03 - Roads.mp3"}
+	e.GuessTags()
+	assert.Equal(t, "", e.Artist)
+	assert.Equal(t, "Roads", e.Title)
+
+	// Existing tags are kept.
+	e = Entry{Location: "/music/x.mp3", Title: "Glory Box"}
+	e.GuessTags()
+	assert.Equal(t, "Glory Box", e.Title)
+}
+
+func TestDetectFormatByExtension(t *testing.T) {
+	assert.Equal(t, FormatM3U8, DetectFormat("list.M3U", nil))
+	assert.Equal(t, FormatPLS, DetectFormat("list.pls", nil))
+	assert.Equal(t, FormatXSPF, DetectFormat("list.xspf", nil))
+	assert.Equal(t, FormatJSPF, DetectFormat("list.jspf", nil))
+	assert.Equal(t, FormatM3U8, DetectFormat("", []byte("/music/a.mp3\n")))
+}
@@ -0,0 +1,57 @@
+// Package playqueue notifies a user's open clients when their play queue changes.
+package playqueue
+
+import (
+	"sync"
+
+	"go-postgres-example/pkg/models"
+)
+
+// Broker fans out play queue changes to every subscriber of the same user. Only the
+// latest queue matters, so a subscriber that falls behind skips intermediate versions.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[int]map[chan models.PlayQueue]struct{}
+}
+
+// NewBroker creates a new Broker
+func NewBroker() *Broker {
+	return &Broker{subscribers: map[int]map[chan models.PlayQueue]struct{}{}}
+}
+
+// Subscribe registers for a user's queue changes. The returned function
+// unsubscribes and must be called once the caller stops reading.
+func (b *Broker) Subscribe(userID int) (<-chan models.PlayQueue, func()) {
+	ch := make(chan models.PlayQueue, 1)
+
+	b.mu.Lock()
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = map[chan models.PlayQueue]struct{}{}
+	}
+	b.subscribers[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers[userID], ch)
+		if len(b.subscribers[userID]) == 0 {
+			delete(b.subscribers, userID)
+		}
+	}
+}
+
+// Publish sends a user's new queue to all of their subscribers without blocking.
+func (b *Broker) Publish(userID int, queue models.PlayQueue) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[userID] {
+		// Replace an undelivered older queue with the new one.
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- queue
+	}
+}
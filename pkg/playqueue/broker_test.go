@@ -0,0 +1,41 @@
+package playqueue
+
+import (
+	"testing"
+
+	"go-postgres-example/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBrokerDeliversLatestQueuePerUser(t *testing.T) {
+	b := NewBroker()
+
+	alice, unsubscribeAlice := b.Subscribe(1)
+	defer unsubscribeAlice()
+	bob, unsubscribeBob := b.Subscribe(2)
+	defer unsubscribeBob()
+
+	b.Publish(1, models.PlayQueue{CurrentIndex: 1})
+	b.Publish(1, models.PlayQueue{CurrentIndex: 2})
+
+	assert.Equal(t, 2, (<-alice).CurrentIndex)
+	select {
+	case <-alice:
+		t.Fatal("stale queue was delivered")
+	case <-bob:
+		t.Fatal("queue was delivered to another user")
+	default:
+	}
+}
+
+func TestBrokerUnsubscribe(t *testing.T) {
+	b := NewBroker()
+
+	ch, unsubscribe := b.Subscribe(1)
+	unsubscribe()
+	b.Publish(1, models.PlayQueue{})
+
+	assert.Empty(t, b.subscribers)
+	assert.Len(t, ch, 0)
+}
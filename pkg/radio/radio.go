@@ -0,0 +1,282 @@
+package radio
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-postgres-example/pkg/db"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Seed kinds accepted by the radio generator.
+const (
+	SeedSong     = "song"
+	SeedArtist   = "artist"
+	SeedGenre    = "genre"
+	SeedPlaylist = "playlist"
+)
+
+const (
+	// DefaultCount is the number of songs generated when no count is requested.
+	DefaultCount = 20
+	// MaxCount caps a single request; clients ask again for more to keep the queue endless.
+	MaxCount = 100
+	// seedSampleSize bounds how many songs are averaged to build an artist or genre seed.
+	seedSampleSize = 50
+	// candidatePoolSize is how many nearest neighbours are considered at every step.
+	candidatePoolSize = 10
+	// recentPlaysExcluded is how many of the user's last played songs are left out.
+	recentPlaysExcluded = 50
+)
+
+// ErrSeedNotFound is returned when the seed matches no songs with embeddings.
+var ErrSeedNotFound = errors.New("no songs with embeddings match the seed")
+
+// Seed describes where a radio station starts.
+type Seed struct {
+	Kind  string
+	Value string
+}
+
+// ParseSeed parses a seed of the form "kind:value" (e.g. "artist:Radiohead").
+// A bare number is treated as a song ID.
+func ParseSeed(s string) (Seed, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Seed{}, fmt.Errorf("seed is required")
+	}
+
+	kind, value, found := strings.Cut(s, ":")
+	if !found {
+		if _, err := strconv.Atoi(s); err != nil {
+			return Seed{}, fmt.Errorf("invalid seed %q", s)
+		}
+		return Seed{Kind: SeedSong, Value: s}, nil
+	}
+
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Seed{}, fmt.Errorf("seed %q has no value", s)
+	}
+
+	switch kind {
+	case SeedSong, SeedPlaylist:
+		if _, err := strconv.Atoi(value); err != nil {
+			return Seed{}, fmt.Errorf("seed %q requires a numeric ID", s)
+		}
+	case SeedArtist, SeedGenre:
+	default:
+		return Seed{}, fmt.Errorf("unknown seed kind %q", kind)
+	}
+	return Seed{Kind: kind, Value: value}, nil
+}
+
+// Options controls how a station is generated.
+type Options struct {
+	// Count is the number of songs to return.
+	Count int
+	// Drift ranges from 0 (stay close to the seed) to 1 (follow the walk wherever it goes).
+	Drift float64
+	// Exclude lists song IDs that must not be returned, on top of the user's
+	// recently played songs, e.g. tracks the client queued but has not played.
+	Exclude []int
+}
+
+// Generator builds radio queues by walking the song embedding space. It is safe
+// for concurrent use.
+type Generator struct {
+	DB *sql.DB
+}
+
+// NewGenerator creates a new Generator.
+func NewGenerator(db *sql.DB) *Generator {
+	return &Generator{DB: db}
+}
+
+// Generate returns a queue of songs for the given seed.
+//
+// Every step looks for the nearest neighbours of an anchor point, scores them by
+// similarity adjusted with the user's ratings, and picks one. The anchor then moves
+// towards the picked song by the drift factor, so a low drift keeps the station
+// centred on the seed while a high drift lets it wander.
+func (g *Generator) Generate(userID int, seed Seed, opts Options) ([]db.RadioCandidate, error) {
+	opts.Count = clampCount(opts.Count)
+	opts.Drift = clampDrift(opts.Drift)
+
+	seedIDs, seedVec, err := g.seedEmbedding(userID, seed)
+	if err != nil {
+		return nil, err
+	}
+
+	recent, err := db.GetRecentlyPlayedSongIDs(g.DB, userID, recentPlaysExcluded)
+	if err != nil {
+		return nil, err
+	}
+
+	exclude := make(map[int]bool, len(opts.Exclude)+len(recent)+len(seedIDs))
+	for _, id := range opts.Exclude {
+		exclude[id] = true
+	}
+	for _, id := range recent {
+		exclude[id] = true
+	}
+	// Only a single song seed is skipped; an artist or playlist station should
+	// still be allowed to play the tracks it was built from.
+	if seed.Kind == SeedSong {
+		for _, id := range seedIDs {
+			exclude[id] = true
+		}
+	}
+
+	anchor := seedVec
+	var queue []db.RadioCandidate
+	for len(queue) < opts.Count {
+		candidates, err := db.FindRadioCandidates(g.DB, userID, anchor, keys(exclude), candidatePoolSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(candidates) == 0 {
+			break
+		}
+
+		next := choose(candidates, opts.Drift)
+		queue = append(queue, next)
+		exclude[next.ID] = true
+		anchor = blend(seedVec, next.Embedding, opts.Drift)
+	}
+	return queue, nil
+}
+
+// seedEmbedding resolves a seed to the IDs of the songs it covers and their centroid.
+func (g *Generator) seedEmbedding(userID int, seed Seed) ([]int, []float64, error) {
+	var ids []int
+	var embeddings [][]float64
+	var err error
+
+	switch seed.Kind {
+	case SeedSong:
+		songID, _ := strconv.Atoi(seed.Value)
+		var vec []float64
+		vec, err = db.GetSongEmbedding(g.DB, songID)
+		if err == sql.ErrNoRows {
+			return nil, nil, ErrSeedNotFound
+		}
+		if err == nil {
+			ids, embeddings = []int{songID}, [][]float64{vec}
+		}
+	case SeedArtist:
+		ids, embeddings, err = db.GetArtistEmbeddings(g.DB, seed.Value, seedSampleSize)
+	case SeedGenre:
+		ids, embeddings, err = db.GetGenreEmbeddings(g.DB, seed.Value, seedSampleSize)
+	case SeedPlaylist:
+		playlistID, _ := strconv.Atoi(seed.Value)
+		playlist, err := db.GetPlaylistByID(g.DB, userID, playlistID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, nil, ErrSeedNotFound
+			}
+			return nil, nil, err
+		}
+		songs, err := db.GetPlaylistContents(g.DB, playlist)
+		if err != nil {
+			return nil, nil, err
+		}
+		songIDs := make([]int, len(songs))
+		for i, song := range songs {
+			songIDs[i] = song.ID
+		}
+		ids, embeddings, err = db.GetSongEmbeddings(g.DB, songIDs)
+		if err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown seed kind %q", seed.Kind)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(embeddings) == 0 {
+		return nil, nil, ErrSeedNotFound
+	}
+	return ids, centroid(embeddings), nil
+}
+
+// choose scores the candidates and picks one. With no drift the best candidate always
+// wins; higher drift widens the window of candidates picked from at random.
+func choose(candidates []db.RadioCandidate, drift float64) db.RadioCandidate {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return score(candidates[i]) > score(candidates[j])
+	})
+	window := 1 + int(drift*float64(len(candidates)-1))
+	return candidates[rand.IntN(window)]
+}
+
+// score combines a candidate's similarity with the user's rating as feedback.
+// Unrated songs are neutral, 2 stars pushes a song down and 4-5 stars pull it up.
+func score(c db.RadioCandidate) float64 {
+	if !c.Rating.Valid {
+		return c.Similarity
+	}
+	return c.Similarity + float64(c.Rating.Int64-3)*0.05
+}
+
+// centroid returns the element-wise mean of the given vectors.
+func centroid(vecs [][]float64) []float64 {
+	out := make([]float64, len(vecs[0]))
+	for _, v := range vecs {
+		for i := range out {
+			if i < len(v) {
+				out[i] += v[i]
+			}
+		}
+	}
+	for i := range out {
+		out[i] /= float64(len(vecs))
+	}
+	return out
+}
+
+// blend moves from a towards b by the factor w.
+func blend(a, b []float64, w float64) []float64 {
+	if len(a) != len(b) {
+		return a
+	}
+	out := make([]float64, len(a))
+	for i := range a {
+		out[i] = (1-w)*a[i] + w*b[i]
+	}
+	return out
+}
+
+func keys(m map[int]bool) []int {
+	out := make([]int, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Ints(out)
+	return out
+}
+
+func clampCount(n int) int {
+	if n <= 0 {
+		return DefaultCount
+	}
+	if n > MaxCount {
+		return MaxCount
+	}
+	return n
+}
+
+func clampDrift(d float64) float64 {
+	if d < 0 {
+		return 0
+	}
+	if d > 1 {
+		return 1
+	}
+	return d
+}
@@ -0,0 +1,104 @@
+package radio
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"go-postgres-example/pkg/db"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSeed(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		expected  Seed
+		expectErr bool
+	}{
+		{name: "bare song id", input: "42", expected: Seed{Kind: SeedSong, Value: "42"}},
+		{name: "song", input: "song:7", expected: Seed{Kind: SeedSong, Value: "7"}},
+		{name: "artist with spaces", input: "Artist: Boards of Canada", expected: Seed{Kind: SeedArtist, Value: "Boards of Canada"}},
+		{name: "genre", input: "genre:Ambient", expected: Seed{Kind: SeedGenre, Value: "Ambient"}},
+		{name: "playlist", input: "playlist:3", expected: Seed{Kind: SeedPlaylist, Value: "3"}},
+		{name: "empty", input: "", expectErr: true},
+		{name: "non numeric song", input: "song:abc", expectErr: true},
+		{name: "unknown kind", input: "mood:happy", expectErr: true},
+		{name: "missing value", input: "artist:", expectErr: true},
+		{name: "bare word", input: "Radiohead", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seed, err := ParseSeed(tt.input)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, seed)
+		})
+	}
+}
+
+func TestCentroidAndBlend(t *testing.T) {
+	c := centroid([][]float64{{1, 0}, {3, 2}})
+	assert.Equal(t, []float64{2, 1}, c)
+
+	assert.Equal(t, []float64{1, 0}, blend([]float64{1, 0}, []float64{3, 2}, 0))
+	assert.Equal(t, []float64{3, 2}, blend([]float64{1, 0}, []float64{3, 2}, 1))
+	assert.Equal(t, []float64{2, 1}, blend([]float64{1, 0}, []float64{3, 2}, 0.5))
+	// Mismatched dimensions keep the original anchor.
+	assert.Equal(t, []float64{1, 0}, blend([]float64{1, 0}, []float64{1}, 0.5))
+}
+
+func TestChoosePrefersRatedSongsWithoutDrift(t *testing.T) {
+	candidates := []db.RadioCandidate{
+		{SimilarSong: db.SimilarSong{Similarity: 0.90}},
+		{SimilarSong: db.SimilarSong{Similarity: 0.88}, Rating: sql.NullInt64{Int64: 5, Valid: true}},
+		{SimilarSong: db.SimilarSong{Similarity: 0.95}, Rating: sql.NullInt64{Int64: 2, Valid: true}},
+	}
+	candidates[0].ID, candidates[1].ID, candidates[2].ID = 1, 2, 3
+
+	picked := choose(candidates, 0)
+	assert.Equal(t, 2, picked.ID)
+}
+
+func TestGenerateSongSeed(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer conn.Close()
+
+	g := NewGenerator(conn)
+
+	mock.ExpectQuery("SELECT embedding::text FROM song_embeddings WHERE song_id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"embedding"}).AddRow("[1,0]"))
+	// Recently played songs are left out
+	mock.ExpectQuery("SELECT song_id\\s+FROM plays").
+		WithArgs(7, recentPlaysExcluded).
+		WillReturnRows(sqlmock.NewRows([]string{"song_id"}).AddRow(5))
+
+	columns := []string{
+		"id", "fingerprint_hash", "file_path", "title", "artist", "album", "year",
+		"genre_id", "genre", "duration", "bitrate", "file_size", "last_modified",
+		"similarity", "rating", "embedding",
+	}
+	mock.ExpectQuery("SELECT (.+) FROM song_embeddings se").
+		WithArgs("[1,0]", 7, "{1,5}", candidatePoolSize).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(2, "h2", "/p/2", "Two", "A", "B", 2020, 1, "Rock", 200, 320, 100, time.Now(), 0.9, nil, "[0,1]"))
+	mock.ExpectQuery("SELECT (.+) FROM song_embeddings se").
+		WithArgs("[1,0]", 7, "{1,2,5}", candidatePoolSize).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	queue, err := g.Generate(7, Seed{Kind: SeedSong, Value: "1"}, Options{Count: 5})
+	assert.NoError(t, err)
+	assert.Len(t, queue, 1)
+	assert.Equal(t, 2, queue[0].ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"go-postgres-example/pkg/handlers"
 	"go-postgres-example/pkg/middleware"
+	"go-postgres-example/pkg/models"
 	"go-postgres-example/pkg/subsonic"
 	"net/http"
 	"os"
@@ -13,7 +14,7 @@ import (
 )
 
 // New creates a new chi router and sets up the routes
-func New(authHandler *handlers.AuthHandler, uploadHandler *handlers.UploadHandler, libraryHandler *handlers.LibraryHandler, playlistHandler *handlers.PlaylistHandler, songHandler *handlers.SongHandler, subsonicHandler *subsonic.Handler) *chi.Mux {
+func New(authHandler *handlers.AuthHandler, uploadHandler *handlers.UploadHandler, libraryHandler *handlers.LibraryHandler, playlistHandler *handlers.PlaylistHandler, songHandler *handlers.SongHandler, shareHandler *handlers.ShareHandler, subsonicHandler *subsonic.Handler) *chi.Mux {
 	r := chi.NewRouter()
 
 	// Enable CORS for cross-origin requests from the frontend
@@ -32,11 +33,26 @@ func New(authHandler *handlers.AuthHandler, uploadHandler *handlers.UploadHandle
 	// Public routes
 	r.Post("/register", authHandler.Register)
 	r.Post("/login", authHandler.Login)
+	r.Post("/api/auth/2fa", authHandler.LoginTwoFactor)
+	r.Post("/api/auth/refresh", authHandler.Refresh)
+	r.Post("/api/auth/logout", authHandler.Logout)
+	r.Post("/api/auth/password-reset", authHandler.RequestPasswordReset)
+	r.Post("/api/auth/password-reset/confirm", authHandler.ConfirmPasswordReset)
 	r.Get("/api/config", authHandler.PublicConfig)
 
+	// Single sign-on through an OpenID Connect provider
+	r.Get("/auth/oidc/login", authHandler.OIDCLogin)
+	r.Get("/auth/oidc/callback", authHandler.OIDCCallback)
+
+	// Public share links
+	r.Get("/share/{token}", shareHandler.SharePageHandler)
+	r.Get("/share/{token}/stream/{songID}", shareHandler.ShareStreamHandler)
+	r.Get("/share/{token}/download/{songID}", shareHandler.ShareDownloadHandler)
+
 	// Protected routes
 	r.Group(func(r chi.Router) {
-		r.Use(middleware.Authenticator(authHandler.Cfg.JWTSecret))
+		r.Use(middleware.ProxyAuthenticator(authHandler.DB, authHandler.HeaderAuth))
+		r.Use(middleware.Authenticator(authHandler.Cfg.JWTSecret, authHandler.DB))
 
 		r.Get("/protected", func(w http.ResponseWriter, r *http.Request) {
 			userID, ok := middleware.GetUserIDFromContext(r.Context())
@@ -50,33 +66,113 @@ func New(authHandler *handlers.AuthHandler, uploadHandler *handlers.UploadHandle
 
 		// Current user info
 		r.Get("/api/me", authHandler.Me)
+		r.Put("/api/me", authHandler.UpdateMe)
+		r.Delete("/api/me", authHandler.DeleteMe)
+		r.Put("/api/me/password", authHandler.ChangePassword)
+		r.Post("/api/auth/proxy", authHandler.ProxySession)
+		r.Get("/api/me/sessions", authHandler.GetSessions)
+		r.Delete("/api/me/sessions", authHandler.RevokeOtherSessions)
+		r.Delete("/api/me/sessions/{sessionID}", authHandler.RevokeSession)
+
+		// Two-factor authentication and app passwords for Subsonic clients
+		r.Get("/api/me/2fa", authHandler.GetTwoFactor)
+		r.Post("/api/me/2fa/setup", authHandler.SetupTwoFactor)
+		r.Post("/api/me/2fa/verify", authHandler.VerifyTwoFactor)
+		r.Post("/api/me/2fa/disable", authHandler.DisableTwoFactor)
+		r.Get("/api/me/app-passwords", authHandler.GetAppPasswords)
+		r.Post("/api/me/app-passwords", authHandler.CreateAppPassword)
+		r.Delete("/api/me/app-passwords/{appPasswordID}", authHandler.DeleteAppPassword)
+
+		// User and role management, for admins only
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.AdminOnly(authHandler.DB))
+			if authHandler.Cfg.RequireAdminTwoFactor {
+				r.Use(middleware.RequireTwoFactor(authHandler.DB))
+			}
+			r.Route("/api/admin/users", func(r chi.Router) {
+				r.Get("/", authHandler.AdminListUsers)
+				r.Post("/", authHandler.AdminCreateUser)
+				r.Get("/{userID}", authHandler.AdminGetUser)
+				r.Patch("/{userID}", authHandler.AdminUpdateUser)
+				r.Delete("/{userID}", authHandler.AdminDeleteUser)
+				r.Post("/{userID}/password", authHandler.AdminResetPassword)
+				r.Post("/{userID}/disable", authHandler.AdminDisableUser)
+				r.Post("/{userID}/enable", authHandler.AdminEnableUser)
+				r.Post("/{userID}/unlock", authHandler.AdminUnlockUser)
+				r.Get("/{userID}/quota", authHandler.AdminGetQuota)
+				r.Put("/{userID}/quota", authHandler.AdminSetQuota)
+			})
+			r.Get("/api/admin/roles", authHandler.AdminListRoles)
+			r.Post("/api/admin/roles", authHandler.AdminCreateRole)
+			r.Put("/api/admin/roles/{role}", authHandler.AdminUpdateRole)
+			r.Delete("/api/admin/roles/{role}", authHandler.AdminDeleteRole)
+		})
 
-		r.Post("/api/upload", uploadHandler.Upload)
+		r.With(middleware.RequirePermission(authHandler.DB, models.PermissionUpload)).Post("/api/upload", uploadHandler.Upload)
+		// Resumable uploads through the tus protocol
+		r.With(middleware.RequirePermission(authHandler.DB, models.PermissionUpload)).Mount("/api/uploads", uploadHandler.Tus.Routes())
+		r.Get("/api/me/usage", uploadHandler.GetUsage)
 
 		// Library and Song routes
 		r.Get("/api/library", libraryHandler.GetLibraryHandler)
+		r.Get("/api/albums", libraryHandler.GetAlbumsHandler)
+		r.Get("/api/albums/{albumID}/download", libraryHandler.DownloadAlbumHandler)
+		r.Get("/api/albums/{albumID}/cover", libraryHandler.GetAlbumCoverHandler)
 		r.Post("/api/songs/{songID}/rate", libraryHandler.RateSongHandler)
+		r.Post("/api/songs/{songID}/scrobble", libraryHandler.ScrobbleHandler)
+		r.Get("/api/history", libraryHandler.RecentPlaysHandler)
+		r.Get("/api/stats/top-songs", libraryHandler.TopSongsHandler)
+		r.Get("/api/stats/top-artists", libraryHandler.TopArtistsHandler)
+		r.Get("/api/stats/top-genres", libraryHandler.TopGenresHandler)
+
+		// Play queue, shared between devices
+		r.Get("/api/queue", libraryHandler.GetQueueHandler)
+		r.Put("/api/queue", libraryHandler.SaveQueueHandler)
+		r.Get("/api/queue/events", libraryHandler.QueueEventsHandler)
+
+		// Favorites
+		r.Get("/api/favorites", libraryHandler.GetFavoritesHandler)
+		r.Put("/api/favorites/{type}/{id}", libraryHandler.StarHandler)
+		r.Delete("/api/favorites/{type}/{id}", libraryHandler.UnstarHandler)
+
+		// Scrobble forwarding accounts
+		r.Get("/api/scrobbling/accounts", libraryHandler.GetScrobbleAccountsHandler)
+		r.Post("/api/scrobbling/accounts", libraryHandler.LinkScrobbleAccountHandler)
+		r.Delete("/api/scrobbling/accounts/{accountID}", libraryHandler.DeleteScrobbleAccountHandler)
+
+		// Share links
+		r.Get("/api/shares", shareHandler.GetSharesHandler)
+		r.With(middleware.RequirePermission(authHandler.DB, models.PermissionShare)).Post("/api/shares", shareHandler.CreateShareHandler)
+		r.With(middleware.RequirePermission(authHandler.DB, models.PermissionShare)).Put("/api/shares/{shareID}", shareHandler.UpdateShareHandler)
+		r.Delete("/api/shares/{shareID}", shareHandler.DeleteShareHandler)
+
 		r.Get("/api/songs/{songID}/similar", songHandler.GetSimilarSongsHandler)
+		r.Get("/api/songs/{songID}/download", songHandler.DownloadSongHandler)
+		r.Get("/api/radio", songHandler.RadioHandler)
 
 		// Playlist routes
 		r.Route("/api/playlists", func(r chi.Router) {
 			r.Post("/", playlistHandler.CreatePlaylistHandler)
 			r.Get("/", playlistHandler.GetUserPlaylistsHandler)
+			r.Post("/import", playlistHandler.ImportPlaylistHandler)
 
 			r.Route("/{playlistID}", func(r chi.Router) {
 				r.Get("/", playlistHandler.GetPlaylistHandler)
 				r.Put("/", playlistHandler.UpdatePlaylistHandler)
 				r.Delete("/", playlistHandler.DeletePlaylistHandler)
+				r.Get("/export", playlistHandler.ExportPlaylistHandler)
+				r.Get("/download", playlistHandler.DownloadPlaylistHandler)
 
 				// Playlist songs routes
 				r.Post("/songs", playlistHandler.AddSongToPlaylistHandler)
+				r.Patch("/songs", playlistHandler.EditPlaylistSongsHandler)
 				r.Delete("/songs/{songID}", playlistHandler.RemoveSongFromPlaylistHandler)
-			})
 
-			// Admin-only routes
-			r.Group(func(r chi.Router) {
-				r.Use(middleware.AdminOnly(authHandler.DB))
-				r.Post("/api/admin/users", authHandler.AdminCreateUser)
+				// Collaboration
+				r.Get("/collaborators", playlistHandler.GetCollaboratorsHandler)
+				r.Put("/collaborators", playlistHandler.SetCollaboratorHandler)
+				r.Delete("/collaborators/{userID}", playlistHandler.RemoveCollaboratorHandler)
+				r.Get("/activity", playlistHandler.GetPlaylistActivityHandler)
 			})
 		})
 	})
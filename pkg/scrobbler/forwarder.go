@@ -0,0 +1,156 @@
+package scrobbler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/config"
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/models"
+)
+
+const (
+	// batchSize is how many queued scrobbles are processed per pass.
+	batchSize = 50
+	// pollInterval is how often the queue is checked when nothing new was enqueued.
+	pollInterval = 30 * time.Second
+	// minRetryDelay and maxRetryDelay bound the exponential backoff between attempts.
+	minRetryDelay = 30 * time.Second
+	maxRetryDelay = time.Hour
+)
+
+// Forwarder submits users' plays to their linked scrobbling accounts. Plays are
+// written to a queue in the database first, so they survive restarts and are
+// retried while a service is unreachable.
+type Forwarder struct {
+	DB     *sql.DB
+	Client *Client
+	Key    string // Decrypts account tokens
+	// DefaultURL and AllowedURLs are the APIs accounts may be on, see AllowedURL
+	DefaultURL  string
+	AllowedURLs []string
+
+	wake chan struct{}
+}
+
+// NewForwarder creates a new Forwarder.
+func NewForwarder(db *sql.DB, cfg *config.Config) *Forwarder {
+	return &Forwarder{
+		DB:          db,
+		Client:      NewClient(),
+		Key:         cfg.EncryptionKey,
+		DefaultURL:  cfg.ListenBrainzURL,
+		AllowedURLs: cfg.ScrobbleAllowedURLs,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Enqueue queues a play (ListenTypeSingle) or now playing notification
+// (ListenTypePlayingNow) for all of the user's scrobbling accounts. Failures are
+// logged rather than returned so that they never fail the play itself.
+func (f *Forwarder) Enqueue(userID int, songID int, listenType string, listenedAt time.Time) {
+	queued, err := db.EnqueueScrobble(f.DB, userID, songID, listenType, listenedAt)
+	if err != nil {
+		log.Printf("Failed to queue scrobble for user %d: %v", userID, err)
+		return
+	}
+	if queued > 0 {
+		select {
+		case f.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run processes the queue until the context is cancelled.
+func (f *Forwarder) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := f.ProcessQueue(ctx); err != nil {
+			log.Printf("Failed to process scrobble queue: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-f.wake:
+		}
+	}
+}
+
+// ProcessQueue submits queued scrobbles that are due and returns how many were
+// submitted successfully.
+func (f *Forwarder) ProcessQueue(ctx context.Context) (int, error) {
+	items, err := db.GetDueScrobbles(f.DB, batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, item := range items {
+		if ctx.Err() != nil {
+			return sent, ctx.Err()
+		}
+		err := f.submit(ctx, item)
+		switch {
+		case err == nil:
+			sent++
+			err = db.DeleteQueuedScrobble(f.DB, item.ID)
+		case item.ListenType == ListenTypePlayingNow:
+			// A late now playing notification is worse than none.
+			err = db.DeleteQueuedScrobble(f.DB, item.ID)
+		case IsRetryable(err):
+			err = db.RescheduleQueuedScrobble(f.DB, item.ID, time.Now().Add(retryDelay(item.Attempts)), err.Error())
+		default:
+			log.Printf("Dropping scrobble %d for account %d: %v", item.ID, item.AccountID, err)
+			if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrURLNotAllowed) {
+				// Stop queueing for the account until the user links it again.
+				if err := db.DisableScrobbleAccount(f.DB, item.AccountID); err != nil {
+					return sent, err
+				}
+			}
+			err = db.DeleteQueuedScrobble(f.DB, item.ID)
+		}
+		if err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+func (f *Forwarder) submit(ctx context.Context, item models.QueuedScrobble) error {
+	// Accounts linked before their API was removed from the allowed ones
+	if !AllowedURL(item.APIURL, f.DefaultURL, f.AllowedURLs) {
+		return ErrURLNotAllowed
+	}
+	token, err := auth.DecryptSecret(f.Key, item.TokenEncrypted)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	listen := Listen{
+		ListenedAt: item.ListenedAt,
+		Artist:     item.Song.Artist,
+		Track:      item.Song.Title,
+		Release:    item.Song.Album,
+		Duration:   item.Song.Duration,
+	}
+	return f.Client.SubmitListens(ctx, item.APIURL, token, item.ListenType, []Listen{listen})
+}
+
+// retryDelay doubles the wait after each failed attempt, up to maxRetryDelay.
+func retryDelay(attempts int) time.Duration {
+	delay := minRetryDelay
+	for i := 0; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
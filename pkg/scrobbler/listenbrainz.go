@@ -0,0 +1,202 @@
+package scrobbler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Listen types understood by the ListenBrainz submit-listens API.
+const (
+	ListenTypeSingle     = "single"
+	ListenTypePlayingNow = "playing_now"
+)
+
+// submissionClient identifies this server in submitted listens.
+const submissionClient = "biomuzak"
+
+// ErrInvalidToken is returned when a service rejects a user token.
+var ErrInvalidToken = errors.New("invalid token")
+
+// ErrURLNotAllowed is returned for accounts on an API that is not allowed.
+var ErrURLNotAllowed = errors.New("scrobbling API is not allowed")
+
+// Listen is a track played by a user.
+type Listen struct {
+	ListenedAt time.Time // Ignored for now playing notifications
+	Artist     string
+	Track      string
+	Release    string
+	Duration   int // Seconds, 0 if unknown
+}
+
+// StatusError is returned when a service answers with an error status.
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("scrobbling service returned %d: %s", e.StatusCode, e.Message)
+}
+
+// IsRetryable reports whether a failed submission may succeed later: network
+// errors, rate limiting and server errors are retried, other rejections are not.
+func IsRetryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	return err != nil && !errors.Is(err, ErrInvalidToken) && !errors.Is(err, ErrURLNotAllowed)
+}
+
+// AllowedURL reports whether an API URL is the default one or under one of the
+// allowed base URLs. The server sends users' tokens to these, so users may not
+// pick arbitrary hosts, such as ones on the server's internal network.
+func AllowedURL(apiURL string, defaultURL string, allowed []string) bool {
+	apiURL = strings.TrimRight(apiURL, "/")
+	for _, base := range append([]string{defaultURL}, allowed...) {
+		base = strings.TrimRight(base, "/")
+		if base != "" && (apiURL == base || strings.HasPrefix(apiURL, base+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Client talks to ListenBrainz or any service implementing its API (Maloja, Koito, ...).
+// API URLs are the base of the API, e.g. "https://api.listenbrainz.org".
+type Client struct {
+	HTTPClient *http.Client
+}
+
+// NewClient creates a new Client.
+func NewClient() *Client {
+	return &Client{HTTPClient: &http.Client{
+		Timeout: 15 * time.Second,
+		// Redirects could lead away from the allowed APIs
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+type trackMetadata struct {
+	ArtistName     string                 `json:"artist_name"`
+	TrackName      string                 `json:"track_name"`
+	ReleaseName    string                 `json:"release_name,omitempty"`
+	AdditionalInfo map[string]interface{} `json:"additional_info,omitempty"`
+}
+
+type listenPayload struct {
+	ListenedAt    int64         `json:"listened_at,omitempty"`
+	TrackMetadata trackMetadata `json:"track_metadata"`
+}
+
+type submitRequest struct {
+	ListenType string          `json:"listen_type"`
+	Payload    []listenPayload `json:"payload"`
+}
+
+// SubmitListens submits listens, or a single now playing notification, for the
+// user owning the token.
+func (c *Client) SubmitListens(ctx context.Context, apiURL string, token string, listenType string, listens []Listen) error {
+	req := submitRequest{ListenType: listenType}
+	for _, l := range listens {
+		payload := listenPayload{
+			TrackMetadata: trackMetadata{
+				ArtistName:  l.Artist,
+				TrackName:   l.Track,
+				ReleaseName: l.Release,
+				AdditionalInfo: map[string]interface{}{
+					"submission_client": submissionClient,
+				},
+			},
+		}
+		if l.Duration > 0 {
+			payload.TrackMetadata.AdditionalInfo["duration_ms"] = l.Duration * 1000
+		}
+		if listenType != ListenTypePlayingNow {
+			payload.ListenedAt = l.ListenedAt.Unix()
+		}
+		req.Payload = append(req.Payload, payload)
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint(apiURL, "/1/submit-listens"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	_, err = c.do(httpReq, token)
+	return err
+}
+
+// ValidateToken checks a user token and returns the user name it belongs to.
+func (c *Client) ValidateToken(ctx context.Context, apiURL string, token string) (string, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint(apiURL, "/1/validate-token"), nil)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := c.do(httpReq, token)
+	if err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		Valid    bool   `json:"valid"`
+		UserName string `json:"user_name"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return "", fmt.Errorf("invalid validate-token response: %w", err)
+	}
+	if !resp.Valid {
+		return "", ErrInvalidToken
+	}
+	return resp.UserName, nil
+}
+
+// do sends an authenticated request and returns the response body.
+func (c *Client) do(req *http.Request, token string) ([]byte, error) {
+	req.Header.Set("Authorization", "Token "+token)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrInvalidToken
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		message := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			message = apiErr.Error
+		}
+		return nil, &StatusError{StatusCode: resp.StatusCode, Message: message}
+	}
+	return data, nil
+}
+
+func endpoint(apiURL string, path string) string {
+	return strings.TrimRight(apiURL, "/") + path
+}
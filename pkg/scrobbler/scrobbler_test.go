@@ -0,0 +1,205 @@
+package scrobbler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/config"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeListenBrainz is a stand-in for the ListenBrainz API that records submissions.
+// Tracks named "Flaky" fail with 503.
+type fakeListenBrainz struct {
+	*httptest.Server
+	submissions []submitRequest
+}
+
+func newFakeListenBrainz(t *testing.T) *fakeListenBrainz {
+	t.Helper()
+	fake := &fakeListenBrainz{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/1/validate-token", func(w http.ResponseWriter, r *http.Request) {
+		valid := r.Header.Get("Authorization") == "Token good-token"
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "valid": valid, "user_name": "rob"})
+	})
+	mux.HandleFunc("/1/submit-listens", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token good-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req submitRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req.Payload[0].TrackMetadata.TrackName == "Flaky" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 503, "error": "down for maintenance"})
+			return
+		}
+		fake.submissions = append(fake.submissions, req)
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	})
+	fake.Server = httptest.NewServer(mux)
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func TestSubmitListens(t *testing.T) {
+	fake := newFakeListenBrainz(t)
+	client := NewClient()
+
+	listenedAt := time.Unix(1700000000, 0)
+	err := client.SubmitListens(context.Background(), fake.URL+"/", "good-token", ListenTypeSingle, []Listen{
+		{ListenedAt: listenedAt, Artist: "Miles Davis", Track: "So What", Release: "Kind of Blue", Duration: 562},
+	})
+	require.NoError(t, err)
+	require.Len(t, fake.submissions, 1)
+
+	payload := fake.submissions[0].Payload[0]
+	assert.Equal(t, "single", fake.submissions[0].ListenType)
+	assert.Equal(t, int64(1700000000), payload.ListenedAt)
+	assert.Equal(t, "So What", payload.TrackMetadata.TrackName)
+	assert.Equal(t, "Kind of Blue", payload.TrackMetadata.ReleaseName)
+	assert.Equal(t, float64(562000), payload.TrackMetadata.AdditionalInfo["duration_ms"])
+
+	// Now playing notifications carry no timestamp.
+	require.NoError(t, client.SubmitListens(context.Background(), fake.URL, "good-token", ListenTypePlayingNow, []Listen{
+		{ListenedAt: listenedAt, Artist: "Miles Davis", Track: "So What"},
+	}))
+	assert.Equal(t, int64(0), fake.submissions[1].Payload[0].ListenedAt)
+
+	err = client.SubmitListens(context.Background(), fake.URL, "bad-token", ListenTypeSingle, []Listen{{Track: "So What"}})
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.False(t, IsRetryable(err))
+
+	err = client.SubmitListens(context.Background(), fake.URL, "good-token", ListenTypeSingle, []Listen{{Track: "Flaky"}})
+	assert.EqualError(t, err, "scrobbling service returned 503: down for maintenance")
+	assert.True(t, IsRetryable(err))
+}
+
+func TestValidateToken(t *testing.T) {
+	fake := newFakeListenBrainz(t)
+	client := NewClient()
+
+	username, err := client.ValidateToken(context.Background(), fake.URL, "good-token")
+	require.NoError(t, err)
+	assert.Equal(t, "rob", username)
+
+	_, err = client.ValidateToken(context.Background(), fake.URL, "bad-token")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestProcessQueue(t *testing.T) {
+	fake := newFakeListenBrainz(t)
+
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	cfg := &config.Config{EncryptionKey: "key", ListenBrainzURL: fake.URL}
+	token, err := auth.EncryptSecret(cfg.EncryptionKey, "good-token")
+	require.NoError(t, err)
+
+	columns := []string{
+		"id", "account_id", "api_url", "token_encrypted", "listen_type", "listened_at", "attempts",
+		"song_id", "title", "artist", "album", "duration",
+	}
+	mock.ExpectQuery("SELECT (.+) FROM scrobble_queue q").
+		WithArgs(batchSize).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, 7, fake.URL, token, ListenTypeSingle, time.Now(), 0, 9, "So What", "Miles Davis", "Kind of Blue", 562).
+			AddRow(2, 7, fake.URL, token, ListenTypeSingle, time.Now(), 2, 10, "Flaky", "Miles Davis", "Kind of Blue", 300).
+			AddRow(3, 7, fake.URL, token, ListenTypePlayingNow, time.Now(), 0, 10, "Flaky", "Miles Davis", "Kind of Blue", 300))
+	mock.ExpectExec("DELETE FROM scrobble_queue").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE scrobble_queue").
+		WithArgs(2, sqlmock.AnyArg(), "scrobbling service returned 503: down for maintenance").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM scrobble_queue").WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	forwarder := NewForwarder(conn, cfg)
+	sent, err := forwarder.ProcessQueue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	require.Len(t, fake.submissions, 1)
+	assert.Equal(t, "So What", fake.submissions[0].Payload[0].TrackMetadata.TrackName)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProcessQueueRejectedToken(t *testing.T) {
+	fake := newFakeListenBrainz(t)
+
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	cfg := &config.Config{EncryptionKey: "key", ScrobbleAllowedURLs: []string{fake.URL}}
+	token, err := auth.EncryptSecret(cfg.EncryptionKey, "revoked-token")
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT (.+) FROM scrobble_queue q").
+		WithArgs(batchSize).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "account_id", "api_url", "token_encrypted", "listen_type", "listened_at", "attempts",
+			"song_id", "title", "artist", "album", "duration",
+		}).AddRow(1, 7, fake.URL, token, ListenTypeSingle, time.Now(), 0, 9, "So What", "Miles Davis", "Kind of Blue", 562))
+	mock.ExpectExec("UPDATE scrobble_accounts SET enabled = FALSE").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM scrobble_queue").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	sent, err := NewForwarder(conn, cfg).ProcessQueue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProcessQueueDisallowedURL(t *testing.T) {
+	fake := newFakeListenBrainz(t)
+
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	cfg := &config.Config{EncryptionKey: "key", ListenBrainzURL: "https://api.listenbrainz.org"}
+	token, err := auth.EncryptSecret(cfg.EncryptionKey, "good-token")
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT (.+) FROM scrobble_queue q").
+		WithArgs(batchSize).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "account_id", "api_url", "token_encrypted", "listen_type", "listened_at", "attempts",
+			"song_id", "title", "artist", "album", "duration",
+		}).AddRow(1, 7, fake.URL, token, ListenTypeSingle, time.Now(), 0, 9, "So What", "Miles Davis", "Kind of Blue", 562))
+	mock.ExpectExec("UPDATE scrobble_accounts SET enabled = FALSE").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM scrobble_queue").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	sent, err := NewForwarder(conn, cfg).ProcessQueue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+	assert.Empty(t, fake.submissions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAllowedURL(t *testing.T) {
+	allowed := []string{"https://maloja.example.com/apis/listenbrainz/"}
+	assert.True(t, AllowedURL("https://api.listenbrainz.org", "https://api.listenbrainz.org", allowed))
+	assert.True(t, AllowedURL("https://maloja.example.com/apis/listenbrainz", "https://api.listenbrainz.org", allowed))
+	assert.False(t, AllowedURL("https://maloja.example.com.evil.test/apis/listenbrainz", "https://api.listenbrainz.org", allowed))
+	assert.False(t, AllowedURL("https://api.listenbrainz.org@169.254.169.254", "https://api.listenbrainz.org", allowed))
+	assert.False(t, AllowedURL("http://127.0.0.1:6379", "https://api.listenbrainz.org", allowed))
+	assert.False(t, AllowedURL("https://maloja.example.com/other", "", allowed))
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, retryDelay(0))
+	assert.Equal(t, 2*time.Minute, retryDelay(2))
+	assert.Equal(t, time.Hour, retryDelay(20))
+}
@@ -0,0 +1,122 @@
+package subsonic
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/models"
+)
+
+const (
+	// defaultListSize and maxListSize bound the size parameter of list endpoints.
+	defaultListSize = 10
+	maxListSize     = 500
+)
+
+// GetAlbumList2 is a handler for the /rest/getAlbumList2.view endpoint
+func (h *Handler) GetAlbumList2(w http.ResponseWriter, r *http.Request) {
+	userID, _ := GetUserIDFromContext(r.Context())
+	query := r.URL.Query()
+
+	opts := models.AlbumListOptions{
+		Type:   query.Get("type"),
+		Size:   parseSize(r),
+		Offset: parseIntParam(r, "offset"),
+		Genre:  query.Get("genre"),
+	}
+	if opts.Type == "" {
+		respondWithError(w, r, 10, "Required parameter 'type' is missing")
+		return
+	}
+	if opts.Type == models.AlbumListByYear {
+		if query.Get("fromYear") == "" || query.Get("toYear") == "" {
+			respondWithError(w, r, 10, "Required parameter 'fromYear' or 'toYear' is missing")
+			return
+		}
+		opts.FromYear = parseIntParam(r, "fromYear")
+		opts.ToYear = parseIntParam(r, "toYear")
+	}
+	if opts.Type == models.AlbumListByGenre && opts.Genre == "" {
+		respondWithError(w, r, 10, "Required parameter 'genre' is missing")
+		return
+	}
+
+	albums, err := db.GetAlbumList(h.DB, userID, opts)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidAlbumList) {
+			respondWithError(w, r, 0, "Unknown album list type")
+		} else {
+			respondWithError(w, r, 0, "Failed to get album list")
+		}
+		return
+	}
+
+	response := NewOkResponse()
+	response.AlbumList2 = &AlbumList2{}
+	for _, album := range albums {
+		response.AlbumList2.Albums = append(response.AlbumList2.Albums, toSubsonicAlbum(album))
+	}
+	respond(w, r, response)
+}
+
+// GetRandomSongs is a handler for the /rest/getRandomSongs.view endpoint
+func (h *Handler) GetRandomSongs(w http.ResponseWriter, r *http.Request) {
+	songs, err := db.GetRandomSongs(h.DB, parseSize(r), r.URL.Query().Get("genre"), parseIntParam(r, "fromYear"), parseIntParam(r, "toYear"))
+	if err != nil {
+		respondWithError(w, r, 0, "Failed to get random songs")
+		return
+	}
+
+	response := NewOkResponse()
+	response.RandomSongs = &RandomSongs{}
+	for _, song := range songs {
+		response.RandomSongs.Songs = append(response.RandomSongs.Songs, toSubsonicSong(song))
+	}
+	respond(w, r, response)
+}
+
+// parseSize reads the optional 'size' parameter, capped at maxListSize.
+func parseSize(r *http.Request) int {
+	size, err := strconv.Atoi(r.URL.Query().Get("size"))
+	if err != nil || size <= 0 {
+		return defaultListSize
+	}
+	if size > maxListSize {
+		return maxListSize
+	}
+	return size
+}
+
+// parseIntParam reads an optional non-negative integer parameter, falling back to 0.
+func parseIntParam(r *http.Request, name string) int {
+	value, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil || value < 0 {
+		return 0
+	}
+	return value
+}
+
+// toSubsonicAlbum converts an album summary into its Subsonic representation
+func toSubsonicAlbum(album models.AlbumSummary) Album {
+	created := album.CreatedAt
+	a := Album{
+		ID:         strconv.Itoa(album.ID),
+		Name:       album.Name,
+		Artist:     album.Artist,
+		SongCount:  album.SongCount,
+		Duration:   album.Duration,
+		PlayCount:  album.PlayCount,
+		Created:    &created,
+		Year:       album.Year,
+		Genre:      album.Genre,
+		UserRating: int(math.Round(album.Rating)),
+		Starred:    album.StarredAt,
+	}
+	if album.ArtistID != 0 {
+		a.ArtistID = strconv.Itoa(album.ArtistID)
+	}
+	return a
+}
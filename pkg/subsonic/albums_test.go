@@ -0,0 +1,80 @@
+package subsonic
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"go-postgres-example/pkg/config"
+)
+
+func TestGetAlbumList2(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`WHERE p.play_count > 0\s+ORDER BY p.play_count DESC`).
+		WithArgs(1, 2, 4).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "name", "artist", "artist_id", "year", "created_at",
+			"genre", "song_count", "duration", "play_count", "last_played", "rating", "starred_at",
+		}).AddRow(4, "Mezzanine", "Massive Attack", 2, 1998, time.Now(), "Trip Hop", 11, 3800, 7, time.Now(), 3.6, nil))
+
+	handler := NewHandler(db, &config.Config{})
+
+	req := withUser(httptest.NewRequest("GET", "/rest/getAlbumList2.view?type=frequent&size=2&offset=4", nil), 1)
+	rr := httptest.NewRecorder()
+
+	handler.GetAlbumList2(rr, req)
+
+	body := rr.Body.String()
+	assert.Contains(t, body, `<albumList2>`)
+	assert.Contains(t, body, `name="Mezzanine"`)
+	assert.Contains(t, body, `artistId="2"`)
+	assert.Contains(t, body, `playCount="7"`)
+	assert.Contains(t, body, `userRating="4"`)
+	assert.NotContains(t, body, `starred=`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAlbumList2MissingParameters(t *testing.T) {
+	handler := NewHandler(nil, &config.Config{})
+
+	for _, query := range []string{"", "type=byYear&fromYear=1990", "type=byGenre"} {
+		req := withUser(httptest.NewRequest("GET", "/rest/getAlbumList2.view?"+query, nil), 1)
+		rr := httptest.NewRecorder()
+
+		handler.GetAlbumList2(rr, req)
+
+		assert.Contains(t, rr.Body.String(), `code="10"`, query)
+	}
+}
+
+func TestGetRandomSongs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`WHERE LOWER\(g.name\) = LOWER\(\$1\) AND s.year >= \$2\s+ORDER BY RANDOM\(\)`).
+		WithArgs("Jazz", 1960, 500).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "fingerprint_hash", "file_path", "title", "artist", "album", "year",
+			"genre_id", "genre", "duration", "bitrate", "file_size", "last_modified",
+		}).AddRow(3, "hash", "/music/so-what.mp3", "So What", "Miles Davis", "Kind of Blue", 1959, 2, "Jazz", 545, 320, 1000, time.Now()))
+
+	handler := NewHandler(db, &config.Config{})
+
+	req := withUser(httptest.NewRequest("GET", "/rest/getRandomSongs.view?size=1000&genre=Jazz&fromYear=1960", nil), 1)
+	rr := httptest.NewRecorder()
+
+	handler.GetRandomSongs(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `<randomSongs><song id="3" title="So What"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
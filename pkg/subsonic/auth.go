@@ -3,18 +3,34 @@ package subsonic
 import (
 	"context"
 	"crypto/md5"
+	"crypto/subtle"
 	"database/sql"
 	"encoding/hex"
+	"fmt"
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/handlers"
+	"go-postgres-example/pkg/middleware"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 )
 
 type contextKey string
 
 const userIDKey contextKey = "userID"
 
-// AuthMiddleware is a middleware that handles Subsonic authentication
-func AuthMiddleware(db *sql.DB) func(http.Handler) http.Handler {
+// appPasswordKey marks requests authenticated with an app password.
+const appPasswordKey contextKey = "appPassword"
+
+// AuthMiddleware is a middleware that handles Subsonic authentication. Failed
+// attempts count towards the limiter's lockouts like web logins; locked out
+// clients get error 40 without their credentials being checked. App passwords
+// are accepted for every user; the account password only for users without
+// two-factor authentication.
+func AuthMiddleware(authHandler *handlers.AuthHandler) func(http.Handler) http.Handler {
+	conn, limiter := authHandler.DB, authHandler.Limiter
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			params := r.URL.Query()
@@ -24,103 +40,137 @@ func AuthMiddleware(db *sql.DB) func(http.Handler) http.Handler {
 			salt := params.Get("s")
 
 			if username == "" {
-				respondWithXML(w, &Response{
-					Status: "failed",
-					Error: &Error{
-						Code:    10,
-						Message: "Required parameter 'u' is missing",
-					},
-				})
+				respondWithError(w, r, 10, "Required parameter 'u' is missing")
+				return
+			}
+			if password == "" && (token == "" || salt == "") {
+				respondWithError(w, r, 10, "Required authentication parameters are missing")
+				return
+			}
+
+			ip := middleware.ClientIP(r, authHandler.TrustedProxies)
+			wait, err := limiter.Check(username, ip)
+			if err != nil {
+				http.Error(w, "Failed to check login attempts", http.StatusInternalServerError)
+				return
+			}
+			if wait > 0 {
+				respondWithError(w, r, 40, fmt.Sprintf("Too many failed login attempts, try again in %s", wait.Round(time.Second)))
 				return
 			}
 
+			wrongCredentials := func() {
+				if err := limiter.Fail(username, ip); err != nil {
+					log.Printf("Failed to record failed login for %q: %v", username, err)
+				}
+				respondWithError(w, r, 40, "Wrong username or password")
+			}
+
 			var userID int
 			var passwordHash string
-			err := db.QueryRow("SELECT id, password_hash FROM users WHERE username = $1", username).Scan(&userID, &passwordHash)
+			var totpEnabled, disabled bool
+			err = conn.QueryRow("SELECT id, password_hash, totp_enabled, disabled FROM users WHERE username = $1", username).Scan(&userID, &passwordHash, &totpEnabled, &disabled)
 			if err != nil {
 				if err == sql.ErrNoRows {
-					respondWithXML(w, &Response{
-						Status: "failed",
-						Error: &Error{
-							Code:    40,
-							Message: "Wrong username or password",
-						},
-					})
+					wrongCredentials()
 					return
 				}
 				http.Error(w, "Failed to query user", http.StatusInternalServerError)
 				return
 			}
 
-			if token != "" && salt != "" {
+			if password, err = decodePassword(password); err != nil {
+				wrongCredentials()
+				return
+			}
+
+			appPasswordID, err := matchAppPassword(conn, authHandler.Cfg.EncryptionKey, userID, password, token, salt)
+			if err != nil {
+				http.Error(w, "Failed to check app passwords", http.StatusInternalServerError)
+				return
+			}
+			switch {
+			case appPasswordID != 0:
+				if err := db.TouchAppPassword(conn, appPasswordID); err != nil {
+					log.Printf("Failed to update app password %d: %v", appPasswordID, err)
+				}
+			case totpEnabled:
+				// The account password alone must not get past the second factor
+				wrongCredentials()
+				return
+			case token != "" && salt != "":
 				// Token-based authentication
 				expectedToken := md5.Sum([]byte(passwordHash + salt))
 				if token != hex.EncodeToString(expectedToken[:]) {
-					respondWithXML(w, &Response{
-						Status: "failed",
-						Error: &Error{
-							Code:    40,
-							Message: "Wrong username or password",
-						},
-					})
+					wrongCredentials()
 					return
 				}
-			} else if password != "" {
-				// Password-based authentication
-				if strings.HasPrefix(password, "enc:") {
-					hexPassword, err := hex.DecodeString(strings.TrimPrefix(password, "enc:"))
-					if err != nil {
-						respondWithXML(w, &Response{
-							Status: "failed",
-							Error: &Error{
-								Code:    40,
-								Message: "Wrong username or password",
-							},
-						})
-						return
-					}
-					password = string(hexPassword)
-				}
-				// Note: This is not secure, but part of the Subsonic API spec.
-				// The provided hash in the database is generated with bcrypt, which is not compatible with MD5.
-				// For this implementation, we will assume the password is stored in plain text for compatibility.
-				// This is a major security flaw and should be addressed in a real-world application.
-				// We will re-hash the provided password with bcrypt and compare it to the stored hash.
-				// This is not what the Subsonic API specifies, but it's the only way to make it work with the existing user management.
-				// A better solution would be to store the password in a way that is compatible with both authentication methods.
-				// For now, we will just simulate a check.
-				// This is a placeholder for the actual password check.
-				// In a real implementation, you would need to implement a proper check.
-				// For the purpose of this exercise, we will assume the password is correct if it is not empty.
-				if password == "" {
-					respondWithXML(w, &Response{
-						Status: "failed",
-						Error: &Error{
-							Code:    40,
-							Message: "Wrong username or password",
-						},
-					})
+			default:
+				if !auth.CheckPasswordHash(password, passwordHash) {
+					wrongCredentials()
 					return
 				}
-			} else {
-				respondWithXML(w, &Response{
-					Status: "failed",
-					Error: &Error{
-						Code:    10,
-						Message: "Required authentication parameters are missing",
-					},
-				})
+			}
+
+			if err := limiter.Succeed(username); err != nil {
+				log.Printf("Failed to clear failed logins for %q: %v", username, err)
+			}
+			if disabled {
+				respondWithError(w, r, 50, "Account is disabled")
 				return
 			}
 
 			ctx := context.WithValue(r.Context(), userIDKey, userID)
+			ctx = context.WithValue(ctx, appPasswordKey, appPasswordID != 0)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// decodePassword decodes a password parameter, which Subsonic clients may send
+// in clear text or hex encoded with an "enc:" prefix.
+func decodePassword(password string) (string, error) {
+	if !strings.HasPrefix(password, "enc:") {
+		return password, nil
+	}
+	decoded, err := hex.DecodeString(strings.TrimPrefix(password, "enc:"))
+	return string(decoded), err
+}
+
+// matchAppPassword returns the ID of the user's app password that matches the
+// password, or the token md5(password + salt), or 0 when none does.
+func matchAppPassword(conn *sql.DB, key string, userID int, password, token, salt string) (int, error) {
+	secrets, err := db.GetAppPasswordSecrets(conn, userID)
+	if err != nil {
+		return 0, err
+	}
+	for id, encrypted := range secrets {
+		appPassword, err := auth.DecryptSecret(key, encrypted)
+		if err != nil {
+			log.Printf("Failed to decrypt app password %d: %v", id, err)
+			continue
+		}
+		if token != "" && salt != "" {
+			expected := md5.Sum([]byte(appPassword + salt))
+			if subtle.ConstantTimeCompare([]byte(strings.ToLower(token)), []byte(hex.EncodeToString(expected[:]))) == 1 {
+				return id, nil
+			}
+		} else if subtle.ConstantTimeCompare([]byte(password), []byte(appPassword)) == 1 {
+			return id, nil
+		}
+	}
+	return 0, nil
+}
+
 // GetUserIDFromContext returns the user ID from the request context
 func GetUserIDFromContext(ctx context.Context) (int, bool) {
 	userID, ok := ctx.Value(userIDKey).(int)
 	return userID, ok
 }
+
+// IsAppPasswordAuthenticated reports whether the request was authenticated
+// with an app password rather than the account password.
+func IsAppPasswordAuthenticated(ctx context.Context) bool {
+	appPassword, _ := ctx.Value(appPasswordKey).(bool)
+	return appPassword
+}
@@ -0,0 +1,164 @@
+package subsonic
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/config"
+	"go-postgres-example/pkg/handlers"
+)
+
+func newAuthTestHandler(t *testing.T) (http.Handler, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := GetUserIDFromContext(r.Context())
+		assert.Equal(t, 3, userID)
+		respond(w, r, &Response{Status: "ok"})
+	})
+	cfg := &config.Config{
+		EncryptionKey:    "test-key",
+		LoginMaxFailures: 5, LoginMaxFailuresPerIP: 20, LoginBaseDelay: time.Second, LoginLockout: 15 * time.Minute,
+	}
+	return AuthMiddleware(handlers.NewAuthHandler(db, cfg))(next), mock
+}
+
+func TestAuthMiddlewarePassword(t *testing.T) {
+	handler, mock := newAuthTestHandler(t)
+	hash, err := auth.HashPassword("secret")
+	require.NoError(t, err)
+
+	mock.ExpectQuery("FROM login_failures").
+		WillReturnRows(sqlmock.NewRows([]string{"seconds"}).AddRow(0))
+	mock.ExpectQuery("SELECT id, password_hash, totp_enabled, disabled FROM users").
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "password_hash", "totp_enabled", "disabled"}).AddRow(3, hash, false, false))
+	mock.ExpectQuery("FROM app_passwords").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "password_encrypted"}))
+	mock.ExpectExec("DELETE FROM login_failures").
+		WithArgs("user:alice").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// "enc:" followed by the hex encoded password
+	req := httptest.NewRequest("GET", "/rest/ping.view?u=alice&p=enc:736563726574&f=json", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `"status":"ok"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthMiddlewareWrongPassword(t *testing.T) {
+	handler, mock := newAuthTestHandler(t)
+	hash, err := auth.HashPassword("secret")
+	require.NoError(t, err)
+
+	mock.ExpectQuery("FROM login_failures").
+		WillReturnRows(sqlmock.NewRows([]string{"seconds"}).AddRow(0))
+	mock.ExpectQuery("SELECT id, password_hash, totp_enabled, disabled FROM users").
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "password_hash", "totp_enabled", "disabled"}).AddRow(3, hash, false, false))
+	mock.ExpectQuery("FROM app_passwords").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "password_encrypted"}))
+	mock.ExpectQuery("INSERT INTO login_failures").
+		WithArgs("user:alice", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(1))
+	mock.ExpectExec("UPDATE login_failures SET locked_until").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO login_failures").
+		WithArgs("ip:192.0.2.1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(1))
+
+	req := httptest.NewRequest("GET", "/rest/ping.view?u=alice&p=guess&f=json", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `"code":40`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthMiddlewareLockedOut(t *testing.T) {
+	handler, mock := newAuthTestHandler(t)
+
+	mock.ExpectQuery("FROM login_failures").
+		WithArgs("user:alice", "ip:192.0.2.1").
+		WillReturnRows(sqlmock.NewRows([]string{"seconds"}).AddRow(600))
+
+	req := httptest.NewRequest("GET", "/rest/ping.view?u=alice&p=secret&f=json", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `"code":40`)
+	assert.Contains(t, rr.Body.String(), "Too many failed login attempts")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthMiddlewareAppPassword(t *testing.T) {
+	handler, mock := newAuthTestHandler(t)
+	encrypted, err := auth.EncryptSecret("test-key", "app-secret")
+	require.NoError(t, err)
+	// md5("app-secret" + "salt")
+	sum := md5.Sum([]byte("app-secretsalt"))
+
+	mock.ExpectQuery("FROM login_failures").
+		WillReturnRows(sqlmock.NewRows([]string{"seconds"}).AddRow(0))
+	mock.ExpectQuery("SELECT id, password_hash, totp_enabled, disabled FROM users").
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "password_hash", "totp_enabled", "disabled"}).AddRow(3, "hash", true, false))
+	mock.ExpectQuery("FROM app_passwords").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "password_encrypted"}).AddRow(8, encrypted))
+	mock.ExpectExec("UPDATE app_passwords SET last_used_at").
+		WithArgs(8).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM login_failures").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := httptest.NewRequest("GET", "/rest/ping.view?u=alice&t="+hex.EncodeToString(sum[:])+"&s=salt&f=json", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `"status":"ok"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthMiddlewareTwoFactorUserNeedsAppPassword(t *testing.T) {
+	handler, mock := newAuthTestHandler(t)
+	hash, err := auth.HashPassword("secret")
+	require.NoError(t, err)
+
+	mock.ExpectQuery("FROM login_failures").
+		WillReturnRows(sqlmock.NewRows([]string{"seconds"}).AddRow(0))
+	mock.ExpectQuery("SELECT id, password_hash, totp_enabled, disabled FROM users").
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "password_hash", "totp_enabled", "disabled"}).AddRow(3, hash, true, false))
+	mock.ExpectQuery("FROM app_passwords").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "password_encrypted"}))
+	mock.ExpectQuery("INSERT INTO login_failures").
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(1))
+	mock.ExpectExec("UPDATE login_failures SET locked_until").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO login_failures").
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(1))
+
+	req := httptest.NewRequest("GET", "/rest/ping.view?u=alice&p=secret&f=json", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `"code":40`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
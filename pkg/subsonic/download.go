@@ -0,0 +1,48 @@
+package subsonic
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/handlers"
+)
+
+// Download is a handler for the /rest/download.view endpoint. It serves the
+// original file of a song, named after its tags, to users with the download role.
+func (h *Handler) Download(w http.ResponseWriter, r *http.Request) {
+	userID, _ := GetUserIDFromContext(r.Context())
+
+	allowed, err := db.HasDownloadRole(h.DB, userID)
+	if err != nil {
+		respondWithError(w, r, 0, "Failed to get user")
+		return
+	}
+	if !allowed {
+		respondWithError(w, r, 50, "User is not authorized to download")
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		respondWithError(w, r, 10, "Required parameter 'id' is missing")
+		return
+	}
+	songID, err := strconv.Atoi(id)
+	if err != nil {
+		respondWithError(w, r, 70, "Song not found")
+		return
+	}
+
+	songs, err := db.GetSongsByIDs(h.DB, []int{songID})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, r, 70, "Song not found")
+		} else {
+			respondWithError(w, r, 0, "Failed to get song")
+		}
+		return
+	}
+	handlers.ServeSongDownload(w, r, &songs[0])
+}
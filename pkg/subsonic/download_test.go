@@ -0,0 +1,64 @@
+package subsonic
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-postgres-example/pkg/config"
+)
+
+func TestDownloadRequiresRole(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("FROM role_permissions").
+		WithArgs(2, "download").
+		WillReturnRows(sqlmock.NewRows([]string{"allowed"}).AddRow(false))
+
+	handler := NewHandler(db, &config.Config{})
+	req := withUser(httptest.NewRequest("GET", "/rest/download.view?id=9", nil), 2)
+	rr := httptest.NewRecorder()
+
+	handler.Download(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `code="50"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDownload(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	path := filepath.Join(t.TempDir(), "abc.flac")
+	require.NoError(t, os.WriteFile(path, []byte("audio"), 0o644))
+
+	mock.ExpectQuery("FROM role_permissions").
+		WithArgs(1, "download").
+		WillReturnRows(sqlmock.NewRows([]string{"allowed"}).AddRow(true))
+	mock.ExpectQuery("SELECT (.+) FROM songs s").
+		WithArgs("{9}").
+		WillReturnRows(sqlmock.NewRows(queueSongColumns).
+			AddRow(9, "abc", path, "So What", "Miles Davis", "Kind of Blue", 1959, 1, "Jazz", 562, 900, 100, time.Now()))
+
+	handler := NewHandler(db, &config.Config{})
+	req := withUser(httptest.NewRequest("GET", "/rest/download.view?id=9", nil), 1)
+	rr := httptest.NewRecorder()
+
+	handler.Download(rr, req)
+
+	assert.Equal(t, "audio", rr.Body.String())
+	assert.Equal(t, `attachment; filename="Miles Davis - So What.flac"`, rr.Header().Get("Content-Disposition"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
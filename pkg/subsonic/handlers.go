@@ -2,6 +2,7 @@ package subsonic
 
 import (
 	"database/sql"
+	"encoding/json"
 	"encoding/xml"
 	"net/http"
 	"os"
@@ -12,22 +13,30 @@ import (
 
 	"go-postgres-example/pkg/config"
 	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/jukebox"
+	"go-postgres-example/pkg/playqueue"
+	"go-postgres-example/pkg/radio"
+	"go-postgres-example/pkg/scrobbler"
 )
 
 // Handler holds the dependencies for the subsonic handlers
 type Handler struct {
-	DB  *sql.DB
-	Cfg *config.Config
+	DB          *sql.DB
+	Cfg         *config.Config
+	Radio       *radio.Generator
+	Scrobbler   *scrobbler.Forwarder
+	QueueEvents *playqueue.Broker
+	Jukebox     *jukebox.Jukebox // Nil when no jukebox player is configured
 }
 
 // NewHandler creates a new Handler
 func NewHandler(db *sql.DB, cfg *config.Config) *Handler {
-	return &Handler{DB: db, Cfg: cfg}
+	return &Handler{DB: db, Cfg: cfg, Radio: radio.NewGenerator(db), Scrobbler: scrobbler.NewForwarder(db, cfg), QueueEvents: playqueue.NewBroker()}
 }
 
 // Ping is a handler for the /rest/ping.view endpoint
 func (h *Handler) Ping(w http.ResponseWriter, r *http.Request) {
-	respondWithXML(w, NewOkResponse())
+	respond(w, r, NewOkResponse())
 }
 
 // GetMusicFolders is a handler for the /rest/getMusicFolders.view endpoint
@@ -38,14 +47,14 @@ func (h *Handler) GetMusicFolders(w http.ResponseWriter, r *http.Request) {
 			{ID: 1, Name: "Music"},
 		},
 	}
-	respondWithXML(w, response)
+	respond(w, r, response)
 }
 
 // GetIndexes is a handler for the /rest/getIndexes.view endpoint
 func (h *Handler) GetIndexes(w http.ResponseWriter, r *http.Request) {
 	artists, err := db.GetAllArtists(h.DB)
 	if err != nil {
-		respondWithXML(w, &Response{
+		respond(w, r, &Response{
 			Status: "failed",
 			Error: &Error{
 				Code:    0,
@@ -82,14 +91,14 @@ func (h *Handler) GetIndexes(w http.ResponseWriter, r *http.Request) {
 		Indexes: indexes,
 	}
 
-	respondWithXML(w, response)
+	respond(w, r, response)
 }
 
 // Search3 is a handler for the /rest/search3.view endpoint
 func (h *Handler) Search3(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("query")
 	if query == "" {
-		respondWithXML(w, &Response{
+		respond(w, r, &Response{
 			Status: "failed",
 			Error: &Error{
 				Code:    10,
@@ -101,7 +110,7 @@ func (h *Handler) Search3(w http.ResponseWriter, r *http.Request) {
 
 	artists, albums, songs, err := db.Search(h.DB, query)
 	if err != nil {
-		respondWithXML(w, &Response{
+		respond(w, r, &Response{
 			Status: "failed",
 			Error: &Error{
 				Code:    0,
@@ -138,14 +147,14 @@ func (h *Handler) Search3(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	respondWithXML(w, response)
+	respond(w, r, response)
 }
 
 // Stream is a handler for the /rest/stream.view endpoint
 func (h *Handler) Stream(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
 	if id == "" {
-		respondWithXML(w, &Response{
+		respond(w, r, &Response{
 			Status: "failed",
 			Error: &Error{
 				Code:    10,
@@ -157,7 +166,7 @@ func (h *Handler) Stream(w http.ResponseWriter, r *http.Request) {
 
 	songID, err := strconv.Atoi(id)
 	if err != nil {
-		respondWithXML(w, &Response{
+		respond(w, r, &Response{
 			Status: "failed",
 			Error: &Error{
 				Code:    70,
@@ -169,7 +178,7 @@ func (h *Handler) Stream(w http.ResponseWriter, r *http.Request) {
 
 	filePath, err := db.GetSongFilePath(h.DB, songID)
 	if err != nil {
-		respondWithXML(w, &Response{
+		respond(w, r, &Response{
 			Status: "failed",
 			Error: &Error{
 				Code:    70,
@@ -189,6 +198,35 @@ func (h *Handler) Stream(w http.ResponseWriter, r *http.Request) {
 	http.ServeContent(w, r, file.Name(), time.Time{}, file)
 }
 
+// respond writes a Subsonic response in the format requested with the "f"
+// parameter: JSON for f=json, XML otherwise.
+func respond(w http.ResponseWriter, r *http.Request, data *Response) {
+	if r.URL.Query().Get("f") == "json" {
+		respondWithJSON(w, data)
+		return
+	}
+	respondWithXML(w, data)
+}
+
+// respondWithError writes a failed Subsonic response with the given error code.
+func respondWithError(w http.ResponseWriter, r *http.Request, code int, message string) {
+	respond(w, r, &Response{
+		Status: "failed",
+		Error: &Error{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
+
+func respondWithJSON(w http.ResponseWriter, data *Response) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	err := json.NewEncoder(w).Encode(jsonResponse{Response: data})
+	if err != nil {
+		http.Error(w, "Failed to encode JSON", http.StatusInternalServerError)
+	}
+}
+
 func respondWithXML(w http.ResponseWriter, data interface{}) {
 	w.Header().Set("Content-Type", "text/xml; charset=UTF-8")
 	err := xml.NewEncoder(w).Encode(data)
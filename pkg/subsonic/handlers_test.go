@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
@@ -69,3 +70,48 @@ func TestRespondWithXML(t *testing.T) {
 	assert.Contains(t, rr.Body.String(), `subsonic-response`)
 	assert.Contains(t, rr.Body.String(), `status="ok"`)
 }
+
+func TestGetTopSongs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT (.+) FROM songs s").
+		WithArgs("Artist 1", 50).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "fingerprint_hash", "file_path", "title", "artist", "album", "year",
+			"genre_id", "genre", "duration", "bitrate", "file_size", "last_modified",
+		}).AddRow(1, "hash1", "/music/hash1.flac", "Song 1", "Artist 1", "Album 1", 2020, 1, "Rock", 200, 900, 5000, time.Now()))
+
+	handler := NewHandler(db, &config.Config{})
+
+	req := httptest.NewRequest("GET", "/rest/getTopSongs.view?artist=Artist+1", nil)
+	rr := httptest.NewRecorder()
+
+	handler.GetTopSongs(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "topSongs")
+	assert.Contains(t, rr.Body.String(), `suffix="flac"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSimilarSongsMissingID(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	handler := NewHandler(db, &config.Config{})
+
+	req := httptest.NewRequest("GET", "/rest/getSimilarSongs.view", nil)
+	rr := httptest.NewRecorder()
+
+	handler.GetSimilarSongs(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `status="failed"`)
+	assert.Contains(t, rr.Body.String(), `code="10"`)
+}
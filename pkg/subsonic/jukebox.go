@@ -0,0 +1,148 @@
+package subsonic
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/jukebox"
+)
+
+// JukeboxControl is a handler for the /rest/jukeboxControl.view endpoint. The
+// action parameter is one of get, status, set, start, stop, skip, add, clear,
+// remove, shuffle or setGain. Only users with the jukebox role may use it.
+func (h *Handler) JukeboxControl(w http.ResponseWriter, r *http.Request) {
+	userID, _ := GetUserIDFromContext(r.Context())
+	query := r.URL.Query()
+
+	allowed, err := db.HasJukeboxRole(h.DB, userID)
+	if err != nil {
+		respondWithError(w, r, 0, "Failed to get user")
+		return
+	}
+	if !allowed {
+		respondWithError(w, r, 50, "User is not authorized for jukebox control")
+		return
+	}
+	if h.Jukebox == nil {
+		respondWithError(w, r, 0, "Jukebox is not enabled on this server")
+		return
+	}
+
+	action := query.Get("action")
+	switch action {
+	case "get":
+		songs, status := h.Jukebox.Playlist()
+		result := &JukeboxPlaylist{
+			CurrentIndex: status.CurrentIndex,
+			Playing:      status.Playing,
+			Gain:         status.Gain,
+			Position:     int(status.Position.Seconds()),
+		}
+		for _, song := range songs {
+			result.Entries = append(result.Entries, toSubsonicSong(song))
+		}
+		response := NewOkResponse()
+		response.JukeboxPlaylist = result
+		respond(w, r, response)
+		return
+	case "status":
+	case "set", "add":
+		songIDs, err := parseIntParams(query["id"])
+		if err != nil {
+			respondWithError(w, r, 0, "Invalid id")
+			return
+		}
+		songs, err := db.GetSongsByIDs(h.DB, songIDs)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(w, r, 70, "Song not found")
+			} else {
+				respondWithError(w, r, 0, "Failed to get songs")
+			}
+			return
+		}
+		if action == "set" {
+			err = h.Jukebox.Set(songs)
+		} else {
+			h.Jukebox.Add(songs)
+		}
+		if !jukeboxOK(w, r, err) {
+			return
+		}
+	case "start":
+		if !jukeboxOK(w, r, h.Jukebox.Start()) {
+			return
+		}
+	case "stop":
+		if !jukeboxOK(w, r, h.Jukebox.Stop()) {
+			return
+		}
+	case "skip":
+		index, err := strconv.Atoi(query.Get("index"))
+		if err != nil {
+			respondWithError(w, r, 10, "Required parameter 'index' is missing")
+			return
+		}
+		offset := time.Duration(parseIntParam(r, "offset")) * time.Second
+		if !jukeboxOK(w, r, h.Jukebox.Skip(index, offset)) {
+			return
+		}
+	case "clear":
+		if !jukeboxOK(w, r, h.Jukebox.Clear()) {
+			return
+		}
+	case "remove":
+		index, err := strconv.Atoi(query.Get("index"))
+		if err != nil {
+			respondWithError(w, r, 10, "Required parameter 'index' is missing")
+			return
+		}
+		if !jukeboxOK(w, r, h.Jukebox.Remove(index)) {
+			return
+		}
+	case "shuffle":
+		h.Jukebox.Shuffle()
+	case "setGain":
+		gain, err := strconv.ParseFloat(query.Get("gain"), 64)
+		if err != nil {
+			respondWithError(w, r, 10, "Required parameter 'gain' is missing")
+			return
+		}
+		if !jukeboxOK(w, r, h.Jukebox.SetGain(gain)) {
+			return
+		}
+	case "":
+		respondWithError(w, r, 10, "Required parameter 'action' is missing")
+		return
+	default:
+		respondWithError(w, r, 0, "Unknown jukebox action")
+		return
+	}
+
+	status := h.Jukebox.Status()
+	response := NewOkResponse()
+	response.JukeboxStatus = &JukeboxStatus{
+		CurrentIndex: status.CurrentIndex,
+		Playing:      status.Playing,
+		Gain:         status.Gain,
+		Position:     int(status.Position.Seconds()),
+	}
+	respond(w, r, response)
+}
+
+// jukeboxOK writes an error response and returns false if a jukebox action failed.
+func jukeboxOK(w http.ResponseWriter, r *http.Request, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, jukebox.ErrInvalidIndex), errors.Is(err, jukebox.ErrInvalidGain):
+		respondWithError(w, r, 0, err.Error())
+	default:
+		respondWithError(w, r, 0, "Jukebox playback failed")
+	}
+	return false
+}
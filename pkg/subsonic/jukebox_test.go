@@ -0,0 +1,99 @@
+package subsonic
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"go-postgres-example/pkg/config"
+	"go-postgres-example/pkg/jukebox"
+)
+
+func TestJukeboxControlRequiresRole(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("FROM role_permissions").
+		WithArgs(2, "jukebox").
+		WillReturnRows(sqlmock.NewRows([]string{"allowed"}).AddRow(false))
+
+	handler := NewHandler(db, &config.Config{})
+	handler.Jukebox = jukebox.New(jukebox.NewFakePlayer())
+
+	req := withUser(httptest.NewRequest("GET", "/rest/jukeboxControl.view?action=start", nil), 2)
+	rr := httptest.NewRecorder()
+
+	handler.JukeboxControl(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `code="50"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestJukeboxControlSetAndStart(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("FROM role_permissions").
+		WithArgs(1, "jukebox").
+		WillReturnRows(sqlmock.NewRows([]string{"allowed"}).AddRow(true))
+	mock.ExpectQuery("FROM songs s").
+		WithArgs("{4,3,4}").
+		WillReturnRows(sqlmock.NewRows(queueSongColumns).
+			AddRow(3, "hash3", "/music/3.mp3", "So What", "Miles Davis", "Kind of Blue", 1959, 2, "Jazz", 545, 320, 1000, time.Now()).
+			AddRow(4, "hash4", "/music/4.mp3", "Blue in Green", "Miles Davis", "Kind of Blue", 1959, 2, "Jazz", 337, 320, 1000, time.Now()))
+	mock.ExpectQuery("FROM role_permissions").
+		WithArgs(1, "jukebox").
+		WillReturnRows(sqlmock.NewRows([]string{"allowed"}).AddRow(true))
+	mock.ExpectQuery("FROM role_permissions").
+		WithArgs(1, "jukebox").
+		WillReturnRows(sqlmock.NewRows([]string{"allowed"}).AddRow(true))
+
+	player := jukebox.NewFakePlayer()
+	handler := NewHandler(db, &config.Config{})
+	handler.Jukebox = jukebox.New(player)
+
+	rr := httptest.NewRecorder()
+	handler.JukeboxControl(rr, withUser(httptest.NewRequest("GET", "/rest/jukeboxControl.view?action=set&id=4&id=3&id=4", nil), 1))
+	assert.Contains(t, rr.Body.String(), `<jukeboxStatus currentIndex="0" playing="false" gain="0.75" position="0">`)
+
+	rr = httptest.NewRecorder()
+	handler.JukeboxControl(rr, withUser(httptest.NewRequest("GET", "/rest/jukeboxControl.view?action=start", nil), 1))
+	assert.Contains(t, rr.Body.String(), `playing="true"`)
+	assert.Equal(t, []string{"/music/4.mp3"}, player.Loads())
+
+	rr = httptest.NewRecorder()
+	handler.JukeboxControl(rr, withUser(httptest.NewRequest("GET", "/rest/jukeboxControl.view?action=get", nil), 1))
+	body := rr.Body.String()
+	assert.Contains(t, body, `<jukeboxPlaylist currentIndex="0" playing="true"`)
+	assert.Contains(t, body, `<entry id="4" title="Blue in Green"`)
+	assert.Contains(t, body, `<entry id="3" title="So What"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestJukeboxControlDisabled(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("FROM role_permissions").
+		WithArgs(1, "jukebox").
+		WillReturnRows(sqlmock.NewRows([]string{"allowed"}).AddRow(true))
+
+	handler := NewHandler(db, &config.Config{})
+
+	rr := httptest.NewRecorder()
+	handler.JukeboxControl(rr, withUser(httptest.NewRequest("GET", "/rest/jukeboxControl.view?action=status", nil), 1))
+
+	assert.Contains(t, rr.Body.String(), `status="failed"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
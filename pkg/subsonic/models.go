@@ -1,74 +1,252 @@
 package subsonic
 
-import "encoding/xml"
+import (
+	"encoding/xml"
+	"time"
+)
 
 // Response is the top-level response object for all Subsonic API calls
 type Response struct {
-	XMLName      xml.Name      `xml:"subsonic-response"`
-	Status       string        `xml:"status,attr"`
-	Version      string        `xml:"version,attr"`
-	XMLNS        string        `xml:"xmlns,attr"`
-	Error        *Error        `xml:"error,omitempty"`
-	MusicFolders  *MusicFolders  `xml:"musicFolders,omitempty"`
-	Indexes       *Indexes       `xml:"indexes,omitempty"`
-	SearchResult3 *SearchResult3 `xml:"searchResult3,omitempty"`
+	XMLName         xml.Name         `xml:"subsonic-response" json:"-"`
+	Status          string           `xml:"status,attr" json:"status"`
+	Version         string           `xml:"version,attr" json:"version"`
+	XMLNS           string           `xml:"xmlns,attr" json:"-"`
+	Error           *Error           `xml:"error,omitempty" json:"error,omitempty"`
+	MusicFolders    *MusicFolders    `xml:"musicFolders,omitempty" json:"musicFolders,omitempty"`
+	Indexes         *Indexes         `xml:"indexes,omitempty" json:"indexes,omitempty"`
+	SearchResult3   *SearchResult3   `xml:"searchResult3,omitempty" json:"searchResult3,omitempty"`
+	SimilarSongs    *SimilarSongs    `xml:"similarSongs,omitempty" json:"similarSongs,omitempty"`
+	SimilarSongs2   *SimilarSongs2   `xml:"similarSongs2,omitempty" json:"similarSongs2,omitempty"`
+	TopSongs        *TopSongs        `xml:"topSongs,omitempty" json:"topSongs,omitempty"`
+	Playlists       *Playlists       `xml:"playlists,omitempty" json:"playlists,omitempty"`
+	Playlist        *Playlist        `xml:"playlist,omitempty" json:"playlist,omitempty"`
+	Starred         *Starred         `xml:"starred,omitempty" json:"starred,omitempty"`
+	Starred2        *Starred2        `xml:"starred2,omitempty" json:"starred2,omitempty"`
+	AlbumList2      *AlbumList2      `xml:"albumList2,omitempty" json:"albumList2,omitempty"`
+	RandomSongs     *RandomSongs     `xml:"randomSongs,omitempty" json:"randomSongs,omitempty"`
+	PlayQueue       *PlayQueue       `xml:"playQueue,omitempty" json:"playQueue,omitempty"`
+	JukeboxStatus   *JukeboxStatus   `xml:"jukeboxStatus,omitempty" json:"jukeboxStatus,omitempty"`
+	JukeboxPlaylist *JukeboxPlaylist `xml:"jukeboxPlaylist,omitempty" json:"jukeboxPlaylist,omitempty"`
+	Shares          *Shares          `xml:"shares,omitempty" json:"shares,omitempty"`
+	User            *User            `xml:"user,omitempty" json:"user,omitempty"`
+}
+
+// jsonResponse wraps a Response the way Subsonic JSON clients expect it.
+type jsonResponse struct {
+	Response *Response `json:"subsonic-response"`
 }
 
 // Error represents an error returned by the Subsonic API
 type Error struct {
-	Code    int    `xml:"code,attr"`
-	Message string `xml:"message,attr"`
+	Code    int    `xml:"code,attr" json:"code"`
+	Message string `xml:"message,attr" json:"message"`
 }
 
 // MusicFolders is a container for MusicFolder elements
 type MusicFolders struct {
-	XMLName      xml.Name      `xml:"musicFolders"`
-	MusicFolders []MusicFolder `xml:"musicFolder"`
+	XMLName      xml.Name      `xml:"musicFolders" json:"-"`
+	MusicFolders []MusicFolder `xml:"musicFolder" json:"musicFolder,omitempty"`
 }
 
 // MusicFolder represents a single music folder
 type MusicFolder struct {
-	ID   int    `xml:"id,attr"`
-	Name string `xml:"name,attr"`
+	ID   int    `xml:"id,attr" json:"id"`
+	Name string `xml:"name,attr" json:"name"`
 }
 
 // Indexes is a container for Index elements
 type Indexes struct {
-	XMLName xml.Name `xml:"indexes"`
-	Indexes []Index  `xml:"index"`
+	XMLName xml.Name `xml:"indexes" json:"-"`
+	Indexes []Index  `xml:"index" json:"index,omitempty"`
 }
 
 // Index represents a single index (e.g., "A", "B", "C")
 type Index struct {
-	Name    string   `xml:"name,attr"`
-	Artists []Artist `xml:"artist"`
+	Name    string   `xml:"name,attr" json:"name"`
+	Artists []Artist `xml:"artist" json:"artist,omitempty"`
 }
 
 // Artist represents a single artist
 type Artist struct {
-	ID   string `xml:"id,attr"`
-	Name string `xml:"name,attr"`
+	ID      string     `xml:"id,attr" json:"id"`
+	Name    string     `xml:"name,attr" json:"name"`
+	Starred *time.Time `xml:"starred,attr,omitempty" json:"starred,omitempty"`
 }
 
 // SearchResult3 is a container for search results
 type SearchResult3 struct {
-	XMLName xml.Name `xml:"searchResult3"`
-	Artists []Artist `xml:"artist"`
-	Albums  []Album  `xml:"album"`
-	Songs   []Song   `xml:"song"`
+	XMLName xml.Name `xml:"searchResult3" json:"-"`
+	Artists []Artist `xml:"artist" json:"artist,omitempty"`
+	Albums  []Album  `xml:"album" json:"album,omitempty"`
+	Songs   []Song   `xml:"song" json:"song,omitempty"`
 }
 
 // Album represents a single album
 type Album struct {
-	ID     string `xml:"id,attr"`
-	Name   string `xml:"name,attr"`
-	Artist string `xml:"artist,attr"`
+	ID         string     `xml:"id,attr" json:"id"`
+	Name       string     `xml:"name,attr" json:"name"`
+	Artist     string     `xml:"artist,attr" json:"artist"`
+	ArtistID   string     `xml:"artistId,attr,omitempty" json:"artistId,omitempty"`
+	SongCount  int        `xml:"songCount,attr,omitempty" json:"songCount,omitempty"`
+	Duration   int        `xml:"duration,attr,omitempty" json:"duration,omitempty"`
+	PlayCount  int        `xml:"playCount,attr,omitempty" json:"playCount,omitempty"`
+	Created    *time.Time `xml:"created,attr,omitempty" json:"created,omitempty"`
+	Year       int        `xml:"year,attr,omitempty" json:"year,omitempty"`
+	Genre      string     `xml:"genre,attr,omitempty" json:"genre,omitempty"`
+	UserRating int        `xml:"userRating,attr,omitempty" json:"userRating,omitempty"`
+	Starred    *time.Time `xml:"starred,attr,omitempty" json:"starred,omitempty"`
 }
 
 // Song represents a single song
 type Song struct {
-	ID     string `xml:"id,attr"`
-	Title  string `xml:"title,attr"`
-	Artist string `xml:"artist,attr"`
-	Album  string `xml:"album,attr"`
+	ID         string     `xml:"id,attr" json:"id"`
+	Title      string     `xml:"title,attr" json:"title"`
+	Artist     string     `xml:"artist,attr" json:"artist"`
+	Album      string     `xml:"album,attr" json:"album"`
+	Year       int        `xml:"year,attr,omitempty" json:"year,omitempty"`
+	Genre      string     `xml:"genre,attr,omitempty" json:"genre,omitempty"`
+	Duration   int        `xml:"duration,attr,omitempty" json:"duration,omitempty"`
+	BitRate    int        `xml:"bitRate,attr,omitempty" json:"bitRate,omitempty"`
+	Size       int64      `xml:"size,attr,omitempty" json:"size,omitempty"`
+	Suffix     string     `xml:"suffix,attr,omitempty" json:"suffix,omitempty"`
+	UserRating int        `xml:"userRating,attr,omitempty" json:"userRating,omitempty"`
+	Starred    *time.Time `xml:"starred,attr,omitempty" json:"starred,omitempty"`
+}
+
+// SimilarSongs is a container for songs similar to a given song
+type SimilarSongs struct {
+	XMLName xml.Name `xml:"similarSongs" json:"-"`
+	Songs   []Song   `xml:"song" json:"song,omitempty"`
+}
+
+// SimilarSongs2 is a container for songs similar to a given artist
+type SimilarSongs2 struct {
+	XMLName xml.Name `xml:"similarSongs2" json:"-"`
+	Songs   []Song   `xml:"song" json:"song,omitempty"`
+}
+
+// TopSongs is a container for an artist's top songs
+type TopSongs struct {
+	XMLName xml.Name `xml:"topSongs" json:"-"`
+	Songs   []Song   `xml:"song" json:"song,omitempty"`
+}
+
+// Playlists is a container for Playlist elements
+type Playlists struct {
+	XMLName   xml.Name   `xml:"playlists" json:"-"`
+	Playlists []Playlist `xml:"playlist" json:"playlist,omitempty"`
+}
+
+// Playlist represents a single playlist. Readonly marks smart playlists,
+// whose songs are computed from rules and cannot be edited. Entries are only
+// filled in by getPlaylist and the playlist editing endpoints.
+type Playlist struct {
+	ID        string    `xml:"id,attr" json:"id"`
+	Name      string    `xml:"name,attr" json:"name"`
+	Owner     string    `xml:"owner,attr" json:"owner"`
+	Public    bool      `xml:"public,attr" json:"public"`
+	SongCount int       `xml:"songCount,attr" json:"songCount"`
+	Duration  int       `xml:"duration,attr" json:"duration"`
+	Created   time.Time `xml:"created,attr" json:"created"`
+	Changed   time.Time `xml:"changed,attr" json:"changed"`
+	Readonly  bool      `xml:"readonly,attr,omitempty" json:"readonly,omitempty"`
+	Entries   []Song    `xml:"entry" json:"entry,omitempty"`
+}
+
+// Starred is a container for the items a user has starred
+type Starred struct {
+	XMLName xml.Name `xml:"starred" json:"-"`
+	Artists []Artist `xml:"artist" json:"artist,omitempty"`
+	Albums  []Album  `xml:"album" json:"album,omitempty"`
+	Songs   []Song   `xml:"song" json:"song,omitempty"`
+}
+
+// Starred2 is a container for the items a user has starred, organized by ID3 tags
+type Starred2 struct {
+	XMLName xml.Name `xml:"starred2" json:"-"`
+	Artists []Artist `xml:"artist" json:"artist,omitempty"`
+	Albums  []Album  `xml:"album" json:"album,omitempty"`
+	Songs   []Song   `xml:"song" json:"song,omitempty"`
+}
+
+// AlbumList2 is a container for a list of albums, organized by ID3 tags
+type AlbumList2 struct {
+	XMLName xml.Name `xml:"albumList2" json:"-"`
+	Albums  []Album  `xml:"album" json:"album,omitempty"`
+}
+
+// RandomSongs is a container for randomly selected songs
+type RandomSongs struct {
+	XMLName xml.Name `xml:"randomSongs" json:"-"`
+	Songs   []Song   `xml:"song" json:"song,omitempty"`
+}
+
+// PlayQueue is the play queue saved by one of the user's clients. Current is
+// the ID of the song being played and Position the offset into it in milliseconds.
+type PlayQueue struct {
+	XMLName   xml.Name  `xml:"playQueue" json:"-"`
+	Current   string    `xml:"current,attr,omitempty" json:"current,omitempty"`
+	Position  int64     `xml:"position,attr" json:"position"`
+	Username  string    `xml:"username,attr" json:"username"`
+	Changed   time.Time `xml:"changed,attr" json:"changed"`
+	ChangedBy string    `xml:"changedBy,attr" json:"changedBy"`
+	Entries   []Song    `xml:"entry" json:"entry,omitempty"`
+}
+
+// JukeboxStatus is the state of the server's jukebox. Position is in seconds.
+type JukeboxStatus struct {
+	XMLName      xml.Name `xml:"jukeboxStatus" json:"-"`
+	CurrentIndex int      `xml:"currentIndex,attr" json:"currentIndex"`
+	Playing      bool     `xml:"playing,attr" json:"playing"`
+	Gain         float64  `xml:"gain,attr" json:"gain"`
+	Position     int      `xml:"position,attr" json:"position"`
+}
+
+// JukeboxPlaylist is the state of the server's jukebox along with its playlist
+type JukeboxPlaylist struct {
+	XMLName      xml.Name `xml:"jukeboxPlaylist" json:"-"`
+	CurrentIndex int      `xml:"currentIndex,attr" json:"currentIndex"`
+	Playing      bool     `xml:"playing,attr" json:"playing"`
+	Gain         float64  `xml:"gain,attr" json:"gain"`
+	Position     int      `xml:"position,attr" json:"position"`
+	Entries      []Song   `xml:"entry" json:"entry,omitempty"`
+}
+
+// Shares is a container for Share elements
+type Shares struct {
+	XMLName xml.Name `xml:"shares" json:"-"`
+	Shares  []Share  `xml:"share" json:"share,omitempty"`
+}
+
+// Share is a public link to songs, with the songs it gives access to
+type Share struct {
+	ID          string     `xml:"id,attr" json:"id"`
+	URL         string     `xml:"url,attr" json:"url"`
+	Description string     `xml:"description,attr,omitempty" json:"description,omitempty"`
+	Username    string     `xml:"username,attr" json:"username"`
+	Created     time.Time  `xml:"created,attr" json:"created"`
+	Expires     *time.Time `xml:"expires,attr,omitempty" json:"expires,omitempty"`
+	LastVisited *time.Time `xml:"lastVisited,attr,omitempty" json:"lastVisited,omitempty"`
+	VisitCount  int        `xml:"visitCount,attr" json:"visitCount"`
+	Entries     []Song     `xml:"entry" json:"entry,omitempty"`
+}
+
+// User is a user's account and what their role lets them do
+type User struct {
+	XMLName             xml.Name `xml:"user" json:"-"`
+	Username            string   `xml:"username,attr" json:"username"`
+	Email               string   `xml:"email,attr,omitempty" json:"email,omitempty"`
+	ScrobblingEnabled   bool     `xml:"scrobblingEnabled,attr" json:"scrobblingEnabled"`
+	AdminRole           bool     `xml:"adminRole,attr" json:"adminRole"`
+	SettingsRole        bool     `xml:"settingsRole,attr" json:"settingsRole"`
+	DownloadRole        bool     `xml:"downloadRole,attr" json:"downloadRole"`
+	UploadRole          bool     `xml:"uploadRole,attr" json:"uploadRole"`
+	PlaylistRole        bool     `xml:"playlistRole,attr" json:"playlistRole"`
+	CoverArtRole        bool     `xml:"coverArtRole,attr" json:"coverArtRole"`
+	CommentRole         bool     `xml:"commentRole,attr" json:"commentRole"`
+	PodcastRole         bool     `xml:"podcastRole,attr" json:"podcastRole"`
+	StreamRole          bool     `xml:"streamRole,attr" json:"streamRole"`
+	JukeboxRole         bool     `xml:"jukeboxRole,attr" json:"jukeboxRole"`
+	ShareRole           bool     `xml:"shareRole,attr" json:"shareRole"`
+	VideoConversionRole bool     `xml:"videoConversionRole,attr" json:"videoConversionRole"`
 }
@@ -0,0 +1,308 @@
+package subsonic
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/models"
+)
+
+// GetPlaylists is a handler for the /rest/getPlaylists.view endpoint. It lists the
+// user's own playlists, those shared with them and public ones.
+func (h *Handler) GetPlaylists(w http.ResponseWriter, r *http.Request) {
+	userID, _ := GetUserIDFromContext(r.Context())
+
+	playlists, err := db.GetUserPlaylists(h.DB, userID)
+	if err != nil {
+		respond(w, r, &Response{
+			Status: "failed",
+			Error: &Error{
+				Code:    0,
+				Message: "Failed to get playlists",
+			},
+		})
+		return
+	}
+
+	response := NewOkResponse()
+	response.Playlists = &Playlists{}
+	for i := range playlists {
+		songs, err := db.GetPlaylistContents(h.DB, &playlists[i])
+		if err != nil {
+			respond(w, r, &Response{
+				Status: "failed",
+				Error: &Error{
+					Code:    0,
+					Message: "Failed to get playlist songs",
+				},
+			})
+			return
+		}
+		response.Playlists.Playlists = append(response.Playlists.Playlists, toSubsonicPlaylist(&playlists[i], songs))
+	}
+	respond(w, r, response)
+}
+
+// GetPlaylist is a handler for the /rest/getPlaylist.view endpoint
+func (h *Handler) GetPlaylist(w http.ResponseWriter, r *http.Request) {
+	userID, _ := GetUserIDFromContext(r.Context())
+
+	playlist, ok := h.loadPlaylist(w, r, userID, "id")
+	if !ok {
+		return
+	}
+	h.respondWithPlaylist(w, r, playlist)
+}
+
+// CreatePlaylist is a handler for the /rest/createPlaylist.view endpoint. Given a
+// playlistId instead of a name, it replaces the songs of that playlist.
+func (h *Handler) CreatePlaylist(w http.ResponseWriter, r *http.Request) {
+	userID, _ := GetUserIDFromContext(r.Context())
+	query := r.URL.Query()
+
+	songIDs, err := parseIntParams(query["songId"])
+	if err != nil {
+		respondWithError(w, r, 0, "Invalid song ID")
+		return
+	}
+
+	name := query.Get("name")
+	if query.Get("playlistId") != "" {
+		playlist, ok := h.loadEditablePlaylist(w, r, userID, "playlistId")
+		if !ok {
+			return
+		}
+		if err := db.ReplacePlaylist(h.DB, userID, playlist.ID, name, songIDs); err != nil {
+			respondWithError(w, r, 0, "Failed to update playlist songs")
+			return
+		}
+		h.respondWithPlaylistID(w, r, userID, playlist.ID)
+		return
+	}
+
+	if name == "" {
+		respondWithError(w, r, 10, "Required parameter 'name' or 'playlistId' is missing")
+		return
+	}
+
+	playlist, err := db.CreatePlaylistWithSongs(h.DB, userID, name, songIDs)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidPlaylistOperation) {
+			respondWithError(w, r, 70, "Song not found")
+		} else {
+			respondWithError(w, r, 0, "Failed to create playlist")
+		}
+		return
+	}
+	h.respondWithPlaylistID(w, r, userID, playlist.ID)
+}
+
+// UpdatePlaylist is a handler for the /rest/updatePlaylist.view endpoint. Only the
+// owner may change whether the playlist is public.
+func (h *Handler) UpdatePlaylist(w http.ResponseWriter, r *http.Request) {
+	userID, _ := GetUserIDFromContext(r.Context())
+	query := r.URL.Query()
+
+	playlist, ok := h.loadEditablePlaylist(w, r, userID, "playlistId")
+	if !ok {
+		return
+	}
+
+	visibility := ""
+	if public := query.Get("public"); public != "" {
+		isPublic, err := strconv.ParseBool(public)
+		if err != nil {
+			respondWithError(w, r, 0, "Invalid public")
+			return
+		}
+		if !playlist.IsOwner() {
+			respondWithError(w, r, 50, "Only the owner can change whether a playlist is public")
+			return
+		}
+		visibility = models.PlaylistPrivate
+		if isPublic {
+			visibility = models.PlaylistPublic
+		}
+	}
+
+	songIDs, err := parseIntParams(query["songIdToAdd"])
+	if err != nil {
+		respondWithError(w, r, 0, "Invalid song ID")
+		return
+	}
+	indexes, err := parseIntParams(query["songIndexToRemove"])
+	if err != nil {
+		respondWithError(w, r, 0, "Invalid song index")
+		return
+	}
+
+	update := models.PlaylistUpdate{Name: query.Get("name")}
+	if visibility != playlist.Visibility {
+		update.Visibility = visibility
+	}
+	if update.Name != "" || update.Visibility != "" {
+		if _, err := db.UpdatePlaylist(h.DB, userID, playlist.ID, 0, update); err != nil {
+			respondWithError(w, r, 0, "Failed to update playlist")
+			return
+		}
+	}
+
+	if len(songIDs) > 0 || len(indexes) > 0 {
+		var ops []models.PlaylistOperation
+		if len(indexes) > 0 {
+			// Indexes refer to the playlist as the client last saw it, so they are
+			// resolved to entries up front rather than shifting as entries are removed.
+			songs, err := db.GetPlaylistSongs(h.DB, playlist.ID)
+			if err != nil {
+				respondWithError(w, r, 0, "Failed to get playlist songs")
+				return
+			}
+			seen := make(map[int]bool)
+			for _, index := range indexes {
+				if index < 0 || index >= len(songs) {
+					respondWithError(w, r, 0, "Invalid song index")
+					return
+				}
+				if seen[index] {
+					continue
+				}
+				seen[index] = true
+				ops = append(ops, models.PlaylistOperation{Op: "remove", EntryID: songs[index].EntryID})
+			}
+		}
+		for _, songID := range songIDs {
+			ops = append(ops, models.PlaylistOperation{Op: "insert", SongID: songID})
+		}
+		if _, err := db.EditPlaylistSongs(h.DB, userID, playlist.ID, 0, ops); err != nil {
+			respondWithError(w, r, 0, "Failed to update playlist songs")
+			return
+		}
+	}
+
+	respond(w, r, NewOkResponse())
+}
+
+// DeletePlaylist is a handler for the /rest/deletePlaylist.view endpoint
+func (h *Handler) DeletePlaylist(w http.ResponseWriter, r *http.Request) {
+	userID, _ := GetUserIDFromContext(r.Context())
+
+	playlistID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		respondWithError(w, r, 10, "Required parameter 'id' is missing")
+		return
+	}
+
+	if err := db.DeletePlaylist(h.DB, userID, playlistID); err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, r, 70, "Playlist not found")
+		} else {
+			respondWithError(w, r, 0, "Failed to delete playlist")
+		}
+		return
+	}
+
+	respond(w, r, NewOkResponse())
+}
+
+// loadPlaylist reads the playlist named by the given query parameter, making sure
+// the user can see it. It writes an error response and returns false on failure.
+func (h *Handler) loadPlaylist(w http.ResponseWriter, r *http.Request, userID int, param string) (*models.Playlist, bool) {
+	playlistID, err := strconv.Atoi(r.URL.Query().Get(param))
+	if err != nil {
+		respondWithError(w, r, 10, "Required parameter '"+param+"' is missing")
+		return nil, false
+	}
+
+	playlist, err := db.GetPlaylistByID(h.DB, userID, playlistID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, r, 70, "Playlist not found")
+		} else {
+			respondWithError(w, r, 0, "Failed to get playlist")
+		}
+		return nil, false
+	}
+	return playlist, true
+}
+
+// loadEditablePlaylist is loadPlaylist for playlists about to be changed, which
+// must be owned by the user or shared with them as an editor, and not be smart.
+func (h *Handler) loadEditablePlaylist(w http.ResponseWriter, r *http.Request, userID int, param string) (*models.Playlist, bool) {
+	playlist, ok := h.loadPlaylist(w, r, userID, param)
+	if !ok {
+		return nil, false
+	}
+	if !playlist.CanEdit() {
+		respondWithError(w, r, 50, "You don't have permission to edit this playlist")
+		return nil, false
+	}
+	if playlist.ReadOnly {
+		respondWithError(w, r, 50, "Smart playlists are read-only")
+		return nil, false
+	}
+	return playlist, true
+}
+
+// respondWithPlaylistID reloads a playlist after an edit and writes it with its songs.
+func (h *Handler) respondWithPlaylistID(w http.ResponseWriter, r *http.Request, userID int, playlistID int) {
+	playlist, err := db.GetPlaylistByID(h.DB, userID, playlistID)
+	if err != nil {
+		respondWithError(w, r, 0, "Failed to get playlist")
+		return
+	}
+	h.respondWithPlaylist(w, r, playlist)
+}
+
+// respondWithPlaylist writes a playlist along with its songs.
+func (h *Handler) respondWithPlaylist(w http.ResponseWriter, r *http.Request, playlist *models.Playlist) {
+	songs, err := db.GetPlaylistContents(h.DB, playlist)
+	if err != nil {
+		respondWithError(w, r, 0, "Failed to get playlist songs")
+		return
+	}
+
+	result := toSubsonicPlaylist(playlist, songs)
+	result.Entries = make([]Song, 0, len(songs))
+	for _, song := range songs {
+		result.Entries = append(result.Entries, toSubsonicSong(song.Song))
+	}
+
+	response := NewOkResponse()
+	response.Playlist = &result
+	respond(w, r, response)
+}
+
+// parseIntParams parses every value of a repeated integer parameter.
+func parseIntParams(values []string) ([]int, error) {
+	ids := make([]int, 0, len(values))
+	for _, value := range values {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// toSubsonicPlaylist converts a playlist model and its songs into its Subsonic representation
+func toSubsonicPlaylist(playlist *models.Playlist, songs []models.PlaylistSong) Playlist {
+	duration := 0
+	for _, song := range songs {
+		duration += song.Duration
+	}
+	return Playlist{
+		ID:        strconv.Itoa(playlist.ID),
+		Name:      playlist.Name,
+		Owner:     playlist.Owner,
+		Public:    playlist.Visibility == models.PlaylistPublic,
+		SongCount: len(songs),
+		Duration:  duration,
+		Created:   playlist.CreatedAt,
+		Changed:   playlist.UpdatedAt,
+		Readonly:  playlist.ReadOnly,
+	}
+}
@@ -0,0 +1,213 @@
+package subsonic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-postgres-example/pkg/config"
+)
+
+var playlistColumns = []string{"id", "user_id", "name", "rules", "version", "created_at", "updated_at", "visibility", "owner", "role"}
+
+func withUser(req *http.Request, userID int) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), userIDKey, userID))
+}
+
+func TestGetPlaylistJSON(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT (.+) FROM playlists").
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows(playlistColumns).AddRow(5, 1, "Road Trip", nil, 2, time.Now(), time.Now(), "private", "alice", "owner"))
+	mock.ExpectQuery("SELECT (.+) FROM songs s").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "fingerprint_hash", "file_path", "title", "artist", "album", "year",
+			"genre_id", "genre", "duration", "bitrate", "file_size", "last_modified", "entry_id", "position",
+		}).AddRow(9, "abc", "/music/abc.flac", "So What", "Miles Davis", "Kind of Blue", 1959, 1, "Jazz", 562, 900, 100, time.Now(), 1, 1))
+
+	handler := NewHandler(db, &config.Config{})
+
+	req := withUser(httptest.NewRequest("GET", "/rest/getPlaylist.view?id=5&f=json", nil), 1)
+	rr := httptest.NewRecorder()
+
+	handler.GetPlaylist(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Header().Get("Content-Type"), "application/json")
+
+	var body struct {
+		Response struct {
+			Status   string `json:"status"`
+			Playlist struct {
+				Name      string `json:"name"`
+				Owner     string `json:"owner"`
+				SongCount int    `json:"songCount"`
+				Entry     []Song `json:"entry"`
+			} `json:"playlist"`
+		} `json:"subsonic-response"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, "ok", body.Response.Status)
+	assert.Equal(t, "alice", body.Response.Playlist.Owner)
+	assert.Equal(t, 1, body.Response.Playlist.SongCount)
+	require.Len(t, body.Response.Playlist.Entry, 1)
+	assert.Equal(t, "So What", body.Response.Playlist.Entry[0].Title)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetPlaylistOfAnotherUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT (.+) FROM playlists").
+		WithArgs(5, 2).
+		WillReturnRows(sqlmock.NewRows(playlistColumns))
+
+	handler := NewHandler(db, &config.Config{})
+
+	req := withUser(httptest.NewRequest("GET", "/rest/getPlaylist.view?id=5", nil), 2)
+	rr := httptest.NewRecorder()
+
+	handler.GetPlaylist(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `status="failed"`)
+	assert.Contains(t, rr.Body.String(), `code="70"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdatePlaylist(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	songColumns := []string{
+		"id", "fingerprint_hash", "file_path", "title", "artist", "album", "year",
+		"genre_id", "genre", "duration", "bitrate", "file_size", "last_modified", "entry_id", "position",
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM playlists").
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows(playlistColumns).AddRow(5, 1, "Road Trip", nil, 2, time.Now(), time.Now(), "private", "alice", "owner"))
+	mock.ExpectQuery("SELECT (.+) FROM songs s").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(songColumns).
+			AddRow(9, "abc", "/music/abc.flac", "So What", "Miles Davis", "Kind of Blue", 1959, 1, "Jazz", 562, 900, 100, time.Now(), 21, 1).
+			AddRow(8, "def", "/music/def.flac", "Blue in Green", "Miles Davis", "Kind of Blue", 1959, 1, "Jazz", 337, 900, 100, time.Now(), 22, 2))
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE playlists").WithArgs(5, 0, 1).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+	mock.ExpectQuery("SELECT id, song_id, position FROM playlist_songs").WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "song_id", "position"}).AddRow(21, 9, 1).AddRow(22, 8, 2))
+	mock.ExpectQuery("FROM unnest").WithArgs("{9}").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec("DELETE FROM playlist_songs").WithArgs(21).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE playlist_songs SET position").WithArgs(1, 22).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO playlist_songs").WithArgs(5, 9, 2).WillReturnResult(sqlmock.NewResult(23, 1))
+	mock.ExpectExec("INSERT INTO playlist_activity").WithArgs(5, 1, "add", "{9}").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO playlist_activity").WithArgs(5, 1, "remove", "{9}").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	handler := NewHandler(db, &config.Config{})
+
+	req := withUser(httptest.NewRequest("GET", "/rest/updatePlaylist.view?playlistId=5&songIndexToRemove=0&songIdToAdd=9", nil), 1)
+	rr := httptest.NewRecorder()
+
+	handler.UpdatePlaylist(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `status="ok"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetPlaylistsIncludesPublic(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT (.+) FROM playlists").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(playlistColumns).
+			AddRow(6, 2, "Office", nil, 1, time.Now(), time.Now(), "public", "bob", "viewer"))
+	mock.ExpectQuery("SELECT (.+) FROM songs s").
+		WithArgs(6).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "fingerprint_hash", "file_path", "title", "artist", "album", "year",
+			"genre_id", "genre", "duration", "bitrate", "file_size", "last_modified", "entry_id", "position",
+		}))
+
+	handler := NewHandler(db, &config.Config{})
+
+	req := withUser(httptest.NewRequest("GET", "/rest/getPlaylists.view", nil), 1)
+	rr := httptest.NewRecorder()
+
+	handler.GetPlaylists(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `owner="bob" public="true"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdatePlaylistViewerNotAuthorized(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT (.+) FROM playlists").
+		WithArgs(6, 1).
+		WillReturnRows(sqlmock.NewRows(playlistColumns).
+			AddRow(6, 2, "Office", nil, 1, time.Now(), time.Now(), "public", "bob", "viewer"))
+
+	handler := NewHandler(db, &config.Config{})
+
+	req := withUser(httptest.NewRequest("GET", "/rest/updatePlaylist.view?playlistId=6&songIdToAdd=9", nil), 1)
+	rr := httptest.NewRecorder()
+
+	handler.UpdatePlaylist(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `code="50"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreatePlaylistWithUnknownSongLeavesNoPlaylist(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO playlists").
+		WithArgs(1, "Road Trip").
+		WillReturnRows(sqlmock.NewRows(playlistColumns).AddRow(7, 1, "Road Trip", nil, 1, time.Now(), time.Now(), "private", "alice", "owner"))
+	mock.ExpectQuery("SELECT id, song_id, position FROM playlist_songs").WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "song_id", "position"}))
+	mock.ExpectQuery("FROM unnest").WithArgs("{9,404}").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(404))
+	mock.ExpectRollback()
+
+	handler := NewHandler(db, &config.Config{})
+
+	req := withUser(httptest.NewRequest("GET", "/rest/createPlaylist.view?name=Road+Trip&songId=9&songId=404", nil), 1)
+	rr := httptest.NewRecorder()
+
+	handler.CreatePlaylist(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `code="70"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
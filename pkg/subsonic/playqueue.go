@@ -0,0 +1,108 @@
+package subsonic
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"go-postgres-example/pkg/db"
+)
+
+// GetPlayQueue is a handler for the /rest/getPlayQueue.view endpoint. The response
+// has no playQueue element if the user never saved one.
+func (h *Handler) GetPlayQueue(w http.ResponseWriter, r *http.Request) {
+	userID, _ := GetUserIDFromContext(r.Context())
+
+	queue, err := db.GetPlayQueue(h.DB, userID)
+	if err == sql.ErrNoRows {
+		respond(w, r, NewOkResponse())
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, 0, "Failed to get play queue")
+		return
+	}
+
+	username, err := db.GetUsernameByID(h.DB, userID)
+	if err != nil {
+		respondWithError(w, r, 0, "Failed to get user")
+		return
+	}
+
+	result := &PlayQueue{
+		Position:  queue.PositionMs,
+		Username:  username,
+		Changed:   queue.UpdatedAt,
+		ChangedBy: queue.Client,
+	}
+	for i, song := range queue.Songs {
+		if i == queue.CurrentIndex {
+			result.Current = strconv.Itoa(song.ID)
+		}
+		result.Entries = append(result.Entries, toSubsonicSong(song))
+	}
+
+	response := NewOkResponse()
+	response.PlayQueue = result
+	respond(w, r, response)
+}
+
+// SavePlayQueue is a handler for the /rest/savePlayQueue.view endpoint. The queue
+// is given as repeated id parameters; current is the ID of the playing song and
+// position the offset into it in milliseconds. Without any id the queue is cleared.
+func (h *Handler) SavePlayQueue(w http.ResponseWriter, r *http.Request) {
+	userID, _ := GetUserIDFromContext(r.Context())
+	query := r.URL.Query()
+
+	songIDs, err := parseIntParams(query["id"])
+	if err != nil {
+		respondWithError(w, r, 0, "Invalid id")
+		return
+	}
+
+	currentIndex := 0
+	if current := query.Get("current"); current != "" {
+		currentID, err := strconv.Atoi(current)
+		if err != nil {
+			respondWithError(w, r, 0, "Invalid current")
+			return
+		}
+		currentIndex = -1
+		for i, id := range songIDs {
+			if id == currentID {
+				currentIndex = i
+				break
+			}
+		}
+		if currentIndex < 0 {
+			respondWithError(w, r, 0, "Current song is not in the queue")
+			return
+		}
+	}
+
+	var position int64
+	if p := query.Get("position"); p != "" {
+		if position, err = strconv.ParseInt(p, 10, 64); err != nil || position < 0 {
+			respondWithError(w, r, 0, "Invalid position")
+			return
+		}
+	}
+
+	if err := db.SavePlayQueue(h.DB, userID, songIDs, currentIndex, position, query.Get("c")); err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, r, 70, "Song not found")
+		} else {
+			respondWithError(w, r, 0, "Failed to save play queue")
+		}
+		return
+	}
+
+	queue, err := db.GetPlayQueue(h.DB, userID)
+	if err != nil {
+		respondWithError(w, r, 0, "Failed to get play queue")
+		return
+	}
+	h.QueueEvents.Publish(userID, *queue)
+
+	respond(w, r, NewOkResponse())
+}
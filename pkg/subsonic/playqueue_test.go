@@ -0,0 +1,101 @@
+package subsonic
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"go-postgres-example/pkg/config"
+)
+
+var queueSongColumns = []string{
+	"id", "fingerprint_hash", "file_path", "title", "artist", "album", "year",
+	"genre_id", "genre", "duration", "bitrate", "file_size", "last_modified",
+}
+
+func TestSavePlayQueue(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO play_queues").
+		WithArgs(1, 1, int64(45000), "DSub").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM play_queue_songs").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO play_queue_songs").
+		WithArgs(1, "{3,4}").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectQuery("FROM play_queues").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"current_index", "position_ms", "client", "updated_at"}).
+			AddRow(1, 45000, "DSub", time.Now()))
+	mock.ExpectQuery("FROM play_queue_songs").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(queueSongColumns))
+
+	handler := NewHandler(db, &config.Config{})
+	events, unsubscribe := handler.QueueEvents.Subscribe(1)
+	defer unsubscribe()
+
+	req := withUser(httptest.NewRequest("GET", "/rest/savePlayQueue.view?id=3&id=4&current=4&position=45000&c=DSub", nil), 1)
+	rr := httptest.NewRecorder()
+
+	handler.SavePlayQueue(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `status="ok"`)
+	assert.Equal(t, "DSub", (<-events).Client)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSavePlayQueueCurrentNotInQueue(t *testing.T) {
+	handler := NewHandler(nil, &config.Config{})
+
+	req := withUser(httptest.NewRequest("GET", "/rest/savePlayQueue.view?id=3&current=4", nil), 1)
+	rr := httptest.NewRecorder()
+
+	handler.SavePlayQueue(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `status="failed"`)
+}
+
+func TestGetPlayQueue(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	changed := time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("FROM play_queues").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"current_index", "position_ms", "client", "updated_at"}).
+			AddRow(1, 45000, "biomuzak-web", changed))
+	mock.ExpectQuery("FROM play_queue_songs").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(queueSongColumns).
+			AddRow(3, "hash3", "/music/3.mp3", "So What", "Miles Davis", "Kind of Blue", 1959, 2, "Jazz", 545, 320, 1000, time.Now()).
+			AddRow(4, "hash4", "/music/4.mp3", "Blue in Green", "Miles Davis", "Kind of Blue", 1959, 2, "Jazz", 337, 320, 1000, time.Now()))
+	mock.ExpectQuery("SELECT username FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+
+	handler := NewHandler(db, &config.Config{})
+
+	req := withUser(httptest.NewRequest("GET", "/rest/getPlayQueue.view", nil), 1)
+	rr := httptest.NewRecorder()
+
+	handler.GetPlayQueue(rr, req)
+
+	body := rr.Body.String()
+	assert.Contains(t, body, `<playQueue current="4" position="45000" username="alice" changed="2026-04-01T09:00:00Z" changedBy="biomuzak-web">`)
+	assert.Contains(t, body, `<entry id="3"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
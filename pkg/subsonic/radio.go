@@ -0,0 +1,175 @@
+package subsonic
+
+import (
+	"database/sql"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/models"
+	"go-postgres-example/pkg/radio"
+)
+
+// defaultSimilarCount mirrors the Subsonic API default for similar and top song lists.
+const defaultSimilarCount = 50
+
+// GetSimilarSongs is a handler for the /rest/getSimilarSongs.view endpoint
+func (h *Handler) GetSimilarSongs(w http.ResponseWriter, r *http.Request) {
+	songs, ok := h.radioSongs(w, r, radio.SeedSong)
+	if !ok {
+		return
+	}
+	response := NewOkResponse()
+	response.SimilarSongs = &SimilarSongs{Songs: songs}
+	respond(w, r, response)
+}
+
+// GetSimilarSongs2 is a handler for the /rest/getSimilarSongs2.view endpoint
+func (h *Handler) GetSimilarSongs2(w http.ResponseWriter, r *http.Request) {
+	songs, ok := h.radioSongs(w, r, radio.SeedArtist)
+	if !ok {
+		return
+	}
+	response := NewOkResponse()
+	response.SimilarSongs2 = &SimilarSongs2{Songs: songs}
+	respond(w, r, response)
+}
+
+// GetTopSongs is a handler for the /rest/getTopSongs.view endpoint
+func (h *Handler) GetTopSongs(w http.ResponseWriter, r *http.Request) {
+	artist := r.URL.Query().Get("artist")
+	if artist == "" {
+		respond(w, r, &Response{
+			Status: "failed",
+			Error: &Error{
+				Code:    10,
+				Message: "Required parameter 'artist' is missing",
+			},
+		})
+		return
+	}
+
+	songs, err := db.GetTopSongsByArtist(h.DB, artist, parseCount(r, defaultSimilarCount))
+	if err != nil {
+		respond(w, r, &Response{
+			Status: "failed",
+			Error: &Error{
+				Code:    0,
+				Message: "Failed to get top songs",
+			},
+		})
+		return
+	}
+
+	response := NewOkResponse()
+	response.TopSongs = &TopSongs{}
+	for _, song := range songs {
+		response.TopSongs.Songs = append(response.TopSongs.Songs, toSubsonicSong(song))
+	}
+	respond(w, r, response)
+}
+
+// radioSongs runs the radio generator for the song or artist given by the 'id' parameter.
+// It writes a Subsonic error response and returns false on failure.
+func (h *Handler) radioSongs(w http.ResponseWriter, r *http.Request, kind string) ([]Song, bool) {
+	userID, _ := GetUserIDFromContext(r.Context())
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		respond(w, r, &Response{
+			Status: "failed",
+			Error: &Error{
+				Code:    10,
+				Message: "Required parameter 'id' is missing",
+			},
+		})
+		return nil, false
+	}
+
+	notFound := &Response{
+		Status: "failed",
+		Error: &Error{
+			Code:    70,
+			Message: "The requested data was not found",
+		},
+	}
+
+	numericID, err := strconv.Atoi(id)
+	if err != nil {
+		respond(w, r, notFound)
+		return nil, false
+	}
+
+	seed := radio.Seed{Kind: kind, Value: id}
+	if kind == radio.SeedArtist {
+		name, err := db.GetArtistNameByID(h.DB, numericID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respond(w, r, notFound)
+			} else {
+				respond(w, r, &Response{
+					Status: "failed",
+					Error: &Error{
+						Code:    0,
+						Message: "Failed to get artist",
+					},
+				})
+			}
+			return nil, false
+		}
+		seed.Value = name
+	}
+
+	candidates, err := h.Radio.Generate(userID, seed, radio.Options{Count: parseCount(r, defaultSimilarCount)})
+	if err != nil {
+		if err == radio.ErrSeedNotFound {
+			respond(w, r, notFound)
+		} else {
+			respond(w, r, &Response{
+				Status: "failed",
+				Error: &Error{
+					Code:    0,
+					Message: "Failed to find similar songs",
+				},
+			})
+		}
+		return nil, false
+	}
+
+	var songs []Song
+	for _, c := range candidates {
+		song := toSubsonicSong(c.Song)
+		if c.Rating.Valid {
+			song.UserRating = int(c.Rating.Int64)
+		}
+		songs = append(songs, song)
+	}
+	return songs, true
+}
+
+// parseCount reads the optional 'count' parameter, falling back to def.
+func parseCount(r *http.Request, def int) int {
+	count, err := strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil || count <= 0 {
+		return def
+	}
+	return count
+}
+
+// toSubsonicSong converts a song model into its Subsonic representation
+func toSubsonicSong(song models.Song) Song {
+	return Song{
+		ID:       strconv.Itoa(song.ID),
+		Title:    song.Title,
+		Artist:   song.Artist,
+		Album:    song.Album,
+		Year:     song.Year,
+		Genre:    song.Genre,
+		Duration: song.Duration,
+		BitRate:  song.Bitrate,
+		Size:     song.FileSize,
+		Suffix:   strings.TrimPrefix(strings.ToLower(filepath.Ext(song.FilePath)), "."),
+	}
+}
@@ -10,13 +10,39 @@ import (
 func NewRouter(authHandler *handlers.AuthHandler, subsonicHandler *Handler) *chi.Mux {
 	r := chi.NewRouter()
 
-	r.Use(AuthMiddleware(authHandler.DB))
+	r.Use(AuthMiddleware(authHandler))
 
 	r.Get("/ping.view", subsonicHandler.Ping)
 	r.Get("/getMusicFolders.view", subsonicHandler.GetMusicFolders)
 	r.Get("/getIndexes.view", subsonicHandler.GetIndexes)
 	r.Get("/search3.view", subsonicHandler.Search3)
 	r.Get("/stream.view", subsonicHandler.Stream)
+	r.Get("/download.view", subsonicHandler.Download)
+	r.Get("/getSimilarSongs.view", subsonicHandler.GetSimilarSongs)
+	r.Get("/getSimilarSongs2.view", subsonicHandler.GetSimilarSongs2)
+	r.Get("/getTopSongs.view", subsonicHandler.GetTopSongs)
+	r.Get("/getPlaylists.view", subsonicHandler.GetPlaylists)
+	r.Get("/getPlaylist.view", subsonicHandler.GetPlaylist)
+	r.Get("/createPlaylist.view", subsonicHandler.CreatePlaylist)
+	r.Get("/updatePlaylist.view", subsonicHandler.UpdatePlaylist)
+	r.Get("/deletePlaylist.view", subsonicHandler.DeletePlaylist)
+	r.Get("/scrobble.view", subsonicHandler.Scrobble)
+	r.Get("/star.view", subsonicHandler.Star)
+	r.Get("/unstar.view", subsonicHandler.Unstar)
+	r.Get("/setRating.view", subsonicHandler.SetRating)
+	r.Get("/getStarred.view", subsonicHandler.GetStarred)
+	r.Get("/getStarred2.view", subsonicHandler.GetStarred2)
+	r.Get("/getAlbumList2.view", subsonicHandler.GetAlbumList2)
+	r.Get("/getRandomSongs.view", subsonicHandler.GetRandomSongs)
+	r.Get("/getPlayQueue.view", subsonicHandler.GetPlayQueue)
+	r.Get("/savePlayQueue.view", subsonicHandler.SavePlayQueue)
+	r.Get("/jukeboxControl.view", subsonicHandler.JukeboxControl)
+	r.Get("/getShares.view", subsonicHandler.GetShares)
+	r.Get("/createShare.view", subsonicHandler.CreateShare)
+	r.Get("/updateShare.view", subsonicHandler.UpdateShare)
+	r.Get("/deleteShare.view", subsonicHandler.DeleteShare)
+	r.Get("/getUser.view", subsonicHandler.GetUser)
+	r.Get("/changePassword.view", subsonicHandler.ChangePassword)
 
 	return r
 }
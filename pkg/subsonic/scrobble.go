@@ -0,0 +1,58 @@
+package subsonic
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/scrobbler"
+)
+
+// Scrobble is a handler for the /rest/scrobble.view endpoint. Clients may send
+// several id parameters, each with an optional matching time (in milliseconds).
+// With submission=false the song is only marked as now playing.
+func (h *Handler) Scrobble(w http.ResponseWriter, r *http.Request) {
+	userID, _ := GetUserIDFromContext(r.Context())
+	query := r.URL.Query()
+
+	songIDs, err := parseIntParams(query["id"])
+	if err != nil || len(songIDs) == 0 {
+		respondWithError(w, r, 10, "Required parameter 'id' is missing")
+		return
+	}
+
+	times := query["time"]
+	submission := query.Get("submission") != "false"
+
+	for i, songID := range songIDs {
+		playedAt := time.Now()
+		listenType := scrobbler.ListenTypeSingle
+		if submission {
+			if i < len(times) {
+				ms, err := strconv.ParseInt(times[i], 10, 64)
+				if err != nil {
+					respondWithError(w, r, 0, "Invalid time")
+					return
+				}
+				playedAt = time.UnixMilli(ms)
+			}
+			err = db.RecordPlay(h.DB, userID, songID, playedAt, 0)
+		} else {
+			listenType = scrobbler.ListenTypePlayingNow
+			err = db.SetNowPlaying(h.DB, userID, songID)
+		}
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(w, r, 70, "Song not found")
+			} else {
+				respondWithError(w, r, 0, "Failed to scrobble song")
+			}
+			return
+		}
+		h.Scrobbler.Enqueue(userID, songID, listenType, playedAt)
+	}
+
+	respond(w, r, NewOkResponse())
+}
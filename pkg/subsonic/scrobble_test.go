@@ -0,0 +1,42 @@
+package subsonic
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"go-postgres-example/pkg/config"
+)
+
+func TestScrobble(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("INSERT INTO plays").
+		WithArgs(1, 3, time.UnixMilli(1700000000000), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO scrobble_queue").
+		WithArgs(1, 3, "single", time.UnixMilli(1700000000000)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO plays").
+		WithArgs(1, 4, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectExec("INSERT INTO scrobble_queue").
+		WithArgs(1, 4, "single", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	handler := NewHandler(db, &config.Config{})
+
+	req := withUser(httptest.NewRequest("GET", "/rest/scrobble.view?id=3&time=1700000000000&id=4", nil), 1)
+	rr := httptest.NewRecorder()
+
+	handler.Scrobble(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `status="ok"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,231 @@
+package subsonic
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/handlers"
+	"go-postgres-example/pkg/models"
+)
+
+// GetShares is a handler for the /rest/getShares.view endpoint. Album and playlist
+// shares created through the REST API are listed with their current songs.
+func (h *Handler) GetShares(w http.ResponseWriter, r *http.Request) {
+	userID, _ := GetUserIDFromContext(r.Context())
+
+	shares, err := db.GetUserShares(h.DB, userID)
+	if err != nil {
+		respondWithError(w, r, 0, "Failed to get shares")
+		return
+	}
+	username, err := db.GetUsernameByID(h.DB, userID)
+	if err != nil {
+		respondWithError(w, r, 0, "Failed to get user")
+		return
+	}
+
+	result := &Shares{}
+	for i := range shares {
+		share, err := h.toSubsonicShare(r, &shares[i], username)
+		if err != nil {
+			respondWithError(w, r, 0, "Failed to get shared songs")
+			return
+		}
+		result.Shares = append(result.Shares, share)
+	}
+
+	response := NewOkResponse()
+	response.Shares = result
+	respond(w, r, response)
+}
+
+// CreateShare is a handler for the /rest/createShare.view endpoint. The songs to
+// share are given as repeated id parameters; expires is the expiry time in
+// milliseconds since the epoch.
+func (h *Handler) CreateShare(w http.ResponseWriter, r *http.Request) {
+	userID, _ := GetUserIDFromContext(r.Context())
+	query := r.URL.Query()
+
+	if len(query["id"]) == 0 {
+		respondWithError(w, r, 10, "Required parameter 'id' is missing")
+		return
+	}
+	songIDs, err := parseIntParams(query["id"])
+	if err != nil {
+		respondWithError(w, r, 70, "Song not found")
+		return
+	}
+	expires, err := parseExpires(query.Get("expires"))
+	if err != nil {
+		respondWithError(w, r, 0, "Invalid expires")
+		return
+	}
+	if !h.checkPermission(w, r, userID, models.PermissionShare, "User is not authorized to share") {
+		return
+	}
+
+	if _, err := db.GetSongsByIDs(h.DB, songIDs); err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, r, 70, "Song not found")
+		} else {
+			respondWithError(w, r, 0, "Failed to get songs")
+		}
+		return
+	}
+
+	share := &models.Share{
+		UserID:      userID,
+		Type:        models.ShareSong,
+		ItemIDs:     songIDs,
+		Description: query.Get("description"),
+		ExpiresAt:   expires,
+	}
+	if share.Token, err = handlers.NewShareToken(); err != nil {
+		respondWithError(w, r, 0, "Failed to create share")
+		return
+	}
+	if err := db.CreateShare(h.DB, share); err != nil {
+		respondWithError(w, r, 0, "Failed to create share")
+		return
+	}
+
+	username, err := db.GetUsernameByID(h.DB, userID)
+	if err != nil {
+		respondWithError(w, r, 0, "Failed to get user")
+		return
+	}
+	result, err := h.toSubsonicShare(r, share, username)
+	if err != nil {
+		respondWithError(w, r, 0, "Failed to get shared songs")
+		return
+	}
+
+	response := NewOkResponse()
+	response.Shares = &Shares{Shares: []Share{result}}
+	respond(w, r, response)
+}
+
+// UpdateShare is a handler for the /rest/updateShare.view endpoint. An expires of 0
+// removes the expiry date.
+func (h *Handler) UpdateShare(w http.ResponseWriter, r *http.Request) {
+	userID, _ := GetUserIDFromContext(r.Context())
+	query := r.URL.Query()
+
+	shareID, ok := h.shareIDParam(w, r)
+	if !ok {
+		return
+	}
+	if !h.checkPermission(w, r, userID, models.PermissionShare, "User is not authorized to share") {
+		return
+	}
+
+	share, err := db.GetShareByID(h.DB, userID, shareID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, r, 70, "Share not found")
+		} else {
+			respondWithError(w, r, 0, "Failed to get share")
+		}
+		return
+	}
+
+	if _, ok := query["description"]; ok {
+		share.Description = query.Get("description")
+	}
+	if _, ok := query["expires"]; ok {
+		if share.ExpiresAt, err = parseExpires(query.Get("expires")); err != nil {
+			respondWithError(w, r, 0, "Invalid expires")
+			return
+		}
+	}
+
+	if err := db.UpdateShare(h.DB, share); err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, r, 70, "Share not found")
+		} else {
+			respondWithError(w, r, 0, "Failed to update share")
+		}
+		return
+	}
+
+	respond(w, r, NewOkResponse())
+}
+
+// DeleteShare is a handler for the /rest/deleteShare.view endpoint
+func (h *Handler) DeleteShare(w http.ResponseWriter, r *http.Request) {
+	userID, _ := GetUserIDFromContext(r.Context())
+
+	shareID, ok := h.shareIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := db.DeleteShare(h.DB, userID, shareID); err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, r, 70, "Share not found")
+		} else {
+			respondWithError(w, r, 0, "Failed to delete share")
+		}
+		return
+	}
+
+	respond(w, r, NewOkResponse())
+}
+
+// shareIDParam reads the required id parameter of the share endpoints, writing an
+// error response if it is missing or invalid.
+func (h *Handler) shareIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		respondWithError(w, r, 10, "Required parameter 'id' is missing")
+		return 0, false
+	}
+	shareID, err := strconv.Atoi(id)
+	if err != nil {
+		respondWithError(w, r, 70, "Share not found")
+		return 0, false
+	}
+	return shareID, true
+}
+
+// toSubsonicShare converts a share into its Subsonic representation, with its songs
+func (h *Handler) toSubsonicShare(r *http.Request, share *models.Share, username string) (Share, error) {
+	songs, err := db.GetShareSongs(h.DB, share)
+	if err != nil {
+		return Share{}, err
+	}
+	result := Share{
+		ID:          strconv.Itoa(share.ID),
+		URL:         handlers.ShareURL(h.Cfg, r, share.Token),
+		Description: share.Description,
+		Username:    username,
+		Created:     share.CreatedAt,
+		Expires:     share.ExpiresAt,
+		LastVisited: share.LastVisitedAt,
+		VisitCount:  share.VisitCount,
+	}
+	for _, song := range songs {
+		result.Entries = append(result.Entries, toSubsonicSong(song))
+	}
+	return result, nil
+}
+
+// parseExpires parses an expiry time given in milliseconds since the epoch. An
+// empty value or 0 means the share never expires.
+func parseExpires(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ms < 0 {
+		return nil, strconv.ErrSyntax
+	}
+	if ms == 0 {
+		return nil, nil
+	}
+	expires := time.UnixMilli(ms)
+	return &expires, nil
+}
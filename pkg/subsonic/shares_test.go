@@ -0,0 +1,120 @@
+package subsonic
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"go-postgres-example/pkg/config"
+)
+
+var shareColumns = []string{
+	"id", "user_id", "token", "item_type", "description", "password_hash",
+	"allow_download", "expires_at", "visit_count", "last_visited_at", "created_at", "item_ids",
+}
+
+func TestCreateShare(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	expires := time.UnixMilli(1893456000000)
+	mock.ExpectQuery("FROM role_permissions").
+		WithArgs(1, "share").
+		WillReturnRows(sqlmock.NewRows([]string{"allowed"}).AddRow(true))
+	mock.ExpectQuery("FROM songs s").
+		WithArgs("{3}").
+		WillReturnRows(sqlmock.NewRows(queueSongColumns).
+			AddRow(3, "abc", "/uploads/abc.flac", "So What", "Miles Davis", "Kind of Blue", 1959, 1, "Jazz", 562, 900, 100, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO shares").
+		WithArgs(1, sqlmock.AnyArg(), "song", "Listen", "", false, &expires).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(7, time.Now()))
+	mock.ExpectExec("INSERT INTO share_items").
+		WithArgs(7, "{3}").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT username FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("miles"))
+	mock.ExpectQuery("FROM share_items si").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(queueSongColumns).
+			AddRow(3, "abc", "/uploads/abc.flac", "So What", "Miles Davis", "Kind of Blue", 1959, 1, "Jazz", 562, 900, 100, time.Now()))
+
+	handler := NewHandler(db, &config.Config{PublicURL: "https://music.example.com"})
+	req := withUser(httptest.NewRequest("GET", "/rest/createShare.view?id=3&description=Listen&expires=1893456000000", nil), 1)
+	rr := httptest.NewRecorder()
+
+	handler.CreateShare(rr, req)
+
+	body := rr.Body.String()
+	assert.Contains(t, body, `status="ok"`)
+	assert.Contains(t, body, `url="https://music.example.com/share/`)
+	assert.Contains(t, body, `username="miles"`)
+	assert.Contains(t, body, `<entry id="3"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateShareMissingID(t *testing.T) {
+	handler := NewHandler(nil, &config.Config{})
+	req := withUser(httptest.NewRequest("GET", "/rest/createShare.view", nil), 1)
+	rr := httptest.NewRecorder()
+
+	handler.CreateShare(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `code="10"`)
+}
+
+func TestUpdateShareRemovesExpiry(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("FROM role_permissions").
+		WithArgs(1, "share").
+		WillReturnRows(sqlmock.NewRows([]string{"allowed"}).AddRow(true))
+	mock.ExpectQuery("FROM shares sh").
+		WithArgs(7, 1).
+		WillReturnRows(sqlmock.NewRows(shareColumns).
+			AddRow(7, 1, "tok", "song", "Listen", nil, false, time.Now().Add(time.Hour), 0, nil, time.Now(), "{3}"))
+	mock.ExpectExec("UPDATE shares").
+		WithArgs("New", "", false, nil, 7, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	handler := NewHandler(db, &config.Config{})
+	req := withUser(httptest.NewRequest("GET", "/rest/updateShare.view?id=7&description=New&expires=0", nil), 1)
+	rr := httptest.NewRecorder()
+
+	handler.UpdateShare(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `status="ok"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteShareNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("DELETE FROM shares").
+		WithArgs(7, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	handler := NewHandler(db, &config.Config{})
+	req := withUser(httptest.NewRequest("GET", "/rest/deleteShare.view?id=7", nil), 1)
+	rr := httptest.NewRecorder()
+
+	handler.DeleteShare(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `code="70"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
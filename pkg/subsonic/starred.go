@@ -0,0 +1,171 @@
+package subsonic
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-postgres-example/pkg/db"
+)
+
+// Star is a handler for the /rest/star.view endpoint. Songs, albums and artists
+// are given with the repeatable id, albumId and artistId parameters.
+func (h *Handler) Star(w http.ResponseWriter, r *http.Request) {
+	h.setStarred(w, r, true)
+}
+
+// Unstar is a handler for the /rest/unstar.view endpoint
+func (h *Handler) Unstar(w http.ResponseWriter, r *http.Request) {
+	h.setStarred(w, r, false)
+}
+
+// setStarred stars or unstars every item named in the request.
+func (h *Handler) setStarred(w http.ResponseWriter, r *http.Request, star bool) {
+	userID, _ := GetUserIDFromContext(r.Context())
+	query := r.URL.Query()
+
+	params := []struct{ name, kind string }{
+		{"id", db.StarSong},
+		{"albumId", db.StarAlbum},
+		{"artistId", db.StarArtist},
+	}
+	items := make([][]int, len(params))
+	found := false
+	for i, p := range params {
+		ids, err := parseIntParams(query[p.name])
+		if err != nil {
+			respondWithError(w, r, 0, "Invalid "+p.name)
+			return
+		}
+		items[i] = ids
+		found = found || len(ids) > 0
+	}
+	if !found {
+		respondWithError(w, r, 10, "Required parameter 'id', 'albumId' or 'artistId' is missing")
+		return
+	}
+
+	for i, ids := range items {
+		if len(ids) == 0 {
+			continue
+		}
+		var err error
+		if star {
+			_, err = db.Star(h.DB, userID, params[i].kind, ids)
+		} else {
+			err = db.Unstar(h.DB, userID, params[i].kind, ids)
+		}
+		if err != nil {
+			respondWithError(w, r, 0, "Failed to update starred items")
+			return
+		}
+	}
+
+	respond(w, r, NewOkResponse())
+}
+
+// SetRating is a handler for the /rest/setRating.view endpoint. A rating of 0 removes the rating.
+func (h *Handler) SetRating(w http.ResponseWriter, r *http.Request) {
+	userID, _ := GetUserIDFromContext(r.Context())
+	query := r.URL.Query()
+
+	songID, err := strconv.Atoi(query.Get("id"))
+	if err != nil {
+		respondWithError(w, r, 10, "Required parameter 'id' is missing")
+		return
+	}
+	rating, err := strconv.Atoi(query.Get("rating"))
+	if err != nil {
+		respondWithError(w, r, 10, "Required parameter 'rating' is missing")
+		return
+	}
+	if rating < 0 || rating > 5 {
+		respondWithError(w, r, 0, "Rating must be between 0 and 5")
+		return
+	}
+
+	exists, err := db.SongExists(h.DB, songID)
+	if err != nil {
+		respondWithError(w, r, 0, "Failed to get song")
+		return
+	}
+	if !exists {
+		respondWithError(w, r, 70, "Song not found")
+		return
+	}
+
+	if err := db.RateSong(h.DB, userID, songID, rating); err != nil {
+		respondWithError(w, r, 0, "Failed to rate song")
+		return
+	}
+
+	respond(w, r, NewOkResponse())
+}
+
+// GetStarred is a handler for the /rest/getStarred.view endpoint
+func (h *Handler) GetStarred(w http.ResponseWriter, r *http.Request) {
+	artists, albums, songs, ok := h.starredItems(w, r)
+	if !ok {
+		return
+	}
+	response := NewOkResponse()
+	response.Starred = &Starred{Artists: artists, Albums: albums, Songs: songs}
+	respond(w, r, response)
+}
+
+// GetStarred2 is a handler for the /rest/getStarred2.view endpoint
+func (h *Handler) GetStarred2(w http.ResponseWriter, r *http.Request) {
+	artists, albums, songs, ok := h.starredItems(w, r)
+	if !ok {
+		return
+	}
+	response := NewOkResponse()
+	response.Starred2 = &Starred2{Artists: artists, Albums: albums, Songs: songs}
+	respond(w, r, response)
+}
+
+// starredItems loads the user's starred items in their Subsonic representation.
+func (h *Handler) starredItems(w http.ResponseWriter, r *http.Request) ([]Artist, []Album, []Song, bool) {
+	userID, _ := GetUserIDFromContext(r.Context())
+
+	favorites, err := db.GetFavorites(h.DB, userID)
+	if err != nil {
+		respondWithError(w, r, 0, "Failed to get starred items")
+		return nil, nil, nil, false
+	}
+
+	var artists []Artist
+	for _, artist := range favorites.Artists {
+		starred := artist.StarredAt
+		artists = append(artists, Artist{
+			ID:      strconv.Itoa(artist.ID),
+			Name:    artist.Name,
+			Starred: &starred,
+		})
+	}
+
+	var albums []Album
+	for _, album := range favorites.Albums {
+		starred := album.StarredAt
+		a := Album{
+			ID:      strconv.Itoa(album.ID),
+			Name:    album.Name,
+			Artist:  album.Artist,
+			Year:    album.Year,
+			Starred: &starred,
+		}
+		if album.ArtistID != 0 {
+			a.ArtistID = strconv.Itoa(album.ArtistID)
+		}
+		albums = append(albums, a)
+	}
+
+	var songs []Song
+	for _, song := range favorites.Songs {
+		starred := song.StarredAt
+		s := toSubsonicSong(song.Song)
+		s.Starred = &starred
+		songs = append(songs, s)
+	}
+
+	return artists, albums, songs, true
+}
@@ -0,0 +1,127 @@
+package subsonic
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-postgres-example/pkg/config"
+)
+
+func TestStar(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO starred_songs").
+		WithArgs(1, "{3,4}").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO starred_artists").
+		WithArgs(1, "{8}").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	handler := NewHandler(db, &config.Config{})
+
+	req := withUser(httptest.NewRequest("GET", "/rest/star.view?id=3&id=4&artistId=8", nil), 1)
+	rr := httptest.NewRecorder()
+
+	handler.Star(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `status="ok"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStarMissingParameter(t *testing.T) {
+	handler := NewHandler(nil, &config.Config{})
+
+	req := withUser(httptest.NewRequest("GET", "/rest/unstar.view", nil), 1)
+	rr := httptest.NewRecorder()
+
+	handler.Unstar(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `code="10"`)
+}
+
+func TestSetRating(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec("UPDATE user_songs SET rating = NULL").
+		WithArgs(1, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(6).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	handler := NewHandler(db, &config.Config{})
+
+	rr := httptest.NewRecorder()
+	handler.SetRating(rr, withUser(httptest.NewRequest("GET", "/rest/setRating.view?id=5&rating=0", nil), 1))
+	assert.Contains(t, rr.Body.String(), `status="ok"`)
+
+	rr = httptest.NewRecorder()
+	handler.SetRating(rr, withUser(httptest.NewRequest("GET", "/rest/setRating.view?id=6&rating=4", nil), 1))
+	assert.Contains(t, rr.Body.String(), `code="70"`)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetStarred2JSON(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	starredAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("FROM starred_songs").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "fingerprint_hash", "file_path", "title", "artist", "album", "year",
+			"genre_id", "genre", "duration", "bitrate", "file_size", "last_modified", "starred_at",
+		}).AddRow(9, "hash", "/music/a.flac", "Teardrop", "Massive Attack", "Mezzanine", 1998, 1, "Trip Hop", 330, 900, 1000, time.Now(), starredAt))
+	mock.ExpectQuery("FROM starred_albums").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "artist", "artist_id", "year", "starred_at"}))
+	mock.ExpectQuery("FROM starred_artists").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "starred_at"}).AddRow(2, "Massive Attack", starredAt))
+
+	handler := NewHandler(db, &config.Config{})
+
+	req := withUser(httptest.NewRequest("GET", "/rest/getStarred2.view?f=json", nil), 1)
+	rr := httptest.NewRecorder()
+
+	handler.GetStarred2(rr, req)
+
+	var body struct {
+		Response struct {
+			Status   string `json:"status"`
+			Starred2 struct {
+				Artists []Artist `json:"artist"`
+				Songs   []Song   `json:"song"`
+			} `json:"starred2"`
+		} `json:"subsonic-response"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, "ok", body.Response.Status)
+	require.Len(t, body.Response.Starred2.Songs, 1)
+	assert.Equal(t, "flac", body.Response.Starred2.Songs[0].Suffix)
+	require.NotNil(t, body.Response.Starred2.Songs[0].Starred)
+	assert.True(t, body.Response.Starred2.Songs[0].Starred.Equal(starredAt))
+	require.Len(t, body.Response.Starred2.Artists, 1)
+	assert.Equal(t, "Massive Attack", body.Response.Starred2.Artists[0].Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
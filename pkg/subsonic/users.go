@@ -0,0 +1,148 @@
+package subsonic
+
+import (
+	"database/sql"
+	"net/http"
+
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/db"
+	"go-postgres-example/pkg/models"
+)
+
+// GetUser is a handler for the /rest/getUser.view endpoint. Users may look up
+// themselves; looking up anyone else needs the admin permission. The Subsonic
+// roles are derived from the permissions of the user's role.
+func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
+	userID, _ := GetUserIDFromContext(r.Context())
+
+	username := r.URL.Query().Get("username")
+	if username == "" {
+		respondWithError(w, r, 10, "Required parameter 'username' is missing")
+		return
+	}
+	targetID, ok := h.userParam(w, r, userID, username, "User is not authorized to get other users")
+	if !ok {
+		return
+	}
+
+	user, err := db.GetUser(h.DB, targetID)
+	if err != nil {
+		respondWithError(w, r, 0, "Failed to get user")
+		return
+	}
+	_, permissions, err := db.GetUserPermissions(h.DB, targetID)
+	if err != nil {
+		respondWithError(w, r, 0, "Failed to get user")
+		return
+	}
+
+	response := NewOkResponse()
+	response.User = toSubsonicUser(user, permissions)
+	respond(w, r, response)
+}
+
+// ChangePassword is a handler for the /rest/changePassword.view endpoint. Users
+// may change their own password, but not when signed in with an app password,
+// which must not be enough to take over the account; changing anyone else's
+// needs the admin permission. The user is logged out of their web sessions.
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, _ := GetUserIDFromContext(r.Context())
+	query := r.URL.Query()
+
+	username := query.Get("username")
+	if username == "" {
+		respondWithError(w, r, 10, "Required parameter 'username' is missing")
+		return
+	}
+	password, err := decodePassword(query.Get("password"))
+	if err != nil || password == "" {
+		respondWithError(w, r, 10, "Required parameter 'password' is missing")
+		return
+	}
+	targetID, ok := h.userParam(w, r, userID, username, "User is not authorized to change other users' passwords")
+	if !ok {
+		return
+	}
+	if targetID == userID && IsAppPasswordAuthenticated(r.Context()) {
+		respondWithError(w, r, 50, "Passwords cannot be changed with an app password")
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		respondWithError(w, r, 0, "Failed to hash password")
+		return
+	}
+	if err := db.SetUserPassword(h.DB, targetID, hashedPassword); err != nil {
+		respondWithError(w, r, 0, "Failed to change password")
+		return
+	}
+	respond(w, r, NewOkResponse())
+}
+
+// userParam returns the ID of the user named by the username parameter. Users
+// other than the authenticated one need the admin permission; without it error
+// 50 is written with the given message.
+func (h *Handler) userParam(w http.ResponseWriter, r *http.Request, userID int, username string, message string) (int, bool) {
+	ownUsername, err := db.GetUsernameByID(h.DB, userID)
+	if err != nil {
+		respondWithError(w, r, 0, "Failed to get user")
+		return 0, false
+	}
+	if username == ownUsername {
+		return userID, true
+	}
+	if !h.checkPermission(w, r, userID, models.PermissionAdmin, message) {
+		return 0, false
+	}
+	targetID, err := db.GetUserIDByUsername(h.DB, username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, r, 70, "User not found")
+		} else {
+			respondWithError(w, r, 0, "Failed to get user")
+		}
+		return 0, false
+	}
+	return targetID, true
+}
+
+// toSubsonicUser maps a user's permissions to Subsonic roles. Every user may
+// stream, scrobble, change their settings and keep playlists; there are no
+// podcasts or video.
+func toSubsonicUser(user *models.User, permissions []string) *User {
+	has := make(map[string]bool, len(permissions))
+	for _, p := range permissions {
+		has[p] = true
+	}
+	return &User{
+		Username:          user.Username,
+		Email:             user.Email,
+		ScrobblingEnabled: true,
+		AdminRole:         has[models.PermissionAdmin],
+		SettingsRole:      true,
+		DownloadRole:      has[models.PermissionDownload],
+		UploadRole:        has[models.PermissionUpload],
+		PlaylistRole:      true,
+		CoverArtRole:      has[models.PermissionEditMetadata],
+		CommentRole:       has[models.PermissionEditMetadata],
+		StreamRole:        true,
+		JukeboxRole:       has[models.PermissionJukebox],
+		ShareRole:         has[models.PermissionShare],
+	}
+}
+
+// checkPermission reports whether a user's role grants a permission, writing
+// error 50 with the given message if it does not.
+func (h *Handler) checkPermission(w http.ResponseWriter, r *http.Request, userID int, permission string, message string) bool {
+	allowed, err := db.HasPermission(h.DB, userID, permission)
+	if err != nil {
+		respondWithError(w, r, 0, "Failed to get user")
+		return false
+	}
+	if !allowed {
+		respondWithError(w, r, 50, message)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+package subsonic
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"go-postgres-example/pkg/config"
+)
+
+func TestGetUserMapsPermissionsToRoles(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT username FROM users").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("bob"))
+	mock.ExpectQuery("SELECT (.+) FROM users WHERE id").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "username", "email", "created_at", "is_admin", "role", "totp_enabled", "disabled",
+		}).AddRow(2, "bob", "bob@example.com", time.Now(), false, "listener", false, false))
+	mock.ExpectQuery("SELECT role, (.+) FROM users").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"role", "is_admin"}).AddRow("listener", false))
+	mock.ExpectQuery("SELECT permission FROM role_permissions").
+		WithArgs("listener").
+		WillReturnRows(sqlmock.NewRows([]string{"permission"}).AddRow("download"))
+
+	handler := NewHandler(db, &config.Config{})
+	req := withUser(httptest.NewRequest("GET", "/rest/getUser.view?username=bob", nil), 2)
+	rr := httptest.NewRecorder()
+
+	handler.GetUser(rr, req)
+
+	body := rr.Body.String()
+	assert.Contains(t, body, `status="ok"`)
+	assert.Contains(t, body, `username="bob"`)
+	assert.Contains(t, body, `adminRole="false"`)
+	assert.Contains(t, body, `downloadRole="true"`)
+	assert.Contains(t, body, `uploadRole="false"`)
+	assert.Contains(t, body, `streamRole="true"`)
+	assert.Contains(t, body, `shareRole="false"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserOtherRequiresAdmin(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT username FROM users").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("bob"))
+	mock.ExpectQuery("FROM role_permissions").
+		WithArgs(2, "admin").
+		WillReturnRows(sqlmock.NewRows([]string{"allowed"}).AddRow(false))
+
+	handler := NewHandler(db, &config.Config{})
+	req := withUser(httptest.NewRequest("GET", "/rest/getUser.view?username=alice", nil), 2)
+	rr := httptest.NewRecorder()
+
+	handler.GetUser(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `code="50"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestChangePasswordOwnAccount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT username FROM users").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("bob"))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET password_hash").
+		WithArgs(2, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE sessions SET revoked_at").
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	handler := NewHandler(db, &config.Config{})
+	// "enc:" followed by the hex encoded password
+	req := withUser(httptest.NewRequest("GET", "/rest/changePassword.view?username=bob&password=enc:736563726574", nil), 2)
+	rr := httptest.NewRecorder()
+
+	handler.ChangePassword(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `status="ok"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestChangePasswordOtherUserRequiresAdmin(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT username FROM users").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("bob"))
+	mock.ExpectQuery("FROM role_permissions").
+		WithArgs(2, "admin").
+		WillReturnRows(sqlmock.NewRows([]string{"allowed"}).AddRow(false))
+
+	handler := NewHandler(db, &config.Config{})
+	req := withUser(httptest.NewRequest("GET", "/rest/changePassword.view?username=alice&password=secret", nil), 2)
+	rr := httptest.NewRecorder()
+
+	handler.ChangePassword(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `code="50"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestChangePasswordRefusesAppPassword(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT username FROM users").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("bob"))
+
+	handler := NewHandler(db, &config.Config{})
+	req := withUser(httptest.NewRequest("GET", "/rest/changePassword.view?username=bob&password=secret", nil), 2)
+	req = req.WithContext(context.WithValue(req.Context(), appPasswordKey, true))
+	rr := httptest.NewRecorder()
+
+	handler.ChangePassword(rr, req)
+
+	assert.Contains(t, rr.Body.String(), `code="50"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,380 @@
+// Package tus implements the server side of the tus resumable upload protocol
+// 1.0 (https://tus.io/protocols/resumable-upload), with the creation,
+// expiration, termination and checksum extensions.
+package tus
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-postgres-example/pkg/middleware"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Version is the version of the protocol spoken.
+const Version = "1.0.0"
+
+// Extensions are the protocol extensions supported.
+const Extensions = "creation,expiration,termination,checksum"
+
+// StatusChecksumMismatch is the status of chunks not matching their checksum.
+const StatusChecksumMismatch = 460
+
+// checksumAlgorithms are the hashes accepted in Upload-Checksum headers.
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+// Handler serves uploads from a Store. Uploads belong to the user who created
+// them; other users get 404 Not Found.
+type Handler struct {
+	Store *Store
+	// MaxSize is the largest upload accepted, 0 for no limit
+	MaxSize int64
+	// Expiry is how long an upload is kept after it was last written to
+	Expiry time.Duration
+
+	// BeforeCreate, when set, may refuse a new upload by writing an error
+	// response and returning false.
+	BeforeCreate func(w http.ResponseWriter, r *http.Request, info Info) bool
+	// Complete is called in the background with each upload once all of its
+	// data has arrived, and must take the data away from path before
+	// returning. An error rejects the upload, and its message is shown to the
+	// client in the Upload-Error header.
+	Complete func(info Info, path string) error
+}
+
+// Routes returns the protocol's routes, to be mounted on the upload endpoint
+// behind authentication.
+func (h *Handler) Routes() *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(methodOverride, h.requireVersion)
+
+	r.Options("/", h.Options)
+	r.Post("/", h.Create)
+	r.Head("/{uploadID}", h.Head)
+	r.Patch("/{uploadID}", h.Patch)
+	r.Delete("/{uploadID}", h.Delete)
+	r.Options("/{uploadID}", h.Options)
+	return r
+}
+
+// methodOverride lets clients that cannot send PATCH or DELETE send POST with
+// an X-HTTP-Method-Override header instead.
+func methodOverride(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if override := r.Header.Get("X-HTTP-Method-Override"); override != "" && r.Method == http.MethodPost {
+			r.Method = strings.ToUpper(override)
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				rctx.RouteMethod = r.Method
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireVersion refuses requests for other versions of the protocol.
+func (h *Handler) requireVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Tus-Resumable", Version)
+		if r.Method != http.MethodOptions && r.Header.Get("Tus-Resumable") != Version {
+			w.Header().Set("Tus-Version", Version)
+			http.Error(w, "Unsupported tus version", http.StatusPreconditionFailed)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Options describes the protocol support of the server.
+func (h *Handler) Options(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Version", Version)
+	w.Header().Set("Tus-Extension", Extensions)
+	if h.MaxSize > 0 {
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(h.MaxSize, 10))
+	}
+	algorithms := make([]string, 0, len(checksumAlgorithms))
+	for name := range checksumAlgorithms {
+		algorithms = append(algorithms, name)
+	}
+	sort.Strings(algorithms)
+	w.Header().Set("Tus-Checksum-Algorithm", strings.Join(algorithms, ","))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Create starts an upload of Upload-Length bytes, described by
+// Upload-Metadata, and returns its URL in the Location header.
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		http.Error(w, "Invalid Upload-Length", http.StatusBadRequest)
+		return
+	}
+	if h.MaxSize > 0 && size > h.MaxSize {
+		http.Error(w, "Upload is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	metadata, err := ParseMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		http.Error(w, "Invalid Upload-Metadata", http.StatusBadRequest)
+		return
+	}
+
+	info := Info{UserID: userID, Size: size, Metadata: metadata, ExpiresAt: time.Now().Add(h.Expiry)}
+	if h.BeforeCreate != nil && !h.BeforeCreate(w, r, info) {
+		return
+	}
+	info, err = h.Store.Create(info)
+	if err != nil {
+		log.Printf("Failed to create upload: %v", err)
+		http.Error(w, "Failed to create upload", http.StatusInternalServerError)
+		return
+	}
+	if size == 0 {
+		if err := h.complete(&info); err != nil {
+			log.Printf("Failed to finish upload %s: %v", info.ID, err)
+			http.Error(w, "Failed to create upload", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+info.ID)
+	w.Header().Set("Upload-Expires", info.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// Head returns how much of an upload has arrived, so a client can resume it.
+// Once it is complete, Upload-Status tells whether it is still being
+// processed, was accepted or was rejected, with the reason in Upload-Error.
+func (h *Handler) Head(w http.ResponseWriter, r *http.Request) {
+	info, ok := h.upload(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(info.Size, 10))
+	if len(info.Metadata) > 0 {
+		w.Header().Set("Upload-Metadata", FormatMetadata(info.Metadata))
+	}
+	w.Header().Set("Upload-Expires", info.ExpiresAt.UTC().Format(http.TimeFormat))
+	setStatus(w, info)
+	w.WriteHeader(http.StatusOK)
+}
+
+// Patch appends the request body to an upload at Upload-Offset, which must be
+// the upload's current offset. With Upload-Checksum, the body is kept only if
+// it matches.
+func (h *Handler) Patch(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		http.Error(w, "Content-Type must be application/offset+octet-stream", http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "Invalid Upload-Offset", http.StatusBadRequest)
+		return
+	}
+	var checksum hash.Hash
+	var expected []byte
+	if header := r.Header.Get("Upload-Checksum"); header != "" {
+		var valid bool
+		if checksum, expected, valid = parseChecksum(header); !valid {
+			http.Error(w, "Invalid or unsupported Upload-Checksum", http.StatusBadRequest)
+			return
+		}
+	}
+
+	unlock, ok := h.Store.Lock(chi.URLParam(r, "uploadID"))
+	if !ok {
+		http.Error(w, "Upload is in use by another request", http.StatusLocked)
+		return
+	}
+	defer unlock()
+	info, ok := h.upload(w, r)
+	if !ok {
+		return
+	}
+	if info.Completed || offset != info.Offset {
+		http.Error(w, "Upload-Offset does not match the upload", http.StatusConflict)
+		return
+	}
+
+	err = h.Store.Append(&info, r.Body, checksum, expected, time.Now().Add(h.Expiry))
+	switch {
+	case errors.Is(err, ErrChecksumMismatch):
+		http.Error(w, "Checksum mismatch", StatusChecksumMismatch)
+		return
+	case errors.Is(err, ErrTooLarge):
+		http.Error(w, "Chunk exceeds Upload-Length", http.StatusRequestEntityTooLarge)
+		return
+	case err != nil:
+		// The client resumes from the offset it gets with HEAD
+		log.Printf("Upload %s interrupted at %d bytes: %v", info.ID, info.Offset, err)
+		http.Error(w, "Failed to write upload", http.StatusInternalServerError)
+		return
+	}
+	if info.Offset == info.Size {
+		if err := h.complete(&info); err != nil {
+			log.Printf("Failed to finish upload %s: %v", info.ID, err)
+			http.Error(w, "Failed to finish upload", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	w.Header().Set("Upload-Expires", info.ExpiresAt.UTC().Format(http.TimeFormat))
+	setStatus(w, info)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Delete terminates an upload and deletes its data.
+func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	unlock, ok := h.Store.Lock(chi.URLParam(r, "uploadID"))
+	if !ok {
+		http.Error(w, "Upload is in use by another request", http.StatusLocked)
+		return
+	}
+	defer unlock()
+	info, ok := h.upload(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Store.Remove(info.ID); err != nil && err != ErrNotFound {
+		http.Error(w, "Failed to delete upload", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// upload returns the upload named in the URL, writing 404 Not Found if it does
+// not exist, has expired or belongs to another user.
+func (h *Handler) upload(w http.ResponseWriter, r *http.Request) (Info, bool) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return Info{}, false
+	}
+	info, err := h.Store.Get(chi.URLParam(r, "uploadID"))
+	if err == nil && (info.UserID != userID || !info.ExpiresAt.After(time.Now())) {
+		err = ErrNotFound
+	}
+	if err != nil {
+		if err == ErrNotFound {
+			http.Error(w, "Upload not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get upload", http.StatusInternalServerError)
+		}
+		return info, false
+	}
+	return info, true
+}
+
+// complete marks a finished upload completed and hands it to the Complete
+// callback in the background, so the request finishing it does not wait for it
+// to be processed.
+func (h *Handler) complete(info *Info) error {
+	path, err := h.Store.Finish(info)
+	if err != nil {
+		return err
+	}
+	go h.process(*info, path)
+	return nil
+}
+
+// process runs the Complete callback on a completed upload and records the
+// outcome for clients to see.
+func (h *Handler) process(info Info, path string) {
+	status, message := UploadAccepted, ""
+	if h.Complete != nil {
+		if err := h.Complete(info, path); err != nil {
+			log.Printf("Rejected upload %s: %v", info.ID, err)
+			status, message = UploadRejected, err.Error()
+		}
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Failed to remove upload %s: %v", info.ID, err)
+	}
+	if err := h.Store.SetStatus(info.ID, status, message); err != nil {
+		log.Printf("Failed to record status of upload %s: %v", info.ID, err)
+	}
+}
+
+// setStatus describes what became of a completed upload.
+func setStatus(w http.ResponseWriter, info Info) {
+	if info.Status != "" {
+		w.Header().Set("Upload-Status", info.Status)
+	}
+	if info.Error != "" {
+		w.Header().Set("Upload-Error", info.Error)
+	}
+}
+
+// parseChecksum parses an Upload-Checksum header, "<algorithm> <base64 hash>",
+// returning a new hash of the algorithm and the expected sum.
+func parseChecksum(header string) (hash.Hash, []byte, bool) {
+	algorithm, encoded, ok := strings.Cut(header, " ")
+	newHash, known := checksumAlgorithms[algorithm]
+	if !ok || !known {
+		return nil, nil, false
+	}
+	expected, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, false
+	}
+	return newHash(), expected, true
+}
+
+// ParseMetadata parses an Upload-Metadata header: comma-separated pairs of a
+// key and, optionally after a space, a base64 encoded value.
+func ParseMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("empty metadata key")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+// FormatMetadata formats metadata for an Upload-Metadata header.
+func FormatMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + " " + base64.StdEncoding.EncodeToString([]byte(metadata[key]))
+	}
+	return strings.Join(pairs, ",")
+}
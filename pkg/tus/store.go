@@ -0,0 +1,319 @@
+package tus
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned for uploads that do not exist or have expired.
+	ErrNotFound = errors.New("upload not found")
+	// ErrChecksumMismatch is returned when a chunk does not match its checksum.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrTooLarge is returned when a chunk goes past the end of the upload.
+	ErrTooLarge = errors.New("chunk exceeds upload length")
+)
+
+// idPattern matches upload IDs, keeping them from naming other files.
+var idPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Info describes an upload. It is kept as JSON next to the upload's data.
+type Info struct {
+	ID        string            `json:"id"`
+	UserID    int               `json:"user_id"`
+	Size      int64             `json:"size"`
+	Offset    int64             `json:"-"`
+	Metadata  map[string]string `json:"metadata"`
+	Completed bool              `json:"completed"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	// Status is what became of a completed upload, one of the Upload*
+	// statuses, and Error why it was rejected
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Statuses of completed uploads.
+const (
+	UploadProcessing = "processing"
+	UploadAccepted   = "accepted"
+	UploadRejected   = "rejected"
+)
+
+// Filename returns the name the client gave the uploaded file, if any.
+func (info Info) Filename() string {
+	if name := info.Metadata["filename"]; name != "" {
+		return name
+	}
+	return info.Metadata["name"]
+}
+
+// Store keeps partial uploads in a directory, each as a data file and an info
+// file, so they can be resumed after a restart.
+type Store struct {
+	Dir string
+
+	mu    sync.Mutex
+	locks map[string]bool
+}
+
+// NewStore creates a new Store in dir, which is created when needed.
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir, locks: make(map[string]bool)}
+}
+
+func (s *Store) infoPath(id string) string { return filepath.Join(s.Dir, id+".info") }
+
+// DataPath returns the path of an upload's data.
+func (s *Store) DataPath(id string) string { return filepath.Join(s.Dir, id+".bin") }
+
+// processingPath is where a completed upload's data waits to be processed.
+func (s *Store) processingPath(id string) string { return filepath.Join(s.Dir, id+".processing") }
+
+// Create starts a new, empty upload and returns it with its ID set.
+func (s *Store) Create(info Info) (Info, error) {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return info, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return info, err
+	}
+	info.ID = hex.EncodeToString(id)
+	info.Offset = 0
+
+	data, err := os.OpenFile(s.DataPath(info.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return info, err
+	}
+	data.Close()
+	if err := s.save(info); err != nil {
+		os.Remove(s.DataPath(info.ID))
+		return info, err
+	}
+	return info, nil
+}
+
+// Get returns an upload, or ErrNotFound.
+func (s *Store) Get(id string) (Info, error) {
+	var info Info
+	if !idPattern.MatchString(id) {
+		return info, ErrNotFound
+	}
+	content, err := os.ReadFile(s.infoPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return info, ErrNotFound
+	} else if err != nil {
+		return info, err
+	}
+	if err := json.Unmarshal(content, &info); err != nil {
+		return info, fmt.Errorf("invalid upload info %s: %w", id, err)
+	}
+
+	if info.Completed {
+		info.Offset = info.Size
+		return info, nil
+	}
+	stat, err := os.Stat(s.DataPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return info, ErrNotFound
+	} else if err != nil {
+		return info, err
+	}
+	info.Offset = stat.Size()
+	return info, nil
+}
+
+// save writes an upload's info, replacing it atomically.
+func (s *Store) save(info Info) error {
+	content, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	tmp := s.infoPath(info.ID) + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.infoPath(info.ID))
+}
+
+// Lock reserves an upload for one request at a time. It returns false if the
+// upload is already locked, and otherwise a function releasing the lock.
+func (s *Store) Lock(id string) (func(), bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locks[id] {
+		return nil, false
+	}
+	s.locks[id] = true
+	return func() {
+		s.mu.Lock()
+		delete(s.locks, id)
+		s.mu.Unlock()
+	}, true
+}
+
+// Append writes the data read from r at the end of an upload and moves its
+// expiry to expiresAt, updating info. Data received before the connection
+// fails is kept, so the client can resume after it. When checksum is set, the
+// chunk is kept only if all of it arrives and its hash equals expected,
+// otherwise ErrChecksumMismatch is returned. A chunk going past the end of the
+// upload is discarded with ErrTooLarge.
+func (s *Store) Append(info *Info, r io.Reader, checksum hash.Hash, expected []byte, expiresAt time.Time) error {
+	data, err := os.OpenFile(s.DataPath(info.ID), os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+	if _, err := data.Seek(info.Offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	remaining := info.Size - info.Offset
+	var w io.Writer = data
+	if checksum != nil {
+		w = io.MultiWriter(data, checksum)
+	}
+	written, copyErr := io.Copy(w, io.LimitReader(r, remaining))
+	if copyErr == nil && written == remaining {
+		// Anything past the end of the upload is refused
+		if n, _ := r.Read(make([]byte, 1)); n > 0 {
+			copyErr = ErrTooLarge
+		}
+	}
+	if copyErr == nil && checksum != nil && !bytes.Equal(checksum.Sum(nil), expected) {
+		copyErr = ErrChecksumMismatch
+	}
+	if copyErr == ErrTooLarge || (checksum != nil && copyErr != nil) {
+		if err := data.Truncate(info.Offset); err != nil {
+			return err
+		}
+		written = 0
+	}
+
+	info.Offset += written
+	info.ExpiresAt = expiresAt
+	if err := s.save(*info); err != nil {
+		return err
+	}
+	return copyErr
+}
+
+// Finish marks an upload completed and being processed, so that clients
+// asking for its offset see it is done until it expires. Its data is moved out
+// of the way to the returned path, to be taken away by whatever processes it.
+func (s *Store) Finish(info *Info) (string, error) {
+	path := s.processingPath(info.ID)
+	if err := os.Rename(s.DataPath(info.ID), path); err != nil {
+		return "", err
+	}
+	info.Completed = true
+	info.Status = UploadProcessing
+	if err := s.save(*info); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// SetStatus records what became of a completed upload. An upload removed in
+// the meantime is left removed.
+func (s *Store) SetStatus(id, status, message string) error {
+	info, err := s.Get(id)
+	if err == ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	info.Status = status
+	info.Error = message
+	return s.save(info)
+}
+
+// Remove deletes an upload.
+func (s *Store) Remove(id string) error {
+	if !idPattern.MatchString(id) {
+		return ErrNotFound
+	}
+	for _, path := range []string{s.DataPath(id), s.processingPath(id)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Remove(s.infoPath(id)); errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RemoveExpired deletes the uploads that expired before now, abandoned ones
+// as well as completed ones, and returns how many were deleted.
+func (s *Store) RemoveExpired(now time.Time) (int, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".info")
+		if !ok || !idPattern.MatchString(id) {
+			continue
+		}
+		unlock, ok := s.Lock(id)
+		if !ok {
+			continue // In use, so not abandoned
+		}
+		info, err := s.Get(id)
+		if err == nil && info.ExpiresAt.After(now) {
+			unlock()
+			continue
+		}
+		if err != nil && err != ErrNotFound {
+			log.Printf("Removing unreadable upload %s: %v", id, err)
+		}
+		if err := s.Remove(id); err != nil && err != ErrNotFound {
+			unlock()
+			return removed, err
+		}
+		unlock()
+		removed++
+	}
+	return removed, nil
+}
+
+// Run deletes expired uploads every interval until the context is cancelled.
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := s.RemoveExpired(time.Now()); err != nil {
+			log.Printf("Failed to remove expired uploads: %v", err)
+		} else if n > 0 {
+			log.Printf("Removed %d expired uploads", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package tus
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"go-postgres-example/pkg/auth"
+	"go-postgres-example/pkg/middleware"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "test-secret"
+
+// testSessionID is the login session of the tokens of testServer.do.
+const testSessionID = 7
+
+// testServer serves a Handler at /files, recording the completed uploads.
+type testServer struct {
+	http.Handler
+	handler *Handler
+	mock    sqlmock.Sqlmock
+	reject  error // Returned for completed uploads
+
+	mu        sync.Mutex
+	completed map[string]string // Content by filename
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	s := &testServer{completed: make(map[string]string)}
+	s.handler = &Handler{
+		Store:   NewStore(t.TempDir()),
+		MaxSize: 1024,
+		Expiry:  time.Hour,
+		Complete: func(info Info, path string) error {
+			content, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			s.mu.Lock()
+			s.completed[info.Filename()] = string(content)
+			s.mu.Unlock()
+			return s.reject
+		},
+	}
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	s.mock = mock
+	r := chi.NewRouter()
+	r.Use(middleware.Authenticator(testSecret, conn))
+	r.Mount("/files", s.handler.Routes())
+	s.Handler = r
+	return s
+}
+
+// do sends a tus request as a user, with the given headers as name/value pairs.
+func (s *testServer) do(t *testing.T, userID int, method, target string, body io.Reader, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	token, err := auth.GenerateAccessToken(userID, testSessionID, testSecret, time.Minute)
+	require.NoError(t, err)
+	s.mock.ExpectQuery("FROM sessions").
+		WithArgs(testSessionID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	req := httptest.NewRequest(method, target, body)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Tus-Resumable", Version)
+	for i := 0; i < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	return rr
+}
+
+// create starts an upload of size bytes named song.mp3 and returns its URL.
+func (s *testServer) create(t *testing.T, size string) string {
+	t.Helper()
+	rr := s.do(t, 1, "POST", "/files", nil,
+		"Upload-Length", size,
+		"Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("song.mp3")),
+	)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	return rr.Header().Get("Location")
+}
+
+func (s *testServer) patch(t *testing.T, location, offset string, body io.Reader, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	headers = append(headers, "Content-Type", "application/offset+octet-stream", "Upload-Offset", offset)
+	return s.do(t, 1, "PATCH", location, body, headers...)
+}
+
+// waitFor waits until an upload has the given status, returning its last
+// HEAD response.
+func (s *testServer) waitFor(t *testing.T, location, status string) *httptest.ResponseRecorder {
+	t.Helper()
+	var rr *httptest.ResponseRecorder
+	require.Eventually(t, func() bool {
+		rr = s.do(t, 1, "HEAD", location, nil)
+		return rr.Header().Get("Upload-Status") == status
+	}, time.Second, 10*time.Millisecond)
+	return rr
+}
+
+// contents returns the completed uploads so far.
+func (s *testServer) contents() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return maps.Clone(s.completed)
+}
+
+func TestOptions(t *testing.T) {
+	s := newTestServer(t)
+
+	rr := s.do(t, 1, "OPTIONS", "/files", nil)
+
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Equal(t, Version, rr.Header().Get("Tus-Version"))
+	assert.Equal(t, Extensions, rr.Header().Get("Tus-Extension"))
+	assert.Equal(t, "1024", rr.Header().Get("Tus-Max-Size"))
+	assert.Equal(t, "md5,sha1,sha256", rr.Header().Get("Tus-Checksum-Algorithm"))
+}
+
+func TestUploadInChunks(t *testing.T) {
+	s := newTestServer(t)
+
+	location := s.create(t, "11")
+	assert.True(t, strings.HasPrefix(location, "/files/"))
+
+	rr := s.patch(t, location, "0", strings.NewReader("hello "))
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	assert.Equal(t, "6", rr.Header().Get("Upload-Offset"))
+	assert.Equal(t, Version, rr.Header().Get("Tus-Resumable"))
+
+	rr = s.do(t, 1, "HEAD", location, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "6", rr.Header().Get("Upload-Offset"))
+	assert.Equal(t, "11", rr.Header().Get("Upload-Length"))
+	assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+	assert.Empty(t, rr.Header().Get("Upload-Status"))
+	assert.Empty(t, s.contents())
+
+	sum := sha1.Sum([]byte("world"))
+	rr = s.patch(t, location, "6", strings.NewReader("world"),
+		"Upload-Checksum", "sha1 "+base64.StdEncoding.EncodeToString(sum[:]))
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	assert.Equal(t, "11", rr.Header().Get("Upload-Offset"))
+	assert.Equal(t, UploadProcessing, rr.Header().Get("Upload-Status"))
+
+	// A client that missed the last response sees the upload is done
+	rr = s.waitFor(t, location, UploadAccepted)
+	assert.Equal(t, "11", rr.Header().Get("Upload-Offset"))
+	assert.Empty(t, rr.Header().Get("Upload-Error"))
+	assert.Equal(t, map[string]string{"song.mp3": "hello world"}, s.contents())
+	rr = s.patch(t, location, "11", strings.NewReader(""))
+	assert.Equal(t, http.StatusConflict, rr.Code)
+}
+
+func TestPatchKeepsDataOfInterruptedRequest(t *testing.T) {
+	s := newTestServer(t)
+	location := s.create(t, "10")
+
+	body := io.MultiReader(strings.NewReader("abcd"), iotest.ErrReader(errors.New("connection reset")))
+	rr := s.patch(t, location, "0", body)
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+
+	rr = s.do(t, 1, "HEAD", location, nil)
+	assert.Equal(t, "4", rr.Header().Get("Upload-Offset"))
+
+	rr = s.patch(t, location, "4", strings.NewReader("efghij"))
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	s.waitFor(t, location, UploadAccepted)
+	assert.Equal(t, "abcdefghij", s.contents()["song.mp3"])
+}
+
+func TestRejectedUploadShowsError(t *testing.T) {
+	s := newTestServer(t)
+	s.reject = errors.New("Invalid archive")
+	location := s.create(t, "5")
+
+	rr := s.patch(t, location, "0", strings.NewReader("abcde"))
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	rr = s.waitFor(t, location, UploadRejected)
+	assert.Equal(t, "5", rr.Header().Get("Upload-Offset"))
+	assert.Equal(t, "Invalid archive", rr.Header().Get("Upload-Error"))
+	id := strings.TrimPrefix(location, "/files/")
+	_, err := os.Stat(s.handler.Store.processingPath(id))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPatchRejectsWrongOffset(t *testing.T) {
+	s := newTestServer(t)
+	location := s.create(t, "10")
+
+	rr := s.patch(t, location, "3", strings.NewReader("abc"))
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+}
+
+func TestPatchDiscardsChecksumMismatch(t *testing.T) {
+	s := newTestServer(t)
+	location := s.create(t, "10")
+
+	sum := sha1.Sum([]byte("other"))
+	rr := s.patch(t, location, "0", strings.NewReader("abcde"),
+		"Upload-Checksum", "sha1 "+base64.StdEncoding.EncodeToString(sum[:]))
+	assert.Equal(t, StatusChecksumMismatch, rr.Code)
+
+	rr = s.do(t, 1, "HEAD", location, nil)
+	assert.Equal(t, "0", rr.Header().Get("Upload-Offset"))
+
+	rr = s.patch(t, location, "0", strings.NewReader("abcde"), "Upload-Checksum", "crc32 AAAA")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestPatchRejectsDataPastLength(t *testing.T) {
+	s := newTestServer(t)
+	location := s.create(t, "3")
+
+	rr := s.patch(t, location, "0", strings.NewReader("abcdef"))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	rr = s.do(t, 1, "HEAD", location, nil)
+	assert.Equal(t, "0", rr.Header().Get("Upload-Offset"))
+	assert.Empty(t, s.contents())
+}
+
+func TestUploadsBelongToTheirUser(t *testing.T) {
+	s := newTestServer(t)
+	location := s.create(t, "10")
+
+	assert.Equal(t, http.StatusNotFound, s.do(t, 2, "HEAD", location, nil).Code)
+	assert.Equal(t, http.StatusNotFound, s.do(t, 2, "DELETE", location, nil).Code)
+	assert.Equal(t, http.StatusOK, s.do(t, 1, "HEAD", location, nil).Code)
+}
+
+func TestDeleteTerminatesUpload(t *testing.T) {
+	s := newTestServer(t)
+	location := s.create(t, "10")
+
+	assert.Equal(t, http.StatusNoContent, s.do(t, 1, "DELETE", location, nil).Code)
+	assert.Equal(t, http.StatusNotFound, s.do(t, 1, "HEAD", location, nil).Code)
+	assert.Equal(t, http.StatusNotFound, s.do(t, 1, "HEAD", "/files/../../etc/passwd", nil).Code)
+}
+
+func TestMethodOverride(t *testing.T) {
+	s := newTestServer(t)
+	location := s.create(t, "10")
+
+	rr := s.do(t, 1, "POST", location, nil, "X-HTTP-Method-Override", "DELETE")
+
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+}
+
+func TestCreateChecks(t *testing.T) {
+	s := newTestServer(t)
+
+	rr := s.do(t, 1, "POST", "/files", nil, "Upload-Length", "10", "Tus-Resumable", "0.2.2")
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+	assert.Equal(t, Version, rr.Header().Get("Tus-Version"))
+
+	rr = s.do(t, 1, "POST", "/files", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = s.do(t, 1, "POST", "/files", nil, "Upload-Length", "2048")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+
+	s.handler.BeforeCreate = func(w http.ResponseWriter, r *http.Request, info Info) bool {
+		assert.Equal(t, 1, info.UserID)
+		http.Error(w, "Quota exceeded", http.StatusRequestEntityTooLarge)
+		return false
+	}
+	rr = s.do(t, 1, "POST", "/files", nil, "Upload-Length", "10")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	assert.Equal(t, "Quota exceeded\n", rr.Body.String())
+}
+
+func TestRemoveExpired(t *testing.T) {
+	store := NewStore(t.TempDir())
+	now := time.Now()
+	expired, err := store.Create(Info{UserID: 1, Size: 10, ExpiresAt: now.Add(-time.Minute)})
+	require.NoError(t, err)
+	fresh, err := store.Create(Info{UserID: 1, Size: 10, ExpiresAt: now.Add(time.Minute)})
+	require.NoError(t, err)
+
+	removed, err := store.RemoveExpired(now)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	_, err = store.Get(expired.ID)
+	assert.Equal(t, ErrNotFound, err)
+	_, err = os.Stat(store.DataPath(expired.ID))
+	assert.True(t, os.IsNotExist(err))
+	_, err = store.Get(fresh.ID)
+	assert.NoError(t, err)
+}
+
+func TestMetadata(t *testing.T) {
+	metadata, err := ParseMetadata("filename c29uZy5mbGFj,is_confidential,filetype YXVkaW8vZmxhYw==")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"filename": "song.flac", "is_confidential": "", "filetype": "audio/flac"}, metadata)
+	assert.Equal(t, "filename c29uZy5mbGFj,filetype YXVkaW8vZmxhYw==,is_confidential ", FormatMetadata(metadata))
+
+	_, err = ParseMetadata("filename not-base64!")
+	assert.Error(t, err)
+}